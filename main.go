@@ -1,44 +1,128 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/asaidimu/nani/pkg/cli"
+	"github.com/asaidimu/nani/pkg/server"
 	"github.com/asaidimu/nani/pkg/ui"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		fmt.Println("Error: GEMINI_API_KEY environment variable not set")
+	flags, args, err := cli.ParseGlobalFlags(os.Args[1:])
+	if err == nil {
+		err = cli.SetProvider(flags.Provider)
+	}
+	if err == nil {
+		err = cli.SetCassette(flags.Record, flags.Replay)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	project :=  filepath.Join(".")
+	// Use the workspace named by --workspace, or else the nearest one at or
+	// above the current directory, like git finds its repository.
+	project := "."
+	if flags.Workspace != "" {
+		if project, err = ai.ResolveWorkspaceDir(flags.Workspace); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if dir, err := ai.FindProjectDir(project); err == nil && dir != "" {
+		project = dir
+	}
+
+	// Shell completion runs in any directory, so it only reads a workspace
+	// that already exists rather than creating one. The context merge driver
+	// runs while `nani sync` rebases the workspace, which must not be touched.
+	if len(args) > 0 && cli.Standalone(args[0]) {
+		var workspace *ai.Workspace
+		if _, err := os.Stat(filepath.Join(project, ".AIWorkspace", "context.json")); err == nil && args[0] != ai.ContextMergeCommand {
+			if workspace, err = ai.NewWorkspace(project); err == nil && workspace.Init(context.Background()) != nil {
+				workspace = nil
+			}
+		}
+		if err := cli.Run(workspace, args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	workspace, err := ai.NewWorkspace(project)
 	if err != nil {
 		fmt.Printf("Error creating workspace: %v\n", err)
 		os.Exit(1)
 	}
 
-	err = workspace.Init("nani", "saidimu", "https://github.com/asaidimu/nani.git")
+	firstRun := !workspace.Initialized()
+	err = workspace.Init(context.Background())
 	if err != nil {
 		fmt.Printf("Error initializing workspace: %v\n", err)
 		os.Exit(1)
 	}
+	// The registry only powers `nani workspace list` and switching by name,
+	// so failing to update it is not worth interrupting the user for.
+	workspace.Register()
 
-	aiClient, err := ai.NewGeminiAIClient(apiKey, workspace)
-	if err != nil {
-		fmt.Printf("Error initializing Gemini client: %v\n", err)
+	if flags.Scope != "" {
+		if workspace, err = workspace.WithScope(flags.Scope); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Context changes are written in the background, so pending ones are
+	// flushed before exiting.
+	if len(args) > 0 {
+		err := cli.Run(workspace, args)
+		if flushErr := workspace.Flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Confirm the detected project details the first time the chat is opened.
+	if firstRun {
+		if err := cli.Run(workspace, []string{"init"}); err != nil {
+			workspace.Flush()
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Attach to a daemon serving this workspace if one is running, so
+	// generations outlive this terminal and other terminals share the session.
+	// The mock provider and cassettes never attach (see `cli.UsesDaemon`).
+	var aiClient ai.AIClient
+	if daemon, err := server.Dial(workspace.SocketPath()); err == nil && cli.UsesDaemon() {
+		aiClient = daemon
+	} else if aiClient, err = cli.NewChatClient(workspace); err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	m := ui.New(aiClient)
+	var opts []ui.Option
+	if flags.Accessible {
+		opts = append(opts, ui.WithAccessible())
+	}
+	m := ui.New(aiClient, workspace, opts...)
 	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	if flushErr := m.Shutdown(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
 	}
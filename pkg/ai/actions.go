@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Action is a follow-up work item extracted from an AI response, such as
+// "add tests for the parser" or "rename Foo in pkg/bar.go".
+type Action struct {
+	Description string `json:"description"`    // What needs to be done.
+	File        string `json:"file,omitempty"` // The file the action relates to, if any.
+	Type        string `json:"type,omitempty"` // Kind of work (e.g., "fix", "test", "docs", "refactor").
+	Done        bool   `json:"done,omitempty"` // Whether the user has checked the action off.
+}
+
+// SessionActions returns all action items recorded in the active session,
+// in the order the responses that produced them were received.
+func (w *Workspace) SessionActions() ([]Action, error) {
+	session, err := w.loadSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session to list actions: %w", err)
+	}
+
+	var actions []Action
+	for _, chat := range session.Chat {
+		actions = append(actions, chat.Response.Actions...)
+	}
+	return actions, nil
+}
+
+// SetActionDone marks the action at the given zero-based position in the list
+// returned by `SessionActions` as done or not done, and saves the session.
+func (w *Workspace) SetActionDone(index int, done bool) error {
+	session, err := w.loadSession()
+	if err != nil {
+		return fmt.Errorf("failed to load session to update action: %w", err)
+	}
+
+	position := 0
+	for i := range session.Chat {
+		actions := session.Chat[i].Response.Actions
+		if index < position+len(actions) {
+			actions[index-position].Done = done
+			session.Metadata.LastUpdated = w.now()
+			if err := w.saveSession(*session); err != nil {
+				return fmt.Errorf("failed to save session after updating action %d: %w", index, err)
+			}
+			return w.logAction(fmt.Sprintf("Set action %d in session %s done=%t", index, session.ID, done))
+		}
+		position += len(actions)
+	}
+	return fmt.Errorf("action %d does not exist in session %s", index, session.ID)
+}
+
+// ActionsMarkdown renders actions as a numbered Markdown checklist, suitable for
+// display in the preview pane or for exporting to a file.
+func ActionsMarkdown(actions []Action) string {
+	if len(actions) == 0 {
+		return "_No action items in this session._\n"
+	}
+
+	var b strings.Builder
+	for i, a := range actions {
+		mark := " "
+		if a.Done {
+			mark = "x"
+		}
+		b.WriteString(fmt.Sprintf("%d. [%s] %s", i+1, mark, a.Description))
+		if a.Type != "" {
+			b.WriteString(fmt.Sprintf(" _(%s)_", a.Type))
+		}
+		if a.File != "" {
+			b.WriteString(fmt.Sprintf(" — `%s`", a.File))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
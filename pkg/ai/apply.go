@@ -0,0 +1,171 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// prettierExtensions are the file extensions `ApplyCodeBlock` formats with
+// prettier, when it is installed.
+var prettierExtensions = map[string]bool{
+	".js": true, ".jsx": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true,
+	".css": true, ".scss": true, ".less": true, ".html": true, ".vue": true,
+	".json": true, ".md": true, ".yaml": true, ".yml": true, ".graphql": true,
+}
+
+// CodeBlock is a fenced code block of a reply.
+type CodeBlock struct {
+	Lang string // Language given after the opening fence, if any.
+	Code string // Contents of the block, without the fences.
+}
+
+// CodeBlocks returns the fenced code blocks of markdown, in order. A block
+// left open runs to the end of markdown.
+func CodeBlocks(markdown string) []CodeBlock {
+	var blocks []CodeBlock
+	var current *CodeBlock
+	var lines []string
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case current == nil && strings.HasPrefix(trimmed, "```"):
+			current = &CodeBlock{Lang: strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))}
+		case current != nil && trimmed == "```":
+			current.Code = strings.Join(lines, "\n") + "\n"
+			blocks = append(blocks, *current)
+			current, lines = nil, nil
+		case current != nil:
+			lines = append(lines, line)
+		}
+	}
+	if current != nil {
+		current.Code = strings.Join(lines, "\n") + "\n"
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// ApplyResult reports how code written by `ApplyCodeBlock` was formatted and
+// checked.
+type ApplyResult struct {
+	Path        string          // The file written, relative to the project root.
+	Formatter   string          // The formatter run on the file, or "" if there is none for its language.
+	Diagnostics []CommandOutput // Output of the formatter and checks that failed.
+}
+
+// OK reports whether the formatter and checks all succeeded.
+func (r ApplyResult) OK() bool {
+	return len(r.Diagnostics) == 0
+}
+
+// Markdown describes the result for the chat, with the output of each failed
+// formatter or check as a fenced block.
+func (r ApplyResult) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Wrote `%s`", r.Path)
+	if r.Formatter != "" {
+		fmt.Fprintf(&b, " and formatted it with %s", r.Formatter)
+	}
+	if r.OK() {
+		b.WriteString("; it passed all checks.")
+		return b.String()
+	}
+	b.WriteString(", but it has errors:")
+	for _, d := range r.Diagnostics {
+		b.WriteString("\n\n" + d.Markdown())
+	}
+	return b.String()
+}
+
+// ApplyCodeBlock writes code to path, a file in the project relative to the
+// current directory or absolute, and runs its language's formatter on it:
+// goimports, or else gofmt, for Go files, and prettier for the web languages
+// it supports, if installed. Go files that format cleanly are then checked
+// with `go vet` on their package, which also reports compile errors. Failing
+// formatters and checks are not an error; their output is returned in the
+// result's diagnostics, to be sent back to the model.
+func (w *Workspace) ApplyCodeBlock(ctx context.Context, path, code string) (ApplyResult, error) {
+	ref, err := w.sourceRef(path)
+	if err != nil {
+		return ApplyResult{}, fmt.Errorf("failed to apply code: %w", err)
+	}
+	file := w.sourcePath(ref)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return ApplyResult{}, fmt.Errorf("failed to create directory for %s: %w", ref, err)
+	}
+	if err := os.WriteFile(file, []byte(code), 0644); err != nil {
+		return ApplyResult{}, fmt.Errorf("failed to write %s: %w", ref, err)
+	}
+
+	result := ApplyResult{Path: ref}
+	formatted := true
+	ext := strings.ToLower(filepath.Ext(file))
+	switch {
+	case ext == ".go":
+		result.Formatter = "gofmt"
+		if _, err := exec.LookPath("goimports"); err == nil {
+			result.Formatter = "goimports"
+		}
+		if _, err := exec.LookPath(result.Formatter); err != nil {
+			// Without the Go tools, format in process; vet cannot run either.
+			if out, err := format.Source([]byte(code)); err != nil {
+				result.Diagnostics = append(result.Diagnostics, CommandOutput{Command: "gofmt " + ref, Output: err.Error(), ExitCode: 2})
+			} else if err := os.WriteFile(file, out, 0644); err != nil {
+				return result, fmt.Errorf("failed to write %s: %w", ref, err)
+			}
+			break
+		}
+		formatted, err = w.runCheck(ctx, &result, result.Formatter, "-w", ref)
+		if err != nil {
+			return result, err
+		}
+		if _, err := exec.LookPath("go"); formatted && err == nil {
+			if _, err := w.runCheck(ctx, &result, "go", "vet", "./"+filepath.ToSlash(filepath.Dir(ref))); err != nil {
+				return result, err
+			}
+		}
+	case prettierExtensions[ext]:
+		if _, err := exec.LookPath("prettier"); err != nil {
+			break
+		}
+		result.Formatter = "prettier"
+		if _, err := w.runCheck(ctx, &result, "prettier", "--write", ref); err != nil {
+			return result, err
+		}
+	}
+	return result, w.logAction(fmt.Sprintf("Applied code to %s (%d diagnostics)", ref, len(result.Diagnostics)))
+}
+
+// runCheck runs a formatter or check in the project directory, adding its
+// output to result's diagnostics if it fails. It reports whether it succeeded.
+func (w *Workspace) runCheck(ctx context.Context, result *ApplyResult, name string, args ...string) (bool, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = w.projectDir()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return false, fmt.Errorf("%s did not finish: %w", name, ctx.Err())
+	case errors.As(err, &exitErr):
+		result.Diagnostics = append(result.Diagnostics, CommandOutput{
+			Command:  strings.Join(append([]string{name}, args...), " "),
+			Output:   out.String(),
+			ExitCode: exitErr.ExitCode(),
+		})
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	return true, nil
+}
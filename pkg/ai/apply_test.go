@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     []CodeBlock
+	}{
+		{"none", "Just prose.", nil},
+		{"one", "Here:\n\n```go\npackage a\n```\n\nDone.", []CodeBlock{{Lang: "go", Code: "package a\n"}}},
+		{"several", "```\nA\n```\ntext\n```py\nB\nC\n```", []CodeBlock{{Code: "A\n"}, {Lang: "py", Code: "B\nC\n"}}},
+		{"unclosed", "```sh\nls", []CodeBlock{{Lang: "sh", Code: "ls\n"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CodeBlocks(tt.markdown)
+			if len(got) != len(tt.want) {
+				t.Fatalf("CodeBlocks() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("block %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyCodeBlock(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	w := newTestWorkspace(t)
+	project := w.projectDir()
+	if err := os.WriteFile(filepath.Join(project, "go.mod"), []byte("module example.com/apply\n\ngo 1.24\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		code      string
+		wantCode  string // Contents of the file afterwards, if the code is valid.
+		wantCheck string // Part of the failing command, if any.
+	}{
+		{
+			name:     "formats valid code",
+			path:     "a/a.go",
+			code:     "package a\nfunc A( ) int {return 1}\n",
+			wantCode: "package a\n\nfunc A() int { return 1 }\n",
+		},
+		{
+			name:      "reports syntax errors",
+			path:      "b/b.go",
+			code:      "package b\nfunc B( {\n",
+			wantCheck: "-w b/b.go",
+		},
+		{
+			name:      "reports vet errors",
+			path:      "c/c.go",
+			code:      "package c\n\nimport \"fmt\"\n\nfunc C() string { return fmt.Sprintf(\"%d\", \"x\") }\n",
+			wantCheck: "go vet ./c",
+		},
+		{
+			name:     "leaves other languages alone",
+			path:     "notes.txt",
+			code:     "some  text\n",
+			wantCode: "some  text\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := w.ApplyCodeBlock(t.Context(), filepath.Join(project, tt.path), tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Path != tt.path {
+				t.Errorf("Path = %q, want %q", result.Path, tt.path)
+			}
+			if tt.wantCheck == "" {
+				if !result.OK() {
+					t.Fatalf("unexpected diagnostics:\n%s", result.Markdown())
+				}
+				data, err := os.ReadFile(filepath.Join(project, tt.path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != tt.wantCode {
+					t.Errorf("file = %q, want %q", data, tt.wantCode)
+				}
+				return
+			}
+			if result.OK() || !strings.Contains(result.Markdown(), tt.wantCheck) {
+				t.Errorf("Markdown() = %q, want a failure of %q", result.Markdown(), tt.wantCheck)
+			}
+		})
+	}
+
+	if _, err := w.ApplyCodeBlock(t.Context(), filepath.Join(project, "..", "outside.go"), "package x\n"); err == nil {
+		t.Error("ApplyCodeBlock() outside the project succeeded, want an error")
+	}
+}
@@ -0,0 +1,229 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// defaultArchiveNamePattern is used for archived session filenames when
+// `Settings.ArchiveNamePattern` is not set.
+const defaultArchiveNamePattern = "{date}-{label}-{shortid}"
+
+// archiveFileName builds the filename (including the `.json` extension) of an
+// archived session from the configured naming pattern. Supported placeholders:
+//
+//	{date}    creation date as YYYY-MM-DD
+//	{time}    creation time as HHMMSS
+//	{label}   slugified session label
+//	{id}      full session ID
+//	{shortid} first 8 characters of the session ID
+//
+// The session ID remains the canonical key in the index; the filename exists
+// only to make the `sessions/` directory easy to browse.
+func (w *Workspace) archiveFileName(session *Session) string {
+	pattern := w.Context.Settings.ArchiveNamePattern
+	if pattern == "" {
+		pattern = defaultArchiveNamePattern
+	}
+
+	shortID := session.ID
+	if len(shortID) > 8 {
+		shortID = shortID[:8]
+	}
+	name := strings.NewReplacer(
+		"{date}", session.Metadata.CreatedAt.Format("2006-01-02"),
+		"{time}", session.Metadata.CreatedAt.Format("150405"),
+		"{label}", slugify(session.Label),
+		"{id}", session.ID,
+		"{shortid}", shortID,
+	).Replace(pattern)
+
+	// Patterns must not be able to place archives outside sessions/.
+	name = strings.ReplaceAll(filepath.ToSlash(name), "/", "-")
+	name = strings.Trim(name, "-.")
+	if name == "" {
+		name = session.ID
+	}
+	return fmt.Sprintf("%s.json", name)
+}
+
+// archivedSessionPath returns the path of the archived session file with the given ID.
+// It uses the filename recorded in the `ArchivedSessions` index and falls back to the
+// legacy `sessions/<id>.json` layout for entries archived before names were configurable.
+func (w *Workspace) archivedSessionPath(id string) string {
+	if summary, ok := w.Context.Indexes.ArchivedSessions[id]; ok && summary.File != "" {
+		return filepath.Join(w.RootDir, "sessions", summary.File)
+	}
+	return filepath.Join(w.RootDir, "sessions", fmt.Sprintf("%s.json", id))
+}
+
+// archiveTarget returns the file name within `sessions/` to archive session
+// under, and the file name of a previous archive of the session to remove once
+// the new one is written, if any. When the name from `archiveFileName` is
+// taken by another session's archive, a version suffix ("-2", "-3", ...) is
+// appended until it is free. An existing archive of the same session may only
+// be replaced if session continues it, holding all of its turns; otherwise
+// an `*ArchiveConflictError` is returned and nothing should be written.
+func (w *Workspace) archiveTarget(session *Session) (file, previous string, err error) {
+	if _, ok := w.Context.Indexes.ArchivedSessions[session.ID]; ok {
+		path := w.archivedSessionPath(session.ID)
+		if existing, err := w.readArchive(path); err == nil {
+			if !continuesArchive(session, existing) {
+				return "", "", &ArchiveConflictError{SessionID: session.ID, Path: path, ArchivedTurns: len(existing.Chat), SessionTurns: len(session.Chat)}
+			}
+			previous = filepath.Base(path)
+		}
+	}
+
+	name := w.archiveFileName(session)
+	base := strings.TrimSuffix(name, ".json")
+	for version := 2; ; version++ {
+		path := filepath.Join(w.RootDir, "sessions", name)
+		existing, err := w.readArchive(path)
+		if os.IsNotExist(err) {
+			return name, previous, nil
+		}
+		if err == nil && existing.ID == session.ID {
+			if !continuesArchive(session, existing) {
+				return "", "", &ArchiveConflictError{SessionID: session.ID, Path: path, ArchivedTurns: len(existing.Chat), SessionTurns: len(session.Chat)}
+			}
+			if previous == name {
+				previous = ""
+			}
+			return name, previous, nil
+		}
+		name = fmt.Sprintf("%s-%d.json", base, version)
+	}
+}
+
+// writeArchive writes session to its archive file (see `archiveTarget`),
+// removing any previous archive of it under another name, and records it in
+// the in-memory `ArchivedSessions` index. Callers save the context.
+func (w *Workspace) writeArchive(session *Session) error {
+	file, previous, err := w.archiveTarget(session)
+	if err != nil {
+		return err
+	}
+	if err := w.writeJSON(filepath.Join(w.RootDir, "sessions", file), session); err != nil {
+		return fmt.Errorf("failed to archive session %s: %w", session.ID, err)
+	}
+	if previous != "" && previous != file {
+		path := filepath.Join(w.RootDir, "sessions", previous)
+		if err := w.storage.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove previous archive of session %s: %w", session.ID, err)
+		}
+		if err := w.recordRemove(path); err != nil {
+			return fmt.Errorf("failed to update manifest after moving session %s: %w", session.ID, err)
+		}
+	}
+	w.Context.Indexes.ArchivedSessions[session.ID] = sessionSummary(session, file)
+	return nil
+}
+
+// readArchive reads the archived session at path. The role is left as a name.
+func (w *Workspace) readArchive(path string) (*Session, error) {
+	data, err := w.storage.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse archived session %s: %w", path, err)
+	}
+	return &session, nil
+}
+
+// continuesArchive reports whether session holds every turn of archived, an
+// earlier archive of the same session, in the same order.
+func continuesArchive(session, archived *Session) bool {
+	if len(archived.Chat) > len(session.Chat) {
+		return false
+	}
+	for i, c := range archived.Chat {
+		if session.Chat[i].ID != c.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// EndSessionAsCopy archives the active session under a new ID, leaving any
+// earlier archive of the session untouched. It resolves an
+// `*ArchiveConflictError` returned by `EndSession` by keeping both versions.
+// It returns the new ID.
+func (w *Workspace) EndSessionAsCopy(ctx context.Context) (string, error) {
+	session, err := w.loadSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to load session to archive as a copy: %w", err)
+	}
+	oldID := session.ID
+	session.ID = uuid.New().String()
+	if err := w.saveSession(*session); err != nil {
+		return "", fmt.Errorf("failed to save session copy: %w", err)
+	}
+	if err := w.logAction(fmt.Sprintf("Copied session %s to %s", oldID, session.ID)); err != nil {
+		return "", err
+	}
+	return session.ID, w.EndSession(ctx)
+}
+
+// sessionSummary builds the `ArchivedSessions` index entry for a session
+// archived under file in the `sessions/` directory.
+func sessionSummary(session *Session, file string) SessionSummary {
+	return SessionSummary{
+		ID:          session.ID,
+		Label:       session.Label,
+		RoleName:    session.Role.Name,
+		CreatedAt:   session.Metadata.CreatedAt,
+		LastUpdated: session.Metadata.LastUpdated,
+		File:        file,
+		Scope:       session.Scope,
+		Priority:    session.Metadata.Priority,
+		Pinned:      session.Metadata.Pinned,
+	}
+}
+
+// slugify converts a label into a lowercase, hyphen-separated string safe for filenames.
+func slugify(label string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(label) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			hyphen = false
+		} else if !hyphen && b.Len() > 0 {
+			b.WriteRune('-')
+			hyphen = true
+		}
+	}
+	slug := strings.TrimRight(b.String(), "-")
+	if runes := []rune(slug); len(runes) > 40 {
+		slug = strings.TrimRight(string(runes[:40]), "-")
+	}
+	if slug == "" {
+		return "session"
+	}
+	return slug
+}
+
+// RenameSession sets the label of the active or archived session with the
+// given ID. An archived session's file is renamed to match when the archive
+// naming pattern includes the label.
+func (w *Workspace) RenameSession(id, label string) error {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return fmt.Errorf("session label cannot be empty")
+	}
+	if err := w.updateSession(id, func(session *Session) { session.Label = label }); err != nil {
+		return err
+	}
+	w.emit(Event{Type: EventSessionRenamed, SessionID: id, Label: label})
+	return w.logAction(fmt.Sprintf("Renamed session %s to '%s'", id, label))
+}
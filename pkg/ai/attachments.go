@@ -0,0 +1,70 @@
+package ai
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxAttachmentSize is the largest file that can be sent inline with a prompt.
+const maxAttachmentSize = 20 << 20
+
+// Attachment is a media file sent to the model alongside a prompt, such as a
+// screenshot or a PDF.
+type Attachment struct {
+	Path     string `json:"path"`     // Path of the file as given by the user.
+	MIMEType string `json:"mimeType"` // Media type of the file (e.g., "image/png").
+}
+
+// supportedAttachment reports whether the model accepts inline data of mimeType.
+func supportedAttachment(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"),
+		strings.HasPrefix(mimeType, "audio/"),
+		strings.HasPrefix(mimeType, "video/"),
+		mimeType == "application/pdf":
+		return true
+	}
+	return false
+}
+
+// NewAttachment validates the file at path and detects its media type, first
+// from the file extension and then by sniffing its content.
+func NewAttachment(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to stat attachment %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("attachment %s is a directory", path)
+	}
+	if info.Size() > maxAttachmentSize {
+		return Attachment{}, fmt.Errorf("attachment %s is %d bytes, larger than the %d byte limit", path, info.Size(), maxAttachmentSize)
+	}
+
+	mimeType, _, _ := strings.Cut(mime.TypeByExtension(strings.ToLower(filepath.Ext(path))), ";")
+	if mimeType == "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Attachment{}, fmt.Errorf("failed to read attachment %s: %w", path, err)
+		}
+		mimeType, _, _ = strings.Cut(http.DetectContentType(data), ";")
+	}
+	if !supportedAttachment(mimeType) {
+		return Attachment{}, fmt.Errorf("attachment %s has unsupported type %s", path, mimeType)
+	}
+	return Attachment{Path: path, MIMEType: mimeType}, nil
+}
+
+// attachmentNames returns the base names of attachments, for display and for
+// text-only replay of earlier turns.
+func attachmentNames(attachments []Attachment) []string {
+	names := make([]string, 0, len(attachments))
+	for _, a := range attachments {
+		names = append(names, filepath.Base(a.Path))
+	}
+	return names
+}
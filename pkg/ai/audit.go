@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultAuditMaxBytes caps the request and response bodies kept in each
+// audit entry when `Settings.AuditMaxBytes` is not set.
+const defaultAuditMaxBytes = 64 * 1024
+
+// AuditEntry is one request to the model provider and its response, as
+// recorded in the audit log, `audit/<date>.jsonl`.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`                // When the request was sent.
+	Method     string    `json:"method"`              // HTTP method.
+	URL        string    `json:"url"`                 // Request URL, without query parameters.
+	Model      string    `json:"model,omitempty"`     // Model addressed by the request, if any.
+	Operation  string    `json:"operation,omitempty"` // Provider operation (e.g., "generateContent").
+	Status     int       `json:"status,omitempty"`    // HTTP status of the response; 0 if none was received.
+	Error      string    `json:"error,omitempty"`     // Transport error, if the request failed.
+	DurationMs int64     `json:"durationMs"`          // Time from sending the request to the end of the response.
+
+	RequestBytes      int64  `json:"requestBytes"`                // Size of the request body.
+	ResponseBytes     int64  `json:"responseBytes"`               // Size of the response body read by the client.
+	Request           string `json:"request"`                     // Request body, cut to the size cap.
+	Response          string `json:"response"`                    // Response body, cut to the size cap.
+	RequestTruncated  bool   `json:"requestTruncated,omitempty"`  // Whether Request was cut.
+	ResponseTruncated bool   `json:"responseTruncated,omitempty"` // Whether Response was cut.
+}
+
+// auditTransport records the requests made through it in the audit log of
+// its workspace, when `Settings.Audit` is enabled. Headers, which carry the
+// API key, are never recorded.
+type auditTransport struct {
+	base      http.RoundTripper
+	workspace atomic.Pointer[Workspace]
+	mu        sync.Mutex // Serializes appends to the audit log.
+}
+
+// RoundTrip implements `http.RoundTripper`.
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := t.workspace.Load()
+	if w == nil || !w.Context.Settings.Audit {
+		return t.base.RoundTrip(req)
+	}
+	limit := w.Context.Settings.AuditMaxBytes
+	if limit <= 0 {
+		limit = defaultAuditMaxBytes
+	}
+
+	entry := AuditEntry{Time: time.Now(), Method: req.Method, URL: req.URL.Scheme + "://" + req.URL.Host + req.URL.Path}
+	entry.Model, entry.Operation = providerOperation(req.URL.Path)
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body for the audit log: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		entry.RequestBytes = int64(len(body))
+		entry.Request, entry.RequestTruncated = truncateAudit(body, limit)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		entry.Error = err.Error()
+		entry.DurationMs = time.Since(entry.Time).Milliseconds()
+		t.record(w, entry)
+		return nil, err
+	}
+	entry.Status = resp.StatusCode
+	// Responses may be streamed, so the entry is written once the client is
+	// done with the body.
+	resp.Body = &auditBody{ReadCloser: resp.Body, limit: limit, done: func(body []byte, size int64, truncated bool) {
+		entry.ResponseBytes = size
+		entry.Response, entry.ResponseTruncated = string(body), truncated
+		entry.DurationMs = time.Since(entry.Time).Milliseconds()
+		t.record(w, entry)
+	}}
+	return resp, nil
+}
+
+// record appends entry to the workspace's audit log for the day. The audit
+// log must never break a request, so failures are only logged.
+func (t *auditTransport) record(w *Workspace, entry AuditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		w.logAction(fmt.Sprintf("Warning: Could not encode audit entry: %v", err))
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dir := filepath.Join(w.RootDir, "audit")
+	if err := w.storage.MkdirAll(dir, 0755); err != nil {
+		w.logAction(fmt.Sprintf("Warning: Could not create audit directory: %v", err))
+		return
+	}
+	if err := w.storage.AppendFile(filepath.Join(dir, entry.Time.Format("2006-01-02")+".jsonl"), append(data, '\n'), 0600); err != nil {
+		w.logAction(fmt.Sprintf("Warning: Could not write audit log: %v", err))
+	}
+}
+
+// auditBody captures up to limit bytes of a response body as the client
+// reads it, and reports them once, when the body is closed.
+type auditBody struct {
+	io.ReadCloser
+	limit     int
+	buf       bytes.Buffer
+	size      int64
+	truncated bool
+	once      sync.Once
+	done      func(body []byte, size int64, truncated bool)
+}
+
+func (b *auditBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	if n > 0 && b.size > int64(b.limit) {
+		b.truncated = true
+	}
+	return n, err
+}
+
+func (b *auditBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.buf.Bytes(), b.size, b.truncated) })
+	return err
+}
+
+// truncateAudit returns body as a string of at most limit bytes, and whether it was cut.
+func truncateAudit(body []byte, limit int) (string, bool) {
+	if len(body) <= limit {
+		return string(body), false
+	}
+	return string(body[:limit]), true
+}
+
+// providerOperation extracts the model and operation from a provider API
+// path such as `/v1beta/models/gemini-2.5-flash:generateContent`.
+func providerOperation(path string) (model, operation string) {
+	_, rest, ok := strings.Cut(path, "/models/")
+	if !ok {
+		return "", ""
+	}
+	model, operation, _ = strings.Cut(rest, ":")
+	return model, operation
+}
@@ -0,0 +1,201 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultBatchConcurrency is the number of batch jobs run at once when neither
+// the caller nor `Settings.BatchConcurrency` sets it.
+const defaultBatchConcurrency = 4
+
+// Provider is implemented by model clients that identify their provider, so
+// batches can share the provider's rate limit. `GeminiAIClient` implements it.
+type Provider interface {
+	Provider() string
+}
+
+// Names of the model providers, as returned by `Provider.Provider`.
+const (
+	ProviderGemini = "gemini" // Google Gemini, via `GeminiAIClient`.
+	ProviderMock   = "mock"   // Canned responses, via `MockAIClient`.
+)
+
+// BatchOptions controls how `RunBatch` schedules jobs.
+type BatchOptions struct {
+	Concurrency int          // Maximum number of jobs run at once; 0 or less uses the default.
+	Limiter     *RateLimiter // Limits how often jobs start; nil for no limit.
+}
+
+// JobError is the failure of a single job of a batch.
+type JobError struct {
+	Index int   // Index of the failed job.
+	Err   error // The job's error.
+}
+
+func (e JobError) Error() string { return fmt.Sprintf("job %d: %v", e.Index, e.Err) }
+
+func (e JobError) Unwrap() error { return e.Err }
+
+// BatchError aggregates the failures of the jobs of a batch run by `RunBatch`.
+// It matches each job's error with `errors.Is` and `errors.As`.
+type BatchError struct {
+	Jobs     int        // Number of jobs in the batch.
+	Failures []JobError // Failed jobs, ordered by index.
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d jobs failed; first failure: %v", len(e.Failures), e.Jobs, e.Failures[0])
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f
+	}
+	return errs
+}
+
+// RunBatch calls run for every job index from 0 to jobs-1, running at most
+// opts.Concurrency jobs at once and starting them no faster than opts.Limiter
+// allows. A failing job does not stop the others; all failures are returned
+// together as a *BatchError. When ctx is cancelled no further jobs are started,
+// running jobs see the cancellation through their context, and ctx.Err() is
+// returned once they have finished.
+func RunBatch(ctx context.Context, jobs int, opts BatchOptions, run func(ctx context.Context, i int) error) error {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	concurrency = min(concurrency, jobs)
+
+	indexes := make(chan int)
+	var (
+		mu       sync.Mutex
+		failures []JobError
+		wg       sync.WaitGroup
+	)
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := run(ctx, i); err != nil {
+					mu.Lock()
+					failures = append(failures, JobError{Index: i, Err: err})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range jobs {
+		if err := opts.Limiter.Wait(ctx); err != nil {
+			break
+		}
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+	return &BatchError{Jobs: jobs, Failures: failures}
+}
+
+// RateLimiter spaces out requests evenly to stay under a per-minute budget.
+// A nil *RateLimiter imposes no limit.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // Minimum time between two requests.
+	next     time.Time     // Earliest time the next request may start.
+}
+
+// NewRateLimiter returns a limiter allowing perMinute requests per minute, or
+// nil (no limit) if perMinute is not positive.
+func NewRateLimiter(perMinute int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &RateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the next request may start or ctx is cancelled.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var (
+	providerLimitersMu sync.Mutex
+	providerLimiters   = make(map[string]*RateLimiter)
+)
+
+// providerLimiter returns the rate limiter shared by every batch sending
+// requests to provider, configured from `Settings.RateLimits`. It returns nil
+// if no limit is configured for the provider.
+func (w *Workspace) providerLimiter(provider string) *RateLimiter {
+	perMinute := w.Context.Settings.RateLimits[provider]
+	if perMinute <= 0 {
+		return nil
+	}
+	providerLimitersMu.Lock()
+	defer providerLimitersMu.Unlock()
+	key := fmt.Sprintf("%s/%d", provider, perMinute)
+	if l, ok := providerLimiters[key]; ok {
+		return l
+	}
+	l := NewRateLimiter(perMinute)
+	providerLimiters[key] = l
+	return l
+}
+
+// batchOptions returns the scheduling options for a batch of requests made
+// with client, honouring the workspace's concurrency and rate limit settings.
+// A positive concurrency overrides `Settings.BatchConcurrency`.
+func (w *Workspace) batchOptions(client any, concurrency int) BatchOptions {
+	if concurrency <= 0 {
+		concurrency = w.Context.Settings.BatchConcurrency
+	}
+	opts := BatchOptions{Concurrency: concurrency}
+	if p, ok := client.(Provider); ok {
+		opts.Limiter = w.providerLimiter(p.Provider())
+	}
+	return opts
+}
+
+// Provider implements `Provider`.
+func (g *GeminiAIClient) Provider() string {
+	return ProviderGemini
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// CandidateSelector is implemented by AI clients that can keep another of the
+// candidate replies generated for the latest message when its role asks for
+// several (see `RoleParameters.CandidateCount`). `GeminiAIClient` implements it.
+type CandidateSelector interface {
+	// SelectCandidate keeps candidate n, counted from zero, of the latest
+	// reply in the session history in place of the one saved, and continues
+	// the chat from it. It returns the kept candidate.
+	SelectCandidate(ctx context.Context, n int) (Response, error)
+}
+
+// candidateReplies holds the candidates of the latest reply, so another can
+// be kept with `SelectCandidate`.
+type candidateReplies struct {
+	contents  []*genai.Content // Chat history the candidates reply to.
+	replies   []*genai.Content // Output of each candidate, as it continues the chat.
+	responses []Response       // Parsed candidates, the first being the one saved.
+}
+
+// keepCandidates parses the candidates of resp other than the first, the
+// reply to contents already parsed as response, and records them for
+// `SelectCandidate`. It returns response with all candidates attached.
+// Candidates whose output cannot be parsed are salvaged as well as possible.
+func (g *GeminiAIClient) keepCandidates(response Response, contents []*genai.Content, resp *genai.GenerateContentResponse) Response {
+	if len(resp.Candidates) < 2 {
+		return response
+	}
+	c := &candidateReplies{contents: contents, replies: []*genai.Content{resp.Candidates[0].Content}, responses: []Response{response}}
+	for _, candidate := range resp.Candidates[1:] {
+		if candidate.Content == nil {
+			continue
+		}
+		var text strings.Builder
+		for _, part := range candidate.Content.Parts {
+			text.WriteString(part.Text)
+		}
+		alternative, err := parseAIResponse(text.String(), g.schema)
+		if err != nil {
+			alternative = salvageReply(text.String())
+		}
+		alternative = g.workspace.PostProcess(g.pipeline, alternative)
+		alternative.Usage, alternative.Latency = response.Usage, response.Latency
+		alternative.Provider, alternative.Model = response.Provider, response.Model
+		alternative.FinishReason = string(candidate.FinishReason)
+		alternative.ChatID = response.ChatID
+		c.replies = append(c.replies, candidate.Content)
+		c.responses = append(c.responses, alternative)
+	}
+	if len(c.responses) < 2 {
+		return response
+	}
+	g.candidates = c
+	response.Candidates = c.responses
+	return response
+}
+
+// SelectCandidate implements `CandidateSelector`.
+func (g *GeminiAIClient) SelectCandidate(ctx context.Context, n int) (Response, error) {
+	c := g.candidates
+	if c == nil {
+		return Response{}, errors.New("the latest reply has no other candidates")
+	}
+	if n < 0 || n >= len(c.responses) {
+		return Response{}, fmt.Errorf("candidate %d does not exist; the latest reply has %d", n+1, len(c.responses))
+	}
+	chosen := c.responses[n]
+	if chosen.ChatID != "" {
+		if err := g.workspace.ReplaceResponse(chosen.ChatID, chosen.saved()); err != nil {
+			return Response{}, err
+		}
+	}
+	history := append(append([]*genai.Content{}, c.contents...), c.replies[n])
+	var err error
+	if g.chat, err = g.client.Chats.Create(ctx, g.model, g.config, history); err != nil {
+		return Response{}, fmt.Errorf("failed to continue the chat: %w", err)
+	}
+	g.workspace.logAction(fmt.Sprintf("Kept candidate %d of %d for the latest reply", n+1, len(c.responses)))
+	return chosen, nil
+}
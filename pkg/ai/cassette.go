@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CassetteMode is whether a `Cassette` captures provider interactions or
+// plays them back.
+type CassetteMode string
+
+// Cassette modes.
+const (
+	CassetteRecord CassetteMode = "record" // Send requests to the provider and capture each exchange.
+	CassetteReplay CassetteMode = "replay" // Answer requests from the captured exchanges without network access.
+)
+
+// CassetteInteraction is one provider request and its response, as captured
+// in a cassette file.
+type CassetteInteraction struct {
+	Method      string `json:"method"`                // HTTP method.
+	URL         string `json:"url"`                   // Request URL, without query parameters.
+	Request     string `json:"request"`               // Request body.
+	Status      int    `json:"status"`                // HTTP status of the response.
+	ContentType string `json:"contentType,omitempty"` // Content type of the response.
+	Response    string `json:"response"`              // Response body, byte for byte, including streamed events.
+}
+
+// Cassette is an `http.RoundTripper` that captures provider interactions to
+// a file or replays them from it, so a chat can be reproduced exactly, for
+// instance to chase a parsing bug or a UI regression in a test. Headers,
+// which carry the API key, are never captured.
+//
+// In replay mode each request is answered with the first unused interaction
+// with the same method, URL, and body, or failing that the next unused one
+// with the same method and URL, so prompts whose context changed between
+// runs still replay in order. Requests with no interaction left fail.
+type Cassette struct {
+	path string
+	mode CassetteMode
+	base http.RoundTripper // Used when recording.
+
+	mu           sync.Mutex
+	interactions []CassetteInteraction
+	used         []bool // Which interactions were replayed.
+}
+
+// OpenCassette opens the cassette file at path in mode. Recording starts a
+// new cassette, replacing any file at path once the first exchange is
+// captured; replaying requires the file to exist.
+func OpenCassette(path string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{path: path, mode: mode, base: http.DefaultTransport}
+	switch mode {
+	case CassetteRecord:
+		return c, nil
+	case CassetteReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &c.interactions); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+		c.used = make([]bool, len(c.interactions))
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown cassette mode '%s': expected %s or %s", mode, CassetteRecord, CassetteReplay)
+}
+
+// Mode returns whether the cassette records or replays.
+func (c *Cassette) Mode() CassetteMode {
+	return c.mode
+}
+
+// RoundTrip implements `http.RoundTripper`.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body for the cassette: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	url := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+
+	if c.mode == CassetteReplay {
+		interaction, err := c.replay(req.Method, url, string(body))
+		if err != nil {
+			return nil, err
+		}
+		header := make(http.Header)
+		if interaction.ContentType != "" {
+			header.Set("Content-Type", interaction.ContentType)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Response))),
+			ContentLength: int64(len(interaction.Response)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	interaction := CassetteInteraction{
+		Method:      req.Method,
+		URL:         url,
+		Request:     string(body),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	// Responses may be streamed, so the exchange is captured once the client
+	// is done with the body.
+	resp.Body = &cassetteBody{ReadCloser: resp.Body, done: func(body []byte) error {
+		interaction.Response = string(body)
+		return c.record(interaction)
+	}}
+	return resp, nil
+}
+
+// replay returns the interaction answering a request, marking it used.
+func (c *Cassette) replay(method, url, body string) (CassetteInteraction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := -1
+	for i, interaction := range c.interactions {
+		if c.used[i] || interaction.Method != method || interaction.URL != url {
+			continue
+		}
+		if interaction.Request == body {
+			next = i
+			break
+		}
+		if next < 0 {
+			next = i
+		}
+	}
+	if next < 0 {
+		return CassetteInteraction{}, fmt.Errorf("cassette %s has no recorded response left for %s %s", c.path, method, url)
+	}
+	c.used[next] = true
+	return c.interactions[next], nil
+}
+
+// record appends interaction to the cassette and rewrites its file.
+func (c *Cassette) record(interaction CassetteInteraction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, interaction)
+	data, err := json.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// cassetteBody buffers a response body as the client reads it, and hands it
+// to done once, when the body is closed.
+type cassetteBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	once sync.Once
+	done func(body []byte) error
+}
+
+func (b *cassetteBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *cassetteBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		if recordErr := b.done(b.buf.Bytes()); err == nil {
+			err = recordErr
+		}
+	})
+	return err
+}
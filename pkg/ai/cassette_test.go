@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.json")
+	interactions := []CassetteInteraction{
+		{Method: "POST", URL: "https://example.com/v1/generate", Request: `{"n":1}`, Status: 200, ContentType: "application/json", Response: `{"reply":"first"}`},
+		{Method: "POST", URL: "https://example.com/v1/generate", Request: `{"n":2}`, Status: 200, Response: `{"reply":"second"}`},
+	}
+	data, err := json.Marshal(interactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cassette, err := OpenCassette(path, CassetteReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: cassette}
+	post := func(body string) (string, error) {
+		resp, err := client.Post("https://example.com/v1/generate?key=secret", "application/json", strings.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+
+	// An exact body match is preferred over recording order; then the
+	// remaining interaction answers a request whose body changed.
+	for _, tt := range []struct{ body, want string }{
+		{`{"n":2}`, `{"reply":"second"}`},
+		{`{"n":3}`, `{"reply":"first"}`},
+	} {
+		got, err := post(tt.body)
+		if err != nil {
+			t.Fatalf("replaying %s failed: %v", tt.body, err)
+		}
+		if got != tt.want {
+			t.Errorf("replaying %s = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+	if _, err := post(`{"n":1}`); err == nil {
+		t.Error("replaying past the end of the cassette succeeded, want an error")
+	}
+}
+
+func TestOpenCassetteErrors(t *testing.T) {
+	if _, err := OpenCassette(filepath.Join(t.TempDir(), "missing.json"), CassetteReplay); err == nil {
+		t.Error("replaying a missing cassette succeeded, want an error")
+	}
+	if _, err := OpenCassette("x.json", "rewind"); err == nil {
+		t.Error("opening a cassette in an unknown mode succeeded, want an error")
+	}
+}
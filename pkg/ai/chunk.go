@@ -0,0 +1,239 @@
+package ai
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// sourceLanguage describes how source files of a language are split into
+// chunks for semantic search and which of their declarations the repo map
+// lists. Declarations are found with regular expressions on whole lines, so
+// no parser is needed for the language.
+type sourceLanguage struct {
+	name      string         // Key of the language in `Settings.ChunkSizes`.
+	chunkSize int            // Default target size, in characters, of a chunk.
+	boundary  *regexp.Regexp // Matches the first line of a top-level declaration, where chunks preferably start.
+	lead      *regexp.Regexp // Matches comment and attribute lines that belong to the declaration below them.
+
+	// symbols returns the exported declarations of a file's text, as "kind
+	// Name" or "method Type.Name". Nil for Go, which is parsed instead (see
+	// `goSymbols`).
+	symbols func(text string) []string
+}
+
+// sourceLanguages maps file extensions to the languages chunked along their
+// declarations. Other files are chunked by size alone (see `chunkText`).
+var sourceLanguages = map[string]*sourceLanguage{
+	".go":  goSource,
+	".py":  pythonSource,
+	".ts":  typeScriptSource,
+	".tsx": typeScriptSource,
+	".js":  typeScriptSource,
+	".jsx": typeScriptSource,
+	".mjs": typeScriptSource,
+	".rs":  rustSource,
+}
+
+var (
+	goSource = &sourceLanguage{
+		name:      "go",
+		chunkSize: chunkSize,
+		boundary:  regexp.MustCompile(`^(?:func|type|var|const)\b`),
+		lead:      regexp.MustCompile(`^//`),
+	}
+	// Python functions are short and indentation makes them dense, so its
+	// chunks are smaller.
+	pythonSource = &sourceLanguage{
+		name:      "python",
+		chunkSize: 1200,
+		boundary:  regexp.MustCompile(`^(?:(?:async\s+)?def|class)\s|^if __name__\b`),
+		lead:      regexp.MustCompile(`^(?:#|@)`),
+		symbols:   pythonSymbols,
+	}
+	typeScriptSource = &sourceLanguage{
+		name:      "typescript",
+		chunkSize: chunkSize,
+		boundary:  regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:async\s+)?(?:abstract\s+)?(?:function|class|interface|type|enum|const|let|var)\b`),
+		lead:      regexp.MustCompile(`^(?:/[/*]|\*|@)`),
+		symbols:   typeScriptSymbols,
+	}
+	// Rust items carry long attribute and doc comment blocks and impl blocks
+	// group many methods, so its chunks are larger.
+	rustSource = &sourceLanguage{
+		name:      "rust",
+		chunkSize: 2000,
+		boundary:  regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:unsafe\s+)?(?:fn|struct|enum|trait|impl|mod|type|const|static|macro_rules!)[\s<!]`),
+		lead:      regexp.MustCompile(`^(?://|#\[)`),
+		symbols:   rustSymbols,
+	}
+)
+
+// sourceLanguageFor returns the language of the source file at path, or nil
+// if it is not one `sourceLanguages` knows.
+func sourceLanguageFor(path string) *sourceLanguage {
+	return sourceLanguages[strings.ToLower(filepath.Ext(path))]
+}
+
+// chunkText splits text into pieces of roughly size characters, breaking on
+// line boundaries where possible.
+func chunkText(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if current.Len() > 0 && current.Len()+len(line) > size {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// chunkSource splits the text of the source file at path into chunks of
+// roughly its language's chunk size, or `Settings.ChunkSizes` for it. Chunks
+// start at top-level declarations, together with the comments and attributes
+// above them, and hold as many whole declarations as fit; a declaration
+// longer than the chunk size is split by lines. Files of other languages are
+// split by size alone.
+func (w *Workspace) chunkSource(path, text string) []string {
+	lang := sourceLanguageFor(path)
+	if lang == nil {
+		return chunkText(text, chunkSize)
+	}
+	size := lang.chunkSize
+	if configured := w.Context.Settings.ChunkSizes[lang.name]; configured > 0 {
+		size = configured
+	}
+
+	var chunks []string
+	var current strings.Builder
+	add := func(section string) {
+		if current.Len() > 0 && current.Len()+len(section) > size {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if len(section) > size {
+			pieces := chunkText(section, size)
+			chunks = append(chunks, pieces[:len(pieces)-1]...)
+			section = pieces[len(pieces)-1]
+		}
+		current.WriteString(section)
+	}
+	for _, section := range lang.sections(text) {
+		add(section)
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// sections splits text before each top-level declaration, keeping the lead
+// lines directly above a declaration with it.
+func (lang *sourceLanguage) sections(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	var starts []int
+	for i, line := range lines {
+		if !lang.boundary.MatchString(line) {
+			continue
+		}
+		start := i
+		for start > 0 && lang.lead.MatchString(lines[start-1]) {
+			start--
+		}
+		if len(starts) == 0 || start > starts[len(starts)-1] {
+			starts = append(starts, start)
+		}
+	}
+	if len(starts) == 0 || starts[0] != 0 {
+		starts = append([]int{0}, starts...)
+	}
+
+	sections := make([]string, 0, len(starts))
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if section := strings.Join(lines[start:end], ""); section != "" {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+var (
+	pythonDef    = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+([A-Za-z]\w*)`)
+	pythonClass  = regexp.MustCompile(`^class\s+([A-Za-z]\w*)`)
+	tsExport     = regexp.MustCompile(`^export\s+(?:default\s+)?(?:declare\s+)?(?:async\s+)?(?:abstract\s+)?(function\*?|class|interface|type|enum|const|let|var)\s+([A-Za-z_$][\w$]*)`)
+	rustItem     = regexp.MustCompile(`^pub(?:\(crate\))?\s+(?:async\s+)?(?:unsafe\s+)?(fn|struct|enum|trait|type|const|static|mod)\s+(\w+)`)
+	rustMethod   = regexp.MustCompile(`^\s+pub(?:\(crate\))?\s+(?:async\s+)?(?:unsafe\s+)?(?:const\s+)?fn\s+(\w+)`)
+	rustImpl     = regexp.MustCompile(`^impl(?:<[^{]*?>)?\s+(?:[\w:]+(?:<[^{]*?>)?\s+for\s+)?(\w+)`)
+	topLevelCode = regexp.MustCompile(`^[^\s#/)\]}]`)
+)
+
+// pythonSymbols lists the public top-level functions and classes of Python
+// source, and the public methods of its classes. Names starting with an
+// underscore are private by convention and left out.
+func pythonSymbols(text string) []string {
+	var symbols []string
+	class := ""
+	for _, line := range strings.Split(text, "\n") {
+		if m := pythonClass.FindStringSubmatch(line); m != nil {
+			class = m[1]
+			symbols = append(symbols, "class "+class)
+			continue
+		}
+		if m := pythonDef.FindStringSubmatch(line); m != nil {
+			switch {
+			case m[1] == "":
+				class = ""
+				symbols = append(symbols, "def "+m[2])
+			case class != "":
+				symbols = append(symbols, "method "+class+"."+m[2])
+			}
+			continue
+		}
+		if topLevelCode.MatchString(line) && !strings.HasPrefix(line, "@") {
+			class = ""
+		}
+	}
+	return symbols
+}
+
+// typeScriptSymbols lists the exported declarations of TypeScript or
+// JavaScript source.
+func typeScriptSymbols(text string) []string {
+	var symbols []string
+	for _, line := range strings.Split(text, "\n") {
+		if m := tsExport.FindStringSubmatch(line); m != nil {
+			symbols = append(symbols, strings.TrimSuffix(m[1], "*")+" "+m[2])
+		}
+	}
+	return symbols
+}
+
+// rustSymbols lists the public items of Rust source and the public methods of
+// its impl blocks.
+func rustSymbols(text string) []string {
+	var symbols []string
+	impl := ""
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case rustImpl.MatchString(line):
+			impl = rustImpl.FindStringSubmatch(line)[1]
+		case strings.HasPrefix(line, "}"):
+			impl = ""
+		case rustItem.MatchString(line):
+			m := rustItem.FindStringSubmatch(line)
+			symbols = append(symbols, m[1]+" "+m[2])
+		case impl != "" && rustMethod.MatchString(line):
+			symbols = append(symbols, "method "+impl+"."+rustMethod.FindStringSubmatch(line)[1])
+		}
+	}
+	return symbols
+}
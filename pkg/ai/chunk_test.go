@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSourceSymbols(t *testing.T) {
+	tests := []struct {
+		path string
+		text string
+		want []string
+	}{
+		{
+			path: "app.py",
+			text: "import os\n\n@dataclass\nclass Store:\n    def get(self):\n        pass\n\n    def _put(self):\n        pass\n\nCONFIG = {}\n\ndef main():\n    def inner():\n        pass\n\nasync def _private():\n    pass\n",
+			want: []string{"class Store", "method Store.get", "def main"},
+		},
+		{
+			path: "api.ts",
+			text: "import x from 'x';\n\nexport interface Options {}\nexport default async function handler() {}\nexport const LIMIT = 3;\nconst hidden = 1;\nexport function* ids() {}\n",
+			want: []string{"interface Options", "function handler", "const LIMIT", "function ids"},
+		},
+		{
+			path: "lib.rs",
+			text: "pub struct Store {}\n\nimpl<T> Display for Store {\n    pub fn fmt(&self) {}\n}\n\nimpl Store {\n    pub fn get(&self) {}\n    fn put(&self) {}\n}\n\nfn private() {}\npub(crate) async fn load() {}\n",
+			want: []string{"struct Store", "method Store.fmt", "method Store.get", "fn load"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := sourceLanguageFor(tt.path).symbols(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("symbols() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkSource(t *testing.T) {
+	w := newTestWorkspace(t)
+	body := strings.Repeat("    x = 1\n", 8)
+	python := "import os\n\n# Loads things.\n@cache\ndef load():\n" + body + "\nclass Store:\n" + body + "\ndef save():\n" + body
+
+	tests := []struct {
+		name       string
+		path       string
+		text       string
+		size       int // Settings.ChunkSizes["python"], if set.
+		wantChunks int
+		wantStarts []string // Prefixes of the chunks, if checked.
+	}{
+		{"whole file fits", "app.py", python, 0, 1, []string{"import os"}},
+		{"breaks at declarations", "app.py", python, 140, 3, []string{"import os", "class Store:", "def save():"}},
+		{"keeps comments and decorators with their declaration", "app.py", python, 120, 4, []string{"import os", "# Loads things.\n@cache\ndef load", "class Store:", "def save():"}},
+		{"splits long declarations by lines", "app.py", "def big():\n" + strings.Repeat(body, 10), 200, 5, nil},
+		{"other files by size", "notes.txt", strings.Repeat("line\n", 600), 0, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w.Context.Settings.ChunkSizes = map[string]int{"python": tt.size}
+			chunks := w.chunkSource(tt.path, tt.text)
+			if len(chunks) != tt.wantChunks {
+				t.Fatalf("chunkSource() = %d chunks %q, want %d", len(chunks), chunks, tt.wantChunks)
+			}
+			if strings.Join(chunks, "") != tt.text {
+				t.Errorf("chunks do not add up to the text")
+			}
+			for i, prefix := range tt.wantStarts {
+				if !strings.HasPrefix(chunks[i], prefix) {
+					t.Errorf("chunk %d = %q, want it to start with %q", i, chunks[i], prefix)
+				}
+			}
+		})
+	}
+}
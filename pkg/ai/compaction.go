@@ -0,0 +1,142 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const (
+	// defaultCompactionThreshold is the token count of a session's chat
+	// history above which older turns are summarized, when
+	// `Settings.CompactionThreshold` is not set.
+	defaultCompactionThreshold = 8000
+
+	// compactionKeepTurns is the number of most recent turns that are always
+	// replayed verbatim rather than folded into the summary.
+	compactionKeepTurns = 4
+)
+
+// Compaction is a model-written summary that stands in for the oldest turns of
+// a long session when its context is rebuilt. The raw turns stay in `Session.Chat`.
+type Compaction struct {
+	Summary   string    `json:"summary"`   // Summary of the compacted turns, including any earlier summary.
+	Turns     int       `json:"turns"`     // Number of leading entries of `Session.Chat` covered by the summary.
+	CreatedAt time.Time `json:"createdAt"` // Timestamp when the summary was generated.
+}
+
+// estimateTokens approximates the token count of text using the common
+// heuristic of four characters per token.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// needsCompaction reports whether the turns of session not yet covered by its
+// compaction exceed threshold tokens and enough turns remain to fold. Only
+// those turns are counted, estimated from their contents: the provider's
+// usage report also counts the system instruction, the summary, the kept
+// turns, and the completion, which a compaction cannot shrink, so once they
+// neared the threshold every later turn would trigger another summary.
+func needsCompaction(session *Session, threshold int) bool {
+	covered := 0
+	if session.Compaction != nil {
+		covered = session.Compaction.Turns
+	}
+	if len(session.Chat)-covered <= compactionKeepTurns {
+		return false
+	}
+	tokens := 0
+	for _, c := range session.Chat[covered:] {
+		tokens += estimateTokens(c.Message.Content) + estimateTokens(c.Response.Content)
+	}
+	return tokens > threshold
+}
+
+// SetSessionCompaction stores a compaction summary on the active session.
+func (w *Workspace) SetSessionCompaction(compaction Compaction) error {
+	session, err := w.loadSession()
+	if err != nil {
+		return fmt.Errorf("failed to load session to store compaction: %w", err)
+	}
+	if compaction.Turns > len(session.Chat) {
+		return fmt.Errorf("compaction covers %d turns but session %s has only %d", compaction.Turns, session.ID, len(session.Chat))
+	}
+
+	session.Compaction = &compaction
+	session.Metadata.LastUpdated = w.now()
+	if err := w.saveSession(*session); err != nil {
+		return fmt.Errorf("failed to save session after compaction: %w", err)
+	}
+	return w.logAction(fmt.Sprintf("Compacted %d turns of session %s", compaction.Turns, session.ID))
+}
+
+// compact summarizes all but the most recent turns of session with the model if
+// its history exceeds the configured threshold. The summary is persisted and
+// applied to session in place. It reports whether a compaction took place.
+func (g *GeminiAIClient) compact(ctx context.Context, session *Session) (bool, error) {
+	threshold := g.workspace.Context.Settings.CompactionThreshold
+	if threshold <= 0 {
+		threshold = defaultCompactionThreshold
+	}
+	if !needsCompaction(session, threshold) {
+		return false, nil
+	}
+
+	turns := len(session.Chat) - compactionKeepTurns
+	covered := 0
+	var prompt strings.Builder
+	prompt.WriteString("Summarize the following conversation between a user and an AI assistant. ")
+	prompt.WriteString("Preserve decisions made, constraints and preferences stated, open tasks, and important facts. ")
+	prompt.WriteString("Write dense plain text of at most 300 words.\n\n")
+	if session.Compaction != nil {
+		covered = session.Compaction.Turns
+		prompt.WriteString(fmt.Sprintf("[earlier-summary]: %s\n", session.Compaction.Summary))
+	}
+	for _, c := range session.Chat[covered:turns] {
+		prompt.WriteString(fmt.Sprintf("[user-message]: %s\n[agent-response]: %s\n", c.Message.Content, c.Response.Content))
+	}
+
+	resp, _, err := g.generate(ctx, g.workspace.ModelsFor(TaskSummary, ""), genai.Text(prompt.String()), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	summary := strings.TrimSpace(resp.Text())
+	if summary == "" {
+		return false, fmt.Errorf("model returned an empty conversation summary")
+	}
+
+	compaction := Compaction{Summary: summary, Turns: turns, CreatedAt: g.workspace.now()}
+	if err := g.workspace.SetSessionCompaction(compaction); err != nil {
+		return false, err
+	}
+	session.Compaction = &compaction
+	return true, nil
+}
+
+// compactLive compacts the active session after an interaction and, if that
+// happened, rebuilds the live chat from the compacted history so the request
+// context stops growing. Failures are logged rather than returned because the
+// interaction itself has already succeeded.
+func (g *GeminiAIClient) compactLive(ctx context.Context) {
+	session, err := g.workspace.GetActiveSession()
+	if err != nil || session == nil {
+		return
+	}
+	compacted, err := g.compact(ctx, session)
+	if err != nil {
+		g.workspace.logAction(fmt.Sprintf("Warning: Failed to compact session %s: %v", session.ID, err))
+		return
+	}
+	if !compacted {
+		return
+	}
+	chat, err := g.client.Chats.Create(ctx, g.model, g.config, chatHistory(session))
+	if err != nil {
+		g.workspace.logAction(fmt.Sprintf("Warning: Failed to rebuild chat after compacting session %s: %v", session.ID, err))
+		return
+	}
+	g.chat = chat
+}
@@ -0,0 +1,45 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNeedsCompaction(t *testing.T) {
+	turn := func(chars int) Chat {
+		return Chat{
+			Message:  SavedMessage{Content: strings.Repeat("x", chars)},
+			Response: SavedResponse{Content: strings.Repeat("y", chars), Usage: &Usage{TotalTokens: 9000}},
+		}
+	}
+	turns := func(n, chars int) []Chat {
+		chat := make([]Chat, n)
+		for i := range chat {
+			chat[i] = turn(chars)
+		}
+		return chat
+	}
+	tests := []struct {
+		name    string
+		session Session
+		want    bool
+	}{
+		{name: "short history", session: Session{Chat: turns(6, 100)}},
+		{name: "long history", session: Session{Chat: turns(6, 4000)}, want: true},
+		{name: "too few turns to fold", session: Session{Chat: turns(4, 10000)}},
+		{
+			// The usage report of every turn exceeds the threshold, as it
+			// counts the summary and kept turns, but the new turns are small.
+			name:    "compacted history with small new turns",
+			session: Session{Chat: turns(12, 100), Compaction: &Compaction{Turns: 6}},
+		},
+		{name: "compacted history with long new turns", session: Session{Chat: append(turns(6, 100), turns(6, 4000)...), Compaction: &Compaction{Turns: 6}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsCompaction(&tt.session, 8000); got != tt.want {
+				t.Errorf("needsCompaction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
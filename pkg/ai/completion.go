@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Roles of a `CompletionMessage`, as used by chat completion APIs.
+const (
+	CompletionSystem    = "system"
+	CompletionUser      = "user"
+	CompletionAssistant = "assistant"
+)
+
+// CompletionMessage is one message of a conversation supplied by the caller
+// of `Completer.Complete`, rather than read from a session.
+type CompletionMessage struct {
+	Role    string // CompletionSystem, CompletionUser, or CompletionAssistant.
+	Content string // Message text.
+}
+
+// Completion is the plain-text reply to a conversation.
+type Completion struct {
+	Content      string        // Reply text.
+	Model        string        // Model that generated the reply.
+	FinishReason string        // Why the model stopped generating (e.g., "STOP", "MAX_TOKENS").
+	Usage        *Usage        // Token usage reported by the provider, if any.
+	Latency      time.Duration // Time taken by the request.
+}
+
+// Completer continues a caller-supplied conversation in a workspace role,
+// without reading or recording any session. `GeminiAIClient` implements it.
+type Completer interface {
+	// Complete answers messages as the named role, or the default role if
+	// roleName is empty. The role's persona, the workspace system prompt, and
+	// the role's preferences are prepended to any system messages. When
+	// onChunk is not nil it is called with each piece of the reply as it is
+	// generated.
+	Complete(ctx context.Context, roleName string, messages []CompletionMessage, onChunk func(text string)) (Completion, error)
+}
+
+// completionRole loads the role a completion is answered in.
+func (w *Workspace) completionRole(roleName string) (Role, error) {
+	if roleName == "" {
+		roleName = w.Context.Settings.DefaultRole
+	}
+	return w.loadRole(roleName)
+}
+
+// Complete implements `Completer`.
+func (g *GeminiAIClient) Complete(ctx context.Context, roleName string, messages []CompletionMessage, onChunk func(text string)) (Completion, error) {
+	role, err := g.workspace.completionRole(roleName)
+	if err != nil {
+		return Completion{}, err
+	}
+	instructions, err := g.workspace.roleInstructions(role)
+	if err != nil {
+		return Completion{}, err
+	}
+
+	var contents []*genai.Content
+	for _, m := range messages {
+		if m.Content, _, err = g.workspace.Redact(string(m.Role)+" message", m.Content); err != nil {
+			return Completion{}, err
+		}
+		switch m.Role {
+		case CompletionSystem:
+			instructions = fmt.Sprintf("%s\n%s", instructions, m.Content)
+		case CompletionUser:
+			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleUser))
+		case CompletionAssistant:
+			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleModel))
+		default:
+			return Completion{}, fmt.Errorf("unknown message role '%s'", m.Role)
+		}
+	}
+	if len(contents) == 0 {
+		return Completion{}, errors.New("no user or assistant messages to complete")
+	}
+
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(instructions, genai.Role(role.Name)),
+		Temperature:       role.Parameters.Temperature,
+		TopP:              role.Parameters.TopP,
+	}
+	models := g.workspace.ModelsFor(TaskPrompt, role.Parameters.Model)
+
+	started := time.Now()
+	var (
+		text  strings.Builder
+		last  *genai.GenerateContentResponse
+		model string
+	)
+	if onChunk == nil {
+		last, model, err = g.generate(ctx, models, contents, config)
+		if err != nil {
+			return Completion{}, fmt.Errorf("failed to get response from Gemini: %w", err)
+		}
+		text.WriteString(last.Text())
+	} else {
+	routes:
+		for i, m := range models {
+			model = m
+			for chunk, err := range g.client.Models.GenerateContentStream(ctx, model, contents, config) {
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					err = providerError(err)
+					// Only fall back while nothing was received, as chunks were already passed on.
+					if text.Len() > 0 || i == len(models)-1 || !errors.Is(err, ErrProviderUnavailable) {
+						return Completion{}, fmt.Errorf("failed to get response from Gemini: %w", err)
+					}
+					g.workspace.logAction(fmt.Sprintf("Warning: Model %s unavailable, falling back to %s: %v", model, models[i+1], err))
+					continue routes
+				}
+				last = chunk
+				if piece := chunk.Text(); piece != "" {
+					text.WriteString(piece)
+					onChunk(piece)
+				}
+			}
+			break
+		}
+	}
+	if last == nil {
+		return Completion{}, errors.New("no response content received from Gemini model")
+	}
+
+	completion := Completion{
+		Content: text.String(),
+		Model:   model,
+		Usage:   geminiUsage(last.UsageMetadata),
+		Latency: time.Since(started),
+	}
+	if last.ModelVersion != "" {
+		completion.Model = last.ModelVersion
+	}
+	if len(last.Candidates) > 0 {
+		completion.FinishReason = string(last.Candidates[0].FinishReason)
+	}
+	return completion, nil
+}
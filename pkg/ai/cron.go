@@ -0,0 +1,170 @@
+package ai
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros maps the supported shorthand schedules to their five-field form.
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@nightly": "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// cronSpec is a parsed five-field cron expression: minute, hour, day of month,
+// month, and day of week. Each field is the set of values it matches.
+type cronSpec struct {
+	minute, hour, dom, month, dow map[int]bool
+	domAny, dowAny                bool // Whether the day fields were "*".
+}
+
+// cronField describes the allowed range of one field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// parseCron parses a standard five-field cron expression such as "30 18 * * 1-5"
+// or one of the macros in `cronMacros`. Fields accept "*", numbers, ranges
+// ("1-5"), lists ("1,15"), and steps ("*/10", "0-30/5"). A day of week of 7 is
+// treated as Sunday.
+func parseCron(expr string) (*cronSpec, error) {
+	if macro, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		expr = macro
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression '%s': expected 5 fields, got %d", expr, len(parts))
+	}
+
+	sets := make([]map[int]bool, len(parts))
+	for i, part := range parts {
+		field := cronFields[i]
+		if i == 4 {
+			field.max = 7
+		}
+		set, err := parseCronField(part, field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression '%s': %w", expr, err)
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &cronSpec{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: parts[2] == "*", dowAny: parts[4] == "*",
+	}, nil
+}
+
+// parseCronField parses one comma-separated field of a cron expression.
+func parseCronField(text string, field cronField) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, item := range strings.Split(text, ",") {
+		rangeText, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step '%s' in %s field", stepText, field.name)
+			}
+			step = n
+		}
+
+		low, high := field.min, field.max
+		if rangeText != "*" {
+			lowText, highText, isRange := strings.Cut(rangeText, "-")
+			var err error
+			if low, err = strconv.Atoi(lowText); err != nil {
+				return nil, fmt.Errorf("invalid value '%s' in %s field", lowText, field.name)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highText); err != nil {
+					return nil, fmt.Errorf("invalid value '%s' in %s field", highText, field.name)
+				}
+			} else if hasStep {
+				high = field.max
+			}
+		}
+		if low < field.min || high > field.max || low > high {
+			return nil, fmt.Errorf("%s field value '%s' is outside %d-%d", field.name, rangeText, field.min, field.max)
+		}
+		for v := low; v <= high; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the minute containing t is selected by the spec.
+func (c *cronSpec) matches(t time.Time) bool {
+	return c.minute[t.Minute()] && c.hour[t.Hour()] && c.month[int(t.Month())] && c.dayMatches(t)
+}
+
+// dayMatches reports whether the day containing t is selected by the day of
+// month and day of week fields. As in cron, when both are restricted a day
+// matches either one.
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	domMatch, dowMatch := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+// cronLookaheadYears bounds the search for a matching minute, so an
+// expression that never matches (e.g., "0 0 31 2 *") cannot loop forever.
+// Eight years cover the longest gap between leap days, as from 2096 to 2104,
+// so "0 0 29 2 *" is always found.
+const cronLookaheadYears = 8
+
+// next returns the first minute after t selected by the spec, or the zero time
+// if none falls within `cronLookaheadYears`. Months, days, and hours that do
+// not match are skipped whole, by the calendar of t's location.
+func (c *cronSpec) next(t time.Time) time.Time {
+	limit := t.AddDate(cronLookaheadYears, 0, 0)
+	m := t.Truncate(time.Minute).Add(time.Minute)
+	for !m.After(limit) {
+		year, month, day := m.Date()
+		var skip time.Time
+		switch {
+		case !c.month[int(month)]:
+			skip = time.Date(year, month+1, 1, 0, 0, 0, 0, m.Location())
+		case !c.dayMatches(m):
+			skip = time.Date(year, month, day+1, 0, 0, 0, 0, m.Location())
+		case !c.hour[m.Hour()]:
+			skip = time.Date(year, month, day, m.Hour()+1, 0, 0, 0, m.Location())
+		case !c.minute[m.Minute()]:
+			skip = m.Add(time.Minute)
+		default:
+			return m
+		}
+		// Around daylight saving changes the next wall-clock hour may not
+		// lie ahead; move on by a minute instead.
+		if !skip.After(m) {
+			skip = m.Add(time.Minute)
+		}
+		m = skip
+	}
+	return time.Time{}
+}
@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		field   func(*cronSpec) map[int]bool
+		want    []int
+		wantErr bool
+	}{
+		{expr: "*/15 * * * *", field: func(c *cronSpec) map[int]bool { return c.minute }, want: []int{0, 15, 30, 45}},
+		{expr: "0-30/10 * * * *", field: func(c *cronSpec) map[int]bool { return c.minute }, want: []int{0, 10, 20, 30}},
+		{expr: "5/20 * * * *", field: func(c *cronSpec) map[int]bool { return c.minute }, want: []int{5, 25, 45}},
+		{expr: "0 9-11,14 * * *", field: func(c *cronSpec) map[int]bool { return c.hour }, want: []int{9, 10, 11, 14}},
+		{expr: "0 0 * * 5-7", field: func(c *cronSpec) map[int]bool { return c.dow }, want: []int{0, 5, 6, 7}},
+		{expr: "@monthly", field: func(c *cronSpec) map[int]bool { return c.dom }, want: []int{1}},
+		{expr: "0 0 * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "0 0 0 * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseCron(%q) succeeded, want an error", tt.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCron(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		got := tt.field(spec)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseCron(%q) field = %v, want %v", tt.expr, got, tt.want)
+			continue
+		}
+		for _, v := range tt.want {
+			if !got[v] {
+				t.Errorf("parseCron(%q) field = %v, want %v", tt.expr, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr string
+		from string
+		want string // Empty when nothing matches.
+	}{
+		{"30 18 * * 1-5", "2026-10-16 18:30", "2026-10-19 18:30"},
+		{"*/10 * * * *", "2026-10-16 10:05", "2026-10-16 10:10"},
+		{"0 0 1 * *", "2026-12-15 12:00", "2027-01-01 00:00"},
+		{"0 0 29 2 *", "2026-10-16 00:00", "2028-02-29 00:00"},
+		{"0 0 29 2 *", "2096-03-01 00:00", "2104-02-29 00:00"},
+		{"0 12 13 * 5", "2026-10-16 13:00", "2026-10-23 12:00"}, // Either day field matches.
+		{"0 0 31 2 *", "2026-10-16 00:00", ""},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q) failed: %v", tt.expr, err)
+		}
+		got := spec.next(at(tt.from))
+		if tt.want == "" {
+			if !got.IsZero() {
+				t.Errorf("next(%q, %s) = %s, want none", tt.expr, tt.from, got)
+			}
+			continue
+		}
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("next(%q, %s) = %s, want %s", tt.expr, tt.from, got, want)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Schedule
+		wantErr bool
+	}{
+		{name: "valid", s: Schedule{Name: "weekly-report", Template: "report", Trigger: TriggerCommit, Output: "reports/{date}.md"}},
+		{name: "name with a path", s: Schedule{Name: "../evil", Template: "report", Trigger: TriggerCommit}, wantErr: true},
+		{name: "absolute output", s: Schedule{Name: "report", Template: "report", Trigger: TriggerCommit, Output: "/home/me/.bashrc"}, wantErr: true},
+		{name: "output outside the project", s: Schedule{Name: "report", Template: "report", Trigger: TriggerCommit, Output: "docs/../../x.md"}, wantErr: true},
+		{name: "unknown trigger", s: Schedule{Name: "report", Template: "report", Trigger: "hourly"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.s.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
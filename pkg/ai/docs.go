@@ -0,0 +1,438 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const (
+	// docsManifestName is the workspace file recording generated documentation.
+	docsManifestName = "docs.json"
+
+	// defaultDocsDir is the directory, relative to the project root, that
+	// generated documentation is written to when none is configured.
+	defaultDocsDir = "docs"
+
+	// defaultDocsRole is the role used to generate documentation when none is given.
+	defaultDocsRole = "documenter"
+)
+
+// DocGenerator writes the documentation of a single source file in the voice
+// of a role. `GeminiAIClient` implements it.
+type DocGenerator interface {
+	GenerateDoc(ctx context.Context, role Role, path, source string) (string, error)
+}
+
+// DocEntry records a generated document in the docs manifest.
+type DocEntry struct {
+	Source      string    `json:"source"`      // Documented source file, relative to the project root.
+	Doc         string    `json:"doc"`         // Generated Markdown document, relative to the project root.
+	Role        string    `json:"role"`        // Name of the role that generated the document.
+	GeneratedAt time.Time `json:"generatedAt"` // Timestamp of the generation.
+	SourceHash  string    `json:"sourceHash"`  // Hash of the source content the document was generated from.
+}
+
+// Reasons a generated document is reported as stale by `StaleDocs`.
+const (
+	StaleSourceChanged  = "source changed"
+	StaleSourceMissing  = "source missing"
+	StaleDocMissing     = "document missing"
+	StaleHashUnrecorded = "source hash not recorded"
+)
+
+// StaleDoc is a generated document that no longer matches its source.
+type StaleDoc struct {
+	DocEntry
+	Reason string // One of the Stale* reasons.
+}
+
+// DocsManifest is stored as `docs.json` and lists every generated document,
+// so interrupted batches can resume and generated files can be found again.
+type DocsManifest struct {
+	Docs map[string]DocEntry `json:"docs"` // Entries keyed by source path.
+}
+
+// DocProgress reports the outcome of one file of a documentation batch.
+type DocProgress struct {
+	Index   int    // Number of files processed so far, including this one.
+	Total   int    // Number of files in the batch.
+	Source  string // Source file, relative to the project root.
+	Doc     string // Generated document, relative to the project root.
+	Skipped bool   // Whether the file was skipped because it is already documented.
+	Err     error  // Generation failure, if any.
+}
+
+// DocsOptions controls a documentation batch run by `GenerateDocs`.
+type DocsOptions struct {
+	Role        string            // Role generating the documents; empty uses "documenter".
+	OutDir      string            // Output directory relative to the project root; empty uses "docs", or the existing document's location.
+	Force       bool              // Regenerate files that are already documented.
+	Concurrency int               // Files documented at once; 0 uses `Settings.BatchConcurrency` or the default.
+	Progress    func(DocProgress) // Called after each file, if set.
+}
+
+// DocsReport summarizes a documentation batch. Paths are relative to the project root.
+type DocsReport struct {
+	Generated []string         // Sources documented by this run.
+	Skipped   []string         // Sources already documented by an earlier run.
+	Failed    map[string]error // Sources whose generation failed, with the error.
+}
+
+// projectDir returns the directory of the project the workspace belongs to.
+func (w *Workspace) projectDir() string {
+	return filepath.Dir(w.RootDir)
+}
+
+// LoadDocsManifest reads `docs.json`. A missing manifest yields an empty one.
+func (w *Workspace) LoadDocsManifest() (DocsManifest, error) {
+	manifest := DocsManifest{Docs: make(map[string]DocEntry)}
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, docsManifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return manifest, fmt.Errorf("failed to read docs manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse docs manifest: %w", err)
+	}
+	if manifest.Docs == nil {
+		manifest.Docs = make(map[string]DocEntry)
+	}
+	return manifest, nil
+}
+
+// saveDocsManifest writes `docs.json`.
+func (w *Workspace) saveDocsManifest(manifest DocsManifest) error {
+	if err := w.writeJSON(filepath.Join(w.RootDir, docsManifestName), manifest); err != nil {
+		return fmt.Errorf("failed to save docs manifest: %w", err)
+	}
+	return nil
+}
+
+// ExpandSourcePatterns resolves command-line patterns into a sorted list of
+// source files. A pattern ending in "/..." matches source files in that
+// directory and all its subdirectories, a directory matches the source files
+// directly inside it, and a file matches itself. Hidden, vendor, and
+// node_modules directories are skipped, as are test files.
+func ExpandSourcePatterns(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, pattern := range patterns {
+		root, recursive := strings.CutSuffix(filepath.ToSlash(pattern), "/...")
+		if root == "" {
+			root = "."
+		}
+		root = filepath.Clean(filepath.FromSlash(root))
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve pattern %s: %w", pattern, err)
+		}
+		if !info.IsDir() {
+			add(root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if path != root && (!recursive || strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isDocumentableSource(path) {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand pattern %s: %w", pattern, err)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// isDocumentableSource reports whether path is a source file worth documenting:
+// a file in a recognized language that is not a test.
+func isDocumentableSource(path string) bool {
+	if _, ok := languageExtensions[strings.ToLower(filepath.Ext(path))]; !ok {
+		return false
+	}
+	base := strings.ToLower(filepath.Base(path))
+	for _, marker := range []string{"_test.", ".test.", ".spec.", "test_"} {
+		if strings.Contains(base, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateDocs documents each of files with gen, writing one Markdown document
+// per file to a tree mirroring the sources under the output directory. Each
+// generated document is recorded in `docs.json` as soon as it is written, so an
+// interrupted run resumes where it stopped: files already in the manifest whose
+// document still exists are skipped unless `Force` is set, in which case they
+// are regenerated where they are unless `OutDir` is given. Files are documented
+// concurrently (see `RunBatch`), within the provider's configured rate limit.
+// Failures of single files are collected in the report; the batch stops early
+// only when ctx is cancelled.
+func (w *Workspace) GenerateDocs(ctx context.Context, gen DocGenerator, files []string, opts DocsOptions) (DocsReport, error) {
+	report := DocsReport{Failed: make(map[string]error)}
+	roleName := opts.Role
+	if roleName == "" {
+		roleName = defaultDocsRole
+	}
+	role, err := w.loadRole(roleName)
+	if err != nil {
+		return report, err
+	}
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = defaultDocsDir
+	}
+	manifest, err := w.LoadDocsManifest()
+	if err != nil {
+		return report, err
+	}
+	projectDir, err := filepath.Abs(w.projectDir())
+	if err != nil {
+		return report, fmt.Errorf("failed to resolve project directory: %w", err)
+	}
+
+	// Jobs run concurrently: mu guards the report, the manifest, the progress
+	// count, and the progress callback.
+	var (
+		mu        sync.Mutex
+		processed int
+	)
+	finish := func(progress DocProgress) {
+		processed++
+		progress.Index, progress.Total = processed, len(files)
+		w.reportDocProgress(opts, progress)
+	}
+
+	// Per-file failures are recorded in the report, so the aggregated batch
+	// error only matters when the batch was cancelled.
+	batchErr := RunBatch(ctx, len(files), w.batchOptions(gen, opts.Concurrency), func(ctx context.Context, i int) error {
+		file := files[i]
+		var progress DocProgress
+		abs, err := filepath.Abs(file)
+		if err == nil {
+			progress.Source, err = filepath.Rel(projectDir, abs)
+		}
+		if err != nil || strings.HasPrefix(progress.Source, "..") {
+			err = fmt.Errorf("source file %s is outside the project", file)
+		} else if !w.InScope(file) {
+			err = fmt.Errorf("source file %s is outside the scope %s", file, w.scope)
+		}
+		if err != nil {
+			progress.Source = file
+			progress.Err = err
+			mu.Lock()
+			defer mu.Unlock()
+			report.Failed[file] = progress.Err
+			finish(progress)
+			return progress.Err
+		}
+		progress.Source = filepath.ToSlash(progress.Source)
+		progress.Doc = filepath.ToSlash(filepath.Join(outDir, progress.Source+".md"))
+
+		mu.Lock()
+		entry, documented := manifest.Docs[progress.Source]
+		mu.Unlock()
+		if documented && opts.OutDir == "" {
+			progress.Doc = entry.Doc // Regenerate in place unless told otherwise.
+		}
+		if documented && !opts.Force {
+			if _, err := os.Stat(filepath.Join(projectDir, filepath.FromSlash(entry.Doc))); err == nil {
+				progress.Skipped = true
+				mu.Lock()
+				defer mu.Unlock()
+				report.Skipped = append(report.Skipped, progress.Source)
+				finish(progress)
+				return nil
+			}
+		}
+
+		docPath := filepath.Join(projectDir, filepath.FromSlash(progress.Doc))
+		hash, err := w.generateDoc(ctx, gen, role, abs, progress.Source, docPath)
+		mu.Lock()
+		defer mu.Unlock()
+		if progress.Err = err; err != nil {
+			if ctx.Err() == nil {
+				report.Failed[progress.Source] = err
+				finish(progress)
+			}
+			return err
+		}
+
+		manifest.Docs[progress.Source] = DocEntry{
+			Source:      progress.Source,
+			Doc:         progress.Doc,
+			Role:        role.Name,
+			GeneratedAt: w.now(),
+			SourceHash:  hash,
+		}
+		if err := w.saveDocsManifest(manifest); err != nil {
+			report.Failed[progress.Source] = err
+			return err
+		}
+		report.Generated = append(report.Generated, progress.Source)
+		finish(progress)
+		return nil
+	})
+	sort.Strings(report.Generated)
+	sort.Strings(report.Skipped)
+	if errors.Is(batchErr, context.Canceled) || errors.Is(batchErr, context.DeadlineExceeded) {
+		return report, batchErr
+	}
+
+	return report, w.logAction(fmt.Sprintf("Generated documentation for %d files with role %s (%d skipped, %d failed)",
+		len(report.Generated), role.Name, len(report.Skipped), len(report.Failed)))
+}
+
+// generateDoc documents the source file at abs, shown to the model as rel, and
+// writes the result to docPath. It returns the hash of the documented source.
+func (w *Workspace) generateDoc(ctx context.Context, gen DocGenerator, role Role, abs, rel, docPath string) (string, error) {
+	source, err := os.ReadFile(abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to read source file %s: %w", rel, err)
+	}
+	doc, err := gen.GenerateDoc(ctx, role, rel, string(source))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate documentation for %s: %w", rel, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(docPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create docs directory for %s: %w", rel, err)
+	}
+	if err := os.WriteFile(docPath, []byte(doc), 0644); err != nil {
+		return "", fmt.Errorf("failed to write documentation for %s: %w", rel, err)
+	}
+	return hashBytes(source), nil
+}
+
+// StaleDocs reports the generated documents that are out of date: those whose
+// source changed or disappeared since generation, whose document was deleted,
+// or that were generated before source hashes were recorded. Results are
+// sorted by source path.
+func (w *Workspace) StaleDocs() ([]StaleDoc, error) {
+	manifest, err := w.LoadDocsManifest()
+	if err != nil {
+		return nil, err
+	}
+	projectDir := w.projectDir()
+	var stale []StaleDoc
+	for _, entry := range manifest.Docs {
+		source, err := os.ReadFile(filepath.Join(projectDir, filepath.FromSlash(entry.Source)))
+		reason := ""
+		switch {
+		case os.IsNotExist(err):
+			reason = StaleSourceMissing
+		case err != nil:
+			return nil, fmt.Errorf("failed to read source file %s: %w", entry.Source, err)
+		case entry.SourceHash == "":
+			reason = StaleHashUnrecorded
+		case hashBytes(source) != entry.SourceHash:
+			reason = StaleSourceChanged
+		}
+		if reason == "" {
+			if _, err := os.Stat(filepath.Join(projectDir, filepath.FromSlash(entry.Doc))); os.IsNotExist(err) {
+				reason = StaleDocMissing
+			}
+		}
+		if reason != "" {
+			stale = append(stale, StaleDoc{DocEntry: entry, Reason: reason})
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Source < stale[j].Source })
+	return stale, nil
+}
+
+// RefreshDocs regenerates the stale documents reported by `StaleDocs` in place.
+// Documents whose source no longer exists cannot be regenerated; they are
+// reported as failures and left untouched. opts.Force and opts.OutDir are ignored.
+func (w *Workspace) RefreshDocs(ctx context.Context, gen DocGenerator, opts DocsOptions) (DocsReport, error) {
+	stale, err := w.StaleDocs()
+	if err != nil {
+		return DocsReport{Failed: make(map[string]error)}, err
+	}
+	var files []string
+	missing := make(map[string]error)
+	for _, s := range stale {
+		if s.Reason == StaleSourceMissing {
+			missing[s.Source] = fmt.Errorf("source file %s no longer exists; remove %s or restore the source", s.Source, s.Doc)
+			continue
+		}
+		files = append(files, filepath.Join(w.projectDir(), filepath.FromSlash(s.Source)))
+	}
+
+	opts.Force, opts.OutDir = true, ""
+	report := DocsReport{Failed: make(map[string]error)}
+	if len(files) > 0 {
+		report, err = w.GenerateDocs(ctx, gen, files, opts)
+	}
+	for source, e := range missing {
+		report.Failed[source] = e
+	}
+	return report, err
+}
+
+// reportDocProgress passes progress to the batch's progress callback, if any.
+func (w *Workspace) reportDocProgress(opts DocsOptions, progress DocProgress) {
+	if opts.Progress != nil {
+		opts.Progress(progress)
+	}
+}
+
+// GenerateDoc implements `DocGenerator` with a single Gemini request, using
+// the role's system instruction (see `Workspace.SystemPrompt`), model, and
+// sampling parameters.
+func (g *GeminiAIClient) GenerateDoc(ctx context.Context, role Role, path, source string) (string, error) {
+	instructions, err := g.workspace.roleInstructions(role)
+	if err != nil {
+		return "", err
+	}
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(instructions, genai.Role(role.Name)),
+		Temperature:       role.Parameters.Temperature,
+		TopP:              role.Parameters.TopP,
+	}
+
+	language := strings.ToLower(languageExtensions[strings.ToLower(filepath.Ext(path))])
+	prompt := fmt.Sprintf("Write the documentation for the source file `%s` as a standalone Markdown document. "+
+		"Output only the Markdown document.\n\n```%s\n%s\n```", path, language, source)
+	prompt, _, err = g.workspace.Redact(path, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	resp, _, err := g.generate(ctx, g.workspace.ModelsFor(TaskDocs, role.Parameters.Model), genai.Text(prompt), config)
+	if err != nil {
+		return "", fmt.Errorf("failed to get documentation from Gemini: %w", err)
+	}
+	doc := strings.TrimSpace(resp.Text())
+	if doc == "" {
+		return "", errors.New("model returned empty documentation")
+	}
+	return doc + "\n", nil
+}
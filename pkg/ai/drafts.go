@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// draftPath returns the file holding the unsent draft of the session with the
+// given ID: `drafts/<id>.md`.
+func (w *Workspace) draftPath(sessionID string) (string, error) {
+	if sessionID == "" || sessionID != filepath.Base(sessionID) || strings.HasPrefix(sessionID, ".") {
+		return "", fmt.Errorf("invalid session ID %q for a draft", sessionID)
+	}
+	return filepath.Join(w.RootDir, "drafts", sessionID+".md"), nil
+}
+
+// SaveDraft keeps text, the unsent prompt of the session with the given ID,
+// in `drafts/`, so it survives restarts and crashes; an empty text removes
+// the draft. The file is replaced atomically, so a crash mid-write leaves the
+// previous draft intact. Drafts are plain Markdown and are neither synced nor
+// recorded in the integrity manifest.
+func (w *Workspace) SaveDraft(sessionID, text string) error {
+	path, err := w.draftPath(sessionID)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		if err := w.storage.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove draft: %w", err)
+		}
+		return nil
+	}
+	if err := w.storage.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := w.storage.WriteFile(tmp, []byte(text), 0644); err != nil {
+		return fmt.Errorf("failed to write draft: %w", err)
+	}
+	if err := w.storage.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save draft: %w", err)
+	}
+	return nil
+}
+
+// LoadDraft returns the unsent prompt saved with `SaveDraft` for the session
+// with the given ID, or "" if there is none.
+func (w *Workspace) LoadDraft(sessionID string) (string, error) {
+	path, err := w.draftPath(sessionID)
+	if err != nil {
+		return "", err
+	}
+	data, err := w.storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read draft: %w", err)
+	}
+	return string(data), nil
+}
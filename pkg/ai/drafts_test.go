@@ -0,0 +1,31 @@
+package ai
+
+import (
+	"testing"
+)
+
+func TestDrafts(t *testing.T) {
+	w := newTestWorkspace(t)
+	if text, err := w.LoadDraft("s1"); err != nil || text != "" {
+		t.Fatalf("LoadDraft without a draft = %q, %v, want none", text, err)
+	}
+	for _, text := range []string{"first", "second\nline"} {
+		if err := w.SaveDraft("s1", text); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := w.LoadDraft("s1"); err != nil || got != text {
+			t.Fatalf("LoadDraft = %q, %v, want %q", got, err, text)
+		}
+	}
+	if err := w.SaveDraft("s1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if text, err := w.LoadDraft("s1"); err != nil || text != "" {
+		t.Fatalf("LoadDraft after clearing = %q, %v, want none", text, err)
+	}
+	for _, id := range []string{"", "../s1", ".hidden", "a/b"} {
+		if err := w.SaveDraft(id, "x"); err == nil {
+			t.Errorf("SaveDraft(%q) succeeded, want an error", id)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const (
+	// embeddingModel is the Gemini model used to embed workspace artifacts
+	// when the embedding route is not configured (see `ModelsFor`).
+	embeddingModel = "text-embedding-004"
+
+	// embeddingBatchSize is the maximum number of chunks embedded per request.
+	embeddingBatchSize = 50
+
+	// chunkSize is the target size, in characters, of an indexed chunk, for
+	// languages without a size of their own (see `sourceLanguage`).
+	chunkSize = 1500
+)
+
+// Kinds of artifacts indexed in the vector store.
+const (
+	ChunkSource     = "source"
+	ChunkSession    = "session"
+	ChunkPreference = "preference"
+)
+
+// ErrNoEmbedder is returned by semantic operations when no `Embedder` has been
+// configured with `SetEmbedder`.
+var ErrNoEmbedder = errors.New("no embedder configured for the workspace")
+
+// Embedder turns text into embedding vectors. `GeminiAIClient` implements it.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// VectorChunk is a piece of a workspace artifact together with its embedding.
+type VectorChunk struct {
+	Ref    string    `json:"ref"`    // Origin of the chunk: a file path, "session:<id>", or "preference:<id>".
+	Kind   string    `json:"kind"`   // One of ChunkSource, ChunkSession, or ChunkPreference.
+	Text   string    `json:"text"`   // The chunk's text.
+	Hash   string    `json:"hash"`   // Hash of Text, used to skip re-embedding unchanged chunks.
+	Vector []float32 `json:"vector"` // Embedding of Text.
+}
+
+// SearchResult is a chunk returned by `SemanticSearch`, ranked by similarity.
+type SearchResult struct {
+	Ref   string  `json:"ref"`   // Origin of the chunk.
+	Kind  string  `json:"kind"`  // Kind of artifact the chunk came from.
+	Text  string  `json:"text"`  // The chunk's text.
+	Score float64 `json:"score"` // Cosine similarity to the query, from -1 to 1.
+}
+
+// SetEmbedder configures the embedder used for indexing and semantic search.
+func (w *Workspace) SetEmbedder(e Embedder) {
+	w.embedder = e
+}
+
+// vectorIndexPath returns the location of the local vector store. Scoped
+// workspaces keep a store of their own under `vectors/scopes/`.
+func (w *Workspace) vectorIndexPath() string {
+	if w.scope != "" {
+		return filepath.Join(w.RootDir, "vectors", "scopes", filepath.FromSlash(w.scope), "index.json")
+	}
+	return filepath.Join(w.RootDir, "vectors", "index.json")
+}
+
+// loadVectors reads the vector store. A missing store yields no chunks.
+func (w *Workspace) loadVectors() ([]VectorChunk, error) {
+	data, err := w.storage.ReadFile(w.vectorIndexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read vector store: %w", err)
+	}
+	var chunks []VectorChunk
+	if err := json.Unmarshal(data, &chunks); err != nil {
+		return nil, fmt.Errorf("failed to parse vector store: %w", err)
+	}
+	return chunks, nil
+}
+
+// saveVectors writes the vector store. It is a derived cache, so it is written
+// directly instead of through `writeJSON` and is not tracked by the manifest.
+func (w *Workspace) saveVectors(chunks []VectorChunk) error {
+	if err := w.storage.MkdirAll(filepath.Dir(w.vectorIndexPath()), 0755); err != nil {
+		return fmt.Errorf("failed to create vectors directory: %w", err)
+	}
+	data, err := json.Marshal(chunks)
+	if err != nil {
+		return fmt.Errorf("failed to encode vector store: %w", err)
+	}
+	if err := w.storage.WriteFile(w.vectorIndexPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write vector store: %w", err)
+	}
+	return nil
+}
+
+// embeddingDocuments collects the text of every artifact to index: the active
+// session's source files, archived session transcripts, and preferences.
+// Artifacts that cannot be read are logged and skipped, as are sessions
+// outside the workspace's scope.
+func (w *Workspace) embeddingDocuments() []VectorChunk {
+	var docs []VectorChunk
+
+	if session, err := w.GetActiveSession(); err == nil && session != nil {
+		for _, src := range session.Sources {
+			data, err := os.ReadFile(w.sourcePath(src))
+			if err != nil {
+				w.logAction(fmt.Sprintf("Warning: Could not read source '%s' for embedding: %v", src, err))
+				continue
+			}
+			docs = append(docs, VectorChunk{Ref: src, Kind: ChunkSource, Text: string(data)})
+		}
+	}
+
+	for id, summary := range w.Context.Indexes.ArchivedSessions {
+		if !w.sessionInScope(summary.Scope) {
+			continue
+		}
+		data, err := w.storage.ReadFile(w.archivedSessionPath(id))
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not read archived session '%s' for embedding: %v", id, err))
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not parse archived session '%s' for embedding: %v", id, err))
+			continue
+		}
+		var transcript strings.Builder
+		for _, c := range session.Chat {
+			transcript.WriteString(fmt.Sprintf("User: %s\nAI: %s\n", c.Message.Content, c.Response.Content))
+		}
+		docs = append(docs, VectorChunk{Ref: "session:" + id, Kind: ChunkSession, Text: transcript.String()})
+	}
+
+	for id := range w.Context.Indexes.PreferencesIndex {
+		pref, err := w.LoadPreference(id)
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not load preference '%s' for embedding: %v", id, err))
+			continue
+		}
+		docs = append(docs, VectorChunk{Ref: "preference:" + id, Kind: ChunkPreference, Text: pref.Content})
+	}
+	return docs
+}
+
+// IndexEmbeddings rebuilds the local vector store under `vectors/` from the
+// current workspace artifacts. Chunks whose text is unchanged since the last
+// run reuse their stored vectors, so only new or edited content is embedded.
+// It returns the number of chunks that were newly embedded.
+func (w *Workspace) IndexEmbeddings(ctx context.Context) (int, error) {
+	if w.embedder == nil {
+		return 0, ErrNoEmbedder
+	}
+
+	existing, err := w.loadVectors()
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[string][]float32, len(existing))
+	for _, c := range existing {
+		known[c.Hash] = c.Vector
+	}
+
+	var chunks []VectorChunk
+	var pending []int
+	for _, doc := range w.embeddingDocuments() {
+		texts := chunkText(doc.Text, chunkSize)
+		if doc.Kind == ChunkSource {
+			texts = w.chunkSource(doc.Ref, doc.Text)
+		}
+		for _, text := range texts {
+			chunk := VectorChunk{Ref: doc.Ref, Kind: doc.Kind, Text: text, Hash: hashBytes([]byte(text))}
+			if vector, ok := known[chunk.Hash]; ok {
+				chunk.Vector = vector
+			} else {
+				pending = append(pending, len(chunks))
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	for start := 0; start < len(pending); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(pending))
+		texts := make([]string, 0, end-start)
+		for _, i := range pending[start:end] {
+			texts = append(texts, chunks[i].Text)
+		}
+		vectors, err := w.embedder.Embed(ctx, texts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to embed workspace chunks: %w", err)
+		}
+		if len(vectors) != len(texts) {
+			return 0, fmt.Errorf("embedder returned %d vectors for %d chunks", len(vectors), len(texts))
+		}
+		for j, i := range pending[start:end] {
+			chunks[i].Vector = vectors[j]
+		}
+	}
+
+	if err := w.saveVectors(chunks); err != nil {
+		return 0, err
+	}
+	return len(pending), w.logAction(fmt.Sprintf("Indexed %d chunks for semantic search (%d newly embedded)", len(chunks), len(pending)))
+}
+
+// SemanticSearch embeds query and returns the k chunks of the vector store most
+// similar to it, best match first. Run `IndexEmbeddings` to populate the store.
+func (w *Workspace) SemanticSearch(ctx context.Context, query string, k int) ([]SearchResult, error) {
+	if w.embedder == nil {
+		return nil, ErrNoEmbedder
+	}
+	chunks, err := w.loadVectors()
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || k <= 0 {
+		return nil, nil
+	}
+
+	vectors, err := w.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedder returned %d vectors for 1 query", len(vectors))
+	}
+
+	results := make([]SearchResult, 0, len(chunks))
+	for _, c := range chunks {
+		results = append(results, SearchResult{
+			Ref:   c.Ref,
+			Kind:  c.Kind,
+			Text:  c.Text,
+			Score: cosineSimilarity(vectors[0], c.Vector),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0 if
+// either vector is empty or their dimensions differ.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// retrievalContext formats search results as a context block prepended to a prompt.
+func retrievalContext(results []SearchResult) string {
+	var b strings.Builder
+	b.WriteString("**Relevant workspace context** (retrieved automatically; use only if helpful):\n")
+	for _, r := range results {
+		b.WriteString(fmt.Sprintf("— Begin snippet: [%s] —\n%s\n— End snippet: [%s] —\n", r.Ref, strings.TrimSpace(r.Text), r.Ref))
+	}
+	return b.String()
+}
+
+// Embed implements `Embedder` using the Gemini embeddings API.
+func (g *GeminiAIClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	contents := make([]*genai.Content, 0, len(texts))
+	for _, t := range texts {
+		contents = append(contents, genai.NewContentFromText(t, genai.RoleUser))
+	}
+	// Vectors of different models cannot be compared, so there is no fallback.
+	resp, err := g.client.Models.EmbedContent(ctx, g.workspace.ModelsFor(TaskEmbedding, "")[0], contents, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embeddings from Gemini: %w", providerError(err))
+	}
+	vectors := make([][]float32, 0, len(resp.Embeddings))
+	for _, e := range resp.Embeddings {
+		vectors = append(vectors, e.Values)
+	}
+	return vectors, nil
+}
@@ -0,0 +1,220 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Sentinel errors returned (wrapped) by the workspace and AI clients. Callers
+// should test for them with `errors.Is` rather than matching error text.
+var (
+	// ErrNoActiveSession is returned by operations on the active session when
+	// `session.json` does not exist.
+	ErrNoActiveSession = errors.New("no active session found")
+
+	// ErrSessionNotFound is returned when an archived session ID is unknown or
+	// its archive file is missing.
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrArchiveConflict is returned when a session cannot be archived without
+	// losing turns of an earlier archive of the same session. The error is an
+	// `*ArchiveConflictError` describing the conflict.
+	ErrArchiveConflict = errors.New("archive conflict")
+
+	// ErrRoleNotFound is returned when a role is neither in the workspace nor,
+	// where applicable, among the built-in presets.
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrChatNotFound is returned when a chat ID is not part of the active session.
+	ErrChatNotFound = errors.New("chat not found")
+
+	// ErrPreferenceNotFound is returned when a preference ID has no file.
+	ErrPreferenceNotFound = errors.New("preference not found")
+
+	// ErrTemplateNotFound is returned when a prompt template name has no file.
+	ErrTemplateNotFound = errors.New("template not found")
+
+	// ErrFlowNotFound is returned when a flow name is neither in the
+	// workspace's `flows/` directory nor among the built-in flows.
+	ErrFlowNotFound = errors.New("flow not found")
+
+	// ErrFactNotFound is returned when a fact ID has no file.
+	ErrFactNotFound = errors.New("fact not found")
+
+	// ErrMemoryNotFound is returned when a memory candidate ID is not in the queue.
+	ErrMemoryNotFound = errors.New("memory candidate not found")
+
+	// ErrScheduleNotFound is returned when a schedule name has no file.
+	ErrScheduleNotFound = errors.New("schedule not found")
+
+	// ErrTrashEntryNotFound is returned when an ID is not in the trash.
+	ErrTrashEntryNotFound = errors.New("trash entry not found")
+
+	// ErrProviderUnavailable is returned when the model provider cannot be
+	// reached or is temporarily refusing requests (rate limits, outages).
+	// Such failures are usually worth retrying later.
+	ErrProviderUnavailable = errors.New("model provider unavailable")
+
+	// ErrQuotaExceeded is returned, together with `ErrProviderUnavailable`,
+	// when the model provider refuses requests because a rate limit or quota
+	// was reached.
+	ErrQuotaExceeded = errors.New("model provider quota exceeded")
+
+	// ErrAuthFailed is returned when the model provider rejects the API key
+	// or credentials, or denies access to the model.
+	ErrAuthFailed = errors.New("model provider rejected the credentials")
+
+	// ErrContextTooLarge is returned when a request exceeds the model's context
+	// window. Compacting the session or removing sources may resolve it.
+	ErrContextTooLarge = errors.New("request exceeds the model context window")
+
+	// ErrSecretsDetected is returned when outgoing text contains secrets and
+	// `Settings.Redaction` is "block". The error is a `*SecretsError` listing them.
+	ErrSecretsDetected = errors.New("secrets detected in outgoing text")
+
+	// ErrResponseIncomplete is returned when a reply was cut off, by the output
+	// token limit or a failure mid-stream. The error is an `*IncompleteError`;
+	// the response returned with it holds what could be salvaged, and
+	// `ResponseContinuer` clients can ask the model to finish it.
+	ErrResponseIncomplete = errors.New("response was cut off")
+
+	// ErrInvalidListOptions is returned when `ListOptions` name an unknown
+	// sort field or order, or a negative offset or limit.
+	ErrInvalidListOptions = errors.New("invalid list options")
+
+	// ErrInvalidName is returned when the name of a role, the ID of a
+	// preference, or another name used as a file name is not a valid slug.
+	// The error is an `*InvalidNameError` saying why.
+	ErrInvalidName = errors.New("invalid name")
+
+	// ErrNoRoleRegistry is returned when a role registry is searched or
+	// installed from but none is given or set in `Settings.RoleRegistry`.
+	ErrNoRoleRegistry = errors.New("no role registry configured")
+
+	// ErrChecksumMismatch is returned when a role fetched from a role registry
+	// does not match the checksum its index lists, or none is listed.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrInsecureRegistry is returned when a role registry would be reached
+	// over plain HTTP or the git:// protocol, where the index and the roles it
+	// vouches for could be replaced in transit.
+	ErrInsecureRegistry = errors.New("insecure role registry")
+
+	// ErrPatchConflict is returned when a patch does not apply to the working
+	// tree: a hunk's lines are not in the file, or a file it creates exists
+	// or one it changes does not.
+	ErrPatchConflict = errors.New("patch does not apply")
+)
+
+// ArchiveConflictError reports that a session's existing archive holds turns
+// the session does not continue, as happens when an older copy of the session
+// is archived again or the archive was edited. Archiving the session would
+// overwrite those turns, so it is refused; `EndSessionAsCopy` archives the
+// session under a new ID instead, keeping both.
+type ArchiveConflictError struct {
+	SessionID     string // ID of the session being archived.
+	Path          string // Existing archive of the session.
+	ArchivedTurns int    // Turns in the existing archive.
+	SessionTurns  int    // Turns in the session being archived.
+}
+
+func (e *ArchiveConflictError) Error() string {
+	return fmt.Sprintf("session %s diverges from its archive %s (%d archived turns, %d in the session)",
+		e.SessionID, e.Path, e.ArchivedTurns, e.SessionTurns)
+}
+
+// Unwrap makes the error match `ErrArchiveConflict` with `errors.Is`.
+func (e *ArchiveConflictError) Unwrap() error { return ErrArchiveConflict }
+
+// InvalidNameError reports why a name cannot be used as a file name (see
+// `validateName`).
+type InvalidNameError struct {
+	Kind   string // What the name is of, as in "role".
+	Name   string // The rejected name.
+	Reason string // Why it was rejected.
+}
+
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("invalid %s name %q: %s", e.Kind, e.Name, e.Reason)
+}
+
+// Unwrap makes the error match `ErrInvalidName` with `errors.Is`.
+func (e *InvalidNameError) Unwrap() error { return ErrInvalidName }
+
+// SecretsError reports the secrets that stopped text from being sent to the
+// model provider.
+type SecretsError struct {
+	Findings []SecretFinding // Secrets found, in the order they appear.
+}
+
+func (e *SecretsError) Error() string {
+	found := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		found[i] = f.String()
+	}
+	return fmt.Sprintf("%v: %s", ErrSecretsDetected, strings.Join(found, "; "))
+}
+
+// Unwrap makes the error match `ErrSecretsDetected` with `errors.Is`.
+func (e *SecretsError) Unwrap() error { return ErrSecretsDetected }
+
+// IncompleteError reports why a reply was cut off.
+type IncompleteError struct {
+	FinishReason string // Why generation stopped (e.g., "MAX_TOKENS"), if it stopped on its own.
+	Err          error  // Failure that interrupted the reply, if any.
+}
+
+func (e *IncompleteError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v: %v", ErrResponseIncomplete, e.Err)
+	}
+	return fmt.Sprintf("%v (%s)", ErrResponseIncomplete, e.FinishReason)
+}
+
+// Unwrap makes the error match `ErrResponseIncomplete`, and the failure that
+// interrupted the reply, with `errors.Is`.
+func (e *IncompleteError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{ErrResponseIncomplete, e.Err}
+	}
+	return []error{ErrResponseIncomplete}
+}
+
+// ErrorClass is the kind of a failure to get a reply from the model, which
+// decides what the user is advised to do about it.
+type ErrorClass string
+
+const (
+	ErrorNetwork ErrorClass = "network" // The provider could not be reached, timed out, or is overloaded.
+	ErrorAuth    ErrorClass = "auth"    // The provider rejected the credentials.
+	ErrorQuota   ErrorClass = "quota"   // A rate limit or quota of the provider was reached.
+	ErrorParse   ErrorClass = "parse"   // The reply could not be parsed or does not match the role schema.
+	ErrorOther   ErrorClass = "other"   // Anything else.
+)
+
+// ClassifyError returns the class of err, or "" if err is nil.
+func ClassifyError(err error) ErrorClass {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrAuthFailed):
+		return ErrorAuth
+	case errors.Is(err, ErrQuotaExceeded):
+		return ErrorQuota
+	case errors.Is(err, ErrInvalidJSON), errors.Is(err, ErrSchemaMismatch):
+		return ErrorParse
+	case errors.Is(err, ErrProviderUnavailable), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// LogError records err, which happened while doing what, in the workspace
+// log together with its class (see `ClassifyError`).
+func (w *Workspace) LogError(what string, err error) error {
+	return w.logAction(fmt.Sprintf("Error: %s failed (%s): %v", what, ClassifyError(err), err))
+}
@@ -0,0 +1,29 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ""},
+		{fmt.Errorf("failed to get response: %w", fmt.Errorf("%w: %w: 429", ErrProviderUnavailable, ErrQuotaExceeded)), ErrorQuota},
+		{fmt.Errorf("%w: 503", ErrProviderUnavailable), ErrorNetwork},
+		{fmt.Errorf("request: %w", context.DeadlineExceeded), ErrorNetwork},
+		{fmt.Errorf("%w: 401", ErrAuthFailed), ErrorAuth},
+		{&ParseError{Err: errors.New("unexpected end")}, ErrorParse},
+		{fmt.Errorf("%w: reply.score is missing", ErrSchemaMismatch), ErrorParse},
+		{fmt.Errorf("%w: too long", ErrContextTooLarge), ErrorOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hookTimeout bounds how long a shell hook may run before it is killed.
+const hookTimeout = 30 * time.Second
+
+// EventType identifies something that happened in a workspace. Its value
+// names the shell hook run for it: `hooks/on-<type>`.
+type EventType string
+
+// Workspace events delivered to `Subscribe` handlers and shell hooks.
+const (
+	EventSessionStarted  EventType = "session-start"  // A new active session was created.
+	EventSessionArchived EventType = "session-end"    // A session was archived to `sessions/`, by ending or splitting it.
+	EventSessionRenamed  EventType = "session-rename" // An active or archived session's label changed.
+)
+
+// Event describes a workspace event. It is passed to handlers and, encoded
+// as JSON, on the standard input of shell hooks.
+type Event struct {
+	Type      EventType `json:"type"`           // What happened.
+	SessionID string    `json:"sessionId"`      // ID of the session concerned.
+	Label     string    `json:"label"`          // Label of the session concerned.
+	File      string    `json:"file,omitempty"` // Absolute path of the session's archive, for archived sessions.
+	Time      time.Time `json:"time"`           // When the event happened.
+}
+
+// eventBus holds the handlers registered with `Subscribe`. It is shared by
+// copies of the workspace made with `WithScope`.
+type eventBus struct {
+	mu       sync.Mutex
+	next     int
+	handlers map[EventType]map[int]func(Event)
+}
+
+// Subscribe registers handler to be called with every event of type t, and
+// returns a function that unregisters it. Handlers run synchronously on the
+// goroutine that changed the workspace, after the change is saved, so they
+// should return quickly and must not call back into the workspace.
+func (w *Workspace) Subscribe(t EventType, handler func(Event)) (unsubscribe func()) {
+	b := w.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = make(map[EventType]map[int]func(Event))
+	}
+	if b.handlers[t] == nil {
+		b.handlers[t] = make(map[int]func(Event))
+	}
+	id := b.next
+	b.next++
+	b.handlers[t][id] = handler
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers[t], id)
+	}
+}
+
+// emit delivers e to its subscribers, then runs its shell hook, if any.
+// Failing hooks are logged rather than reported, as the change they follow
+// has already been made.
+func (w *Workspace) emit(e Event) {
+	e.Time = w.now()
+	b := w.events
+	b.mu.Lock()
+	handlers := make([]func(Event), 0, len(b.handlers[e.Type]))
+	for _, h := range b.handlers[e.Type] {
+		handlers = append(handlers, h)
+	}
+	b.mu.Unlock()
+	for _, h := range handlers {
+		h(e)
+	}
+	if err := w.runHook(e); err != nil {
+		w.logAction(fmt.Sprintf("Warning: %v", err))
+	}
+}
+
+// HookPath returns where the shell hook for events of type t is looked up.
+func (w *Workspace) HookPath(t EventType) string {
+	return filepath.Join(w.RootDir, "hooks", "on-"+string(t))
+}
+
+// runHook runs the executable at `HookPath(e.Type)`, if there is one, from the
+// project directory. The event is given as JSON on standard input and in the
+// NANI_EVENT, NANI_SESSION_ID, NANI_SESSION_LABEL, NANI_SESSION_FILE, and
+// NANI_WORKSPACE environment variables.
+func (w *Workspace) runHook(e Event) error {
+	path := w.HookPath(e.Type)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to check hook %s: %w", path, err)
+	}
+	if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("hook %s is not executable; run `chmod +x` on it to enable it", path)
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", e.Type, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = w.projectDir()
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"NANI_EVENT="+string(e.Type),
+		"NANI_SESSION_ID="+e.SessionID,
+		"NANI_SESSION_LABEL="+e.Label,
+		"NANI_SESSION_FILE="+e.File,
+		"NANI_WORKSPACE="+w.RootDir,
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s", hookTimeout)
+		}
+		return fmt.Errorf("hook %s failed: %v: %s", path, err, strings.TrimSpace(output.String()))
+	}
+	return w.logAction(fmt.Sprintf("Ran hook %s for session %s", filepath.Base(path), e.SessionID))
+}
+
+// archivedEvent builds the event for session having been archived.
+func (w *Workspace) archivedEvent(session *Session) Event {
+	e := Event{Type: EventSessionArchived, SessionID: session.ID, Label: session.Label}
+	if summary, ok := w.Context.Indexes.ArchivedSessions[session.ID]; ok {
+		e.File = filepath.Join(w.RootDir, "sessions", summary.File)
+	}
+	return e
+}
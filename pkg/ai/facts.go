@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources of a fact.
+const (
+	FactSourceUser  = "user"  // Added or edited by the user (e.g., with `/facts add`).
+	FactSourceModel = "model" // Recorded by the model from the `remember` field of a reply.
+)
+
+// defaultFactBudget caps the characters of facts injected into the system
+// instruction when `Settings.FactBudget` is not set.
+const defaultFactBudget = 4000
+
+// Fact is a piece of durable project knowledge, such as "logging uses zap" or
+// "the API lives under internal/api", stored in `facts/<id>.json`. Facts
+// describe the project and are included in the system instruction of every
+// role; unlike preferences, they are not instructions about style.
+type Fact struct {
+	ID        string    `json:"id"`        // Unique identifier, also the file name.
+	Content   string    `json:"content"`   // The fact, as one sentence.
+	Source    string    `json:"source"`    // FactSourceUser or FactSourceModel.
+	Timestamp time.Time `json:"timestamp"` // When the fact was recorded or last edited.
+}
+
+// AddFact records content as a new fact from source and returns it. Content
+// that is already a fact, ignoring case and surrounding space, is not added
+// twice; the existing fact is returned instead.
+func (w *Workspace) AddFact(content, source string) (*Fact, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, fmt.Errorf("fact content is required")
+	}
+	facts, err := w.ListFacts()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range facts {
+		if strings.EqualFold(strings.TrimSpace(f.Content), content) {
+			return &f, nil
+		}
+	}
+	fact := Fact{ID: uuid.New().String(), Content: content, Source: source, Timestamp: w.now()}
+	if err := w.SaveFact(fact); err != nil {
+		return nil, err
+	}
+	return &fact, nil
+}
+
+// SaveFact writes fact to `facts/<id>.json`, replacing any fact with the same ID.
+func (w *Workspace) SaveFact(fact Fact) error {
+	if !safeArtifactName(fact.ID) {
+		return fmt.Errorf("invalid fact ID %q", fact.ID)
+	}
+	path := filepath.Join(w.RootDir, "facts", fmt.Sprintf("%s.json", fact.ID))
+	if err := w.writeJSON(path, fact); err != nil {
+		return fmt.Errorf("failed to save fact %s: %w", fact.ID, err)
+	}
+	return w.logAction(fmt.Sprintf("Saved fact %s", fact.ID))
+}
+
+// LoadFact loads a fact by its ID from `facts/<id>.json`.
+func (w *Workspace) LoadFact(id string) (*Fact, error) {
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, "facts", fmt.Sprintf("%s.json", id)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrFactNotFound, id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read fact %s: %w", id, err)
+	}
+	var fact Fact
+	if err := json.Unmarshal(data, &fact); err != nil {
+		return nil, fmt.Errorf("failed to parse fact %s: %w", id, err)
+	}
+	return &fact, nil
+}
+
+// ListFacts returns the facts of the project, ordered from oldest to newest
+// by `Timestamp`. Facts whose files cannot be loaded are logged and skipped.
+func (w *Workspace) ListFacts() ([]Fact, error) {
+	entries, err := w.storage.ReadDir(filepath.Join(w.RootDir, "facts"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read facts directory: %w", err)
+	}
+	facts := make([]Fact, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		fact, err := w.LoadFact(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not load fact '%s': %v", entry.Name(), err))
+			continue
+		}
+		facts = append(facts, *fact)
+	}
+	sort.Slice(facts, func(i, j int) bool {
+		return facts[i].Timestamp.Before(facts[j].Timestamp)
+	})
+	return facts, nil
+}
+
+// DeleteFact deletes `facts/<id>.json`.
+func (w *Workspace) DeleteFact(id string) error {
+	path := filepath.Join(w.RootDir, "facts", fmt.Sprintf("%s.json", id))
+	if err := w.storage.Remove(path); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrFactNotFound, id)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete fact file %s: %w", id, err)
+	}
+	if err := w.recordRemove(path); err != nil {
+		return fmt.Errorf("failed to update manifest after deleting fact: %w", err)
+	}
+	return w.logAction(fmt.Sprintf("Deleted fact %s", id))
+}
+
+// rememberFacts records the facts a model asked to remember in a reply.
+// Facts only inform later sessions, so failures to record them are logged.
+func (w *Workspace) rememberFacts(contents []string) {
+	for _, content := range contents {
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		if _, err := w.AddFact(content, FactSourceModel); err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not remember fact: %v", err))
+		}
+	}
+}
+
+// factsPrompt renders facts, ordered oldest to newest, as a section of the
+// system instruction. Like `preferencesPrompt`, it drops the oldest facts
+// first when their combined size exceeds budget characters.
+func factsPrompt(facts []Fact, budget int) string {
+	start := len(facts)
+	used := 0
+	for i := len(facts) - 1; i >= 0; i-- {
+		size := len(facts[i].Content)
+		if used+size > budget {
+			break
+		}
+		used += size
+		start = i
+	}
+	if start == len(facts) {
+		return ""
+	}
+
+	var section strings.Builder
+	section.WriteString("**Project Facts**:\n")
+	for _, f := range facts[start:] {
+		section.WriteString(fmt.Sprintf("- %s\n", f.Content))
+	}
+	return section.String()
+}
+
+// FactsMarkdown renders facts as a numbered Markdown list, numbered as the
+// `/facts` command expects.
+func FactsMarkdown(facts []Fact) string {
+	if len(facts) == 0 {
+		return "_No facts recorded yet._\n"
+	}
+	var b strings.Builder
+	for i, f := range facts {
+		fmt.Fprintf(&b, "%d. %s _(%s, %s)_\n", i+1, f.Content, f.Source, f.Timestamp.Format("2006-01-02"))
+	}
+	return b.String()
+}
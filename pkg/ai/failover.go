@@ -0,0 +1,248 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// FailoverClient chats through an ordered chain of providers, configured with
+// `Settings.Providers`. Each request goes to the primary provider first and
+// moves on to the next whenever one is unavailable (see
+// `ErrProviderUnavailable`), so quota and outage errors do not interrupt the
+// session. The provider that served a reply is recorded in its metadata.
+type FailoverClient struct {
+	workspace *Workspace
+	providers []string   // Provider names, in order of preference.
+	clients   []AIClient // Client of each provider.
+	active    int        // Index of the client whose chat is in sync with the session.
+}
+
+// ProviderSwitcher is implemented by AI clients that chat through several
+// providers and let the user choose which is tried first. `FailoverClient`
+// implements it.
+type ProviderSwitcher interface {
+	// Providers returns the provider names in the order they are tried.
+	Providers() []string
+	// SwitchProvider makes the named provider the first one tried.
+	SwitchProvider(name string) error
+}
+
+// NewFailoverClient creates a client that tries clients in order, each
+// serving the provider of the same index in providers.
+func NewFailoverClient(workspace *Workspace, providers []string, clients []AIClient) (*FailoverClient, error) {
+	if len(clients) == 0 || len(providers) != len(clients) {
+		return nil, errors.New("failover needs one client per provider")
+	}
+	return &FailoverClient{workspace: workspace, providers: providers, clients: clients}, nil
+}
+
+// failoverOpener is implemented by clients that can rebuild their chat from
+// the saved session, as a fallback must before taking over.
+type failoverOpener interface {
+	OpenSession(ctx context.Context) (*Session, error)
+}
+
+// try calls call with each client in turn until one succeeds or fails with
+// an error other than `ErrProviderUnavailable`, and returns the index of the
+// last client called. When reopen is true, a client other than the active
+// one reopens the session before it is called. canRetry, if not nil, reports
+// whether the request may still move on after a failure.
+func (f *FailoverClient) try(ctx context.Context, reopen bool, canRetry func() bool, call func(client AIClient) error) (int, error) {
+	var err error
+	for i, client := range f.clients {
+		if reopen && i != f.active {
+			if opener, ok := client.(failoverOpener); ok {
+				if _, err = opener.OpenSession(ctx); err != nil {
+					return i, err
+				}
+			}
+		}
+		if err = call(client); err == nil || !errors.Is(err, ErrProviderUnavailable) || i == len(f.clients)-1 || (canRetry != nil && !canRetry()) {
+			return i, err
+		}
+		f.workspace.logAction(fmt.Sprintf("Warning: Provider %s unavailable, falling back to %s: %v", f.providers[i], f.providers[i+1], err))
+	}
+	return len(f.clients) - 1, err
+}
+
+// Provider implements `Provider`, returning the provider that served the
+// latest chat reply.
+func (f *FailoverClient) Provider() string {
+	return f.providers[f.active]
+}
+
+// Providers implements `ProviderSwitcher`.
+func (f *FailoverClient) Providers() []string {
+	return slices.Clone(f.providers)
+}
+
+// SwitchProvider implements `ProviderSwitcher`, moving the named provider to
+// the front of the chain. It takes over the chat, reopening the session, with
+// the next request; the others remain its fallbacks in their order.
+func (f *FailoverClient) SwitchProvider(name string) error {
+	i := slices.Index(f.providers, name)
+	if i < 0 {
+		return fmt.Errorf("unknown provider '%s': expected one of %v", name, f.providers)
+	}
+	providers := append([]string{name}, slices.Delete(slices.Clone(f.providers), i, i+1)...)
+	clients := append([]AIClient{f.clients[i]}, slices.Delete(slices.Clone(f.clients), i, i+1)...)
+	switch {
+	case f.active == i:
+		f.active = 0
+	case f.active < i:
+		f.active++
+	}
+	f.providers, f.clients = providers, clients
+	return nil
+}
+
+// SetWorkspace points every client of the chain at another workspace.
+func (f *FailoverClient) SetWorkspace(workspace *Workspace) {
+	f.workspace = workspace
+	for _, client := range f.clients {
+		if switcher, ok := client.(interface{ SetWorkspace(*Workspace) }); ok {
+			switcher.SetWorkspace(workspace)
+		}
+	}
+}
+
+// StartSession opens the active session and returns the greeting of the
+// first available provider.
+func (f *FailoverClient) StartSession(ctx context.Context) (Response, error) {
+	var response Response
+	i, err := f.try(ctx, false, nil, func(client AIClient) error {
+		var err error
+		response, err = client.StartSession(ctx)
+		return err
+	})
+	if err == nil {
+		f.active = i
+	}
+	return response, err
+}
+
+// OpenSession prepares the primary provider's chat for the active session.
+// Fallbacks open the session when they first take over.
+func (f *FailoverClient) OpenSession(ctx context.Context) (*Session, error) {
+	f.active = 0
+	if opener, ok := f.clients[0].(failoverOpener); ok {
+		return opener.OpenSession(ctx)
+	}
+	return f.workspace.GetSession(ctx, defaultSessionLabel, "")
+}
+
+// SendMessage sends message to the first available provider.
+func (f *FailoverClient) SendMessage(ctx context.Context, message SavedMessage, history []Message, save bool) (Response, error) {
+	var response Response
+	i, err := f.try(ctx, true, nil, func(client AIClient) error {
+		var err error
+		response, err = client.SendMessage(ctx, message, history, save)
+		return err
+	})
+	if err == nil || errors.Is(err, ErrResponseIncomplete) {
+		f.active = i
+	}
+	return response, err
+}
+
+// StreamMessage implements `MessageStreamer`. A provider that fails after
+// delivering part of its reply is not replaced, since the caller has already
+// shown that part; providers that cannot stream deliver their reply in one
+// piece.
+func (f *FailoverClient) StreamMessage(ctx context.Context, message SavedMessage, save bool, onChunk func(text string)) (Response, error) {
+	var response Response
+	streamed := false
+	chunk := func(text string) {
+		streamed = true
+		if onChunk != nil {
+			onChunk(text)
+		}
+	}
+	i, err := f.try(ctx, true, func() bool { return !streamed }, func(client AIClient) error {
+		var err error
+		if streamer, ok := client.(MessageStreamer); ok {
+			response, err = streamer.StreamMessage(ctx, message, save, chunk)
+		} else {
+			response, err = client.SendMessage(ctx, message, nil, save)
+		}
+		return err
+	})
+	if err == nil || errors.Is(err, ErrResponseIncomplete) {
+		f.active = i
+	}
+	return response, err
+}
+
+// ContinueResponse implements `ResponseContinuer` with the provider that
+// served the reply being continued.
+func (f *FailoverClient) ContinueResponse(ctx context.Context, onChunk func(text string)) (Response, error) {
+	continuer, ok := f.clients[f.active].(ResponseContinuer)
+	if !ok {
+		return Response{}, fmt.Errorf("the %s provider cannot continue cut-off replies", f.providers[f.active])
+	}
+	return continuer.ContinueResponse(ctx, onChunk)
+}
+
+// SelectCandidate implements `CandidateSelector` with the provider that
+// served the latest reply.
+func (f *FailoverClient) SelectCandidate(ctx context.Context, n int) (Response, error) {
+	selector, ok := f.clients[f.active].(CandidateSelector)
+	if !ok {
+		return Response{}, fmt.Errorf("the %s provider does not generate candidates", f.providers[f.active])
+	}
+	return selector.SelectCandidate(ctx, n)
+}
+
+// RunPrompt implements `PromptRunner` with the first available provider
+// that can answer prompts.
+func (f *FailoverClient) RunPrompt(ctx context.Context, role Role, prompt string) (string, error) {
+	var answer string
+	_, err := f.try(ctx, false, nil, func(client AIClient) error {
+		runner, ok := client.(PromptRunner)
+		if !ok {
+			return fmt.Errorf("%w: the provider cannot answer prompts", ErrProviderUnavailable)
+		}
+		var err error
+		answer, err = runner.RunPrompt(ctx, role, prompt)
+		return err
+	})
+	return answer, err
+}
+
+// Complete implements `Completer` with the first available provider that
+// supports completions. Like `StreamMessage`, it does not fall back once part
+// of the reply has been delivered.
+func (f *FailoverClient) Complete(ctx context.Context, roleName string, messages []CompletionMessage, onChunk func(text string)) (Completion, error) {
+	var completion Completion
+	streamed := false
+	chunk := onChunk
+	if onChunk != nil {
+		chunk = func(text string) {
+			streamed = true
+			onChunk(text)
+		}
+	}
+	_, err := f.try(ctx, false, func() bool { return !streamed }, func(client AIClient) error {
+		completer, ok := client.(Completer)
+		if !ok {
+			return fmt.Errorf("%w: the provider does not support completions", ErrProviderUnavailable)
+		}
+		var err error
+		completion, err = completer.Complete(ctx, roleName, messages, chunk)
+		return err
+	})
+	return completion, err
+}
+
+// Embed implements `Embedder` with the first provider of the chain that can
+// embed text.
+func (f *FailoverClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	for _, client := range f.clients {
+		if embedder, ok := client.(Embedder); ok {
+			return embedder.Embed(ctx, texts)
+		}
+	}
+	return nil, ErrNoEmbedder
+}
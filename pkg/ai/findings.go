@@ -0,0 +1,257 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Severities of a `Finding`, from most to least severe.
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+// Severities lists the finding severities from most to least severe.
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// Finding is a single review comment anchored to a line of a file.
+type Finding struct {
+	File     string `json:"file"`     // Path of the file, relative to the project root.
+	Line     int    `json:"line"`     // Line in the new version of the file; 0 for the whole file.
+	Severity string `json:"severity"` // One of `Severities`.
+	Comment  string `json:"comment"`  // The problem and the suggested fix.
+}
+
+// Anchor returns the finding's location as "file:line", or the file alone for
+// file-level findings.
+func (f Finding) Anchor() string {
+	if f.Line <= 0 {
+		return f.File
+	}
+	return fmt.Sprintf("%s:%d", f.File, f.Line)
+}
+
+// normalizeSeverity maps a severity to one of `Severities`, treating unknown
+// severities as informational.
+func normalizeSeverity(severity string) string {
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	if severityRank(severity) == len(Severities) {
+		return SeverityInfo
+	}
+	return severity
+}
+
+// severityRank returns the position of severity in `Severities`, or
+// len(Severities) if it is unknown.
+func severityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities)
+}
+
+// sortFindings orders findings by severity, then file, then line.
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+			return ra < rb
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
+
+// SessionFindings returns all findings recorded in the active session, in the
+// order the responses that produced them were received.
+func (w *Workspace) SessionFindings() ([]Finding, error) {
+	session, err := w.loadSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session to list findings: %w", err)
+	}
+
+	var findings []Finding
+	for _, chat := range session.Chat {
+		findings = append(findings, chat.Response.Findings...)
+	}
+	return findings, nil
+}
+
+// findingsNode is a directory or file of the tree drawn by `FindingsTree`.
+type findingsNode struct {
+	children map[string]*findingsNode
+	findings []Finding
+}
+
+// FindingsTree renders findings as a tree of the files they annotate, each
+// file listing its findings by line, for display in a code block.
+func FindingsTree(findings []Finding) string {
+	root := &findingsNode{children: make(map[string]*findingsNode)}
+	for _, f := range findings {
+		node := root
+		for _, part := range strings.Split(path.Clean(filepath.ToSlash(f.File)), "/") {
+			child, ok := node.children[part]
+			if !ok {
+				child = &findingsNode{children: make(map[string]*findingsNode)}
+				node.children[part] = child
+			}
+			node = child
+		}
+		node.findings = append(node.findings, f)
+	}
+	var b strings.Builder
+	root.write(&b, "")
+	return b.String()
+}
+
+// write draws the children of n, then its findings, each line starting with prefix.
+func (n *findingsNode) write(b *strings.Builder, prefix string) {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.SliceStable(n.findings, func(i, j int) bool { return n.findings[i].Line < n.findings[j].Line })
+
+	items := len(names) + len(n.findings)
+	branch := func(i int) (string, string) {
+		if i == items-1 {
+			return "└── ", "    "
+		}
+		return "├── ", "│   "
+	}
+	for i, name := range names {
+		child := n.children[name]
+		head, indent := branch(i)
+		if len(child.children) > 0 {
+			name += "/"
+		}
+		b.WriteString(prefix + head + name + "\n")
+		child.write(b, prefix+indent)
+	}
+	for i, f := range n.findings {
+		head, indent := branch(len(names) + i)
+		line := "file"
+		if f.Line > 0 {
+			line = fmt.Sprintf("L%d", f.Line)
+		}
+		comment := strings.Split(strings.TrimSpace(f.Comment), "\n")
+		fmt.Fprintf(b, "%s%s%s [%s] %s\n", prefix, head, line, f.Severity, comment[0])
+		for _, more := range comment[1:] {
+			b.WriteString(prefix + indent + more + "\n")
+		}
+	}
+}
+
+// Formats findings can be exported in with `ExportFindings`.
+const (
+	FindingsSARIF  = "sarif"  // SARIF 2.1.0, as read by GitHub code scanning.
+	FindingsRDJSON = "rdjson" // reviewdog's Diagnostic Format (`reviewdog -f=rdjson`).
+)
+
+// FindingsFormat returns the export format implied by the extension of path:
+// `FindingsRDJSON` for ".rdjson" files and `FindingsSARIF` otherwise.
+func FindingsFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".rdjson") {
+		return FindingsRDJSON
+	}
+	return FindingsSARIF
+}
+
+// ExportFindings encodes findings in format, for consumption by CI tools.
+func ExportFindings(findings []Finding, format string) ([]byte, error) {
+	var doc any
+	switch format {
+	case FindingsSARIF:
+		doc = sarifLog(findings)
+	case FindingsRDJSON:
+		doc = rdjsonResult(findings)
+	default:
+		return nil, fmt.Errorf("unknown findings format '%s', expected %s or %s", format, FindingsSARIF, FindingsRDJSON)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode findings: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// sarifLog converts findings into a SARIF log with one run, using each
+// severity as a rule.
+func sarifLog(findings []Finding) map[string]any {
+	levels := map[string]string{
+		SeverityCritical: "error",
+		SeverityHigh:     "error",
+		SeverityMedium:   "warning",
+		SeverityLow:      "note",
+		SeverityInfo:     "note",
+	}
+	rules := make([]map[string]any, 0, len(Severities))
+	for _, s := range Severities {
+		rules = append(rules, map[string]any{
+			"id":                   s,
+			"shortDescription":     map[string]string{"text": fmt.Sprintf("Review finding of %s severity", s)},
+			"defaultConfiguration": map[string]string{"level": levels[s]},
+		})
+	}
+	results := make([]map[string]any, 0, len(findings))
+	for _, f := range findings {
+		location := map[string]any{"artifactLocation": map[string]string{"uri": filepath.ToSlash(f.File)}}
+		if f.Line > 0 {
+			location["region"] = map[string]int{"startLine": f.Line}
+		}
+		results = append(results, map[string]any{
+			"ruleId":    f.Severity,
+			"level":     levels[f.Severity],
+			"message":   map[string]string{"text": f.Comment},
+			"locations": []map[string]any{{"physicalLocation": location}},
+		})
+	}
+	return map[string]any{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []map[string]any{{
+			"tool":    map[string]any{"driver": map[string]any{"name": "nani", "rules": rules}},
+			"results": results,
+		}},
+	}
+}
+
+// rdjsonResult converts findings into a reviewdog diagnostic result.
+func rdjsonResult(findings []Finding) map[string]any {
+	severities := map[string]string{
+		SeverityCritical: "ERROR",
+		SeverityHigh:     "ERROR",
+		SeverityMedium:   "WARNING",
+		SeverityLow:      "INFO",
+		SeverityInfo:     "INFO",
+	}
+	diagnostics := make([]map[string]any, 0, len(findings))
+	for _, f := range findings {
+		location := map[string]any{"path": filepath.ToSlash(f.File)}
+		if f.Line > 0 {
+			location["range"] = map[string]any{"start": map[string]int{"line": f.Line}}
+		}
+		diagnostics = append(diagnostics, map[string]any{
+			"message":  f.Comment,
+			"location": location,
+			"severity": severities[f.Severity],
+			"code":     map[string]string{"value": f.Severity},
+		})
+	}
+	return map[string]any{
+		"source":      map[string]string{"name": "nani"},
+		"diagnostics": diagnostics,
+	}
+}
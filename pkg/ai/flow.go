@@ -0,0 +1,343 @@
+package ai
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FlowEnd is the step a `FlowTransition` goes to to finish its flow.
+const FlowEnd = "end"
+
+// flowExtensions are the file extensions flow definitions are read from, in
+// the order they are tried.
+var flowExtensions = []string{".json", ".yaml", ".yml"}
+
+// builtinFlowFiles holds the flows shipped with nani. A flow with the same
+// name in the workspace's `flows/` directory replaces one of them.
+//
+//go:embed flows/*.yaml
+var builtinFlowFiles embed.FS
+
+// Flow is a guided, multi-step conversation defined as a state machine in
+// `flows/<name>.json` or `flows/<name>.yaml`, such as a bug report intake. Each
+// step sends a prompt built from the user's input and the answers of earlier
+// steps, expects a reply carrying the fields of its schema, and picks the
+// next step from those fields.
+type Flow struct {
+	Name        string     `json:"name"`                  // Unique name, also the file name.
+	Description string     `json:"description,omitempty"` // One-line summary shown in listings.
+	Steps       []FlowStep `json:"steps"`                 // The steps; the flow starts at the first.
+}
+
+// FlowStep is a state of a `Flow`.
+type FlowStep struct {
+	ID     string           `json:"id"`               // Name of the step, unique in the flow.
+	Prompt string           `json:"prompt"`           // Go text/template rendered with `FlowData` and sent to the model.
+	Schema *FieldSchema     `json:"schema,omitempty"` // Object schema of the fields the reply must carry, if any.
+	Next   []FlowTransition `json:"next,omitempty"`   // Transitions tried in order; without a match, the flow moves to the following step, or ends after the last.
+}
+
+// FlowTransition moves a flow to another step when its condition holds.
+type FlowTransition struct {
+	// If is a condition on the fields of the step's reply: "field" or
+	// "!field" for whether a field is set and not false, zero, or empty, or
+	// "field == value" or "field != value" to compare its text. An empty
+	// condition always holds.
+	If   string `json:"if,omitempty"`
+	Goto string `json:"goto"` // ID of the next step, or FlowEnd.
+}
+
+// FlowData holds the values available to the placeholders of a step's prompt.
+type FlowData struct {
+	Input   string                    // Text the user gave for this step ({{.Input}}).
+	Answers map[string]map[string]any // Fields of the replies to earlier steps, by step ID ({{.Answers.triage.severity}}).
+}
+
+// flowCondition matches the conditions of `FlowTransition.If`.
+var flowCondition = regexp.MustCompile(`^\s*(!?)\s*([A-Za-z_]\w*)\s*(?:(==|!=)\s*(.*?))?\s*$`)
+
+// flowFuncs are the functions available in step prompts besides the
+// text/template builtins.
+var flowFuncs = template.FuncMap{
+	// join joins the elements of a list field with sep.
+	"join": func(list any, sep string) string {
+		items, _ := list.([]any)
+		texts := make([]string, len(items))
+		for i, item := range items {
+			texts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(texts, sep)
+	},
+}
+
+// parseFlow decodes a flow definition, in JSON or, if yamlSyntax is set,
+// YAML, and validates it. YAML is converted to JSON first, so both accept
+// the same fields.
+func parseFlow(data []byte, yamlSyntax bool) (Flow, error) {
+	if yamlSyntax {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return Flow{}, err
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return Flow{}, err
+		}
+		data = converted
+	}
+	var flow Flow
+	if err := json.Unmarshal(data, &flow); err != nil {
+		return Flow{}, err
+	}
+	return flow, validateFlow(flow)
+}
+
+// validateFlow checks that flow has a valid name and at least one step, that
+// its step IDs are unique, its prompts parse, its schemas are well-formed
+// object schemas, and its transitions test fields of their step's schema
+// and go to steps that exist.
+func validateFlow(flow Flow) error {
+	if err := validateName("flow", flow.Name); err != nil {
+		return err
+	}
+	if len(flow.Steps) == 0 {
+		return fmt.Errorf("invalid flow %s: it has no steps", flow.Name)
+	}
+	ids := make(map[string]bool)
+	for _, step := range flow.Steps {
+		if step.ID == "" || step.ID == FlowEnd || ids[step.ID] {
+			return fmt.Errorf("invalid flow %s: step IDs must be unique and neither empty nor %q, got %q", flow.Name, FlowEnd, step.ID)
+		}
+		ids[step.ID] = true
+	}
+	for _, step := range flow.Steps {
+		invalid := func(format string, args ...any) error {
+			return fmt.Errorf("invalid flow %s: step %s: %s", flow.Name, step.ID, fmt.Sprintf(format, args...))
+		}
+		if _, err := template.New(step.ID).Funcs(flowFuncs).Parse(step.Prompt); err != nil {
+			return invalid("%v", err)
+		}
+		if step.Schema != nil {
+			if step.Schema.Type != "object" {
+				return invalid("schema type must be \"object\", not %q", step.Schema.Type)
+			}
+			if err := step.Schema.check("schema"); err != nil {
+				return invalid("%v", err)
+			}
+		}
+		for _, t := range step.Next {
+			if t.Goto != FlowEnd && !ids[t.Goto] {
+				return invalid("transition goes to unknown step %q", t.Goto)
+			}
+			if t.If == "" {
+				continue
+			}
+			m := flowCondition.FindStringSubmatch(t.If)
+			if m == nil || (m[1] != "" && m[3] != "") {
+				return invalid("malformed condition %q", t.If)
+			}
+			if step.Schema == nil || step.Schema.Properties[m[2]] == nil {
+				return invalid("condition %q tests field %q, which the step's schema does not define", t.If, m[2])
+			}
+		}
+	}
+	return nil
+}
+
+// LoadFlow loads the flow with the given name from the workspace's `flows/`
+// directory, as JSON or YAML, or else from the flows shipped with nani.
+func (w *Workspace) LoadFlow(name string) (Flow, error) {
+	if err := validateName("flow", name); err != nil {
+		return Flow{}, err
+	}
+	for _, ext := range flowExtensions {
+		data, err := w.storage.ReadFile(filepath.Join(w.RootDir, "flows", name+ext))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return Flow{}, fmt.Errorf("failed to read flow %s: %w", name, err)
+		}
+		flow, err := parseFlow(data, ext != ".json")
+		if err != nil {
+			return Flow{}, fmt.Errorf("failed to parse flow %s: %w", name, err)
+		}
+		return flow, nil
+	}
+	data, err := builtinFlowFiles.ReadFile("flows/" + name + ".yaml")
+	if err != nil {
+		return Flow{}, fmt.Errorf("%w: %s", ErrFlowNotFound, name)
+	}
+	return parseFlow(data, true)
+}
+
+// ListFlows returns the workspace's flows and those shipped with nani, sorted
+// by name. A workspace flow hides a shipped flow with the same name; flows
+// that do not load are logged and skipped.
+func (w *Workspace) ListFlows() ([]Flow, error) {
+	names := make(map[string]bool)
+	builtin, _ := builtinFlowFiles.ReadDir("flows")
+	for _, entry := range builtin {
+		names[strings.TrimSuffix(entry.Name(), ".yaml")] = true
+	}
+	entries, err := w.storage.ReadDir(filepath.Join(w.RootDir, "flows"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read flows directory: %w", err)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		for _, known := range flowExtensions {
+			if !entry.IsDir() && ext == known {
+				names[strings.TrimSuffix(entry.Name(), ext)] = true
+			}
+		}
+	}
+
+	flows := make([]Flow, 0, len(names))
+	for name := range names {
+		flow, err := w.LoadFlow(name)
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not load flow '%s': %v", name, err))
+			continue
+		}
+		flows = append(flows, flow)
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].Name < flows[j].Name })
+	return flows, nil
+}
+
+// FlowRun is a flow in progress: the step it is at and the answers of the
+// steps it went through.
+type FlowRun struct {
+	Flow    Flow
+	Step    int                       // Index of the current step in `Flow.Steps`.
+	Answers map[string]map[string]any // Fields of the replies to the steps done so far, by step ID.
+}
+
+// StartFlow loads the named flow and returns a run of it at its first step.
+func (w *Workspace) StartFlow(name string) (*FlowRun, error) {
+	flow, err := w.LoadFlow(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.logAction(fmt.Sprintf("Started flow %s", name)); err != nil {
+		return nil, err
+	}
+	return &FlowRun{Flow: flow, Answers: make(map[string]map[string]any)}, nil
+}
+
+// Current returns the step the run is at.
+func (r *FlowRun) Current() FlowStep {
+	return r.Flow.Steps[r.Step]
+}
+
+// Prompt renders the current step's prompt with input, the user's text for
+// the step, and the answers so far. The fields of steps not reached yet are
+// empty. If the step has a schema, the prompt asks for its fields as a JSON
+// code block at the end of the reply.
+func (r *FlowRun) Prompt(input string) (string, error) {
+	step := r.Current()
+	data := FlowData{Input: input, Answers: make(map[string]map[string]any)}
+	for _, s := range r.Flow.Steps {
+		fields := make(map[string]any)
+		if s.Schema != nil {
+			for name := range s.Schema.Properties {
+				fields[name] = ""
+			}
+		}
+		for name, value := range r.Answers[s.ID] {
+			fields[name] = value
+		}
+		data.Answers[s.ID] = fields
+	}
+
+	tmpl, err := template.New(step.ID).Funcs(flowFuncs).Option("missingkey=error").Parse(step.Prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse step %s of flow %s: %w", step.ID, r.Flow.Name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render step %s of flow %s: %w", step.ID, r.Flow.Name, err)
+	}
+	if step.Schema != nil {
+		schema, err := json.MarshalIndent(step.Schema, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode schema of step %s: %w", step.ID, err)
+		}
+		fmt.Fprintf(&b, "\n\nEnd your reply with a ```json code block holding an object that matches this schema:\n\n```json\n%s\n```", schema)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Advance records the fields of reply, the model's reply to the current step,
+// and moves the run to the next step. It reports whether the flow is done. If
+// the reply lacks the fields the step's schema requires, it returns an error
+// wrapping `ErrSchemaMismatch` or `ErrInvalidJSON` and stays at the step, so
+// the step can be answered again.
+func (r *FlowRun) Advance(reply string) (bool, error) {
+	step := r.Current()
+	var fields map[string]any
+	if step.Schema != nil {
+		raw := strings.TrimSpace(reply)
+		for _, block := range CodeBlocks(reply) {
+			if block.Lang == "json" {
+				raw = block.Code // The last one wins.
+			}
+		}
+		var err error
+		if fields, err = roleFields([]byte(raw), step.Schema); err != nil {
+			return false, fmt.Errorf("reply to step %s of flow %s: %w", step.ID, r.Flow.Name, err)
+		}
+	}
+	r.Answers[step.ID] = fields
+
+	next := ""
+	for _, t := range step.Next {
+		if flowConditionHolds(t.If, fields) {
+			next = t.Goto
+			break
+		}
+	}
+	switch {
+	case next == FlowEnd, next == "" && r.Step == len(r.Flow.Steps)-1:
+		return true, nil
+	case next == "":
+		r.Step++
+		return false, nil
+	}
+	for i, s := range r.Flow.Steps {
+		if s.ID == next {
+			r.Step = i
+		}
+	}
+	return false, nil
+}
+
+// flowConditionHolds evaluates cond, a condition of `FlowTransition.If`
+// already checked by `validateFlow`, on fields.
+func flowConditionHolds(cond string, fields map[string]any) bool {
+	if cond == "" {
+		return true
+	}
+	m := flowCondition.FindStringSubmatch(cond)
+	value, ok := fields[m[2]]
+	switch m[3] {
+	case "==":
+		return ok && fmt.Sprint(value) == m[4]
+	case "!=":
+		return !ok || fmt.Sprint(value) != m[4]
+	}
+	set := ok && value != nil && value != false && value != "" && value != 0.0
+	if items, isList := value.([]any); isList {
+		set = len(items) > 0
+	}
+	return set != (m[1] == "!")
+}
@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFlow(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // File written to flows/, if any.
+		data    string
+		flow    string
+		first   string // ID of the flow's first step.
+		wantErr string
+	}{
+		{name: "builtin", flow: "bug-report", first: "report"},
+		{
+			name:  "workspace json",
+			file:  "intake.json",
+			data:  `{"name": "intake", "steps": [{"id": "ask", "prompt": "{{.Input}}"}]}`,
+			flow:  "intake",
+			first: "ask",
+		},
+		{
+			name:  "workspace yaml overrides builtin",
+			file:  "bug-report.yml",
+			data:  "name: bug-report\nsteps:\n  - id: only\n    prompt: Report {{.Input}}\n",
+			flow:  "bug-report",
+			first: "only",
+		},
+		{name: "unknown", flow: "nope", wantErr: "flow not found"},
+		{
+			name:    "unknown goto",
+			file:    "bad.yaml",
+			data:    "name: bad\nsteps:\n  - id: a\n    prompt: x\n    next: [{goto: b}]\n",
+			flow:    "bad",
+			wantErr: `unknown step "b"`,
+		},
+		{
+			name:    "condition on undeclared field",
+			file:    "bad.yaml",
+			data:    "name: bad\nsteps:\n  - id: a\n    prompt: x\n    schema: {type: object, properties: {ok: {type: boolean}}}\n    next: [{if: done, goto: end}]\n",
+			flow:    "bad",
+			wantErr: `field "done"`,
+		},
+		{
+			name:    "bad prompt",
+			file:    "bad.yaml",
+			data:    "name: bad\nsteps:\n  - id: a\n    prompt: '{{.Input'\n",
+			flow:    "bad",
+			wantErr: "step a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkspace(t)
+			if tt.file != "" {
+				dir := filepath.Join(w.RootDir, "flows")
+				if err := w.storage.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.storage.WriteFile(filepath.Join(dir, tt.file), []byte(tt.data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			flow, err := w.LoadFlow(tt.flow)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadFlow() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flow.Name != tt.flow || flow.Steps[0].ID != tt.first {
+				t.Errorf("LoadFlow() = %+v, want flow %s starting at %s", flow, tt.flow, tt.first)
+			}
+		})
+	}
+}
+
+func TestListFlows(t *testing.T) {
+	w := newTestWorkspace(t)
+	flows, err := w.ListFlows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range flows {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "architecture-review,bug-report,design-doc" {
+		t.Errorf("ListFlows() = %s, want the built-in flows", got)
+	}
+}
+
+func TestFlowRun(t *testing.T) {
+	w := newTestWorkspace(t)
+	run, err := w.StartFlow("design-doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		input     string
+		reply     string
+		wantStep  string // Step the run is at after the reply.
+		wantDone  bool
+		wantErr   error
+		wantInput string // Text the step's prompt must contain.
+	}{
+		{input: "Offline mode", reply: "What about sync?", wantStep: "goal", wantErr: ErrInvalidJSON, wantInput: "Offline mode"},
+		{input: "Offline mode", reply: "Unclear.\n```json\n{\"problem\": \"no network\", \"clear\": false}\n```", wantStep: "goal"},
+		{input: "For field staff", reply: "```json\n{\"problem\": \"staff lose work offline\", \"clear\": true}\n```", wantStep: "requirements"},
+		{input: "Must sync later", reply: "```json\n{\"requirements\": [\"queue edits\", \"sync later\"], \"done\": true}\n```", wantStep: "draft", wantInput: "staff lose work offline"},
+		{input: "", reply: "# Design", wantDone: true, wantInput: "queue edits; sync later"},
+	}
+	for i, s := range steps {
+		prompt, err := run.Prompt(s.input)
+		if err != nil {
+			t.Fatalf("step %d: Prompt() = %v", i, err)
+		}
+		if !strings.Contains(prompt, s.wantInput) {
+			t.Errorf("step %d: Prompt() = %q, want it to contain %q", i, prompt, s.wantInput)
+		}
+		if schema := run.Current().Schema != nil; schema != strings.Contains(prompt, "```json") {
+			t.Errorf("step %d: Prompt() asks for JSON = %v, want %v", i, !schema, schema)
+		}
+		done, err := run.Advance(s.reply)
+		if s.wantErr != nil {
+			if !errors.Is(err, s.wantErr) {
+				t.Fatalf("step %d: Advance() = %v, want %v", i, err, s.wantErr)
+			}
+		} else if err != nil {
+			t.Fatalf("step %d: Advance() = %v", i, err)
+		}
+		if done != s.wantDone {
+			t.Fatalf("step %d: Advance() done = %v, want %v", i, done, s.wantDone)
+		}
+		if !done && run.Current().ID != s.wantStep {
+			t.Errorf("step %d: at step %s, want %s", i, run.Current().ID, s.wantStep)
+		}
+	}
+}
+
+func TestFlowConditionHolds(t *testing.T) {
+	fields := map[string]any{"ok": true, "no": false, "list": []any{}, "risk": "low", "n": 0.0}
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"", true},
+		{"ok", true},
+		{"!ok", false},
+		{"no", false},
+		{"!no", true},
+		{"list", false},
+		{"n", false},
+		{"missing", false},
+		{"risk == low", true},
+		{"risk != low", false},
+		{"risk == high", false},
+	}
+	for _, tt := range tests {
+		if got := flowConditionHolds(tt.cond, fields); got != tt.want {
+			t.Errorf("flowConditionHolds(%q) = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// contextFlushDelay is how long a change to the context may wait before it is
+// written to `context.json`, so bursts of index updates cost a single write.
+const contextFlushDelay = time.Second
+
+// persistState coordinates the deferred writes of `context.json` with the
+// other writes of a workspace. It is shared by copies of the workspace made
+// with `WithScope`, which write the same files.
+type persistState struct {
+	mu           sync.Mutex  // Guards pending, pendingLocal, and timer.
+	pending      []byte      // Encoded context not yet written; nil when `context.json` is current.
+	pendingLocal []byte      // Encoded machine-local settings written to `local.json` with pending.
+	timer        *time.Timer // Background flush scheduled for pending, if any.
+
+	flushMu       sync.Mutex        // Serializes flushes so an older context never overwrites a newer one.
+	manifestMu    sync.Mutex        // Guards manifest and manifestDirty, which background flushes also update.
+	manifest      map[string]string // Integrity manifest of artifact hashes, loaded lazily (see manifest.go).
+	manifestDirty bool              // Whether manifest has changes not yet written to `manifest.json`.
+}
+
+// Flush writes pending context changes to `context.json` and `local.json`, and
+// the integrity manifest to `manifest.json`, now. Callers should flush before
+// the process exits and before other processes need to read the context. A
+// failed write stays pending, so a later flush retries it.
+func (w *Workspace) Flush() error {
+	p := w.persist
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+
+	p.mu.Lock()
+	data, local := p.pending, p.pendingLocal
+	p.pending, p.pendingLocal = nil, nil
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+	if data == nil {
+		return w.flushManifest()
+	}
+
+	// Local settings are written first, so that a context without them is
+	// never on disk while `local.json` still lacks them.
+	err := w.writeFile(filepath.Join(w.RootDir, "local.json"), local)
+	if err == nil {
+		err = w.writeFile(filepath.Join(w.RootDir, "context.json"), data)
+	}
+	if err != nil {
+		p.mu.Lock()
+		if p.pending == nil {
+			p.pending, p.pendingLocal = data, local
+		}
+		p.mu.Unlock()
+		return fmt.Errorf("failed to save context: %w", err)
+	}
+	return w.flushManifest()
+}
+
+// scheduleFlush makes sure a background `Flush` runs within
+// `contextFlushDelay`. p.mu must be held.
+func (w *Workspace) scheduleFlush() {
+	p := w.persist
+	if p.timer == nil {
+		p.timer = time.AfterFunc(contextFlushDelay, func() {
+			if err := w.Flush(); err != nil {
+				w.logAction(fmt.Sprintf("Warning: Background context save failed: %v", err))
+			}
+		})
+	}
+}
+
+// discardContextChanges drops pending context changes, for when the context
+// on disk is about to be replaced.
+func (w *Workspace) discardContextChanges() {
+	p := w.persist
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending, p.pendingLocal = nil, nil
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
@@ -4,83 +4,198 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"google.golang.org/genai"
 )
 
+// defaultModel is the Gemini model used when neither the role nor the route of a
+// request names one (see `ModelsFor`).
+const defaultModel = "gemini-2.5-flash-preview-05-20"
+
+// defaultPreferenceBudget caps the characters of user preferences injected into
+// the system instruction when `Settings.PreferenceBudget` is not set.
+const defaultPreferenceBudget = 4000
+
 type GeminiAIClient struct {
-	client *genai.Client
-	chat  *genai.Chat
-	workspace *Workspace
+	client     *genai.Client
+	chat       *genai.Chat
+	workspace  *Workspace
+	model      string                       // Model that produced the current chat's latest reply, or its first choice.
+	roleModel  string                       // Model of the session's role, tried first on every chat route (see `ModelsFor`).
+	config     *genai.GenerateContentConfig // Generation config used by the current chat.
+	schema     *FieldSchema                 // Schema of the role-specific fields of the current chat's replies.
+	pipeline   []string                     // Post-processing of the current chat's replies (see `Role.PostProcess`).
+	role       Role                         // Role of the current chat, whose instructions are rebuilt as the repo map changes.
+	partial    *partialReply                // Latest reply, if it was cut off (see partial.go).
+	candidates *candidateReplies            // Candidates of the latest reply, if there were several (see candidates.go).
+	audit      *auditTransport              // Records provider requests when `Settings.Audit` is on (see audit.go).
 }
 
 func NewGeminiAIClient(apiKey string, workspace *Workspace) (*GeminiAIClient, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+	return newGenAIClient(&genai.ClientConfig{
 		APIKey:  apiKey,
 		Backend: genai.BackendGeminiAPI,
-	})
+	}, http.DefaultTransport, workspace)
+}
+
+// newGenAIClient creates a client for config whose requests pass through the
+// audit log before reaching base.
+func newGenAIClient(config *genai.ClientConfig, base http.RoundTripper, workspace *Workspace) (*GeminiAIClient, error) {
+	audit := &auditTransport{base: base}
+	audit.workspace.Store(workspace)
+	config.HTTPClient = &http.Client{Transport: audit}
+	client, err := genai.NewClient(context.Background(), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
 	}
 
 	return &GeminiAIClient{
-		client: client,
+		client:    client,
 		workspace: workspace,
+		audit:     audit,
 	}, nil
 }
 
+// SetWorkspace points the client at another workspace. The current chat is
+// dropped; call StartSession or OpenSession to open the new workspace's session.
+func (g *GeminiAIClient) SetWorkspace(workspace *Workspace) {
+	g.workspace = workspace
+	g.audit.workspace.Store(workspace)
+	g.chat, g.candidates = nil, nil
+}
+
+// SetTransport sends the client's provider requests through transport, such
+// as a `Cassette`, instead of straight to the network. Requests still pass
+// through the audit log, and a recording cassette sends them on through the
+// client's own transport, which authenticates them on Vertex AI. Call it
+// before the client is first used.
+func (g *GeminiAIClient) SetTransport(transport http.RoundTripper) {
+	if c, ok := transport.(*Cassette); ok && c.mode == CassetteRecord {
+		c.base = g.audit.base
+	}
+	g.audit.base = transport
+}
+
+// StartSession opens the chat for the active session, creating one if needed,
+// and returns the model's greeting.
 func (g *GeminiAIClient) StartSession(ctx context.Context) (Response, error) {
+	session, err := g.OpenSession(ctx)
+	if err != nil {
+		return Response{}, err
+	}
+
+	message := "Greetings"
+	if len(session.Chat) > 0 {
+		message = "The session has been resumed. Greet me again, acknowledging the conversation so far."
+	}
+
+	return g.SendMessage(ctx, SavedMessage{Content: message}, nil, false)
+}
+
+// OpenSession prepares the chat for the active session, creating one if
+// needed, without sending anything to the model. It returns the session.
+func (g *GeminiAIClient) OpenSession(ctx context.Context) (*Session, error) {
 	workspace := g.workspace
-	session, err := workspace.GetSession("Session", "")
+	session, err := workspace.GetSession(ctx, defaultSessionLabel, "")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to start a session: %w", err)
+	}
 
+	instructions, err := workspace.roleInstructions(session.Role)
 	if err != nil {
-		return Response{}, fmt.Errorf("failed to start a session: %w", err)
+		return nil, err
 	}
 
-	instructions := fmt.Sprintf("%s\n%s", session.Role.Persona, workspace.Context.Settings.SystemPrompt)
-	responseSchema := &genai.Schema{
-		Type: genai.TypeObject,
-		Properties: map[string]*genai.Schema{
-			"think":   {Type: genai.TypeString},
-			"summary": {Type: genai.TypeString},
-			"content": {Type: genai.TypeString},
-		},
-		Required: []string{"think", "summary", "content"},
+	if err := validateRoleSchema(session.Role.Schema); err != nil {
+		return nil, fmt.Errorf("role %s: %w", session.Role.Name, err)
+	}
+	if err := validateRoutes(workspace.Context.Settings.Routes); err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
 	}
 
 	genConfig := &genai.GenerateContentConfig{
-		ResponseMIMEType: "application/json",
-		ResponseSchema:   responseSchema,
+		ResponseMIMEType:  "application/json",
+		ResponseSchema:    responseSchema(session.Role),
 		SystemInstruction: genai.NewContentFromText(instructions, genai.Role(session.Role.Name)),
+		Temperature:       session.Role.Parameters.Temperature,
+		TopP:              session.Role.Parameters.TopP,
+		CandidateCount:    session.Role.Parameters.CandidateCount,
 	}
 
-	g.chat, err = g.client.Chats.Create(ctx, "gemini-2.5-flash-preview-05-20", genConfig, nil)
+	if _, err := g.compact(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to compact session history: %w", err)
+	}
+
+	g.roleModel = session.Role.Parameters.Model
+	g.model, g.config, g.schema = workspace.ModelsFor(TaskChat, g.roleModel)[0], genConfig, session.Role.Schema
+	g.pipeline, g.role = session.Role.PostProcess, session.Role
+	g.chat, err = g.client.Chats.Create(ctx, g.model, genConfig, chatHistory(session))
 	if err != nil {
-		return Response{}, fmt.Errorf("failed to start a chat: %w", err)
+		return nil, fmt.Errorf("failed to start a chat: %w", providerError(err))
 	}
-	var message strings.Builder
-	if len(session.Chat) > 0 {
-		message.WriteString("**Chat Context**: \n")
-		for _, v := range session.Chat {
-			message.WriteString(fmt.Sprintf("[user-message]: %s \n [agent-response]: %s", v.Message.Content, v.Response.Content))
+
+	return session, nil
+}
+
+// chatHistory converts persisted interactions into alternating user/model turns
+// so a resumed chat carries the real conversation structure. Only response
+// summaries are persisted, so model turns contain the summary of each reply.
+// Turns covered by the session's compaction are replaced by its summary,
+// followed by the session's context pins, which are replayed however much of
+// the history is compacted. Attachments are not re-sent; user turns only note the attached file names.
+func chatHistory(session *Session) []*genai.Content {
+	chats := session.Chat
+	history := make([]*genai.Content, 0, len(chats)*2+4)
+	if session.Compaction != nil {
+		history = append(history,
+			genai.NewContentFromText("Summary of our earlier conversation: "+session.Compaction.Summary, genai.RoleUser),
+			genai.NewContentFromText("Understood. I will continue from that context.", genai.RoleModel),
+		)
+		chats = chats[session.Compaction.Turns:]
+	}
+	if pinned := pinnedContext(session.Pins); pinned != "" {
+		history = append(history,
+			genai.NewContentFromText(pinned, genai.RoleUser),
+			genai.NewContentFromText("Understood. I will keep it in mind.", genai.RoleModel),
+		)
+	}
+	for _, c := range chats {
+		message := c.Message.Content
+		if len(c.Message.Attachments) > 0 {
+			message += fmt.Sprintf("\n[attached: %s]", strings.Join(attachmentNames(c.Message.Attachments), ", "))
 		}
-	} else {
-		message.WriteString("Greetings")
+		history = append(history,
+			genai.NewContentFromText(message, genai.RoleUser),
+			genai.NewContentFromText(c.Response.Content, genai.RoleModel),
+		)
 	}
-
-	return g.SendMessage(ctx, message.String(), nil, false)
+	return history
 }
 
-func (g *GeminiAIClient) SendMessage(ctx context.Context, message string, history []Message, save bool) (Response, error) {
+// SendMessage sends message, together with any media attachments, to the live chat.
+// When save is true the exchange is recorded in the active session, including
+// the chat entry the message replies to, if any.
+func (g *GeminiAIClient) SendMessage(ctx context.Context, message SavedMessage, history []Message, save bool) (Response, error) {
 	if g.chat == nil {
 		return Response{}, errors.New("chat session not started. Call StartSession first.")
 	}
+	g.partial, g.candidates = nil, nil
+	message, parts, secrets, err := g.messageParts(ctx, message)
+	if err != nil {
+		return Response{}, err
+	}
+	contents := append(append([]*genai.Content{}, g.chat.History(false)...), inputContent(parts))
 
-	resp, err := g.chat.SendMessage(ctx, genai.Part{
-		Text: message,
-	})
+	started := time.Now()
+	resp, model, err := g.generate(ctx, g.chatModels(parts), contents, g.config)
+	latency := time.Since(started)
 
 	if err != nil {
 		return Response{}, fmt.Errorf("failed to get response from Gemini: %w", err)
@@ -89,6 +204,11 @@ func (g *GeminiAIClient) SendMessage(ctx context.Context, message string, histor
 	if resp.Candidates == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
 		return Response{}, errors.New("no response content received from Gemini model")
 	}
+	g.model = model
+	turns := append(contents, resp.Candidates[0].Content)
+	if g.chat, err = g.client.Chats.Create(ctx, g.model, g.config, turns); err != nil {
+		return Response{}, fmt.Errorf("failed to continue the chat: %w", err)
+	}
 
 	var responseText strings.Builder
 	for _, part := range resp.Candidates[0].Content.Parts {
@@ -96,16 +216,253 @@ func (g *GeminiAIClient) SendMessage(ctx context.Context, message string, histor
 			responseText.WriteString(part.Text)
 		}
 	}
+	if truncated(resp) {
+		return g.cutOff(&partialReply{message: message, contents: contents, raw: responseText.String(), save: save, secrets: secrets}, resp, nil)
+	}
+	response, err := g.finishResponse(ctx, message, responseText.String(), resp, latency, save)
+	if err == nil {
+		response = g.keepCandidates(response, contents, resp)
+	}
+	response.Secrets = secrets
+	return response, err
+}
 
-	rawAIResponse := responseText.String()
-	respStruct, err := parseAIResponse(rawAIResponse)
+// StreamMessage is like SendMessage, but calls onChunk with each piece of the
+// model's raw output as it is generated. The pieces are fragments of the
+// structured JSON reply; the parsed reply is returned once it is complete.
+func (g *GeminiAIClient) StreamMessage(ctx context.Context, message SavedMessage, save bool, onChunk func(text string)) (Response, error) {
+	if g.chat == nil {
+		return Response{}, errors.New("chat session not started. Call StartSession first.")
+	}
+	if g.config.CandidateCount > 1 {
+		// The pieces of several candidates would be interleaved.
+		return g.SendMessage(ctx, message, nil, save)
+	}
+	g.partial, g.candidates = nil, nil
+	message, parts, secrets, err := g.messageParts(ctx, message)
 	if err != nil {
-		return Response{}, fmt.Errorf("failed to parse AI response into structured format: %w", err)
+		return Response{}, err
+	}
+	contents := append(append([]*genai.Content{}, g.chat.History(false)...), inputContent(parts))
+
+	started := time.Now()
+	var (
+		responseText string
+		last         *genai.GenerateContentResponse
+	)
+	models := g.chatModels(parts)
+	for i, model := range models {
+		responseText, last, err = g.stream(ctx, model, contents, onChunk)
+		g.model = model
+		// Only fall back while nothing was received, as chunks were already passed on.
+		if err == nil || responseText != "" || i == len(models)-1 || !errors.Is(providerError(err), ErrProviderUnavailable) {
+			break
+		}
+		g.workspace.logAction(fmt.Sprintf("Warning: Model %s unavailable, falling back to %s: %v", model, models[i+1], providerError(err)))
+	}
+	latency := time.Since(started)
+	if responseText != "" && (err != nil || truncated(last)) {
+		return g.cutOff(&partialReply{message: message, contents: contents, raw: responseText, save: save, secrets: secrets}, last, err)
+	} else if err != nil {
+		return Response{}, fmt.Errorf("failed to get response from Gemini: %w", providerError(err))
+	}
+
+	if last == nil || responseText == "" {
+		return Response{}, errors.New("no response content received from Gemini model")
 	}
 
-	if _, err := g.workspace.GetActiveSession(); err == nil && save {
-		g.workspace.AddInteraction(message, respStruct.Summary)
+	// Continue the chat from the merged reply rather than one turn per chunk.
+	history := append(contents, genai.NewContentFromText(responseText, genai.RoleModel))
+	if g.chat, err = g.client.Chats.Create(ctx, g.model, g.config, history); err != nil {
+		return Response{}, fmt.Errorf("failed to continue the chat: %w", err)
+	}
+	response, err := g.finishResponse(ctx, message, responseText, last, latency, save)
+	response.Secrets = secrets
+	return response, err
+}
+
+// stream generates a reply to contents with model, calling onChunk, if not
+// nil, with each piece of raw output. It returns the output and the final
+// response received, even when the stream fails part way.
+func (g *GeminiAIClient) stream(ctx context.Context, model string, contents []*genai.Content, onChunk func(text string)) (string, *genai.GenerateContentResponse, error) {
+	var (
+		responseText strings.Builder
+		last         *genai.GenerateContentResponse
+	)
+	for chunk, err := range g.client.Models.GenerateContentStream(ctx, model, contents, g.config) {
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return responseText.String(), last, err
+		}
+		last = chunk
+		if len(chunk.Candidates) == 0 || chunk.Candidates[0].Content == nil {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text != "" {
+				responseText.WriteString(part.Text)
+				if onChunk != nil {
+					onChunk(part.Text)
+				}
+			}
+		}
+	}
+	return responseText.String(), last, nil
+}
+
+// chatModels returns the models to try for a chat message made of parts,
+// routed by the size of its text (see `chatTask`).
+func (g *GeminiAIClient) chatModels(parts []genai.Part) []string {
+	size := 0
+	for _, p := range parts {
+		size += len(p.Text)
+	}
+	return g.workspace.ModelsFor(g.workspace.chatTask(size), g.roleModel)
+}
+
+// inputContent combines the request parts of a message into a user turn.
+func inputContent(parts []genai.Part) *genai.Content {
+	input := &genai.Content{Role: genai.RoleUser}
+	for i := range parts {
+		input.Parts = append(input.Parts, &parts[i])
+	}
+	return input
+}
+
+// messageParts builds the request parts for message: its text, prefixed with
+// semantically retrieved context when enabled, followed by its attachments.
+// The text and context are first passed through `Workspace.Redact`; the
+// message is returned with its text as sent, so that masked secrets are not
+// saved in the session either. The system instruction is refreshed first, so
+// sent and streamed messages see the current repo map alike.
+func (g *GeminiAIClient) messageParts(ctx context.Context, message SavedMessage) (SavedMessage, []genai.Part, []SecretFinding, error) {
+	g.refreshInstructions()
+	content, secrets, err := g.workspace.Redact("prompt", message.Content)
+	if err != nil {
+		return message, nil, nil, err
+	}
+	message.Content = content
+
+	prompt, found, err := g.workspace.MessagePrompt(ctx, message.Content)
+	if err != nil {
+		return message, nil, nil, err
+	}
+	secrets = append(secrets, found...)
+
+	parts := []genai.Part{{Text: prompt.String()}}
+	for _, a := range message.Attachments {
+		data, err := os.ReadFile(a.Path)
+		if err != nil {
+			return message, nil, nil, fmt.Errorf("failed to read attachment %s: %w", a.Path, err)
+		}
+		parts = append(parts, *genai.NewPartFromBytes(data, a.MIMEType))
+	}
+	return message, parts, secrets, nil
+}
+
+// finishResponse parses the raw model output for message, fills in the
+// metadata of resp (the final response of a stream), and records the exchange
+// in the active session when save is true. Output that cannot be parsed is
+// sent back for correction (see `reformat`).
+func (g *GeminiAIClient) finishResponse(ctx context.Context, message SavedMessage, rawAIResponse string, resp *genai.GenerateContentResponse, latency time.Duration, save bool) (Response, error) {
+	usage := geminiUsage(resp.UsageMetadata)
+	respStruct, err := parseAIResponse(rawAIResponse, g.schema)
+	g.workspace.recordParse(respStruct.Repairs, err)
+	if err != nil {
+		started := time.Now()
+		if respStruct, resp, usage, err = g.reformat(ctx, rawAIResponse, err, resp, usage); err != nil {
+			return Response{}, err
+		}
+		latency += time.Since(started)
+	}
+	respStruct = g.workspace.PostProcess(g.pipeline, respStruct)
+	respStruct.Usage = usage
+	respStruct.Latency = latency
+	if len(resp.Candidates) > 0 {
+		respStruct.FinishReason = string(resp.Candidates[0].FinishReason)
+	}
+	respStruct.Provider = ProviderGemini
+	respStruct.Model = g.model
+	if resp.ModelVersion != "" {
+		respStruct.Model = resp.ModelVersion
+	}
+
+	if session, err := g.workspace.GetActiveSession(); err == nil && session != nil && save {
+		chat, err := g.workspace.AddInteraction(message, respStruct.saved())
+		if err == nil {
+			respStruct.ChatID = chat.ID
+		}
+		g.workspace.rememberFacts(respStruct.Remember)
+		g.compactLive(ctx)
+		g.titleLive(ctx)
 	}
 
 	return respStruct, nil
 }
+
+// preferencesPrompt renders preferences, ordered oldest to newest, as a section
+// of the system instruction. When their combined size exceeds budget characters,
+// the oldest preferences are dropped first so the most recent instructions win.
+func preferencesPrompt(preferences []Preference, budget int) string {
+	start := len(preferences)
+	used := 0
+	for i := len(preferences) - 1; i >= 0; i-- {
+		size := len(preferences[i].Content)
+		if used+size > budget {
+			break
+		}
+		used += size
+		start = i
+	}
+	if start == len(preferences) {
+		return ""
+	}
+
+	var section strings.Builder
+	section.WriteString("**User Preferences**:\n")
+	for _, p := range preferences[start:] {
+		section.WriteString(fmt.Sprintf("- %s\n", p.Content))
+	}
+	return section.String()
+}
+
+// geminiUsage converts Gemini usage metadata into a provider-agnostic Usage.
+// Reasoning ("thoughts") tokens are billed as output and so count as completion tokens.
+func geminiUsage(md *genai.GenerateContentResponseUsageMetadata) *Usage {
+	if md == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     int(md.PromptTokenCount),
+		CompletionTokens: int(md.CandidatesTokenCount + md.ThoughtsTokenCount),
+		TotalTokens:      int(md.TotalTokenCount),
+		CachedTokens:     int(md.CachedContentTokenCount),
+	}
+}
+
+// providerError maps Gemini API and transport failures onto the package's
+// sentinel errors, so callers can tell outages, exhausted quotas, rejected
+// credentials, and oversized requests apart without parsing provider-specific
+// messages. Other errors are returned as is.
+func providerError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %w: %w", ErrProviderUnavailable, ErrQuotaExceeded, err)
+	case apiErr.Code >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	case apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden:
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	case apiErr.Code == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "token"):
+		return fmt.Errorf("%w: %w", ErrContextTooLarge, err)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultGlobalDir returns the location of the user-level workspace whose roles
+// and preferences are shared by every project: `$XDG_CONFIG_HOME/nani/global`,
+// or `~/.config/nani/global` when XDG_CONFIG_HOME is not set. It returns an
+// empty string if the home directory cannot be determined.
+func DefaultGlobalDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "nani", "global")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "nani", "global")
+}
+
+// SetGlobalDir changes the user-level workspace directory. An empty dir disables
+// global roles and preferences, leaving only the project's own.
+func (w *Workspace) SetGlobalDir(dir string) {
+	w.globalDir = dir
+}
+
+// globalArtifacts reads every JSON file in the given subdirectory of the global
+// workspace and decodes it with decode. Unreadable files are logged and skipped.
+func (w *Workspace) globalArtifacts(subdir string, decode func(data []byte) error) {
+	if w.globalDir == "" {
+		return
+	}
+	dir := filepath.Join(w.globalDir, subdir)
+	files, err := w.storage.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			w.logAction(fmt.Sprintf("Warning: Could not read global %s directory '%s': %v", subdir, dir, err))
+		}
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		data, err := w.storage.ReadFile(path)
+		if err == nil {
+			err = decode(data)
+		}
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not load global artifact '%s': %v", path, err))
+		}
+	}
+}
+
+// globalRoles returns summaries of the global roles not overridden by a
+// project role of the same name.
+func (w *Workspace) globalRoles() []RoleSummary {
+	var roles []RoleSummary
+	w.globalArtifacts("roles", func(data []byte) error {
+		var r Role
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		if _, overridden := w.Context.Indexes.RolesIndex[r.Name]; !overridden && r.Name != "" {
+			roles = append(roles, RoleSummary{Name: r.Name, Label: r.Label, Description: r.Description, Global: true})
+		}
+		return nil
+	})
+	return roles
+}
+
+// globalPreferences returns summaries of the global preferences not overridden
+// by a project preference with the same ID.
+func (w *Workspace) globalPreferences() []PreferenceSummary {
+	var prefs []PreferenceSummary
+	w.globalArtifacts("preferences", func(data []byte) error {
+		var p Preference
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		if _, overridden := w.Context.Indexes.PreferencesIndex[p.ID]; !overridden && p.ID != "" {
+			snippet := p.Content
+			if len(snippet) > 100 {
+				snippet = snippet[:100] + "..."
+			}
+			prefs = append(prefs, PreferenceSummary{ID: p.ID, Timestamp: p.Timestamp, ContentSnippet: snippet, Global: true})
+		}
+		return nil
+	})
+	return prefs
+}
+
+// roleExists reports whether a role is available in the project or globally.
+func (w *Workspace) roleExists(name string) bool {
+	if _, ok := w.Context.Indexes.RolesIndex[name]; ok {
+		return true
+	}
+	for _, r := range w.globalRoles() {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readArtifact reads `<subdir>/<name>.json` from the project workspace, falling
+// back to the global workspace when the project has no such file.
+func (w *Workspace) readArtifact(subdir, name string) ([]byte, error) {
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, subdir, fmt.Sprintf("%s.json", name)))
+	if os.IsNotExist(err) && w.globalDir != "" {
+		data, err = w.storage.ReadFile(filepath.Join(w.globalDir, subdir, fmt.Sprintf("%s.json", name)))
+	}
+	return data, err
+}
+
+// SaveGlobalRole writes a role to the global workspace, making it available to
+// every project that does not define a role with the same name. Global files
+// live outside the project and are not tracked by its integrity manifest.
+func (w *Workspace) SaveGlobalRole(role Role) error {
+	if w.globalDir == "" {
+		return fmt.Errorf("no global workspace directory configured")
+	}
+	dir := filepath.Join(w.globalDir, "roles")
+	if err := w.storage.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create global roles directory: %w", err)
+	}
+	data, err := json.MarshalIndent(role, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode global role %s: %w", role.Name, err)
+	}
+	if err := w.storage.WriteFile(filepath.Join(dir, fmt.Sprintf("%s.json", role.Name)), data, 0644); err != nil {
+		return fmt.Errorf("failed to save global role %s: %w", role.Name, err)
+	}
+	return w.logAction(fmt.Sprintf("Saved global role %s", role.Name))
+}
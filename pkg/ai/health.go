@@ -0,0 +1,313 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Health issue kinds reported by `CheckHealth`.
+const (
+	IssuePermissions = "permissions"  // A file or directory its owner cannot read or write.
+	IssueCorrupt     = "corrupt"      // An artifact that is not valid JSON.
+	IssueOrphaned    = "orphaned"     // An index entry for an artifact that no longer exists.
+	IssueUnindexed   = "unindexed"    // An artifact missing from its index.
+	IssueMissingRole = "missing-role" // A session whose role has no role file.
+)
+
+// HealthIssue is a problem with the workspace found by `CheckHealth`.
+type HealthIssue struct {
+	Kind   string `json:"kind"`             // One of IssuePermissions, IssueCorrupt, IssueOrphaned, IssueUnindexed, or IssueMissingRole.
+	Path   string `json:"path"`             // Affected file, relative to the workspace root, using forward slashes.
+	Detail string `json:"detail"`           // Description of the problem.
+	Repair string `json:"repair,omitempty"` // What `RepairHealth` does about it; empty if it must be fixed by hand.
+
+	drift *Issue // Index drift behind the issue, repaired with `Repair`.
+}
+
+// CheckHealth looks for problems that keep the workspace from working as
+// expected: files with unusable permissions, artifacts that are not valid
+// JSON, index entries (including semantic search chunks) whose artifact is
+// gone, and sessions using a role that no longer exists. Unlike
+// `VerifyManifest`, it does not report edits made outside nani.
+func (w *Workspace) CheckHealth(ctx context.Context) ([]HealthIssue, error) {
+	var issues []HealthIssue
+	for _, check := range []func(context.Context) ([]HealthIssue, error){w.checkPermissions, w.checkCorrupt, w.checkOrphaned, w.checkSessionRoles} {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		found, err := check(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(found, func(i, j int) bool {
+			if found[i].Path != found[j].Path {
+				return found[i].Path < found[j].Path
+			}
+			return found[i].Detail < found[j].Detail
+		})
+		issues = append(issues, found...)
+	}
+	return issues, nil
+}
+
+// checkPermissions reports files its owner cannot read and write, and
+// directories its owner cannot list and write to. Workspaces kept outside the
+// operating system's file system have no permissions to check.
+func (w *Workspace) checkPermissions(ctx context.Context) ([]HealthIssue, error) {
+	if _, ok := w.storage.(OSStorage); !ok {
+		return nil, nil
+	}
+	var issues []HealthIssue
+	err := filepath.WalkDir(w.RootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsPermission(err) {
+				issues = append(issues, HealthIssue{Kind: IssuePermissions, Path: w.relPath(path), Detail: "cannot be read", Repair: "grant the owner read and write access"})
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			// Repositories older versions of `Sync` created in the workspace keep
+			// their objects read-only.
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		want := os.FileMode(0600)
+		if d.IsDir() {
+			want = 0700
+		}
+		if info.Mode().Perm()&want != want {
+			issues = append(issues, HealthIssue{Kind: IssuePermissions, Path: w.relPath(path), Detail: fmt.Sprintf("mode %s lacks owner access", info.Mode().Perm()), Repair: "grant the owner read and write access"})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check workspace permissions: %w", err)
+	}
+	return issues, nil
+}
+
+// checkCorrupt reports artifacts, the integrity manifest, and the vector
+// store that do not contain valid JSON.
+func (w *Workspace) checkCorrupt(ctx context.Context) ([]HealthIssue, error) {
+	paths, err := w.trackedArtifacts()
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, "manifest.json", w.relPath(w.vectorIndexPath()))
+
+	var issues []HealthIssue
+	for _, rel := range paths {
+		data, err := w.storage.ReadFile(filepath.Join(w.RootDir, filepath.FromSlash(rel)))
+		if err != nil {
+			// Missing optional files need no repair, and unreadable ones are
+			// reported by checkPermissions.
+			continue
+		}
+		if json.Valid(data) {
+			continue
+		}
+		issue := HealthIssue{Kind: IssueCorrupt, Path: rel, Detail: "not valid JSON", Repair: fmt.Sprintf("move it aside to %s.corrupt", rel)}
+		switch {
+		case rel == "manifest.json":
+			issue.Repair = "rebuild the manifest from the current files"
+		case rel == w.relPath(w.vectorIndexPath()):
+			issue.Repair = "delete it; run `nani index` to rebuild it"
+		case w.hasValidBackup(rel):
+			issue.Repair = "restore it from backup"
+		case rel == "context.json":
+			issue.Repair = ""
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// hasValidBackup reports whether the backup of the artifact at rel is valid JSON.
+func (w *Workspace) hasValidBackup(rel string) bool {
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, "backups", filepath.FromSlash(rel)))
+	return err == nil && json.Valid(data)
+}
+
+// checkOrphaned reports the index drift found by `Verify`, and chunks of the
+// vector store whose artifact no longer exists.
+func (w *Workspace) checkOrphaned(ctx context.Context) ([]HealthIssue, error) {
+	drift, err := w.Verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var issues []HealthIssue
+	for i, d := range drift {
+		issue := HealthIssue{Kind: IssueOrphaned, Path: d.Path, Detail: d.String(), Repair: "drop the entry from the in-memory index", drift: &drift[i]}
+		if d.Kind == DriftUnindexed {
+			issue = HealthIssue{Kind: IssueUnindexed, Path: d.Path, Detail: d.String(), Repair: "add it to the index", drift: &drift[i]}
+		}
+		issues = append(issues, issue)
+	}
+
+	chunks, err := w.loadVectors()
+	if err != nil {
+		// A corrupt store is reported by checkCorrupt.
+		return issues, nil
+	}
+	stale := make(map[string]bool)
+	for _, c := range chunks {
+		if !stale[c.Ref] && !w.chunkSourceExists(c) {
+			stale[c.Ref] = true
+			issues = append(issues, HealthIssue{Kind: IssueOrphaned, Path: w.relPath(w.vectorIndexPath()), Detail: fmt.Sprintf("chunks of %s, which no longer exists", c.Ref), Repair: "remove its chunks"})
+		}
+	}
+	return issues, nil
+}
+
+// chunkSourceExists reports whether the artifact a vector chunk was taken
+// from still exists.
+func (w *Workspace) chunkSourceExists(c VectorChunk) bool {
+	switch c.Kind {
+	case ChunkSession:
+		_, ok := w.Context.Indexes.ArchivedSessions[strings.TrimPrefix(c.Ref, "session:")]
+		return ok
+	case ChunkPreference:
+		_, ok := w.Context.Indexes.PreferencesIndex[strings.TrimPrefix(c.Ref, "preference:")]
+		return ok
+	default:
+		_, err := os.Stat(c.Ref)
+		return !os.IsNotExist(err)
+	}
+}
+
+// checkSessionRoles reports the active and archived sessions whose role is
+// neither a project nor a global role. The active session cannot be opened
+// until this is repaired.
+func (w *Workspace) checkSessionRoles(ctx context.Context) ([]HealthIssue, error) {
+	paths := []string{filepath.Join(w.RootDir, "session.json")}
+	for id := range w.Context.Indexes.ArchivedSessions {
+		paths = append(paths, w.archivedSessionPath(id))
+	}
+
+	var issues []HealthIssue
+	for _, path := range paths {
+		data, err := w.storage.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var session struct {
+			Role string `json:"role"`
+		}
+		if err := json.Unmarshal(data, &session); err != nil || w.roleExists(session.Role) {
+			continue
+		}
+		issue := HealthIssue{Kind: IssueMissingRole, Path: w.relPath(path), Detail: fmt.Sprintf("uses role '%s', which has no role file", session.Role)}
+		if def := w.Context.Settings.DefaultRole; w.roleExists(def) {
+			issue.Repair = fmt.Sprintf("switch it to the default role '%s'", def)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// RepairHealth repairs an issue reported by `CheckHealth` as its Repair
+// describes. Artifacts are restored from the backups nani keeps where
+// possible, and indexes are rebuilt from the files on disk.
+func (w *Workspace) RepairHealth(ctx context.Context, issue HealthIssue) error {
+	if issue.Repair == "" {
+		return fmt.Errorf("%s must be repaired by hand", issue.Path)
+	}
+	path := filepath.Join(w.RootDir, filepath.FromSlash(issue.Path))
+	var err error
+	switch issue.Kind {
+	case IssuePermissions:
+		err = w.repairPermissions(path)
+	case IssueCorrupt:
+		err = w.repairCorrupt(ctx, issue.Path, path)
+	case IssueOrphaned, IssueUnindexed:
+		if issue.drift != nil {
+			err = w.Repair([]Issue{*issue.drift})
+		} else {
+			err = w.pruneVectors()
+		}
+	case IssueMissingRole:
+		err = w.repairSessionRole(ctx, path)
+	default:
+		return fmt.Errorf("unknown health issue kind '%s'", issue.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to repair %s: %w", issue.Path, err)
+	}
+	return w.logAction(fmt.Sprintf("Repaired %s issue in %s: %s", issue.Kind, issue.Path, issue.Detail))
+}
+
+// repairPermissions grants the owner read and write access to path, and
+// search access if it is a directory.
+func (w *Workspace) repairPermissions(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	want := os.FileMode(0600)
+	if info.IsDir() {
+		want = 0700
+	}
+	return os.Chmod(path, info.Mode().Perm()|want)
+}
+
+// repairCorrupt replaces the corrupt artifact at rel: the manifest is
+// rebuilt, the vector store deleted, and other artifacts are restored from
+// backup or moved aside so they no longer break loading.
+func (w *Workspace) repairCorrupt(ctx context.Context, rel, path string) error {
+	switch {
+	case rel == "manifest.json":
+		return w.rebuildManifest(ctx)
+	case path == w.vectorIndexPath():
+		return w.storage.Remove(path)
+	case w.hasValidBackup(rel):
+		return w.RestoreFromBackup(ctx, rel)
+	}
+	if err := w.storage.Rename(path, path+".corrupt"); err != nil {
+		return err
+	}
+	if err := w.recordRemove(path); err != nil {
+		return err
+	}
+	return w.rebuildIndexes(ctx)
+}
+
+// pruneVectors removes the chunks of artifacts that no longer exist from the
+// vector store.
+func (w *Workspace) pruneVectors() error {
+	chunks, err := w.loadVectors()
+	if err != nil {
+		return err
+	}
+	kept := chunks[:0]
+	for _, c := range chunks {
+		if w.chunkSourceExists(c) {
+			kept = append(kept, c)
+		}
+	}
+	return w.saveVectors(kept)
+}
+
+// repairSessionRole switches the session stored at path to the default role.
+// The file is decoded without hydrating the role, since a session whose role
+// is missing cannot be loaded.
+func (w *Workspace) repairSessionRole(ctx context.Context, path string) error {
+	session, err := w.readArchive(path)
+	if err != nil {
+		return err
+	}
+	session.Role = Role{Name: w.Context.Settings.DefaultRole}
+	if err := w.writeJSON(path, session); err != nil {
+		return err
+	}
+	return w.rebuildIndexes(ctx)
+}
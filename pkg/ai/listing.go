@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Fields `ListOptions.SortBy` accepts. Not every list has every field; an
+// unsupported one yields `ErrInvalidListOptions`.
+const (
+	SortByName    = "name"    // Session label, role name, or preference ID.
+	SortByCreated = "created" // When a session was created.
+	SortByUpdated = "updated" // When a session or preference was last updated.
+)
+
+// SortOrder is the direction of a sorted list.
+type SortOrder string
+
+const (
+	OrderAsc  SortOrder = "asc"  // Smallest, earliest, or alphabetically first item first.
+	OrderDesc SortOrder = "desc" // Largest, latest, or alphabetically last item first.
+)
+
+// ListOptions selects, orders, and pages the items returned by
+// `ListArchivedSessionsWith`, `ListRolesWith`, and `ListPreferencesWith`.
+// The zero value lists everything in each list's default order. Ties are
+// broken by ID or name, so the same options always yield the same page.
+type ListOptions struct {
+	SortBy string    // Field to sort by (see `SortByName`); empty keeps the list's default order.
+	Order  SortOrder // Direction of the sort; empty is ascending, or the default order unchanged.
+	Offset int       // Number of matching items to skip.
+	Limit  int       // Maximum number of items to return; 0 returns all remaining.
+	Filter string    // Case-insensitive text an item's name, label, or content must contain.
+}
+
+// validate checks that opts is usable for a list sortable by the given fields.
+func (opts ListOptions) validate(fields ...string) error {
+	if opts.SortBy != "" && !slices.Contains(fields, opts.SortBy) {
+		return fmt.Errorf("%w: cannot sort by %q; expected one of %v", ErrInvalidListOptions, opts.SortBy, fields)
+	}
+	if opts.Order != "" && opts.Order != OrderAsc && opts.Order != OrderDesc {
+		return fmt.Errorf("%w: unknown order %q; expected %q or %q", ErrInvalidListOptions, opts.Order, OrderAsc, OrderDesc)
+	}
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return fmt.Errorf("%w: offset and limit must not be negative", ErrInvalidListOptions)
+	}
+	return nil
+}
+
+// listPage filters items, which are in their default order, by opts, sorts
+// them with the comparison cmp returns for `opts.SortBy`, and returns the
+// requested page and the number of items that matched the filter. text
+// returns the strings of an item that `opts.Filter` is matched against; key
+// returns its ID or name, which breaks ties.
+func listPage[T any](items []T, opts ListOptions, text func(T) []string, key func(T) string, cmp func(field string) func(a, b T) int) ([]T, int) {
+	if filter := strings.ToLower(strings.TrimSpace(opts.Filter)); filter != "" {
+		items = slices.DeleteFunc(items, func(item T) bool {
+			return !slices.ContainsFunc(text(item), func(s string) bool {
+				return strings.Contains(strings.ToLower(s), filter)
+			})
+		})
+	}
+	if opts.SortBy != "" {
+		by := cmp(opts.SortBy)
+		slices.SortStableFunc(items, func(a, b T) int {
+			if c := by(a, b); c != 0 {
+				return c
+			}
+			return strings.Compare(key(a), key(b))
+		})
+	}
+	if opts.Order == OrderDesc {
+		slices.Reverse(items)
+	}
+
+	total := len(items)
+	start := min(opts.Offset, total)
+	end := total
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, total)
+	}
+	return items[start:end], total
+}
+
+// ListArchivedSessionsWith lists archived sessions like `ListArchivedSessions`,
+// filtered by label, ID, and role name, sorted by `SortByName` (label),
+// `SortByCreated`, or `SortByUpdated`, and paged as opts asks. It also returns
+// the number of sessions that matched the filter.
+func (w *Workspace) ListArchivedSessionsWith(opts ListOptions) ([]SessionSummary, int, error) {
+	if err := opts.validate(SortByName, SortByCreated, SortByUpdated); err != nil {
+		return nil, 0, err
+	}
+	sessions, err := w.ListArchivedSessions()
+	if err != nil {
+		return nil, 0, err
+	}
+	page, total := listPage(sessions, opts,
+		func(s SessionSummary) []string { return []string{s.Label, s.ID, s.RoleName} },
+		func(s SessionSummary) string { return s.ID },
+		func(field string) func(a, b SessionSummary) int {
+			switch field {
+			case SortByCreated:
+				return func(a, b SessionSummary) int { return a.CreatedAt.Compare(b.CreatedAt) }
+			case SortByUpdated:
+				return func(a, b SessionSummary) int { return a.LastUpdated.Compare(b.LastUpdated) }
+			}
+			return func(a, b SessionSummary) int {
+				return strings.Compare(strings.ToLower(a.Label), strings.ToLower(b.Label))
+			}
+		})
+	return page, total, nil
+}
+
+// ListRolesWith lists roles like `ListRoles`, filtered by name, label, and
+// description, sorted by `SortByName`, and paged as opts asks. It also returns
+// the number of roles that matched the filter.
+func (w *Workspace) ListRolesWith(opts ListOptions) ([]RoleSummary, int, error) {
+	if err := opts.validate(SortByName); err != nil {
+		return nil, 0, err
+	}
+	roles, err := w.ListRoles()
+	if err != nil {
+		return nil, 0, err
+	}
+	page, total := listPage(roles, opts,
+		func(r RoleSummary) []string { return []string{r.Name, r.Label, r.Description} },
+		func(r RoleSummary) string { return r.Name },
+		func(string) func(a, b RoleSummary) int {
+			return func(a, b RoleSummary) int { return strings.Compare(a.Name, b.Name) }
+		})
+	return page, total, nil
+}
+
+// ListPreferencesWith lists preferences like `ListPreferences`, filtered by ID
+// and content, sorted by `SortByName` (ID) or `SortByUpdated`, and paged as
+// opts asks. It also returns the number of preferences that matched the
+// filter.
+func (w *Workspace) ListPreferencesWith(opts ListOptions) ([]PreferenceSummary, int, error) {
+	if err := opts.validate(SortByName, SortByUpdated); err != nil {
+		return nil, 0, err
+	}
+	preferences, err := w.ListPreferences()
+	if err != nil {
+		return nil, 0, err
+	}
+	page, total := listPage(preferences, opts,
+		func(p PreferenceSummary) []string { return []string{p.ID, p.ContentSnippet} },
+		func(p PreferenceSummary) string { return p.ID },
+		func(field string) func(a, b PreferenceSummary) int {
+			if field == SortByUpdated {
+				return func(a, b PreferenceSummary) int { return a.Timestamp.Compare(b.Timestamp) }
+			}
+			return func(a, b PreferenceSummary) int { return strings.Compare(a.ID, b.ID) }
+		})
+	return page, total, nil
+}
@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListPage(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sessions := []SessionSummary{
+		{ID: "a", Label: "Parser", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: "b", Label: "lexer", CreatedAt: base},
+		{ID: "c", Label: "Parser tests", CreatedAt: base.Add(time.Hour)},
+		{ID: "d", Label: "docs", CreatedAt: base.Add(time.Hour)},
+	}
+	tests := []struct {
+		name      string
+		opts      ListOptions
+		want      []string
+		wantTotal int
+	}{
+		{"default order", ListOptions{}, []string{"a", "b", "c", "d"}, 4},
+		{"by name", ListOptions{SortBy: SortByName}, []string{"d", "b", "a", "c"}, 4},
+		{"by created, ties by ID", ListOptions{SortBy: SortByCreated}, []string{"b", "c", "d", "a"}, 4},
+		{"descending", ListOptions{SortBy: SortByCreated, Order: OrderDesc}, []string{"a", "d", "c", "b"}, 4},
+		{"filter", ListOptions{Filter: "parser"}, []string{"a", "c"}, 2},
+		{"page", ListOptions{SortBy: SortByName, Offset: 1, Limit: 2}, []string{"b", "a"}, 4},
+		{"offset past end", ListOptions{Offset: 10}, nil, 4},
+	}
+	for _, tt := range tests {
+		items := append([]SessionSummary(nil), sessions...)
+		page, total := listPage(items, tt.opts,
+			func(s SessionSummary) []string { return []string{s.Label} },
+			func(s SessionSummary) string { return s.ID },
+			func(field string) func(a, b SessionSummary) int {
+				if field == SortByCreated {
+					return func(a, b SessionSummary) int { return a.CreatedAt.Compare(b.CreatedAt) }
+				}
+				return func(a, b SessionSummary) int {
+					return strings.Compare(strings.ToLower(a.Label), strings.ToLower(b.Label))
+				}
+			})
+		var got []string
+		for _, s := range page {
+			got = append(got, s.ID)
+		}
+		if total != tt.wantTotal || len(got) != len(tt.want) {
+			t.Errorf("%s: got %v (total %d), want %v (total %d)", tt.name, got, total, tt.want, tt.wantTotal)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestListOptionsValidate(t *testing.T) {
+	w := newTestWorkspace(t)
+	for _, opts := range []ListOptions{{SortBy: SortByCreated}, {Order: "up"}, {Offset: -1}, {Limit: -1}} {
+		if _, _, err := w.ListRolesWith(opts); !errors.Is(err, ErrInvalidListOptions) {
+			t.Errorf("ListRolesWith(%+v) = %v, want ErrInvalidListOptions", opts, err)
+		}
+	}
+	roles, total, err := w.ListRolesWith(ListOptions{Limit: 1})
+	if err != nil || len(roles) != 1 || total < 1 {
+		t.Fatalf("ListRolesWith(Limit 1) = %v, %d, %v", roles, total, err)
+	}
+}
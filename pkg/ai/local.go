@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// localSettingKeys names, as in `context.json`, the settings kept in
+// `local.json` instead (see `localSettings`). `MergeContext` drops them from
+// merged contexts.
+var localSettingKeys = []string{"vertex", "providers", "sync", "runAllowlist", "redaction", "audit", "auditMaxBytes"}
+
+// localSettings holds the settings that stay on one machine, stored in
+// `local.json` rather than `context.json`, which `Sync` shares with a team.
+// They describe this machine's credentials and remotes, or loosen what nani
+// may do without asking, so a teammate's workspace must never set them.
+type localSettings struct {
+	Vertex        *VertexConfig `json:"vertex,omitempty"`
+	Providers     []string      `json:"providers,omitempty"`
+	Sync          *SyncConfig   `json:"sync,omitempty"`
+	RunAllowlist  []string      `json:"runAllowlist,omitempty"`
+	Redaction     RedactionMode `json:"redaction,omitempty"`
+	Audit         bool          `json:"audit,omitempty"`
+	AuditMaxBytes int           `json:"auditMaxBytes,omitempty"`
+}
+
+// splitLocal returns context without its machine-local settings, and those
+// settings.
+func splitLocal(context Context) (Context, localSettings) {
+	s := &context.Settings
+	local := localSettings{
+		Vertex:        s.Vertex,
+		Providers:     s.Providers,
+		Sync:          s.Sync,
+		RunAllowlist:  s.RunAllowlist,
+		Redaction:     s.Redaction,
+		Audit:         s.Audit,
+		AuditMaxBytes: s.AuditMaxBytes,
+	}
+	localSettings{}.applyTo(s)
+	return context, local
+}
+
+// applyTo replaces the machine-local settings of s with those of l.
+func (l localSettings) applyTo(s *Settings) {
+	s.Vertex = l.Vertex
+	s.Providers = l.Providers
+	s.Sync = l.Sync
+	s.RunAllowlist = l.RunAllowlist
+	s.Redaction = l.Redaction
+	s.Audit = l.Audit
+	s.AuditMaxBytes = l.AuditMaxBytes
+}
+
+// loadLocal replaces the machine-local settings of w.Context with those in
+// `local.json`. Workspaces created before `local.json` existed keep the ones
+// read from `context.json`, which move to `local.json` on the next save.
+func (w *Workspace) loadLocal() error {
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, "local.json"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read local settings: %w", err)
+	}
+	var local localSettings
+	if err := json.Unmarshal(data, &local); err != nil {
+		return fmt.Errorf("failed to parse local settings: %w", err)
+	}
+	local.applyTo(&w.Context.Settings)
+	return nil
+}
@@ -0,0 +1,276 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Manifest change kinds reported by `VerifyManifest`.
+const (
+	ChangeModified  = "modified"  // The file's content no longer matches the hash nani recorded.
+	ChangeMissing   = "missing"   // The file was recorded by nani but no longer exists.
+	ChangeUntracked = "untracked" // The file exists but was never written by nani.
+)
+
+// ManifestChange describes an artifact whose on-disk state differs from the
+// integrity manifest, typically because it was edited outside nani.
+type ManifestChange struct {
+	Path string `json:"path"` // Path relative to the workspace root, using forward slashes.
+	Kind string `json:"kind"` // One of ChangeModified, ChangeMissing, or ChangeUntracked.
+}
+
+// manifestDirs lists the artifact directories covered by the integrity manifest.
+var manifestDirs = []string{"roles", "preferences", "templates", "schedules", "facts", "sessions"}
+
+// manifestFiles lists the top-level artifact files covered by the integrity manifest.
+var manifestFiles = []string{"context.json", "local.json", "session.json", "ui.json"}
+
+// hashBytes returns the hex-encoded SHA-256 digest of data.
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// relPath converts an absolute artifact path into the manifest key form.
+func (w *Workspace) relPath(path string) string {
+	rel, err := filepath.Rel(w.RootDir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// loadManifest reads `manifest.json` into memory on first use.
+// A missing manifest yields an empty one.
+func (w *Workspace) loadManifest() error {
+	if w.persist.manifest != nil {
+		return nil
+	}
+	w.persist.manifest = make(map[string]string)
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, "manifest.json"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &w.persist.manifest); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return nil
+}
+
+// saveManifest persists the in-memory manifest to `manifest.json`.
+// It writes the file directly rather than through `writeJSON` so that the
+// manifest never records a hash of itself. manifestMu must be held.
+func (w *Workspace) saveManifest() error {
+	data, err := json.MarshalIndent(w.persist.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := w.storage.WriteFile(filepath.Join(w.RootDir, "manifest.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	w.persist.manifestDirty = false
+	return nil
+}
+
+// markManifestDirty notes that the manifest changed, to be saved by the next
+// `Flush` rather than on every artifact write. manifestMu must be held.
+func (w *Workspace) markManifestDirty() {
+	w.persist.manifestDirty = true
+	w.persist.mu.Lock()
+	defer w.persist.mu.Unlock()
+	w.scheduleFlush()
+}
+
+// flushManifest saves the manifest if it changed since it was last saved.
+func (w *Workspace) flushManifest() error {
+	w.persist.manifestMu.Lock()
+	defer w.persist.manifestMu.Unlock()
+	if !w.persist.manifestDirty {
+		return nil
+	}
+	return w.saveManifest()
+}
+
+// recordWrite updates the manifest with the hash of content just written to path
+// and keeps a backup copy under `backups/`, from which tampered files can be restored.
+// The manifest itself is saved by the next `Flush`.
+func (w *Workspace) recordWrite(path string, content []byte) error {
+	w.persist.manifestMu.Lock()
+	defer w.persist.manifestMu.Unlock()
+	if err := w.loadManifest(); err != nil {
+		return err
+	}
+	rel := w.relPath(path)
+	backupPath := filepath.Join(w.RootDir, "backups", filepath.FromSlash(rel))
+	if err := w.storage.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory for %s: %w", rel, err)
+	}
+	if err := w.storage.WriteFile(backupPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", rel, err)
+	}
+	w.persist.manifest[rel] = hashBytes(content)
+	w.markManifestDirty()
+	return nil
+}
+
+// recordRemove drops a deleted artifact from the manifest. Its backup is kept
+// so that accidental deletions remain recoverable.
+func (w *Workspace) recordRemove(path string) error {
+	w.persist.manifestMu.Lock()
+	defer w.persist.manifestMu.Unlock()
+	if err := w.loadManifest(); err != nil {
+		return err
+	}
+	delete(w.persist.manifest, w.relPath(path))
+	w.markManifestDirty()
+	return nil
+}
+
+// manifestHash returns the hash recorded for the artifact at the manifest path
+// rel, and whether one is recorded.
+func (w *Workspace) manifestHash(rel string) (string, bool) {
+	w.persist.manifestMu.Lock()
+	defer w.persist.manifestMu.Unlock()
+	if err := w.loadManifest(); err != nil {
+		return "", false
+	}
+	hash, ok := w.persist.manifest[rel]
+	return hash, ok
+}
+
+// trackedArtifacts returns the manifest keys of every artifact currently on disk.
+func (w *Workspace) trackedArtifacts() ([]string, error) {
+	var paths []string
+	for _, name := range manifestFiles {
+		if _, err := w.storage.Stat(filepath.Join(w.RootDir, name)); err == nil {
+			paths = append(paths, name)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to check %s: %w", name, err)
+		}
+	}
+	for _, dir := range manifestDirs {
+		entries, err := w.storage.ReadDir(filepath.Join(w.RootDir, dir))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s directory: %w", dir, err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+				paths = append(paths, dir+"/"+e.Name())
+			}
+		}
+	}
+	return paths, nil
+}
+
+// VerifyManifest compares every artifact on disk with the integrity manifest and
+// reports files that were modified, deleted, or added outside nani.
+// Results are sorted by path. The comparison stops when ctx is done.
+func (w *Workspace) VerifyManifest(ctx context.Context) ([]ManifestChange, error) {
+	w.persist.manifestMu.Lock()
+	defer w.persist.manifestMu.Unlock()
+	if err := w.loadManifest(); err != nil {
+		return nil, err
+	}
+	paths, err := w.trackedArtifacts()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []ManifestChange
+	seen := make(map[string]bool, len(paths))
+	for _, rel := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		seen[rel] = true
+		data, err := w.storage.ReadFile(filepath.Join(w.RootDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		recorded, ok := w.persist.manifest[rel]
+		if !ok {
+			changes = append(changes, ManifestChange{Path: rel, Kind: ChangeUntracked})
+		} else if recorded != hashBytes(data) {
+			changes = append(changes, ManifestChange{Path: rel, Kind: ChangeModified})
+		}
+	}
+	for rel := range w.persist.manifest {
+		if !seen[rel] {
+			changes = append(changes, ManifestChange{Path: rel, Kind: ChangeMissing})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// RebuildManifest re-hashes every artifact currently on disk, accepting the
+// present state as authoritative. It is typically used after `RefreshIndexes`
+// once external edits have been reviewed.
+func (w *Workspace) RebuildManifest(ctx context.Context) error {
+	if err := w.rebuildManifest(ctx); err != nil {
+		return err
+	}
+	return w.logAction("Rebuilt integrity manifest")
+}
+
+// rebuildManifest re-hashes every artifact on disk and saves the manifest.
+func (w *Workspace) rebuildManifest(ctx context.Context) error {
+	w.persist.manifestMu.Lock()
+	defer w.persist.manifestMu.Unlock()
+	paths, err := w.trackedArtifacts()
+	if err != nil {
+		return err
+	}
+	w.persist.manifest = make(map[string]string, len(paths))
+	for _, rel := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := w.storage.ReadFile(filepath.Join(w.RootDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		w.persist.manifest[rel] = hashBytes(data)
+	}
+	return w.saveManifest()
+}
+
+// RestoreFromBackup replaces the artifact at the given manifest path with the
+// last copy written by nani, then reloads the context and indexes so memory
+// matches disk again.
+func (w *Workspace) RestoreFromBackup(ctx context.Context, rel string) error {
+	if rel == "context.json" || rel == "local.json" {
+		w.discardContextChanges()
+	}
+	backupPath := filepath.Join(w.RootDir, "backups", filepath.FromSlash(rel))
+	data, err := w.storage.ReadFile(backupPath)
+	if err != nil {
+		return fmt.Errorf("no backup available for %s: %w", rel, err)
+	}
+	target := filepath.Join(w.RootDir, filepath.FromSlash(rel))
+	if err := w.storage.WriteFile(target, data, 0644); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", rel, err)
+	}
+	if err := w.recordWrite(target, data); err != nil {
+		return err
+	}
+	if rel == "context.json" || rel == "local.json" {
+		if err := w.loadContext(); err != nil {
+			return fmt.Errorf("failed to reload restored context: %w", err)
+		}
+	}
+	if err := w.rebuildIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild indexes after restoring %s: %w", rel, err)
+	}
+	return w.logAction(fmt.Sprintf("Restored %s from backup", rel))
+}
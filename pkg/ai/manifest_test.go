@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestSavedOnFlush(t *testing.T) {
+	w := newTestWorkspace(t)
+	manifestPath := filepath.Join(w.RootDir, "manifest.json")
+	readManifest := func() map[string]string {
+		t.Helper()
+		manifest := make(map[string]string)
+		if data, err := w.storage.ReadFile(manifestPath); err == nil {
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return manifest
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := w.SaveTemplate(Template{Name: name, Content: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := readManifest()["templates/a.json"]; ok {
+		t.Fatal("manifest.json was written before Flush")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	manifest := readManifest()
+	for _, rel := range []string{"templates/a.json", "templates/b.json", "templates/c.json"} {
+		if manifest[rel] == "" {
+			t.Errorf("manifest.json lacks %s after Flush", rel)
+		}
+	}
+	if changes, err := w.VerifyManifest(t.Context()); err != nil || len(changes) != 0 {
+		t.Errorf("VerifyManifest() = %v, %v, want no changes", changes, err)
+	}
+}
@@ -0,0 +1,262 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of memory candidates, named after the store they are saved to.
+const (
+	MemoryFact       = "fact"       // Saved as a `Fact`.
+	MemoryPreference = "preference" // Saved as a `Preference` applying to every role.
+)
+
+// MemoryCandidate is a fact or preference the model extracted from a
+// conversation, waiting for the user to approve or reject it.
+type MemoryCandidate struct {
+	ID           string    `json:"id"`           // Unique identifier of the candidate.
+	Kind         string    `json:"kind"`         // MemoryFact or MemoryPreference.
+	Content      string    `json:"content"`      // The fact or preference, as one sentence.
+	SessionID    string    `json:"sessionId"`    // Session the candidate was extracted from.
+	SessionLabel string    `json:"sessionLabel"` // Label of that session, for display.
+	Proposed     time.Time `json:"proposed"`     // When the candidate was extracted.
+}
+
+// memoryQueueMu serializes updates of the memory queue file.
+var memoryQueueMu sync.Mutex
+
+// memoryQueuePath returns the sidecar file holding the candidates awaiting
+// approval. Candidates become artifacts only once approved, so the queue is
+// excluded from the integrity manifest.
+func (w *Workspace) memoryQueuePath() string {
+	return filepath.Join(w.RootDir, "memory-queue.json")
+}
+
+// PendingMemories returns the candidates awaiting approval, oldest first.
+func (w *Workspace) PendingMemories() ([]MemoryCandidate, error) {
+	var queue []MemoryCandidate
+	data, err := w.storage.ReadFile(w.memoryQueuePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read memory queue: %w", err)
+	}
+	if err := json.Unmarshal(data, &queue); err != nil {
+		return nil, fmt.Errorf("failed to parse memory queue: %w", err)
+	}
+	return queue, nil
+}
+
+// updateMemoryQueue replaces the queue with the result of update.
+func (w *Workspace) updateMemoryQueue(update func(queue []MemoryCandidate) ([]MemoryCandidate, error)) error {
+	memoryQueueMu.Lock()
+	defer memoryQueueMu.Unlock()
+	queue, err := w.PendingMemories()
+	if err != nil {
+		return err
+	}
+	if queue, err = update(queue); err != nil {
+		return err
+	}
+	if len(queue) == 0 {
+		if err := w.storage.Remove(w.memoryQueuePath()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear memory queue: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode memory queue: %w", err)
+	}
+	if err := w.storage.WriteFile(w.memoryQueuePath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write memory queue: %w", err)
+	}
+	return nil
+}
+
+// memoryReply is the JSON object the model answers an extraction request with.
+type memoryReply struct {
+	Facts       []string `json:"facts"`
+	Preferences []string `json:"preferences"`
+}
+
+// ExtractMemories asks runner for the facts about the project and the
+// preferences of the user stated in the archived session sessionID, and
+// queues those not already known for approval with `ApproveMemory`. It
+// returns the candidates queued.
+func (w *Workspace) ExtractMemories(ctx context.Context, runner PromptRunner, sessionID string) ([]MemoryCandidate, error) {
+	session, err := w.readArchive(w.archivedSessionPath(sessionID))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", sessionID, err)
+	}
+	if len(session.Chat) == 0 {
+		return nil, nil
+	}
+	known, err := w.knownMemories()
+	if err != nil {
+		return nil, err
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("Read the following conversation between a user and an AI assistant about a software project. ")
+	prompt.WriteString("List the durable facts about the project it establishes (e.g., libraries used, where code lives, conventions), ")
+	prompt.WriteString("and the lasting preferences the user stated about how the assistant should answer or write code. ")
+	prompt.WriteString("Skip anything specific to the task at hand and anything already known. Write each item as one sentence.\n")
+	prompt.WriteString(`Respond with only a JSON object of the form {"facts": [...], "preferences": [...]}, using empty lists if there is nothing worth keeping.` + "\n\n")
+	if len(known) > 0 {
+		prompt.WriteString("Already known:\n")
+		for _, k := range known {
+			fmt.Fprintf(&prompt, "- %s\n", k)
+		}
+		prompt.WriteString("\n")
+	}
+	for _, c := range session.Chat {
+		fmt.Fprintf(&prompt, "[user-message]: %s\n[agent-response]: %s\n", excerptText(c.Message.Content), excerptText(c.Response.Content))
+	}
+
+	role, err := w.loadRole(session.Role.Name)
+	if err != nil {
+		role = Role{Name: session.Role.Name}
+	}
+	answer, err := runner.RunPrompt(ctx, role, prompt.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract memories from session %s: %w", sessionID, err)
+	}
+	var reply memoryReply
+	if _, _, err := decodeReply(answer, &reply); err != nil {
+		return nil, fmt.Errorf("failed to extract memories from session %s: %w", sessionID, err)
+	}
+
+	var added []MemoryCandidate
+	err = w.updateMemoryQueue(func(queue []MemoryCandidate) ([]MemoryCandidate, error) {
+		seen := make(map[string]bool)
+		for _, k := range known {
+			seen[memoryKey(k)] = true
+		}
+		for _, c := range queue {
+			seen[memoryKey(c.Content)] = true
+		}
+		add := func(kind string, contents []string) {
+			for _, content := range contents {
+				content = strings.TrimSpace(content)
+				if content == "" || seen[memoryKey(content)] {
+					continue
+				}
+				seen[memoryKey(content)] = true
+				added = append(added, MemoryCandidate{
+					ID:           uuid.New().String(),
+					Kind:         kind,
+					Content:      content,
+					SessionID:    session.ID,
+					SessionLabel: session.Label,
+					Proposed:     w.now(),
+				})
+			}
+		}
+		add(MemoryFact, reply.Facts)
+		add(MemoryPreference, reply.Preferences)
+		return append(queue, added...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.logAction(fmt.Sprintf("Queued %d memories from session %s", len(added), session.ID))
+	return added, nil
+}
+
+// knownMemories returns the content of the facts and preferences already
+// stored, so extraction does not propose them again.
+func (w *Workspace) knownMemories() ([]string, error) {
+	facts, err := w.ListFacts()
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := w.ListPreferences()
+	if err != nil {
+		return nil, err
+	}
+	known := make([]string, 0, len(facts)+len(preferences))
+	for _, f := range facts {
+		known = append(known, f.Content)
+	}
+	for _, summary := range preferences {
+		if p, err := w.LoadPreference(summary.ID); err == nil {
+			known = append(known, p.Content)
+		}
+	}
+	return known, nil
+}
+
+// memoryKey normalizes content for detecting duplicate memories.
+func memoryKey(content string) string {
+	return strings.ToLower(strings.Join(strings.Fields(content), " "))
+}
+
+// ApproveMemory saves the queued candidate id to the facts or preferences
+// store, according to its kind, and removes it from the queue.
+func (w *Workspace) ApproveMemory(id string) error {
+	return w.updateMemoryQueue(func(queue []MemoryCandidate) ([]MemoryCandidate, error) {
+		i := memoryIndex(queue, id)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMemoryNotFound, id)
+		}
+		c := queue[i]
+		var err error
+		switch c.Kind {
+		case MemoryFact:
+			_, err = w.AddFact(c.Content, FactSourceModel)
+		case MemoryPreference:
+			err = w.SavePreference(Preference{ID: uuid.New().String(), Content: c.Content, Timestamp: w.now()})
+		default:
+			err = fmt.Errorf("unknown memory kind %q", c.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return append(queue[:i], queue[i+1:]...), nil
+	})
+}
+
+// RejectMemory removes the queued candidate id without saving it.
+func (w *Workspace) RejectMemory(id string) error {
+	return w.updateMemoryQueue(func(queue []MemoryCandidate) ([]MemoryCandidate, error) {
+		i := memoryIndex(queue, id)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMemoryNotFound, id)
+		}
+		return append(queue[:i], queue[i+1:]...), nil
+	})
+}
+
+// memoryIndex returns the index of the candidate id in queue, or -1.
+func memoryIndex(queue []MemoryCandidate, id string) int {
+	for i, c := range queue {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// MemoriesMarkdown renders candidates as a numbered Markdown list, numbered
+// as the `/memories` command expects.
+func MemoriesMarkdown(candidates []MemoryCandidate) string {
+	if len(candidates) == 0 {
+		return "_No memories awaiting approval._\n"
+	}
+	var b strings.Builder
+	for i, c := range candidates {
+		fmt.Fprintf(&b, "%d. **%s**: %s _(from %q)_\n", i+1, c.Kind, c.Content, c.SessionLabel)
+	}
+	return b.String()
+}
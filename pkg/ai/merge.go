@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ContextMergeCommand is the hidden nani command that git runs to merge
+// `context.json` when `Sync` rebases onto teammates' changes (see
+// `MergeContext`). It is called with the paths of the base, current, and
+// other versions, and writes the result over the current one.
+const ContextMergeCommand = "__merge-context"
+
+// MergeContext merges two versions of `context.json` changed independently
+// from base, one setting or project field at a time, and returns the merged
+// file and the fields both versions changed differently, such as
+// "settings.defaultRole". Those keep their value in ours. The workspace ID
+// never conflicts; ours is kept. An empty base is treated as an empty
+// context, as when two workspaces sync for the first time. Indexes and
+// machine-local settings (see `localSettings`), which older workspaces stored
+// in `context.json`, are dropped: a teammate must never set the latter.
+func MergeContext(base, ours, theirs []byte) ([]byte, []string, error) {
+	var docs [3]map[string]json.RawMessage
+	for i, data := range [][]byte{base, ours, theirs} {
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(data, &docs[i]); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse context: %w", err)
+		}
+	}
+
+	var conflicts []string
+	merged := make(map[string]json.RawMessage)
+	if id := mergeValue(docs[0]["workspace"], docs[1]["workspace"], docs[2]["workspace"], nil); id != nil {
+		merged["workspace"] = id
+	}
+	for _, key := range []string{"settings", "project"} {
+		var fields [3]map[string]json.RawMessage
+		for i, doc := range docs {
+			if raw, ok := doc[key]; ok && !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				if err := json.Unmarshal(raw, &fields[i]); err != nil {
+					return nil, nil, fmt.Errorf("failed to parse context %s: %w", key, err)
+				}
+			}
+		}
+		object := make(map[string]json.RawMessage)
+		for _, name := range fieldNames(fields[:]) {
+			if key == "settings" && slices.Contains(localSettingKeys, name) {
+				continue
+			}
+			conflicted := false
+			if value := mergeValue(fields[0][name], fields[1][name], fields[2][name], &conflicted); value != nil {
+				object[name] = value
+			}
+			if conflicted {
+				conflicts = append(conflicts, key+"."+name)
+			}
+		}
+		raw, err := json.Marshal(object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode merged %s: %w", key, err)
+		}
+		merged[key] = raw
+	}
+
+	// Round-trip through Context so the result is formatted like any other
+	// context nani writes.
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode merged context: %w", err)
+	}
+	var context Context
+	if err := json.Unmarshal(raw, &context); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse merged context: %w", err)
+	}
+	content, err := encodeJSON(context)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode merged context: %w", err)
+	}
+	sort.Strings(conflicts)
+	return content, conflicts, nil
+}
+
+// mergeValue merges a single JSON value changed independently from base in
+// ours and theirs; nil stands for a missing value. A value changed
+// differently on both sides keeps ours and sets *conflicted, if given.
+func mergeValue(base, ours, theirs json.RawMessage, conflicted *bool) json.RawMessage {
+	switch {
+	case sameJSON(ours, theirs), sameJSON(base, theirs):
+		return ours
+	case sameJSON(base, ours):
+		return theirs
+	}
+	if conflicted != nil {
+		*conflicted = true
+	}
+	return ours
+}
+
+// sameJSON reports whether a and b encode the same value, ignoring
+// whitespace. Nil only matches nil.
+func sameJSON(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// fieldNames returns the sorted names of the fields set in any of objects.
+func fieldNames(objects []map[string]json.RawMessage) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, object := range objects {
+		for name := range object {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
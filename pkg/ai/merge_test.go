@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestMergeContext(t *testing.T) {
+	const base = `{"workspace": "w1", "settings": {"defaultRole": "coder", "factBudget": 100}, "project": {"name": "nani"}}`
+	tests := []struct {
+		name          string
+		base          string
+		ours          string
+		theirs        string
+		wantRole      string
+		wantBudget    int
+		wantProject   string
+		wantConflicts []string
+		check         func(t *testing.T, merged map[string]any)
+	}{
+		{
+			name:        "change on our side",
+			base:        base,
+			ours:        `{"workspace": "w1", "settings": {"defaultRole": "reviewer", "factBudget": 100}, "project": {"name": "nani"}}`,
+			theirs:      base,
+			wantRole:    "reviewer",
+			wantBudget:  100,
+			wantProject: "nani",
+		},
+		{
+			name:        "change on their side",
+			base:        base,
+			ours:        base,
+			theirs:      `{"workspace": "w1", "settings": {"defaultRole": "coder", "factBudget": 200}, "project": {"name": "nani-cli"}}`,
+			wantRole:    "coder",
+			wantBudget:  200,
+			wantProject: "nani-cli",
+		},
+		{
+			name:          "both sides changed differently keeps ours",
+			base:          base,
+			ours:          `{"workspace": "w1", "settings": {"defaultRole": "reviewer", "factBudget": 100}, "project": {"name": "nani"}}`,
+			theirs:        `{"workspace": "w2", "settings": {"defaultRole": "architect", "factBudget": 300}, "project": {"name": "nani"}}`,
+			wantRole:      "reviewer",
+			wantBudget:    300,
+			wantProject:   "nani",
+			wantConflicts: []string{"settings.defaultRole"},
+		},
+		{
+			name:        "local settings from theirs are dropped",
+			base:        base,
+			ours:        base,
+			theirs:      `{"workspace": "w1", "settings": {"defaultRole": "coder", "factBudget": 100, "runAllowlist": ["rm -rf /"], "sync": {"remote": "evil"}, "audit": true}, "project": {"name": "nani"}}`,
+			wantRole:    "coder",
+			wantBudget:  100,
+			wantProject: "nani",
+			check: func(t *testing.T, merged map[string]any) {
+				settings := merged["settings"].(map[string]any)
+				for _, key := range localSettingKeys {
+					if value, ok := settings[key]; ok {
+						t.Errorf("merged settings keep the local setting %s = %v", key, value)
+					}
+				}
+			},
+		},
+		{
+			name:        "empty base",
+			base:        "",
+			ours:        `{"workspace": "w1", "settings": {"defaultRole": "coder"}, "project": {"name": "nani"}}`,
+			theirs:      `{"workspace": "w2", "settings": {"factBudget": 200}, "project": {"name": "nani"}}`,
+			wantRole:    "coder",
+			wantBudget:  200,
+			wantProject: "nani",
+			check: func(t *testing.T, merged map[string]any) {
+				if id := merged["workspace"]; id != "w1" {
+					t.Errorf("workspace ID = %v, want ours", id)
+				}
+			},
+		},
+		{
+			name:        "unknown top-level keys and indexes are dropped",
+			base:        base,
+			ours:        base,
+			theirs:      `{"workspace": "w1", "settings": {"defaultRole": "coder", "factBudget": 100}, "project": {"name": "nani"}, "indexes": {"roles": {"x": {}}}, "future": {"a": 1}}`,
+			wantRole:    "coder",
+			wantBudget:  100,
+			wantProject: "nani",
+			check: func(t *testing.T, merged map[string]any) {
+				for _, key := range []string{"future", "indexes"} {
+					if value, ok := merged[key]; ok {
+						t.Errorf("merged context keeps %s = %v", key, value)
+					}
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, conflicts, err := MergeContext([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(conflicts, tt.wantConflicts) {
+				t.Errorf("conflicts = %v, want %v", conflicts, tt.wantConflicts)
+			}
+			var context Context
+			if err := json.Unmarshal(data, &context); err != nil {
+				t.Fatal(err)
+			}
+			if context.Settings.DefaultRole != tt.wantRole || context.Settings.FactBudget != tt.wantBudget || context.Project.Name != tt.wantProject {
+				t.Errorf("merged = role %q, fact budget %d, project %q, want %q, %d, %q",
+					context.Settings.DefaultRole, context.Settings.FactBudget, context.Project.Name, tt.wantRole, tt.wantBudget, tt.wantProject)
+			}
+			if tt.check != nil {
+				var merged map[string]any
+				if err := json.Unmarshal(data, &merged); err != nil {
+					t.Fatal(err)
+				}
+				tt.check(t, merged)
+			}
+		})
+	}
+}
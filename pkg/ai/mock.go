@@ -0,0 +1,229 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// mockModel is the model name reported by `MockAIClient` responses.
+const mockModel = "mock"
+
+// mockChunkSize is the approximate number of bytes streamed per chunk.
+const mockChunkSize = 24
+
+// MockFixture is a canned reply of `MockAIClient`.
+type MockFixture struct {
+	// Match selects the fixture for prompts containing it, ignoring case.
+	// Fixtures without Match are replied in order, one per prompt no
+	// matching fixture answers.
+	Match    string   `json:"match,omitempty"`
+	Response Response `json:"response"`          // The reply: think, summary, content, and optional actions and findings.
+	DelayMs  int      `json:"delayMs,omitempty"` // Simulated generation time, in milliseconds.
+}
+
+// MockFixtures is the content of a mock fixture file.
+type MockFixtures struct {
+	Greeting  *Response     `json:"greeting,omitempty"` // Reply to `StartSession`; nil uses a built-in greeting.
+	Responses []MockFixture `json:"responses"`          // Replies to prompts; prompts none answers are echoed.
+}
+
+// MockAIClient is an `AIClient` that replies with canned responses instead
+// of calling a model provider, for UI development, integration tests, and
+// demos without network access or API keys. Exchanges are saved to the
+// active session like those of a real client.
+type MockAIClient struct {
+	workspace *Workspace
+	fixtures  MockFixtures
+
+	mu   sync.Mutex
+	next int // Index into fixtures.Responses from which scripted replies continue.
+}
+
+// MockFixturesPath returns where `NewMockAIClient` looks for fixtures by
+// default, `mock.json` in the workspace.
+func MockFixturesPath(w *Workspace) string {
+	return filepath.Join(w.RootDir, "mock.json")
+}
+
+// NewMockAIClient creates a mock client replying with the fixtures in the file
+// at path (see `MockFixtures`). A missing file leaves only the built-in
+// greeting and echo replies.
+func NewMockAIClient(workspace *Workspace, path string) (*MockAIClient, error) {
+	m := &MockAIClient{workspace: workspace}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read mock fixtures: %w", err)
+	}
+	if err := json.Unmarshal(data, &m.fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse mock fixtures %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Provider implements `Provider`.
+func (m *MockAIClient) Provider() string {
+	return ProviderMock
+}
+
+// SetWorkspace points the client at another workspace.
+func (m *MockAIClient) SetWorkspace(workspace *Workspace) {
+	m.workspace = workspace
+}
+
+// StartSession opens the active session, creating one if needed, and returns
+// the fixture greeting.
+func (m *MockAIClient) StartSession(ctx context.Context) (Response, error) {
+	if _, err := m.OpenSession(ctx); err != nil {
+		return Response{}, err
+	}
+	greeting := Response{
+		Summary: "Greeted the user.",
+		Content: "Hello! This is nani's mock provider; replies are canned, and no model is called.",
+	}
+	if m.fixtures.Greeting != nil {
+		greeting = *m.fixtures.Greeting
+	}
+	greeting.Model = mockModel
+	greeting.FinishReason = "STOP"
+	return greeting, nil
+}
+
+// OpenSession returns the active session, creating one if needed.
+func (m *MockAIClient) OpenSession(ctx context.Context) (*Session, error) {
+	session, err := m.workspace.GetSession(ctx, defaultSessionLabel, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start a session: %w", err)
+	}
+	return session, nil
+}
+
+// SendMessage replies to message with the matching fixture, the next
+// scripted one, or an echo of the prompt, and records the exchange in the
+// active session when save is true.
+func (m *MockAIClient) SendMessage(ctx context.Context, message SavedMessage, history []Message, save bool) (Response, error) {
+	return m.StreamMessage(ctx, message, save, nil)
+}
+
+// StreamMessage is like SendMessage, but delivers the reply to onChunk in
+// pieces spread over the fixture's delay. Like those of
+// `GeminiAIClient.StreamMessage`, the pieces are fragments of the reply's
+// JSON encoding.
+func (m *MockAIClient) StreamMessage(ctx context.Context, message SavedMessage, save bool, onChunk func(text string)) (Response, error) {
+	content, secrets, err := m.workspace.Redact("prompt", message.Content)
+	if err != nil {
+		return Response{}, err
+	}
+	message.Content = content
+
+	fixture := m.reply(message.Content)
+	started := time.Now()
+	delay := time.Duration(fixture.DelayMs) * time.Millisecond
+	if onChunk == nil {
+		if err := sleepContext(ctx, delay); err != nil {
+			return Response{}, err
+		}
+	} else {
+		raw, err := json.Marshal(fixture.Response)
+		if err != nil {
+			return Response{}, fmt.Errorf("failed to encode mock response: %w", err)
+		}
+		chunks := mockChunks(string(raw))
+		for _, chunk := range chunks {
+			onChunk(chunk)
+			if err := sleepContext(ctx, delay/time.Duration(len(chunks))); err != nil {
+				return Response{}, err
+			}
+		}
+	}
+
+	response := fixture.Response
+	response.Provider = ProviderMock
+	response.Model = mockModel
+	response.FinishReason = "STOP"
+	response.Latency = time.Since(started)
+	response.Secrets = secrets
+	session, err := m.workspace.GetActiveSession()
+	if err == nil && session != nil {
+		response = m.workspace.PostProcess(session.Role.PostProcess, response)
+	}
+	if err == nil && session != nil && save {
+		chat, err := m.workspace.AddInteraction(message, SavedResponse{
+			Content:      response.Summary,
+			Actions:      response.Actions,
+			Findings:     response.Findings,
+			Fields:       response.Fields,
+			Provider:     response.Provider,
+			Model:        response.Model,
+			LatencyMs:    response.Latency.Milliseconds(),
+			FinishReason: response.FinishReason,
+		})
+		if err == nil {
+			response.ChatID = chat.ID
+		}
+		m.workspace.rememberFacts(response.Remember)
+	}
+	return response, nil
+}
+
+// reply picks the fixture answering prompt.
+func (m *MockAIClient) reply(prompt string) MockFixture {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lower := strings.ToLower(prompt)
+	for _, f := range m.fixtures.Responses {
+		if f.Match != "" && strings.Contains(lower, strings.ToLower(f.Match)) {
+			return f
+		}
+	}
+	for m.next < len(m.fixtures.Responses) {
+		f := m.fixtures.Responses[m.next]
+		m.next++
+		if f.Match == "" {
+			return f
+		}
+	}
+	return MockFixture{Response: Response{
+		Think:   "No fixture matched the prompt, so it is echoed.",
+		Summary: "Echoed the prompt.",
+		Content: fmt.Sprintf("You said:\n\n> %s", strings.ReplaceAll(prompt, "\n", "\n> ")),
+	}}
+}
+
+// mockChunks splits s into pieces of about mockChunkSize bytes, without
+// splitting a character.
+func mockChunks(s string) []string {
+	var chunks []string
+	for len(s) > 0 {
+		n := min(mockChunkSize, len(s))
+		for n < len(s) && !utf8.RuneStart(s[n]) {
+			n++
+		}
+		chunks = append(chunks, s[:n])
+		s = s[n:]
+	}
+	return chunks
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestWorkspace returns an initialized workspace in a temporary project
+// directory, without a global workspace.
+func newTestWorkspace(t *testing.T) *Workspace {
+	t.Helper()
+	w, err := NewWorkspace(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetGlobalDir("")
+	if err := w.Init(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Flush() })
+	return w
+}
+
+func TestMockAIClientSavesExchanges(t *testing.T) {
+	w := newTestWorkspace(t)
+	fixtures := `{"responses": [
+		{"match": "weather", "response": {"summary": "Talked about the weather.", "content": "Sunny."}},
+		{"response": {"summary": "First scripted.", "content": "One."}}
+	]}`
+	path := filepath.Join(t.TempDir(), "mock.json")
+	if err := os.WriteFile(path, []byte(fixtures), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewMockAIClient(w, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := client.StartSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ prompt, content string }{
+		{"How is the WEATHER?", "Sunny."},
+		{"anything", "One."},
+		{"hello\nthere", "You said:\n\n> hello\n> there"},
+	} {
+		response, err := client.SendMessage(ctx, SavedMessage{Content: tt.prompt}, nil, true)
+		if err != nil {
+			t.Fatalf("SendMessage(%q) failed: %v", tt.prompt, err)
+		}
+		if response.Content != tt.content || response.Provider != ProviderMock || response.ChatID == "" {
+			t.Errorf("SendMessage(%q) = %q from %s (chat %q), want %q from the mock provider", tt.prompt, response.Content, response.Provider, response.ChatID, tt.content)
+		}
+	}
+
+	session, err := w.GetActiveSession()
+	if err != nil || session == nil {
+		t.Fatalf("GetActiveSession() = %v, %v", session, err)
+	}
+	if len(session.Chat) != 3 || session.Chat[0].Response.Content != "Talked about the weather." {
+		t.Errorf("active session has %d exchanges, want 3 starting with the weather summary", len(session.Chat))
+	}
+}
+
+func TestMockAIClientStreamsJSON(t *testing.T) {
+	w := newTestWorkspace(t)
+	client, err := NewMockAIClient(w, filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	response, err := client.StreamMessage(context.Background(), SavedMessage{Content: "ping"}, false, func(text string) { b.WriteString(text) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed Response
+	if _, _, err := decodeReply(b.String(), &streamed); err != nil {
+		t.Fatalf("streamed chunks are not a JSON reply: %v", err)
+	}
+	if streamed.Content != response.Content {
+		t.Errorf("streamed content %q, want %q", streamed.Content, response.Content)
+	}
+}
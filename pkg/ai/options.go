@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Option configures a `Workspace` created by `NewWorkspace`.
+type Option func(*Workspace)
+
+// WithStorage keeps the workspace's files in storage instead of on disk (see
+// `Storage`). The default is `OSStorage`.
+func WithStorage(storage Storage) Option {
+	return func(w *Workspace) { w.storage = storage }
+}
+
+// WithLogger sends the workspace's operational log to logger instead of the
+// daily files in `logs/`. Entries starting with "Warning:" are logged at
+// `slog.LevelWarn`, those starting with "Error:" at `slog.LevelError`, and
+// the rest at `slog.LevelInfo`.
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Workspace) { w.logger = logger }
+}
+
+// WithClock makes the workspace read the time from now, for timestamps of
+// sessions, interactions, and other artifacts. The default is `time.Now`.
+func WithClock(now func() time.Time) Option {
+	return func(w *Workspace) { w.clock = now }
+}
+
+// now returns the current time according to the workspace's clock.
+func (w *Workspace) now() time.Time {
+	if w.clock == nil {
+		return time.Now()
+	}
+	return w.clock()
+}
+
+// logToLogger writes action to the logger given with `WithLogger`.
+func (w *Workspace) logToLogger(action string) {
+	if rest, ok := strings.CutPrefix(action, "Warning: "); ok {
+		w.logger.Warn(rest)
+		return
+	}
+	if rest, ok := strings.CutPrefix(action, "Error: "); ok {
+		w.logger.Error(rest)
+		return
+	}
+	w.logger.Info(action)
+}
+
+// Settings returns the workspace's settings, including the machine-local ones.
+// The result shares its slices with the workspace and must not be modified;
+// use the workspace's setters, such as `SetSyncConfig`, to change settings.
+func (w *Workspace) Settings() Settings {
+	return w.Context.Settings
+}
+
+// Project returns the metadata of the workspace's project.
+func (w *Workspace) Project() Project {
+	return w.Context.Project
+}
+
+// IsArchived reports whether id names an archived session.
+func (w *Workspace) IsArchived(id string) bool {
+	_, ok := w.Context.Indexes.ArchivedSessions[id]
+	return ok
+}
@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkspaceOptions(t *testing.T) {
+	ctx := t.Context()
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	var logs bytes.Buffer
+	w, err := NewWorkspace("/project",
+		WithStorage(NewMemoryStorage()),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetGlobalDir("")
+	if err := w.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := w.StartSession(ctx, "clock", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.Metadata.CreatedAt.Equal(now) {
+		t.Errorf("session created at %s, want %s", session.Metadata.CreatedAt, now)
+	}
+	if !strings.Contains(logs.String(), "level=INFO") {
+		t.Errorf("logger received %q, want the workspace's actions", logs.String())
+	}
+	if entries, err := w.storage.ReadDir("/project/.AIWorkspace/logs"); err != nil || len(entries) != 0 {
+		t.Errorf("logs/ holds %d files (%v), want none with a logger", len(entries), err)
+	}
+}
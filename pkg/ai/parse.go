@@ -0,0 +1,296 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Repairs `repairJSON` applies to model output that is not valid JSON as is.
+const (
+	RepairFence         = "code fence"         // The object was wrapped in a Markdown code fence.
+	RepairProse         = "surrounding prose"  // Text before or after the object was dropped.
+	RepairEscaped       = "escaped JSON"       // The object was itself encoded as a JSON string.
+	RepairControlChars  = "control characters" // Raw newlines or tabs inside strings were escaped.
+	RepairTrailingComma = "trailing comma"     // Commas before a closing brace or bracket were removed.
+)
+
+// ParseError describes where model output failed to parse as JSON, after
+// any repairs.
+type ParseError struct {
+	Line, Column int      // 1-based position of the error within the repaired text.
+	Snippet      string   // Text around the error.
+	Repairs      []string // Repairs applied before parsing failed.
+	Err          error    // Underlying decoding error.
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%v at line %d, column %d near %q: %v", ErrInvalidJSON, e.Line, e.Column, e.Snippet, e.Err)
+	if len(e.Repairs) > 0 {
+		msg += fmt.Sprintf(" (after repairing: %s)", strings.Join(e.Repairs, ", "))
+	}
+	return msg
+}
+
+// Unwrap makes the error match `ErrInvalidJSON` with `errors.Is`.
+func (e *ParseError) Unwrap() error { return ErrInvalidJSON }
+
+// parseErrorSnippet is how many bytes of context `newParseError` shows on each side of an error.
+const parseErrorSnippet = 20
+
+// newParseError locates err, returned by decoding text, within text.
+func newParseError(text string, err error, repairs []string) *ParseError {
+	offset := len(text)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		offset = int(syntaxErr.Offset)
+	} else if errors.As(err, &typeErr) {
+		offset = int(typeErr.Offset)
+	}
+	offset = min(offset, len(text))
+	line := strings.Count(text[:offset], "\n") + 1
+	column := offset - strings.LastIndex(text[:offset], "\n")
+	start, end := max(0, offset-parseErrorSnippet), min(len(text), offset+parseErrorSnippet)
+	return &ParseError{Line: line, Column: column, Snippet: text[start:end], Repairs: repairs, Err: err}
+}
+
+// decodeReply decodes text, a model's structured reply, into v. Text that
+// is not valid JSON as is goes through `repairJSON` first. It returns the
+// JSON decoded and the repairs applied, or a `*ParseError`.
+func decodeReply(text string, v any) (string, []string, error) {
+	text = strings.TrimSpace(text)
+	if err := json.Unmarshal([]byte(text), v); err == nil {
+		return text, nil, nil
+	}
+	repaired, repairs := repairJSON(text)
+	if err := json.Unmarshal([]byte(repaired), v); err != nil {
+		return repaired, repairs, newParseError(repaired, err, repairs)
+	}
+	return repaired, repairs, nil
+}
+
+// repairJSON works around the ways models commonly wrap or mangle a JSON
+// object: it extracts the first balanced object from fences and prose,
+// decodes it if it was escaped, and fixes raw control characters and
+// trailing commas. It returns the result and the repairs applied.
+func repairJSON(text string) (string, []string) {
+	var repairs []string
+	var decoded string
+	if strings.HasPrefix(text, `"`) && json.Unmarshal([]byte(text), &decoded) == nil {
+		text = strings.TrimSpace(decoded)
+		repairs = append(repairs, RepairEscaped)
+	}
+
+	if start := strings.IndexByte(text, '{'); start >= 0 {
+		end := balancedObjectEnd(text, start)
+		outside := text[:start] + text[end:]
+		if strings.Contains(outside, "```") {
+			repairs = append(repairs, RepairFence)
+			outside = strings.ReplaceAll(outside, "```json", "")
+			outside = strings.ReplaceAll(outside, "```", "")
+		}
+		if strings.TrimSpace(outside) != "" {
+			repairs = append(repairs, RepairProse)
+		}
+		text = text[start:end]
+	}
+
+	// An object whose quotes are all escaped was encoded as a string without
+	// the enclosing quotes.
+	if strings.Contains(text, `\"`) && !strings.Contains(strings.ReplaceAll(text, `\"`, ""), `"`) &&
+		json.Unmarshal([]byte(`"`+text+`"`), &decoded) == nil {
+		text = decoded
+		repairs = append(repairs, RepairEscaped)
+	}
+
+	if fixed, changed := escapeControlChars(text); changed {
+		text = fixed
+		repairs = append(repairs, RepairControlChars)
+	}
+	if fixed, changed := removeTrailingCommas(text); changed {
+		text = fixed
+		repairs = append(repairs, RepairTrailingComma)
+	}
+	return text, repairs
+}
+
+// scanJSON calls visit with the index of each byte of text, and whether the
+// byte is inside a string literal, escapes included, or is a quote.
+func scanJSON(text string, visit func(i int, inString bool) bool) {
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		literal := inString || c == '"'
+		if !visit(i, literal) {
+			return
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		}
+	}
+}
+
+// balancedObjectEnd returns the index just past the brace closing the
+// object opened at start, or len(text) if the object is not closed.
+func balancedObjectEnd(text string, start int) int {
+	end, depth := len(text), 0
+	scanJSON(text[start:], func(i int, inString bool) bool {
+		if inString {
+			return true
+		}
+		switch text[start+i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				end = start + i + 1
+				return false
+			}
+		}
+		return true
+	})
+	return end
+}
+
+// escapeControlChars escapes the raw newlines, carriage returns, and tabs
+// inside the string literals of text, which JSON forbids.
+func escapeControlChars(text string) (string, bool) {
+	var b strings.Builder
+	changed := false
+	scanJSON(text, func(i int, inString bool) bool {
+		c := text[i]
+		replacement := ""
+		if inString {
+			switch c {
+			case '\n':
+				replacement = `\n`
+			case '\r':
+				replacement = `\r`
+			case '\t':
+				replacement = `\t`
+			}
+		}
+		if replacement != "" {
+			b.WriteString(replacement)
+			changed = true
+		} else {
+			b.WriteByte(c)
+		}
+		return true
+	})
+	return b.String(), changed
+}
+
+// removeTrailingCommas removes the commas of text that are followed only by
+// whitespace before a closing brace or bracket.
+func removeTrailingCommas(text string) (string, bool) {
+	var b strings.Builder
+	changed := false
+	scanJSON(text, func(i int, inString bool) bool {
+		if !inString && text[i] == ',' {
+			rest := strings.TrimLeft(text[i+1:], " \t\r\n")
+			if rest != "" && (rest[0] == '}' || rest[0] == ']') {
+				changed = true
+				return true
+			}
+		}
+		b.WriteByte(text[i])
+		return true
+	})
+	return b.String(), changed
+}
+
+// ParseStats counts how model replies were parsed, to tell how often models
+// need the tolerant parser or defeat it.
+type ParseStats struct {
+	Strict      int            `json:"strict"`                // Replies that were valid JSON as is.
+	Repaired    int            `json:"repaired"`              // Replies parsed after repairs.
+	Failed      int            `json:"failed"`                // Replies that could not be parsed.
+	Reformatted int            `json:"reformatted,omitempty"` // Failed replies the model resent correctly when asked (see `Settings.ReformatAttempts`).
+	Fallbacks   int            `json:"fallbacks,omitempty"`   // Failed replies kept as raw content after every request to resend failed.
+	Repairs     map[string]int `json:"repairs,omitempty"`     // Times each repair was applied.
+	LastFailure string         `json:"lastFailure,omitempty"` // Diagnostic of the latest failure.
+	LastFailed  time.Time      `json:"lastFailed,omitempty"`  // When the latest failure happened.
+}
+
+// parseStatsMu serializes updates of the parse statistics file.
+var parseStatsMu sync.Mutex
+
+// parseStatsPath returns the sidecar file holding the workspace's
+// `ParseStats`. Like the session state, it is not a durable artifact and is
+// excluded from the integrity manifest.
+func (w *Workspace) parseStatsPath() string {
+	return filepath.Join(w.RootDir, "parse-stats.json")
+}
+
+// ParseStats returns the parse statistics of the workspace.
+func (w *Workspace) ParseStats() (ParseStats, error) {
+	var stats ParseStats
+	data, err := w.storage.ReadFile(w.parseStatsPath())
+	if os.IsNotExist(err) {
+		return stats, nil
+	} else if err != nil {
+		return stats, fmt.Errorf("failed to read parse statistics: %w", err)
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return stats, fmt.Errorf("failed to parse parse statistics: %w", err)
+	}
+	return stats, nil
+}
+
+// recordParse counts the parse of a reply that needed repairs, or failed
+// with err.
+func (w *Workspace) recordParse(repairs []string, err error) {
+	w.updateParseStats(func(stats *ParseStats) {
+		switch {
+		case err != nil:
+			stats.Failed++
+			stats.LastFailure, stats.LastFailed = err.Error(), w.now()
+			w.logAction(fmt.Sprintf("Warning: Could not parse model reply: %v", err))
+		case len(repairs) > 0:
+			stats.Repaired++
+			w.logAction(fmt.Sprintf("Repaired model reply: %s", strings.Join(repairs, ", ")))
+		default:
+			stats.Strict++
+		}
+		if stats.Repairs == nil && len(repairs) > 0 {
+			stats.Repairs = make(map[string]int)
+		}
+		for _, r := range repairs {
+			stats.Repairs[r]++
+		}
+	})
+}
+
+// updateParseStats applies update to the workspace's parse statistics.
+// Statistics only inform, so failures to update them are logged.
+func (w *Workspace) updateParseStats(update func(stats *ParseStats)) {
+	parseStatsMu.Lock()
+	defer parseStatsMu.Unlock()
+	stats, err := w.ParseStats()
+	if err != nil {
+		w.logAction(fmt.Sprintf("Warning: %v", err))
+		stats = ParseStats{}
+	}
+	update(&stats)
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		w.logAction(fmt.Sprintf("Warning: Could not encode parse statistics: %v", err))
+		return
+	}
+	if err := w.storage.WriteFile(w.parseStatsPath(), data, 0644); err != nil {
+		w.logAction(fmt.Sprintf("Warning: Could not write parse statistics: %v", err))
+	}
+}
@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		repairs []string
+	}{
+		{"fenced", "```json\n{\"a\": 1}\n```", `{"a": 1}`, []string{RepairFence}},
+		{"prose before", "Here is the reply:\n{\"a\": 1}", `{"a": 1}`, []string{RepairProse}},
+		{"prose after", "{\"a\": 1}\nHope this helps!", `{"a": 1}`, []string{RepairProse}},
+		{"braces in strings", "Sure: {\"a\": \"}{\"} done", `{"a": "}{"}`, []string{RepairProse}},
+		{"escaped string", `"{\"a\": 1}"`, `{"a": 1}`, []string{RepairEscaped}},
+		{"escaped without quotes", `{\"a\": \"b\"}`, `{"a": "b"}`, []string{RepairEscaped}},
+		{"raw newline", "{\"a\": \"line\nbreak\"}", `{"a": "line\nbreak"}`, []string{RepairControlChars}},
+		{"trailing commas", "{\"a\": [1, 2,], \"b\": 3,\n}", "{\"a\": [1, 2], \"b\": 3\n}", []string{RepairTrailingComma}},
+		{"comma in string", `{"a": "x,}"}`, `{"a": "x,}"}`, nil},
+		{"unclosed", `{"a": {"b": 1}`, `{"a": {"b": 1}`, nil},
+		{"several", "```\n{\"a\": \"x\ty\",}\n```", `{"a": "x\ty"}`, []string{RepairFence, RepairControlChars, RepairTrailingComma}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repairs := repairJSON(tt.in)
+			if got != tt.want {
+				t.Errorf("repairJSON(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !slices.Equal(repairs, tt.repairs) {
+				t.Errorf("repairJSON(%q) repairs = %v, want %v", tt.in, repairs, tt.repairs)
+			}
+		})
+	}
+}
+
+func TestBalancedObjectEnd(t *testing.T) {
+	tests := []struct {
+		in    string
+		start int
+		want  int
+	}{
+		{`{"a": 1} tail`, 0, 8},
+		{`x {"a": [1, {"b": 2}]} y`, 2, 22},
+		{`{"a": "\"}"}`, 0, 12},
+		{`{"a": 1`, 0, 7},
+	}
+	for _, tt := range tests {
+		if got := balancedObjectEnd(tt.in, tt.start); got != tt.want {
+			t.Errorf("balancedObjectEnd(%q, %d) = %d, want %d", tt.in, tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeControlChars(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		changed bool
+	}{
+		{"{\"a\": \"x\ny\"}", `{"a": "x\ny"}`, true},
+		{"{\"a\": \"x\r\n\ty\"}", `{"a": "x\r\n\ty"}`, true},
+		{"{\n\t\"a\": 1\n}", "{\n\t\"a\": 1\n}", false},
+		{`{"a": "x\"` + "\n" + `"}`, `{"a": "x\"\n"}`, true},
+	}
+	for _, tt := range tests {
+		got, changed := escapeControlChars(tt.in)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("escapeControlChars(%q) = %q, %v, want %q, %v", tt.in, got, changed, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestRemoveTrailingCommas(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		changed bool
+	}{
+		{`{"a": 1,}`, `{"a": 1}`, true},
+		{"[1,\n ]", "[1\n ]", true},
+		{`{"a": [1,], "b": {"c": 2,},}`, `{"a": [1], "b": {"c": 2}}`, true},
+		{`{"a": ",}", "b": 1}`, `{"a": ",}", "b": 1}`, false},
+		{`[1, 2]`, `[1, 2]`, false},
+	}
+	for _, tt := range tests {
+		got, changed := removeTrailingCommas(tt.in)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("removeTrailingCommas(%q) = %q, %v, want %q, %v", tt.in, got, changed, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestDecodeReply(t *testing.T) {
+	var v struct {
+		Content string `json:"content"`
+	}
+	if _, repairs, err := decodeReply("  {\"content\": \"ok\"}  ", &v); err != nil || repairs != nil || v.Content != "ok" {
+		t.Errorf("decodeReply(valid) = %v, %v, content %q", repairs, err, v.Content)
+	}
+
+	_, _, err := decodeReply("{\"content\": \"a\"\n\"b\": 1}", &v)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("decodeReply(invalid) error = %v, want a *ParseError", err)
+	}
+	if parseErr.Line != 2 {
+		t.Errorf("decodeReply(invalid) error at line %d, want line 2", parseErr.Line)
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(parseErr.Err, &syntaxErr) {
+		t.Errorf("decodeReply(invalid) underlying error = %v, want a *json.SyntaxError", parseErr.Err)
+	}
+}
@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// continuePrompt asks the model to finish a reply that was cut off.
+const continuePrompt = "Your last reply was cut off. Continue it exactly where it stopped, " +
+	"without repeating anything already written, so that the two parts together form the complete reply."
+
+// maxStitchOverlap is the longest repeated text `stitchReply` looks for where
+// a continuation starts over part of the reply it continues.
+const maxStitchOverlap = 200
+
+// partialReply is a reply that was cut off, kept so that `ContinueResponse`
+// can resume it. Cut-off replies are not saved to the session until they
+// are complete.
+type partialReply struct {
+	message  SavedMessage     // Message the reply answers, as sent.
+	contents []*genai.Content // Chat history up to and including the message.
+	raw      string           // Model output received so far.
+	save     bool             // Whether to record the exchange once complete.
+	secrets  []SecretFinding  // Secrets found in the message.
+}
+
+// truncated reports whether resp stopped because it hit the output token limit.
+func truncated(resp *genai.GenerateContentResponse) bool {
+	return resp != nil && len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens
+}
+
+// cutOff keeps p for `ContinueResponse` and returns what can be salvaged of
+// its output with an `*IncompleteError`. resp is the last response received,
+// if any, and cause the failure that interrupted the reply, if any.
+func (g *GeminiAIClient) cutOff(p *partialReply, resp *genai.GenerateContentResponse, cause error) (Response, error) {
+	g.partial = p
+	response := salvageReply(p.raw)
+	response.Provider = ProviderGemini
+	response.Model = g.model
+	response.Secrets = p.secrets
+	if resp != nil {
+		response.Usage = geminiUsage(resp.UsageMetadata)
+		if len(resp.Candidates) > 0 {
+			response.FinishReason = string(resp.Candidates[0].FinishReason)
+		}
+	}
+	if cause != nil {
+		cause = providerError(cause)
+	}
+	g.workspace.logAction(fmt.Sprintf("Warning: Model reply was cut off after %d bytes", len(p.raw)))
+	return response, &IncompleteError{FinishReason: response.FinishReason, Err: cause}
+}
+
+// ContinueResponse asks the model to finish the latest reply, which was cut
+// off with `ErrResponseIncomplete`, calling onChunk, if not nil, with each
+// piece of new output. The continuation is stitched to the output received
+// before, and the complete reply is parsed and saved like that of
+// `SendMessage`. If it is cut off again, it can be continued again.
+func (g *GeminiAIClient) ContinueResponse(ctx context.Context, onChunk func(text string)) (Response, error) {
+	p := g.partial
+	if p == nil {
+		return Response{}, errors.New("the latest reply was not cut off; there is nothing to continue")
+	}
+	contents := append(append([]*genai.Content{}, p.contents...),
+		genai.NewContentFromText(p.raw, genai.RoleModel),
+		genai.NewContentFromText(continuePrompt, genai.RoleUser))
+
+	started := time.Now()
+	continuation, last, err := g.stream(ctx, g.model, contents, onChunk)
+	latency := time.Since(started)
+	p.raw = stitchReply(p.raw, continuation)
+	if continuation != "" && (err != nil || truncated(last)) {
+		return g.cutOff(p, last, err)
+	} else if err != nil {
+		return Response{}, fmt.Errorf("failed to get response from Gemini: %w", providerError(err))
+	} else if last == nil {
+		return Response{}, errors.New("no response content received from Gemini model")
+	}
+
+	g.partial = nil
+	history := append(append([]*genai.Content{}, p.contents...), genai.NewContentFromText(p.raw, genai.RoleModel))
+	if g.chat, err = g.client.Chats.Create(ctx, g.model, g.config, history); err != nil {
+		return Response{}, fmt.Errorf("failed to continue the chat: %w", err)
+	}
+	g.workspace.logAction(fmt.Sprintf("Continued a cut-off model reply with %d bytes", len(continuation)))
+	response, err := g.finishResponse(ctx, p.message, p.raw, last, latency, p.save)
+	response.Secrets = p.secrets
+	return response, err
+}
+
+// stitchReply joins the output of a reply and its continuation. Models
+// sometimes start over instead of continuing, or repeat the last few words
+// before continuing: a continuation that is a complete reply on its own
+// replaces the output, and text it repeats is dropped.
+func stitchReply(output, continuation string) string {
+	if _, err := parseAIResponse(continuation, nil); err == nil {
+		return strings.TrimSpace(continuation)
+	}
+	for n := min(maxStitchOverlap, len(output), len(continuation)); n > 0; n-- {
+		if strings.HasSuffix(output, continuation[:n]) {
+			return output + continuation[n:]
+		}
+	}
+	return output + continuation
+}
+
+// salvageReply recovers what it can of raw, the output of a reply that was
+// cut off: the think, summary, and content strings written so far. Output
+// that is not JSON is taken as content.
+func salvageReply(raw string) Response {
+	if response, err := parseAIResponse(raw, nil); err == nil {
+		return response
+	}
+	start := strings.IndexByte(raw, '{')
+	if start < 0 {
+		return Response{Summary: "Reply cut off.", Content: raw}
+	}
+	response := Response{
+		Think:   partialField(raw[start:], "think"),
+		Summary: partialField(raw[start:], "summary"),
+		Content: partialField(raw[start:], "content"),
+	}
+	if response.Summary == "" {
+		response.Summary = "Reply cut off."
+	}
+	return response
+}
+
+// partialUnicodeEscape matches a \u escape cut off at the end of a JSON string.
+var partialUnicodeEscape = regexp.MustCompile(`\\u[0-9a-fA-F]{0,3}$`)
+
+// partialField returns the value of the string field key of the JSON object
+// at the start of text, which may end before the value does.
+func partialField(text, key string) string {
+	loc := regexp.MustCompile(`"` + regexp.QuoteMeta(key) + `"\s*:\s*"`).FindStringIndex(text)
+	if loc == nil {
+		return ""
+	}
+	value, closed := text[loc[1]:], false
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+		} else if value[i] == '"' {
+			value, closed = value[:i], true
+			break
+		}
+	}
+	if !closed {
+		// Drop an escape sequence the cut split.
+		if trailing := len(value) - len(strings.TrimRight(value, `\`)); trailing%2 == 1 {
+			value = value[:len(value)-1]
+		}
+		value = partialUnicodeEscape.ReplaceAllString(value, "")
+	}
+	var decoded string
+	if err := json.Unmarshal([]byte(`"`+value+`"`), &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
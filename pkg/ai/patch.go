@@ -0,0 +1,314 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PatchField is the field of a role's schema in which the role replies with
+// its changes to the project as a unified diff, for `ApplyPatch`. A role asks
+// for patches by declaring it as a string in its `Schema`.
+const PatchField = "patch"
+
+// FilePatch holds the changes a unified diff makes to one file.
+type FilePatch struct {
+	OldPath string      // Path of the file before the change, relative to the project root; "" for a new file.
+	NewPath string      // Path of the file after the change; "" for a deleted file.
+	Hunks   []PatchHunk // Changed regions, in order.
+}
+
+// PatchHunk is a hunk of a unified diff.
+type PatchHunk struct {
+	OldStart int      // First line of the hunk in the old version, from 1.
+	Lines    []string // Lines of the hunk, each prefixed by ' ', '-', or '+'.
+}
+
+// AppliedPatch records a patch applied to the project with `ApplyPatch`, in
+// `Session.Patches`.
+type AppliedPatch struct {
+	ID        string    `json:"id"`
+	ChatID    string    `json:"chatId,omitempty"` // ID of the `Chat` entry whose reply proposed the patch, if any.
+	Files     []string  `json:"files"`            // Files created, changed, or deleted, relative to the project root.
+	Diff      string    `json:"diff"`             // The unified diff as applied.
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// ParsePatch parses a unified diff, as printed by `git diff` or `diff -u`,
+// into the changes it makes to each file. Paths lose their "a/" and "b/"
+// prefixes. Blank lines inside hunks are read as blank context lines, which
+// models often emit without the leading space.
+func ParsePatch(diff string) ([]FilePatch, error) {
+	var (
+		patches []FilePatch
+		current *FilePatch
+		hunk    *PatchHunk
+	)
+	stripPath := func(line, prefix string) string {
+		path := strings.TrimSpace(strings.TrimPrefix(line, prefix))
+		if tab := strings.IndexByte(path, '\t'); tab >= 0 {
+			path = path[:tab] // Drop the timestamp of `diff -u`.
+		}
+		if path == "/dev/null" {
+			return ""
+		}
+		if len(path) > 2 && (path[:2] == "a/" || path[:2] == "b/") {
+			path = path[2:]
+		}
+		return path
+	}
+	endHunk := func() {
+		if hunk != nil {
+			current.Hunks = append(current.Hunks, *hunk)
+			hunk = nil
+		}
+	}
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if current != nil {
+				endHunk()
+				patches = append(patches, *current)
+			}
+			current = &FilePatch{OldPath: stripPath(line, "--- ")}
+		case current != nil && hunk == nil && len(current.Hunks) == 0 && strings.HasPrefix(line, "+++ "):
+			current.NewPath = stripPath(line, "+++ ")
+			if current.OldPath == "" && current.NewPath == "" {
+				return nil, fmt.Errorf("invalid patch: line %d: no file to change", i+1)
+			}
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("invalid patch: line %d: hunk before any file header", i+1)
+			}
+			endHunk()
+			start, err := parseOldStart(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid patch: line %d: %w", i+1, err)
+			}
+			hunk = &PatchHunk{OldStart: start}
+		case hunk != nil && line == "":
+			hunk.Lines = append(hunk.Lines, " ")
+		case hunk != nil && strings.ContainsRune(" -+", rune(line[0])):
+			hunk.Lines = append(hunk.Lines, line)
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file": the file's last newline is kept as is.
+		default:
+			endHunk() // Headers such as "diff --git" and "index" end a file's hunks.
+		}
+	}
+	if current != nil {
+		endHunk()
+		patches = append(patches, *current)
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("invalid patch: no file headers found")
+	}
+	return patches, nil
+}
+
+// parseOldStart returns the first line in the old version of the hunk with a
+// header such as "@@ -10,7 +12,9 @@ func main() {". A hunk removing no lines
+// names the line it follows, so it starts on the next one.
+func parseOldStart(header string) (int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		return 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	from, length, found := strings.Cut(strings.TrimPrefix(fields[1], "-"), ",")
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	if found && length == "0" {
+		start++
+	}
+	return start, nil
+}
+
+// apply returns content with the hunks applied. Each hunk's context and
+// removed lines must appear in content; they are looked for at the line the
+// hunk names first, then ever further from it, so hunks still apply when
+// earlier lines were added or removed.
+func (p FilePatch) apply(content string) (string, error) {
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	var out []string
+	next := 0 // First line of lines not yet copied to out.
+	for n, h := range p.Hunks {
+		var old, replacement []string
+		for _, line := range h.Lines {
+			if line[0] != '+' {
+				old = append(old, line[1:])
+			}
+			if line[0] != '-' {
+				replacement = append(replacement, line[1:])
+			}
+		}
+		at := findLines(lines, old, max(h.OldStart-1, next), next)
+		if at < 0 {
+			return "", fmt.Errorf("%w: hunk %d of %s does not match the file at line %d", ErrPatchConflict, n+1, p.path(), h.OldStart)
+		}
+		out = append(append(out, lines[next:at]...), replacement...)
+		next = at + len(old)
+	}
+	out = append(out, lines[next:]...)
+	if len(out) == 0 {
+		return "", nil
+	}
+	return strings.Join(out, "\n") + "\n", nil
+}
+
+// findLines returns where want appears in lines at or after from, searching
+// outwards from near, or -1 if it does not.
+func findLines(lines, want []string, near, from int) int {
+	matches := func(at int) bool {
+		if at < from || at+len(want) > len(lines) {
+			return false
+		}
+		for i, line := range want {
+			if strings.TrimRight(lines[at+i], " \t\r") != strings.TrimRight(line, " \t\r") {
+				return false
+			}
+		}
+		return true
+	}
+	for offset := 0; near-offset >= from || near+offset <= len(lines); offset++ {
+		if matches(near + offset) {
+			return near + offset
+		}
+		if offset > 0 && matches(near-offset) {
+			return near - offset
+		}
+	}
+	return -1
+}
+
+// path returns the path the patch is known by: its new path, or its old path
+// for a deleted file.
+func (p FilePatch) path() string {
+	if p.NewPath != "" {
+		return p.NewPath
+	}
+	return p.OldPath
+}
+
+// fileChange is a file `ApplyPatch` writes or removes, with what it held
+// before so the change can be rolled back.
+type fileChange struct {
+	path    string // Absolute path of the file.
+	content string // New content; ignored when remove is set.
+	remove  bool   // Whether the file is deleted.
+	existed bool   // Whether the file existed before the patch.
+	before  []byte // Content of the file before the patch.
+	mode    os.FileMode
+}
+
+// ApplyPatch applies diff, a unified diff with paths relative to the project
+// root, to the project's files, and records it in the active session's
+// `Patches` for audit. chatID is the `Chat` entry whose reply proposed it, if
+// any. Every hunk is checked against the working tree before anything is
+// written; if one does not match, `ErrPatchConflict` is returned and no file
+// is changed. If writing a file fails, the files already written are restored
+// before the error is returned.
+func (w *Workspace) ApplyPatch(chatID, diff string) (AppliedPatch, error) {
+	session, err := w.GetActiveSession()
+	if err != nil {
+		return AppliedPatch{}, err
+	}
+	if session == nil {
+		return AppliedPatch{}, ErrNoActiveSession
+	}
+	patches, err := ParsePatch(diff)
+	if err != nil {
+		return AppliedPatch{}, err
+	}
+
+	applied := AppliedPatch{ID: uuid.New().String(), ChatID: chatID, Diff: diff, AppliedAt: w.now()}
+	var changes []fileChange
+	for _, p := range patches {
+		ref, err := w.sourceRef(filepath.Join(w.projectDir(), filepath.FromSlash(p.path())))
+		if err != nil {
+			return AppliedPatch{}, fmt.Errorf("failed to apply patch: %w", err)
+		}
+		change := fileChange{path: w.sourcePath(ref), remove: p.NewPath == "", mode: 0644}
+		if info, err := os.Stat(change.path); err == nil {
+			if change.before, err = os.ReadFile(change.path); err != nil {
+				return AppliedPatch{}, fmt.Errorf("failed to read %s: %w", ref, err)
+			}
+			change.existed, change.mode = true, info.Mode().Perm()
+		} else if !os.IsNotExist(err) {
+			return AppliedPatch{}, fmt.Errorf("failed to check %s: %w", ref, err)
+		}
+		switch {
+		case p.OldPath == "" && change.existed:
+			return AppliedPatch{}, fmt.Errorf("%w: %s already exists", ErrPatchConflict, ref)
+		case p.OldPath != "" && !change.existed:
+			return AppliedPatch{}, fmt.Errorf("%w: %s does not exist", ErrPatchConflict, ref)
+		}
+		if change.content, err = p.apply(string(change.before)); err != nil {
+			return AppliedPatch{}, err
+		}
+		if change.remove && change.content != "" {
+			return AppliedPatch{}, fmt.Errorf("%w: %s has lines the patch does not delete", ErrPatchConflict, ref)
+		}
+		changes = append(changes, change)
+		applied.Files = append(applied.Files, ref)
+	}
+
+	for i, change := range changes {
+		if err := change.commit(); err != nil {
+			for _, done := range changes[:i] {
+				done.rollback()
+			}
+			return AppliedPatch{}, fmt.Errorf("failed to apply patch, changes rolled back: %w", err)
+		}
+	}
+	if err := w.updateSession(session.ID, func(s *Session) { s.Patches = append(s.Patches, applied) }); err != nil {
+		return applied, fmt.Errorf("failed to record patch: %w", err)
+	}
+	return applied, w.logAction(fmt.Sprintf("Applied patch %s to %s", applied.ID, strings.Join(applied.Files, ", ")))
+}
+
+// commit makes the change, writing the new content to a temporary file
+// renamed over the old one so the file is never left half written.
+func (c fileChange) commit() error {
+	if c.remove {
+		return os.Remove(c.path)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), "."+filepath.Base(c.path)+".patch-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(c.content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), c.mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// rollback undoes a committed change, restoring what the file held before.
+func (c fileChange) rollback() {
+	if !c.existed {
+		os.Remove(c.path)
+		return
+	}
+	os.WriteFile(c.path, c.before, c.mode)
+}
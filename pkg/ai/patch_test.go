@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	const original = "one\ntwo\nthree\nfour\nfive\n"
+	tests := []struct {
+		name      string
+		diff      string
+		want      map[string]string // Files after the patch; "" means deleted.
+		wantFiles []string
+		wantErr   error
+	}{
+		{
+			name: "changes a file",
+			diff: "--- a/a.txt\n+++ b/a.txt\n@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+			want: map[string]string{"a.txt": "one\ntwo\nTHREE\nfour\nfive\n"},
+		},
+		{
+			name: "finds hunks that moved",
+			diff: "--- a/a.txt\n+++ b/a.txt\n@@ -10,2 +10,3 @@\n four\n+four and a half\n five\n",
+			want: map[string]string{"a.txt": "one\ntwo\nthree\nfour\nfour and a half\nfive\n"},
+		},
+		{
+			name: "creates and deletes files",
+			diff: "diff --git a/new.txt b/new.txt\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+hello\n+world\n" +
+				"diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ /dev/null\n@@ -1,5 +0,0 @@\n-one\n-two\n-three\n-four\n-five\n",
+			want:      map[string]string{"new.txt": "hello\nworld\n", "a.txt": ""},
+			wantFiles: []string{"new.txt", "a.txt"},
+		},
+		{
+			name:    "rejects a hunk that does not match",
+			diff:    "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-deux\n+TWO\n",
+			want:    map[string]string{"a.txt": original},
+			wantErr: ErrPatchConflict,
+		},
+		{
+			name: "changes nothing if any file conflicts",
+			diff: "--- a/a.txt\n+++ b/a.txt\n@@ -1,1 +1,1 @@\n-one\n+ONE\n" +
+				"--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1 @@\n+again\n",
+			want:    map[string]string{"a.txt": original},
+			wantErr: ErrPatchConflict,
+		},
+		{
+			name:    "rejects paths outside the project",
+			diff:    "--- a/../outside.txt\n+++ b/../outside.txt\n@@ -0,0 +1 @@\n+x\n",
+			want:    map[string]string{"a.txt": original},
+			wantErr: errors.New("outside the project"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkspace(t)
+			if _, err := w.StartSession(t.Context(), "patch", ""); err != nil {
+				t.Fatal(err)
+			}
+			project := w.projectDir()
+			if err := os.WriteFile(filepath.Join(project, "a.txt"), []byte(original), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			applied, err := w.ApplyPatch("chat-1", tt.diff)
+			switch {
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("ApplyPatch() succeeded, want %v", tt.wantErr)
+			case tt.wantErr == ErrPatchConflict && !errors.Is(err, ErrPatchConflict):
+				t.Fatalf("ApplyPatch() = %v, want %v", err, ErrPatchConflict)
+			case tt.wantErr == nil && err != nil:
+				t.Fatal(err)
+			}
+
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(project, name))
+				if want == "" {
+					if !os.IsNotExist(err) {
+						t.Errorf("%s still exists (%v), want it deleted", name, err)
+					}
+					continue
+				}
+				if string(data) != want {
+					t.Errorf("%s = %q, want %q", name, data, want)
+				}
+			}
+
+			session, err := w.GetActiveSession()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != nil {
+				if len(session.Patches) != 0 {
+					t.Errorf("session records %d patches after a failed apply, want none", len(session.Patches))
+				}
+				return
+			}
+			if len(session.Patches) != 1 || session.Patches[0].ID != applied.ID || session.Patches[0].ChatID != "chat-1" {
+				t.Fatalf("session.Patches = %+v, want the applied patch", session.Patches)
+			}
+			if tt.wantFiles != nil && !slices.Equal(applied.Files, tt.wantFiles) {
+				t.Errorf("Files = %v, want %v", applied.Files, tt.wantFiles)
+			}
+		})
+	}
+}
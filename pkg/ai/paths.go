@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// reservedNames are the device names Windows reserves in every directory,
+// whatever their case. They are listed in lower case, the only case
+// `validateName` allows.
+var reservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// maxNameLength is the longest name `validateName` accepts, in bytes.
+const maxNameLength = 64
+
+// validateName returns an `*InvalidNameError` if name, the name of a role,
+// the ID of a preference, or another name of the given kind used as a file
+// name, is not a slug: 1 to maxNameLength lowercase ASCII letters, digits,
+// hyphens, and underscores, starting with a letter or digit. Slugs cannot
+// contain path separators or escape their directory, and are also refused if
+// Windows reserves them, such as "con".
+func validateName(kind, name string) error {
+	invalid := func(reason string) error {
+		return &InvalidNameError{Kind: kind, Name: name, Reason: reason}
+	}
+	switch {
+	case name == "":
+		return invalid("it is empty")
+	case len(name) > maxNameLength:
+		return invalid(fmt.Sprintf("it is longer than %d characters", maxNameLength))
+	case strings.ContainsAny(name, `/\`):
+		return invalid("it contains a path separator")
+	case reservedNames[name]:
+		return invalid("it is reserved on Windows")
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case (r == '-' || r == '_') && i > 0:
+		default:
+			return invalid("only lowercase letters, digits, hyphens, and underscores are allowed, starting with a letter or digit")
+		}
+	}
+	return nil
+}
+
+// sourceRef returns how path, a source file relative to the current directory
+// or absolute, is recorded in `Session.Sources`: relative to the project
+// root, with forward slashes, so sessions read the same on every platform and
+// after the project moves. It returns an error if path is outside the project.
+func (w *Workspace) sourceRef(path string) (string, error) {
+	projectDir, err := filepath.Abs(w.projectDir())
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve project directory: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve source %s: %w", path, err)
+	}
+	rel, err := filepath.Rel(projectDir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("source file %s is outside the project %s", path, projectDir)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// sourcePath returns the file of ref, an entry of `Session.Sources`. Entries
+// recorded by `sourceRef` are relative to the project root; older sessions
+// may hold absolute paths, which are used as is, and separators of either
+// kind.
+func (w *Workspace) sourcePath(ref string) string {
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(w.projectDir(), filepath.FromSlash(strings.ReplaceAll(ref, `\`, "/")))
+}
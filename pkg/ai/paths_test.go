@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"reviewer", true},
+		{"code_review-2", true},
+		{"console", true},
+		{"8f14e45f-ceea-467f-a0e6-7fd4a1e1d3a2", true},
+		{"", false},
+		{"..", false},
+		{"../evil", false},
+		{`..\evil`, false},
+		{"con", false},
+		{"CON", false},
+		{"nul.txt", false},
+		{"com1", false},
+		{"Reviewer", false},
+		{"code review", false},
+		{"-flag", false},
+		{strings.Repeat("a", 65), false},
+	}
+	for _, tt := range tests {
+		err := validateName("role", tt.name)
+		if (err == nil) != tt.ok {
+			t.Errorf("validateName(%q) = %v, want ok %v", tt.name, err, tt.ok)
+		}
+		var invalid *InvalidNameError
+		if err != nil && (!errors.Is(err, ErrInvalidName) || !errors.As(err, &invalid)) {
+			t.Errorf("validateName(%q) = %v, want an *InvalidNameError", tt.name, err)
+		}
+	}
+}
+
+func TestSaveRejectsInvalidNames(t *testing.T) {
+	w := newTestWorkspace(t)
+	if err := w.saveRole(Role{Name: "../evil"}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("saveRole(../evil) = %v, want ErrInvalidName", err)
+	}
+	if err := w.SavePreference(Preference{ID: "../../etc/passwd"}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("SavePreference(../../etc/passwd) = %v, want ErrInvalidName", err)
+	}
+	if err := w.moveToTrash(TrashRole, "../evil", filepath.Join(w.RootDir, "roles", "reviewer.json")); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("moveToTrash(../evil) = %v, want ErrInvalidName", err)
+	}
+	if err := w.SaveTemplate(Template{Name: "../evil", Content: "x"}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("SaveTemplate(../evil) = %v, want ErrInvalidName", err)
+	}
+	if _, err := w.LoadTemplate("../evil"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("LoadTemplate(../evil) = %v, want ErrInvalidName", err)
+	}
+	if err := w.DeleteTemplate("../evil"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("DeleteTemplate(../evil) = %v, want ErrInvalidName", err)
+	}
+	if _, err := w.LoadSchedule("../evil"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("LoadSchedule(../evil) = %v, want ErrInvalidName", err)
+	}
+	if err := w.DeleteSchedule("../evil"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("DeleteSchedule(../evil) = %v, want ErrInvalidName", err)
+	}
+}
+
+func TestSourceRef(t *testing.T) {
+	w := newTestWorkspace(t)
+	project := w.projectDir()
+	tests := []struct {
+		path string
+		want string // "" if the path is rejected.
+	}{
+		{filepath.Join(project, "main.go"), "main.go"},
+		{filepath.Join(project, "pkg", "ai", "..", "ui", "view.go"), "pkg/ui/view.go"},
+		{filepath.Join(project, "..", "elsewhere.go"), ""},
+	}
+	for _, tt := range tests {
+		got, err := w.sourceRef(tt.path)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("sourceRef(%q) = %q, want an error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("sourceRef(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+		if back := w.sourcePath(got); back != filepath.Clean(tt.path) {
+			t.Errorf("sourcePath(%q) = %q, want %q", got, back, filepath.Clean(tt.path))
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Priority is the importance of a session. Sessions are listed by priority
+// after pinned sessions (see `ListArchivedSessions`).
+type Priority string
+
+// Session priorities, from least to most important.
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+)
+
+// Priorities lists the valid priorities, from least to most important.
+var Priorities = []Priority{PriorityLow, PriorityMedium, PriorityHigh}
+
+// defaultArchiveAfter is how long after its creation an unpinned session
+// becomes eligible for archiving.
+const defaultArchiveAfter = 7 * 24 * time.Hour
+
+// ParsePriority returns the priority named s, ignoring case.
+func ParsePriority(s string) (Priority, error) {
+	for _, p := range Priorities {
+		if strings.EqualFold(s, string(p)) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown priority '%s', expected low, medium, or high", s)
+}
+
+// rank orders priorities for sorting. Unknown values, such as those of
+// sessions saved before priorities were validated, rank as medium.
+func (p Priority) rank() int {
+	switch p {
+	case PriorityLow:
+		return 0
+	case PriorityHigh:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// sortSessions orders summaries with pinned sessions first, then by
+// descending priority, then most recently updated first, then by ID.
+func sortSessions(sessions []SessionSummary) {
+	sort.Slice(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.Priority.rank() != b.Priority.rank() {
+			return a.Priority.rank() > b.Priority.rank()
+		}
+		if !a.LastUpdated.Equal(b.LastUpdated) {
+			return a.LastUpdated.After(b.LastUpdated)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// PinSession pins the active or archived session with the given ID. Pinned
+// sessions are listed first and are never eligible for automatic archiving
+// or pruning: their `ArchiveAfter` is cleared.
+func (w *Workspace) PinSession(id string) error {
+	return w.setPinned(id, true)
+}
+
+// UnpinSession unpins the session with the given ID, making it eligible for
+// archiving again a week from now.
+func (w *Workspace) UnpinSession(id string) error {
+	return w.setPinned(id, false)
+}
+
+// setPinned pins or unpins the session with the given ID.
+func (w *Workspace) setPinned(id string, pinned bool) error {
+	err := w.updateSession(id, func(session *Session) {
+		session.Metadata.Pinned = pinned
+		session.Metadata.ArchiveAfter = time.Time{}
+		if !pinned {
+			session.Metadata.ArchiveAfter = w.now().Add(defaultArchiveAfter)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	action := "Pinned"
+	if !pinned {
+		action = "Unpinned"
+	}
+	return w.logAction(fmt.Sprintf("%s session %s", action, id))
+}
+
+// SetSessionPriority sets the priority of the active or archived session with
+// the given ID.
+func (w *Workspace) SetSessionPriority(id string, priority Priority) error {
+	if _, err := ParsePriority(string(priority)); err != nil {
+		return err
+	}
+	if err := w.updateSession(id, func(session *Session) { session.Metadata.Priority = priority }); err != nil {
+		return err
+	}
+	return w.logAction(fmt.Sprintf("Set priority of session %s to %s", id, priority))
+}
+
+// updateSession applies update to the active or archived session with the
+// given ID and saves it. An archived session is moved to the file its archive
+// name now calls for (see `archiveFileName`), and its `ArchivedSessions` index
+// entry is refreshed. It returns `ErrSessionNotFound` if no session has the ID.
+func (w *Workspace) updateSession(id string, update func(*Session)) error {
+	if active, err := w.GetActiveSession(); err != nil {
+		return err
+	} else if active != nil && active.ID == id {
+		update(active)
+		if err := w.saveSession(*active); err != nil {
+			return fmt.Errorf("failed to save session %s: %w", id, err)
+		}
+		return nil
+	}
+
+	if _, ok := w.Context.Indexes.ArchivedSessions[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	session, err := w.readArchive(w.archivedSessionPath(id))
+	if err != nil {
+		return fmt.Errorf("failed to read archived session %s: %w", id, err)
+	}
+	update(session)
+	if err := w.writeArchive(session); err != nil {
+		return err
+	}
+	if err := w.saveContext(w.Context); err != nil {
+		return fmt.Errorf("failed to update context after changing session %s: %w", id, err)
+	}
+	return nil
+}
+
+// ContextPin is a chat message or snippet pinned to a session so it is part
+// of the rebuilt context even after the turns around it are compacted or
+// removed. Messages are pinned by copy, so later edits to the history do not
+// change them.
+type ContextPin struct {
+	ID        string    `json:"id"`
+	ChatID    string    `json:"chatId,omitempty"` // ID of the `Chat` entry the text was pinned from; empty for snippets.
+	Content   string    `json:"content"`          // The pinned text.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AddContextPin pins content to the active or archived session sessionID.
+// chatID is the chat entry the content was taken from, or "" for a snippet.
+// A live chat of the active session must be reopened to see the pin.
+func (w *Workspace) AddContextPin(sessionID, chatID, content string) (ContextPin, error) {
+	if strings.TrimSpace(content) == "" {
+		return ContextPin{}, fmt.Errorf("cannot pin empty text")
+	}
+	pin := ContextPin{ID: uuid.New().String(), ChatID: chatID, Content: content, CreatedAt: w.now()}
+	if err := w.updateSession(sessionID, func(session *Session) { session.Pins = append(session.Pins, pin) }); err != nil {
+		return ContextPin{}, fmt.Errorf("failed to pin context: %w", err)
+	}
+	return pin, w.logAction(fmt.Sprintf("Pinned context %s to session %s", pin.ID, sessionID))
+}
+
+// RemoveContextPin unpins the context pin pinID from the session sessionID.
+func (w *Workspace) RemoveContextPin(sessionID, pinID string) error {
+	found := false
+	err := w.updateSession(sessionID, func(session *Session) {
+		for i, pin := range session.Pins {
+			if pin.ID == pinID {
+				session.Pins = append(session.Pins[:i], session.Pins[i+1:]...)
+				found = true
+				return
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unpin context: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("no context pin %s in session %s", pinID, sessionID)
+	}
+	return w.logAction(fmt.Sprintf("Unpinned context %s from session %s", pinID, sessionID))
+}
+
+// PinsMarkdown renders context pins as a numbered Markdown list, numbered as
+// the `/pin` command expects.
+func PinsMarkdown(pins []ContextPin) string {
+	if len(pins) == 0 {
+		return "_Nothing pinned to this session's context._\n"
+	}
+	var b strings.Builder
+	for i, pin := range pins {
+		source := "snippet"
+		if pin.ChatID != "" {
+			source = "message"
+		}
+		fmt.Fprintf(&b, "%d. %s _(%s, %s)_\n", i+1, strings.Join(strings.Fields(pin.Content), " "), source, pin.CreatedAt.Format("2006-01-02"))
+	}
+	return b.String()
+}
+
+// pinnedContext returns the text of the turn that replays a session's
+// context pins, or "" if it has none.
+func pinnedContext(pins []ContextPin) string {
+	if len(pins) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Keep the following pinned context in mind for the rest of our conversation:\n")
+	for _, pin := range pins {
+		fmt.Fprintf(&b, "\n---\n%s\n", pin.Content)
+	}
+	return b.String()
+}
@@ -0,0 +1,330 @@
+package ai
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// exportFormatVersion is the version of the archive layout written by `Export`.
+	exportFormatVersion = 1
+
+	// exportHeaderName is the archive entry describing the export itself.
+	exportHeaderName = "export.json"
+
+	// maxImportEntrySize bounds the size of a single archive entry read by `Import`.
+	maxImportEntrySize = 64 << 20
+)
+
+// ExportOptions controls which optional parts of the workspace `Export` includes.
+// Roles, preferences, sessions, and the context are always exported.
+type ExportOptions struct {
+	IncludeLogs  bool // Include the daily action logs under `logs/` and the audit log under `audit/`.
+	IncludeCache bool // Include derived data: the vector store and manifest backups.
+}
+
+// exportHeader is stored as `export.json` at the root of an exported archive.
+type exportHeader struct {
+	Version    int       `json:"version"`    // Archive layout version (see exportFormatVersion).
+	Workspace  string    `json:"workspace"`  // ID of the exported workspace.
+	Project    Project   `json:"project"`    // Project metadata of the exported workspace.
+	ExportedAt time.Time `json:"exportedAt"` // Timestamp of the export.
+}
+
+// ImportReport lists what `Import` did with each artifact found in an archive.
+// Paths are relative to the workspace root, using forward slashes.
+type ImportReport struct {
+	Added   []string // Artifacts written to the workspace.
+	Skipped []string // Artifacts already present locally, left untouched.
+}
+
+// exportable reports whether the workspace-relative path rel belongs in an export.
+// Machine-local files (the manifest, local settings, UI preferences, and session
+// state sidecar) are never exported.
+func exportable(rel string, opts ExportOptions) bool {
+	switch {
+	case rel == "context.json" || rel == "session.json" || rel == "docs.json":
+		return true
+	case strings.HasPrefix(rel, "roles/"), strings.HasPrefix(rel, "preferences/"), strings.HasPrefix(rel, "templates/"), strings.HasPrefix(rel, "flows/"), strings.HasPrefix(rel, "schedules/"), strings.HasPrefix(rel, "facts/"), strings.HasPrefix(rel, "sessions/"):
+		return true
+	case strings.HasPrefix(rel, "logs/"), strings.HasPrefix(rel, "audit/"):
+		return opts.IncludeLogs
+	case strings.HasPrefix(rel, "vectors/"), strings.HasPrefix(rel, "repomap/"), strings.HasPrefix(rel, "backups/"):
+		return opts.IncludeCache
+	}
+	return false
+}
+
+// Export writes the workspace to archivePath as a gzip-compressed tar archive that can
+// be moved to another machine and loaded with `Import`. If ctx is done before
+// every file is written, the export fails with ctx's error.
+func (w *Workspace) Export(ctx context.Context, archivePath string, opts ExportOptions) error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create export archive %s: %w", archivePath, err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	header, err := json.MarshalIndent(exportHeader{
+		Version:    exportFormatVersion,
+		Workspace:  w.Context.Workspace,
+		Project:    w.Context.Project,
+		ExportedAt: w.now(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode export header: %w", err)
+	}
+	if err := writeTarEntry(tw, exportHeaderName, header); err != nil {
+		return err
+	}
+
+	count := 0
+	err = walkStorage(w.storage, w.RootDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel := w.relPath(p)
+		if !exportable(rel, opts) {
+			return nil
+		}
+		data, err := w.storage.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		count++
+		return writeTarEntry(tw, rel, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export workspace: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish export archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish export archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write export archive %s: %w", archivePath, err)
+	}
+	return w.logAction(fmt.Sprintf("Exported %d artifacts to %s", count, archivePath))
+}
+
+// writeTarEntry adds a regular file named name with the given content to tw.
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write archive entry %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write archive entry %s: %w", name, err)
+	}
+	return nil
+}
+
+// readExport reads and validates every entry of an exported archive. Entry
+// names must be clean relative paths; anything else is rejected so a crafted
+// archive cannot write outside the workspace.
+func readExport(archivePath string) (exportHeader, map[string][]byte, error) {
+	var header exportHeader
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return header, nil, fmt.Errorf("failed to open archive %s: %w", archivePath, err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return header, nil, fmt.Errorf("archive %s is not gzip-compressed: %w", archivePath, err)
+	}
+	defer gz.Close()
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return header, nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if name != path.Clean(name) || path.IsAbs(name) || strings.HasPrefix(name, "../") {
+			return header, nil, fmt.Errorf("archive entry %q has an unsafe path", hdr.Name)
+		}
+		if hdr.Size > maxImportEntrySize {
+			return header, nil, fmt.Errorf("archive entry %s is too large (%d bytes)", name, hdr.Size)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxImportEntrySize))
+		if err != nil {
+			return header, nil, fmt.Errorf("failed to read archive entry %s: %w", name, err)
+		}
+		entries[name] = data
+	}
+
+	if data, ok := entries[exportHeaderName]; ok {
+		if err := json.Unmarshal(data, &header); err != nil {
+			return header, nil, fmt.Errorf("failed to parse export header: %w", err)
+		}
+		delete(entries, exportHeaderName)
+	}
+	if header.Version > exportFormatVersion {
+		return header, nil, fmt.Errorf("archive format version %d is newer than supported version %d", header.Version, exportFormatVersion)
+	}
+	return header, entries, nil
+}
+
+// safeArtifactName reports whether name can be used as an artifact filename
+// without escaping its directory.
+func safeArtifactName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// Import merges a workspace archive created by `Export` into this workspace.
+// Local artifacts always win: roles, preferences, and archived sessions that
+// already exist are skipped, and the local context and settings are kept.
+// An imported active session becomes active only if none is active locally;
+// otherwise it is archived. Logs and cached data in the archive are ignored.
+// Every imported artifact is parsed before anything is written, and archives
+// without a header (a plain tarball of `.AIWorkspace`) are accepted as version 0.
+// Cancelling ctx while the archive is parsed leaves the workspace untouched.
+func (w *Workspace) Import(ctx context.Context, archivePath string) (ImportReport, error) {
+	var report ImportReport
+	header, entries, err := readExport(archivePath)
+	if err != nil {
+		return report, err
+	}
+
+	roles := make(map[string]Role)
+	prefs := make(map[string]Preference)
+	var sessions []Session
+	var importedActive *Session
+	for name, data := range entries {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		dir, base := path.Split(name)
+		if !strings.HasSuffix(base, ".json") {
+			continue
+		}
+		switch dir {
+		case "roles/":
+			var r Role
+			if err := json.Unmarshal(data, &r); err != nil {
+				return report, fmt.Errorf("failed to parse role in archive entry %s: %w", name, err)
+			}
+			if !safeArtifactName(r.Name) {
+				return report, fmt.Errorf("archive entry %s has invalid role name %q", name, r.Name)
+			}
+			roles[r.Name] = r
+		case "preferences/":
+			var p Preference
+			if err := json.Unmarshal(data, &p); err != nil {
+				return report, fmt.Errorf("failed to parse preference in archive entry %s: %w", name, err)
+			}
+			if !safeArtifactName(p.ID) {
+				return report, fmt.Errorf("archive entry %s has invalid preference ID %q", name, p.ID)
+			}
+			prefs[p.ID] = p
+		case "sessions/", "":
+			if dir == "" && base != "session.json" {
+				continue
+			}
+			var s Session
+			if err := json.Unmarshal(data, &s); err != nil {
+				return report, fmt.Errorf("failed to parse session in archive entry %s: %w", name, err)
+			}
+			if s.ID == "" {
+				return report, fmt.Errorf("archive entry %s has a session without an ID", name)
+			}
+			if dir == "" {
+				importedActive = &s
+			} else {
+				sessions = append(sessions, s)
+			}
+		}
+	}
+
+	for name, role := range roles {
+		rel := fmt.Sprintf("roles/%s.json", name)
+		if _, exists := w.Context.Indexes.RolesIndex[name]; exists {
+			report.Skipped = append(report.Skipped, rel)
+			continue
+		}
+		if err := w.saveRole(role); err != nil {
+			return report, fmt.Errorf("failed to import role %s: %w", name, err)
+		}
+		report.Added = append(report.Added, rel)
+	}
+
+	for id, pref := range prefs {
+		rel := fmt.Sprintf("preferences/%s.json", id)
+		if _, exists := w.Context.Indexes.PreferencesIndex[id]; exists {
+			report.Skipped = append(report.Skipped, rel)
+			continue
+		}
+		if err := w.writeJSON(filepath.Join(w.RootDir, filepath.FromSlash(rel)), pref); err != nil {
+			return report, fmt.Errorf("failed to import preference %s: %w", id, err)
+		}
+		report.Added = append(report.Added, rel)
+	}
+
+	active, err := w.GetActiveSession()
+	if err != nil {
+		return report, err
+	}
+	if importedActive != nil {
+		if active == nil {
+			if err := w.saveSession(*importedActive); err != nil {
+				return report, fmt.Errorf("failed to import active session %s: %w", importedActive.ID, err)
+			}
+			report.Added = append(report.Added, "session.json")
+			active = importedActive
+		} else {
+			sessions = append(sessions, *importedActive)
+		}
+	}
+	for _, session := range sessions {
+		_, archived := w.Context.Indexes.ArchivedSessions[session.ID]
+		if archived || (active != nil && active.ID == session.ID) {
+			report.Skipped = append(report.Skipped, "sessions/"+session.ID)
+			continue
+		}
+		rel := "sessions/" + w.archiveFileName(&session)
+		if err := w.writeJSON(filepath.Join(w.RootDir, filepath.FromSlash(rel)), session); err != nil {
+			return report, fmt.Errorf("failed to import session %s: %w", session.ID, err)
+		}
+		report.Added = append(report.Added, rel)
+	}
+
+	if err := w.rebuildIndexes(ctx); err != nil {
+		return report, fmt.Errorf("failed to rebuild indexes after import: %w", err)
+	}
+	return report, w.logAction(fmt.Sprintf("Imported %d artifacts (%d skipped) from %s, exported from workspace %s (format version %d)",
+		len(report.Added), len(report.Skipped), archivePath, header.Workspace, header.Version))
+}
@@ -0,0 +1,255 @@
+package ai
+
+import (
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Response transformers a role can list in `Role.PostProcess`. They rewrite
+// the content of each reply after it is parsed, before it is shown or saved,
+// in the order listed.
+const (
+	TransformStripFluff = "strip-fluff" // Drops stock openers ("Great question!") and closers ("I hope this helps!").
+	TransformGofmt      = "gofmt"       // Formats Go code blocks with gofmt, leaving blocks that do not parse as they are.
+	TransformLinkFiles  = "link-files"  // Links inline code naming a project file, as in `pkg/ai/chat.go`, to the file.
+	TransformWidth      = "width"       // Wraps prose to a line width given after a colon, as in "width:100".
+)
+
+// fluffPattern matches a stock opening or closing sentence of a reply.
+var fluffPattern = regexp.MustCompile(`(?i)^(?:` +
+	`(?:great|good|excellent|fantastic) question[.!]+|` +
+	`(?:certainly|absolutely|sure|of course)[,.!]+(?: (?:here(?:'s| is)|i(?:'d| would) be happy to)[^.!]*[.!:]*)?|` +
+	`i(?:'d| would) be (?:happy|glad) to help[^.!]*[.!]*|` +
+	`i hope (?:this|that) helps[^.!]*[.!]*|` +
+	`(?:feel free to|let me know if)[^.!]*(?:questions?|help|anything else)[^.!]*[.!]*|` +
+	`happy coding[.!]*` +
+	`)\s*`)
+
+// fileRefPattern matches inline code that may name a file, with an optional
+// line number, not already the text of a link.
+var fileRefPattern = regexp.MustCompile("(^|[^\\[])`([\\w./-]+\\.\\w+)(?::(\\d+))?`")
+
+// responseTransformer returns the transformer named by spec, one of the
+// Transform constants with its argument, if any. File references are linked
+// if they name a file in the project directory root.
+func responseTransformer(spec, root string) (func(string) string, error) {
+	name, arg, hasArg := strings.Cut(spec, ":")
+	if hasArg != (name == TransformWidth) {
+		return nil, fmt.Errorf("invalid post-processing step %q", spec)
+	}
+	switch name {
+	case TransformStripFluff:
+		return stripFluff, nil
+	case TransformGofmt:
+		return gofmtBlocks, nil
+	case TransformLinkFiles:
+		return func(text string) string { return linkFiles(text, root) }, nil
+	case TransformWidth:
+		width, err := strconv.Atoi(arg)
+		if err != nil || width < 20 {
+			return nil, fmt.Errorf("invalid post-processing step %q: the width must be a number of at least 20", spec)
+		}
+		return func(text string) string { return wrapProse(text, width) }, nil
+	}
+	return nil, fmt.Errorf("unknown post-processing step %q, expected %s, %s, %s, or %s:<columns>",
+		spec, TransformStripFluff, TransformGofmt, TransformLinkFiles, TransformWidth)
+}
+
+// validatePostProcess checks that every step of a role's `PostProcess`
+// pipeline names a known transformer.
+func validatePostProcess(steps []string) error {
+	for _, step := range steps {
+		if _, err := responseTransformer(step, ""); err != nil {
+			return fmt.Errorf("invalid role: %w", err)
+		}
+	}
+	return nil
+}
+
+// PostProcess passes the content of response through steps, the
+// `PostProcess` pipeline of the role that produced it, and returns the
+// result. Steps that are not valid are logged and skipped.
+func (w *Workspace) PostProcess(steps []string, response Response) Response {
+	for _, step := range steps {
+		transform, err := responseTransformer(step, w.projectDir())
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Skipping post-processing step: %v", err))
+			continue
+		}
+		response.Content = transform(response.Content)
+	}
+	return response
+}
+
+// mapProse calls f with each run of lines of markdown outside fenced code
+// blocks, and returns markdown with the runs replaced by what f returns.
+func mapProse(markdown string, f func(prose string) string) string {
+	var out, run []string
+	flush := func() {
+		if len(run) > 0 {
+			out = append(out, strings.Split(f(strings.Join(run, "\n")), "\n")...)
+			run = nil
+		}
+	}
+	fenced := false
+	for _, line := range strings.Split(markdown, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flush()
+			fenced = !fenced
+			out = append(out, line)
+		} else if fenced {
+			out = append(out, line)
+		} else {
+			run = append(run, line)
+		}
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// stripFluff removes stock sentences from the start of the first paragraph
+// and the whole of the last one, when it is made only of them.
+func stripFluff(content string) string {
+	paragraphs := strings.Split(strings.TrimSpace(content), "\n\n")
+	for len(paragraphs) > 0 {
+		first := paragraphs[0]
+		for loc := fluffPattern.FindStringIndex(first); loc != nil && loc[1] > 0; loc = fluffPattern.FindStringIndex(first) {
+			first = first[loc[1]:]
+		}
+		if strings.TrimSpace(first) != "" {
+			paragraphs[0] = first
+			break
+		}
+		paragraphs = paragraphs[1:]
+	}
+	for len(paragraphs) > 1 {
+		last := paragraphs[len(paragraphs)-1]
+		for loc := fluffPattern.FindStringIndex(last); loc != nil && loc[1] > 0; loc = fluffPattern.FindStringIndex(last) {
+			last = last[loc[1]:]
+		}
+		if strings.TrimSpace(last) != "" {
+			break
+		}
+		paragraphs = paragraphs[:len(paragraphs)-1]
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// gofmtBlocks formats the code blocks of content fenced as Go.
+func gofmtBlocks(content string) string {
+	lines := strings.Split(content, "\n")
+	var out, block []string
+	inGo, fenced := false, false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !fenced && strings.HasPrefix(trimmed, "```"):
+			fenced = true
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			inGo = lang == "go" || lang == "golang"
+			out = append(out, line)
+		case fenced && trimmed == "```":
+			if inGo {
+				out = append(out, formatGo(block)...)
+			}
+			fenced, inGo, block = false, false, nil
+			out = append(out, line)
+		case inGo:
+			block = append(block, line)
+		default:
+			out = append(out, line)
+		}
+	}
+	return strings.Join(append(out, block...), "\n")
+}
+
+// formatGo formats lines of Go source: a whole file, or declarations or
+// statements as they would appear in one. Lines that do not parse either way
+// are returned unchanged.
+func formatGo(lines []string) []string {
+	src := strings.Join(lines, "\n")
+	if formatted, err := format.Source([]byte(src)); err == nil {
+		return strings.Split(strings.TrimRight(string(formatted), "\n"), "\n")
+	}
+	return lines
+}
+
+// linkFiles turns inline code naming a file in the project directory root,
+// relative to it and optionally followed by a line number, into a Markdown
+// link to the file. Text in fenced code blocks is left alone.
+func linkFiles(content, root string) string {
+	return mapProse(content, func(prose string) string {
+		return fileRefPattern.ReplaceAllStringFunc(prose, func(match string) string {
+			m := fileRefPattern.FindStringSubmatch(match)
+			path := m[2]
+			if strings.Contains(path, "..") {
+				return match
+			}
+			if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(path))); err != nil || info.IsDir() {
+				return match
+			}
+			target := path
+			if m[3] != "" {
+				target += "#L" + m[3]
+			}
+			return fmt.Sprintf("%s[%s](%s)", m[1], strings.TrimPrefix(match, m[1]), target)
+		})
+	})
+}
+
+// wrapProse wraps the lines of paragraphs and list items of markdown longer
+// than width at spaces. Headings, tables, indented code, and fenced code
+// blocks are left alone, as are words longer than width.
+func wrapProse(markdown string, width int) string {
+	return mapProse(markdown, func(prose string) string {
+		lines := strings.Split(prose, "\n")
+		var out []string
+		for _, line := range lines {
+			trimmed := strings.TrimSpace(line)
+			if len([]rune(line)) <= width || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "|") ||
+				strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+				out = append(out, line)
+				continue
+			}
+			out = append(out, wrapLine(line, width)...)
+		}
+		return strings.Join(out, "\n")
+	})
+}
+
+// wrapLine breaks line at spaces into lines at most width runes wide where
+// possible. Continuation lines are indented to align with the text of a list
+// item or quote.
+func wrapLine(line string, width int) []string {
+	body := strings.TrimLeft(line, " ")
+	indent := line[:len(line)-len(body)]
+	prefix := indent
+	if marker := listMarker.FindString(body); marker != "" {
+		prefix = indent + strings.Repeat(" ", len(marker))
+	} else if strings.HasPrefix(body, ">") {
+		prefix = indent + "> "
+	}
+
+	var out []string
+	current := indent
+	for i, word := range strings.Fields(body) {
+		switch {
+		case i == 0:
+			current += word
+		case len([]rune(current))+1+len([]rune(word)) > width:
+			out = append(out, current)
+			current = prefix + word
+		default:
+			current += " " + word
+		}
+	}
+	return append(out, current)
+}
+
+// listMarker matches the marker of a Markdown list item, with its space.
+var listMarker = regexp.MustCompile(`^(?:[-*+]|\d+[.)]) `)
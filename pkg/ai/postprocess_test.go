@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResponseTransformers(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg", "chat.go"), []byte("package pkg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		step string
+		in   string
+		want string
+	}{
+		{TransformStripFluff, "Great question! The parser is recursive.\n\nI hope this helps!", "The parser is recursive."},
+		{TransformStripFluff, "Certainly! Here's the fix:\n\nUse a mutex.\n\nLet me know if you have any questions.", "Use a mutex."},
+		{TransformStripFluff, "Sure enough, the test fails.", "Sure enough, the test fails."},
+		{TransformGofmt, "Try:\n\n```go\nfunc f( ) int {return 1}\n```", "Try:\n\n```go\nfunc f() int { return 1 }\n```"},
+		{TransformGofmt, "```go\nx :=  1\n```", "```go\nx := 1\n```"},
+		{TransformGofmt, "```go\nnot go at all {\n```", "```go\nnot go at all {\n```"},
+		{TransformGofmt, "```python\nx =  1\n```", "```python\nx =  1\n```"},
+		{TransformLinkFiles, "See `pkg/chat.go:12` and `missing.go`.", "See [`pkg/chat.go:12`](pkg/chat.go#L12) and `missing.go`."},
+		{TransformLinkFiles, "```\n`pkg/chat.go`\n```", "```\n`pkg/chat.go`\n```"},
+		{"width:20", "one two three four five six", "one two three four\nfive six"},
+		{"width:20", "- one two three four five six", "- one two three four\n  five six"},
+		{"width:20", "# one two three four five six", "# one two three four five six"},
+	}
+	for _, tt := range tests {
+		transform, err := responseTransformer(tt.step, root)
+		if err != nil {
+			t.Fatalf("responseTransformer(%q): %v", tt.step, err)
+		}
+		if got := transform(tt.in); got != tt.want {
+			t.Errorf("%s(%q) = %q, want %q", tt.step, tt.in, got, tt.want)
+		}
+	}
+
+	for _, step := range []string{"shout", "width", "width:5", "gofmt:1"} {
+		if err := validatePostProcess([]string{step}); err == nil {
+			t.Errorf("validatePostProcess(%q) succeeded, want an error", step)
+		}
+	}
+}
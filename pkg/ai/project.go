@@ -0,0 +1,232 @@
+package ai
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DetectProject infers project metadata for the directory dir from its `go.mod`
+// module path, the URL of its git remote, the files it contains, and the directory
+// name. Fields that cannot be determined are left empty, except Name, which falls
+// back to the directory name.
+func DetectProject(dir string) Project {
+	var project Project
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+
+	module := goModulePath(abs)
+	project.Module = module
+	project.Language = detectLanguage(abs)
+	remote := gitRemoteURL(abs)
+	remoteOwner, remoteName := splitRepoURL(remote)
+
+	switch {
+	case module != "":
+		project.Name = module[strings.LastIndex(module, "/")+1:]
+	case remoteName != "":
+		project.Name = remoteName
+	default:
+		project.Name = filepath.Base(abs)
+	}
+
+	project.Owner = remoteOwner
+	if parts := strings.Split(module, "/"); project.Owner == "" && len(parts) >= 3 && strings.Contains(parts[0], ".") {
+		project.Owner = parts[1] // e.g., github.com/<owner>/<name>
+	}
+
+	project.Repository = remote
+	if project.Repository == "" && strings.Contains(strings.Split(module, "/")[0], ".") {
+		project.Repository = "https://" + module
+	}
+	return project
+}
+
+// goModulePath returns the module path declared in `dir/go.mod`, or an empty string.
+func goModulePath(dir string) string {
+	file, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`)
+		}
+	}
+	return ""
+}
+
+// gitRemoteURL returns the URL of the "origin" remote, or of the first remote
+// listed, from the git configuration of dir or its nearest parent repository.
+func gitRemoteURL(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if file, err := os.Open(filepath.Join(d, ".git", "config")); err == nil {
+			defer file.Close()
+			var first, origin, section string
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if strings.HasPrefix(line, "[") {
+					section = line
+					continue
+				}
+				key, value, ok := strings.Cut(line, "=")
+				if !ok || strings.TrimSpace(key) != "url" || !strings.HasPrefix(section, "[remote ") {
+					continue
+				}
+				value = strings.TrimSpace(value)
+				if first == "" {
+					first = value
+				}
+				if section == `[remote "origin"]` {
+					origin = value
+				}
+			}
+			if origin != "" {
+				return origin
+			}
+			return first
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// splitRepoURL extracts the owner and repository name from a git remote URL in
+// either the "https://host/owner/name.git" or "git@host:owner/name.git" form.
+func splitRepoURL(url string) (owner, name string) {
+	if url == "" {
+		return "", ""
+	}
+	path := url
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+3:]
+		path = path[strings.Index(path, "/")+1:]
+	} else if _, after, ok := strings.Cut(path, ":"); ok {
+		path = after
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(path, "/"), ".git"), "/")
+	if len(parts) < 2 {
+		return "", parts[len(parts)-1]
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// languageMarkers maps build manifests to the language they indicate, in order
+// of precedence.
+var languageMarkers = []struct{ file, language string }{
+	{"go.mod", "Go"},
+	{"Cargo.toml", "Rust"},
+	{"tsconfig.json", "TypeScript"},
+	{"package.json", "JavaScript"},
+	{"pyproject.toml", "Python"},
+	{"requirements.txt", "Python"},
+	{"setup.py", "Python"},
+	{"pom.xml", "Java"},
+	{"build.gradle", "Java"},
+	{"build.gradle.kts", "Kotlin"},
+	{"Gemfile", "Ruby"},
+	{"composer.json", "PHP"},
+	{"mix.exs", "Elixir"},
+	{"Package.swift", "Swift"},
+	{"CMakeLists.txt", "C++"},
+}
+
+// languageExtensions maps source file extensions to languages, used when no
+// build manifest identifies the project.
+var languageExtensions = map[string]string{
+	".go": "Go", ".rs": "Rust", ".ts": "TypeScript", ".tsx": "TypeScript", ".js": "JavaScript",
+	".py": "Python", ".java": "Java", ".kt": "Kotlin", ".rb": "Ruby", ".php": "PHP",
+	".cs": "C#", ".c": "C", ".h": "C", ".cpp": "C++", ".cc": "C++", ".swift": "Swift",
+	".ex": "Elixir", ".exs": "Elixir", ".scala": "Scala", ".lua": "Lua", ".sh": "Shell",
+}
+
+// detectLanguage returns the primary language of the project in dir: the one
+// indicated by a build manifest at its root or, failing that, the language with
+// the most source files within the first few directory levels.
+func detectLanguage(dir string) string {
+	for _, m := range languageMarkers {
+		if _, err := os.Stat(filepath.Join(dir, m.file)); err == nil {
+			return m.language
+		}
+	}
+
+	counts := make(map[string]int)
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			depth := strings.Count(strings.TrimPrefix(path, dir), string(filepath.Separator))
+			if path != dir && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" || depth > 3) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if language, ok := languageExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+			counts[language]++
+		}
+		return nil
+	})
+
+	primary := ""
+	for language, n := range counts {
+		if n > counts[primary] || (n == counts[primary] && language < primary) {
+			primary = language
+		}
+	}
+	return primary
+}
+
+// refreshProject re-detects project metadata for an existing workspace. When the
+// repository URL has changed (for example, after moving to a new remote), the
+// repository-derived fields are replaced; fields missing from older workspaces
+// are filled in. The user-chosen name is never changed.
+func (w *Workspace) refreshProject() error {
+	detected := DetectProject(filepath.Dir(w.RootDir))
+	current := w.Context.Project
+	updated := current
+	if detected.Repository != "" && detected.Repository != current.Repository {
+		updated.Repository = detected.Repository
+		if detected.Owner != "" {
+			updated.Owner = detected.Owner
+		}
+	}
+	if updated.Module != detected.Module && detected.Module != "" {
+		updated.Module = detected.Module
+	}
+	if updated.Language == "" {
+		updated.Language = detected.Language
+	}
+	if updated == current {
+		return nil
+	}
+	if err := w.SetProject(updated); err != nil {
+		return fmt.Errorf("failed to refresh project metadata: %w", err)
+	}
+	return nil
+}
+
+// Initialized reports whether the workspace has been set up by `Init`, that is,
+// whether its `context.json` exists.
+func (w *Workspace) Initialized() bool {
+	_, err := w.storage.Stat(filepath.Join(w.RootDir, "context.json"))
+	return err == nil
+}
+
+// SetProject replaces the workspace's project metadata and saves the context.
+func (w *Workspace) SetProject(project Project) error {
+	w.Context.Project = project
+	if err := w.saveContext(w.Context); err != nil {
+		return fmt.Errorf("failed to save project metadata: %w", err)
+	}
+	return w.logAction(fmt.Sprintf("Set project to %s (owner: %s, repository: %s)", project.Name, project.Owner, project.Repository))
+}
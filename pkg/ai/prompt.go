@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Names of the layers of the prompts nani builds, in the order they appear.
+const (
+	LayerSystem      = "system"      // The workspace system prompt (`Settings.SystemPrompt`).
+	LayerLanguage    = "language"    // The language replies are written in (`Settings.DefaultLanguage`).
+	LayerPersona     = "persona"     // The persona of the session's role.
+	LayerPreferences = "preferences" // The preferences that apply to the role.
+	LayerProject     = "project"     // Project metadata and the scope the workspace is confined to.
+	LayerRepoMap     = "repo-map"    // Source files and exported symbols of the project (see `RepoMap`).
+	LayerFacts       = "facts"       // Durable facts recorded about the project (see `Fact`).
+	LayerContext     = "context"     // Workspace context retrieved for a message (see `Settings.RetrievalTopK`).
+	LayerMessage     = "message"     // The text of a message itself.
+)
+
+// PromptLayer is one section of a prompt.
+type PromptLayer struct {
+	Name   string // Layer name (e.g., LayerPersona).
+	Source string // Where the text comes from (e.g., "role reviewer"), for display.
+	Text   string // Text of the layer.
+}
+
+// PromptBuilder composes a prompt from layers, kept apart so that the final
+// prompt can be inspected layer by layer. The zero value is an empty prompt.
+type PromptBuilder struct {
+	layers []PromptLayer
+}
+
+// Add appends a layer to the prompt, unless text is blank, and returns the
+// builder for chaining.
+func (b *PromptBuilder) Add(name, source, text string) *PromptBuilder {
+	if strings.TrimSpace(text) != "" {
+		b.layers = append(b.layers, PromptLayer{Name: name, Source: source, Text: text})
+	}
+	return b
+}
+
+// Layers returns the layers of the prompt, in order.
+func (b *PromptBuilder) Layers() []PromptLayer {
+	return b.layers
+}
+
+// String returns the final prompt: the text of the layers, one after the other.
+func (b *PromptBuilder) String() string {
+	texts := make([]string, len(b.layers))
+	for i, l := range b.layers {
+		texts[i] = strings.TrimRight(l.Text, "\n")
+	}
+	return strings.Join(texts, "\n")
+}
+
+// SystemPrompt builds the system instruction for role, in layers: the
+// workspace system prompt, the language to reply in, the role's persona, the
+// preferences that apply to the role, the project the workspace is about, its
+// repo map, and the facts recorded about the project. Chats, scheduled and
+// LSP prompts, reviews, and generated docs all use it.
+func (w *Workspace) SystemPrompt(role Role) (*PromptBuilder, error) {
+	preferences, err := w.PreferencesForRole(role.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load preferences: %w", err)
+	}
+	budget := w.Context.Settings.PreferenceBudget
+	if budget <= 0 {
+		budget = defaultPreferenceBudget
+	}
+	facts, err := w.ListFacts()
+	if err != nil {
+		return nil, err
+	}
+	factBudget := w.Context.Settings.FactBudget
+	if factBudget <= 0 {
+		factBudget = defaultFactBudget
+	}
+
+	b := &PromptBuilder{}
+	b.Add(LayerSystem, "settings.systemPrompt", w.Context.Settings.SystemPrompt)
+	b.Add(LayerLanguage, "settings.defaultLanguage", languagePrompt(w.Context.Settings.DefaultLanguage))
+	b.Add(LayerPersona, "role "+role.Name, role.Persona)
+	b.Add(LayerPreferences, fmt.Sprintf("%d preference(s)", len(preferences)), preferencesPrompt(preferences, budget))
+	b.Add(LayerProject, "context.json", w.projectPrompt()+w.scopePrompt())
+	b.Add(LayerRepoMap, "settings.repoMap", w.repoMapPrompt())
+	b.Add(LayerFacts, fmt.Sprintf("%d fact(s)", len(facts)), factsPrompt(facts, factBudget))
+	return b, nil
+}
+
+// roleInstructions returns the system instruction for role (see `SystemPrompt`).
+func (w *Workspace) roleInstructions(role Role) (string, error) {
+	b, err := w.SystemPrompt(role)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// languagePrompt asks the model to reply in language, a BCP 47 tag such as
+// "en" or "pt-BR", unless the user asks for another. It is empty if no
+// language is configured.
+func languagePrompt(language string) string {
+	if language == "" {
+		return ""
+	}
+	return fmt.Sprintf("Reply in the language with the BCP 47 tag `%s`, unless the user asks for another language.\n", language)
+}
+
+// projectPrompt describes the project to the model, from the metadata
+// confirmed by `nani init`. It is empty if the project has no name.
+func (w *Workspace) projectPrompt() string {
+	p := w.Context.Project
+	if p.Name == "" {
+		return ""
+	}
+	var details []string
+	if p.Language != "" {
+		details = append(details, "written in "+p.Language)
+	}
+	if p.Module != "" {
+		details = append(details, fmt.Sprintf("module `%s`", p.Module))
+	}
+	if p.Repository != "" {
+		details = append(details, "repository "+p.Repository)
+	}
+	line := fmt.Sprintf("**Project**: %s", p.Name)
+	if len(details) > 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+	return line + "\n"
+}
+
+// MessagePrompt builds the text sent for a message whose text is content, in
+// layers: workspace context retrieved for it, when retrieval is enabled, and
+// the text itself. The retrieved context is passed through `Redact`, and the
+// secrets found are returned; content is expected to have been already.
+func (w *Workspace) MessagePrompt(ctx context.Context, content string) (*PromptBuilder, []SecretFinding, error) {
+	b := &PromptBuilder{}
+	var secrets []SecretFinding
+	if k := w.Context.Settings.RetrievalTopK; k > 0 {
+		results, err := w.SemanticSearch(ctx, content, k)
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Semantic retrieval failed: %v", err))
+		} else if len(results) > 0 {
+			retrieved, found, err := w.Redact("context", retrievalContext(results))
+			if err != nil {
+				return nil, nil, err
+			}
+			secrets = found
+			b.Add(LayerContext, fmt.Sprintf("%d retrieved snippet(s)", len(results)), retrieved)
+		}
+	}
+	b.Add(LayerMessage, "prompt", content)
+	return b, secrets, nil
+}
@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemPromptLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string // Text of the language layer; "" if there is none.
+	}{
+		{"", ""},
+		{"en", "`en`"},
+		{"pt-BR", "`pt-BR`"},
+	}
+	w := newTestWorkspace(t)
+	for _, tt := range tests {
+		w.Context.Settings.DefaultLanguage = tt.language
+		b, err := w.SystemPrompt(Role{Name: "tester", Persona: "You test."})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		for _, l := range b.Layers() {
+			if l.Name == LayerLanguage {
+				got = l.Text
+			}
+		}
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("language layer for %q = %q, want one naming %s", tt.language, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Index drift kinds reported by `Verify`.
+const (
+	DriftUnindexed = "unindexed" // An artifact on disk has no index entry.
+	DriftOrphaned  = "orphaned"  // An index entry's artifact no longer exists.
+)
+
+// Indexes of the workspace context that `Verify` reconciles, named after the
+// artifact directories they cover.
+const (
+	IndexSessions    = "sessions"
+	IndexRoles       = "roles"
+	IndexPreferences = "preferences"
+)
+
+// Issue is a difference between an in-memory index of the workspace context
+// and the artifacts on disk, such as after files were added or deleted by hand
+// or by another process while the workspace was open.
+type Issue struct {
+	Kind  string `json:"kind"`  // DriftUnindexed or DriftOrphaned.
+	Index string `json:"index"` // IndexSessions, IndexRoles, or IndexPreferences.
+	Key   string `json:"key"`   // Key of the entry: a session ID, role name, or preference ID.
+	Path  string `json:"path"`  // Artifact file, relative to the workspace root, using forward slashes.
+}
+
+// String describes the issue for display.
+func (i Issue) String() string {
+	if i.Kind == DriftOrphaned {
+		return fmt.Sprintf("%s index entry %s has no file %s", i.Index, i.Key, i.Path)
+	}
+	return fmt.Sprintf("%s is missing from the %s index", i.Path, i.Index)
+}
+
+// Verify compares the session, role, and preference indexes with the files
+// on disk and reports artifacts missing from an index and index entries whose
+// file was deleted. Files that cannot be parsed are not reported; see
+// `CheckHealth`. Results are sorted by index, then path. Indexes are rebuilt
+// whenever the context is loaded, so drift only arises while the workspace
+// stays open, as in a chat or a `nani serve` daemon.
+func (w *Workspace) Verify(ctx context.Context) ([]Issue, error) {
+	var issues []Issue
+	dirs := []struct {
+		index  string
+		dir    string
+		keyOf  func(path string) (string, error)
+		exists func(key string) bool
+	}{
+		{IndexSessions, "sessions", func(path string) (string, error) {
+			summary, err := w.readSessionSummary(filepath.Dir(path), filepath.Base(path))
+			return summary.ID, err
+		}, func(key string) bool { _, ok := w.Context.Indexes.ArchivedSessions[key]; return ok }},
+		{IndexRoles, "roles", func(path string) (string, error) {
+			summary, err := w.readRoleSummary(path)
+			return summary.Name, err
+		}, func(key string) bool { _, ok := w.Context.Indexes.RolesIndex[key]; return ok }},
+		{IndexPreferences, "preferences", func(path string) (string, error) {
+			summary, err := w.readPreferenceSummary(path)
+			return summary.ID, err
+		}, func(key string) bool { _, ok := w.Context.Indexes.PreferencesIndex[key]; return ok }},
+	}
+	for _, d := range dirs {
+		files, err := w.storage.ReadDir(filepath.Join(w.RootDir, d.dir))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s directory: %w", d.dir, err)
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+				continue
+			}
+			key, err := d.keyOf(filepath.Join(w.RootDir, d.dir, file.Name()))
+			if err != nil || d.exists(key) {
+				continue
+			}
+			issues = append(issues, Issue{Kind: DriftUnindexed, Index: d.index, Key: key, Path: d.dir + "/" + file.Name()})
+		}
+	}
+
+	orphaned := func(index, key, path string) {
+		if _, err := w.storage.Stat(path); os.IsNotExist(err) {
+			issues = append(issues, Issue{Kind: DriftOrphaned, Index: index, Key: key, Path: w.relPath(path)})
+		}
+	}
+	for id := range w.Context.Indexes.ArchivedSessions {
+		orphaned(IndexSessions, id, w.archivedSessionPath(id))
+	}
+	for name := range w.Context.Indexes.RolesIndex {
+		orphaned(IndexRoles, name, filepath.Join(w.RootDir, "roles", name+".json"))
+	}
+	for id := range w.Context.Indexes.PreferencesIndex {
+		orphaned(IndexPreferences, id, filepath.Join(w.RootDir, "preferences", id+".json"))
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Index != issues[j].Index {
+			return issues[i].Index < issues[j].Index
+		}
+		return issues[i].Path < issues[j].Path
+	})
+	return issues, nil
+}
+
+// Repair fixes issues reported by `Verify` one entry at a time: orphaned
+// entries are removed from their index and unindexed artifacts are added to
+// it, leaving the rest of the indexes untouched. Issues that no longer apply
+// are skipped. Indexes only live in memory, so nothing is written but the log.
+func (w *Workspace) Repair(issues []Issue) error {
+	for _, issue := range issues {
+		if err := w.repairIssue(issue); err != nil {
+			return fmt.Errorf("failed to repair %s: %w", issue, err)
+		}
+	}
+	return w.logAction(fmt.Sprintf("Repaired %d index issue(s)", len(issues)))
+}
+
+// repairIssue applies the repair of issue to the in-memory indexes.
+func (w *Workspace) repairIssue(issue Issue) error {
+	path := filepath.Join(w.RootDir, filepath.FromSlash(issue.Path))
+	switch issue.Kind {
+	case DriftOrphaned:
+		if _, err := w.storage.Stat(path); err == nil {
+			return nil
+		}
+		switch issue.Index {
+		case IndexSessions:
+			delete(w.Context.Indexes.ArchivedSessions, issue.Key)
+		case IndexRoles:
+			delete(w.Context.Indexes.RolesIndex, issue.Key)
+		case IndexPreferences:
+			delete(w.Context.Indexes.PreferencesIndex, issue.Key)
+		default:
+			return fmt.Errorf("unknown index '%s'", issue.Index)
+		}
+		return nil
+	case DriftUnindexed:
+		switch issue.Index {
+		case IndexSessions:
+			summary, err := w.readSessionSummary(filepath.Dir(path), filepath.Base(path))
+			if err != nil {
+				return err
+			}
+			w.Context.Indexes.ArchivedSessions[summary.ID] = summary
+		case IndexRoles:
+			summary, err := w.readRoleSummary(path)
+			if err != nil {
+				return err
+			}
+			w.Context.Indexes.RolesIndex[summary.Name] = summary
+		case IndexPreferences:
+			summary, err := w.readPreferenceSummary(path)
+			if err != nil {
+				return err
+			}
+			w.Context.Indexes.PreferencesIndex[summary.ID] = summary
+		default:
+			return fmt.Errorf("unknown index '%s'", issue.Index)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown issue kind '%s'", issue.Kind)
+	}
+}
+
+// readSessionSummary reads the index entry of the archived session stored
+// as name in dir, without loading its chat history or role.
+func (w *Workspace) readSessionSummary(dir, name string) (SessionSummary, error) {
+	sessionPath := filepath.Join(dir, name)
+	data, err := w.storage.ReadFile(sessionPath)
+	if err != nil {
+		return SessionSummary{}, fmt.Errorf("failed to read archived session file '%s': %w", sessionPath, err)
+	}
+	// Use a temporary anonymous struct for unmarshaling just the summary parts
+	temp := struct {
+		ID       string   `json:"id"`
+		Label    string   `json:"label"`
+		Role     string   `json:"role"` // Unmarshal role name from JSON
+		Scope    string   `json:"scope"`
+		Metadata Metadata `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return SessionSummary{}, fmt.Errorf("failed to parse archived session summary from '%s': %w", sessionPath, err)
+	}
+	return SessionSummary{
+		ID:          temp.ID,
+		Label:       temp.Label,
+		RoleName:    temp.Role,
+		CreatedAt:   temp.Metadata.CreatedAt,
+		LastUpdated: temp.Metadata.LastUpdated,
+		File:        name,
+		Scope:       temp.Scope,
+		Priority:    temp.Metadata.Priority,
+		Pinned:      temp.Metadata.Pinned,
+	}, nil
+}
+
+// readRoleSummary reads the index entry of the role stored at rolePath.
+func (w *Workspace) readRoleSummary(rolePath string) (RoleSummary, error) {
+	data, err := w.storage.ReadFile(rolePath)
+	if err != nil {
+		return RoleSummary{}, fmt.Errorf("failed to read role file '%s': %w", rolePath, err)
+	}
+	var r Role
+	if err := json.Unmarshal(data, &r); err != nil {
+		return RoleSummary{}, fmt.Errorf("failed to parse role from '%s': %w", rolePath, err)
+	}
+	return RoleSummary{Name: r.Name, Label: r.Label, Description: r.Description}, nil
+}
+
+// readPreferenceSummary reads the index entry of the preference stored at
+// prefPath.
+func (w *Workspace) readPreferenceSummary(prefPath string) (PreferenceSummary, error) {
+	data, err := w.storage.ReadFile(prefPath)
+	if err != nil {
+		return PreferenceSummary{}, fmt.Errorf("failed to read preference file '%s': %w", prefPath, err)
+	}
+	var p Preference
+	if err := json.Unmarshal(data, &p); err != nil {
+		return PreferenceSummary{}, fmt.Errorf("failed to parse preference from '%s': %w", prefPath, err)
+	}
+	snippet := p.Content
+	if len(snippet) > 100 { // Limit snippet length for snippet
+		snippet = snippet[:100] + "..."
+	}
+	return PreferenceSummary{ID: p.ID, Timestamp: p.Timestamp, ContentSnippet: snippet}, nil
+}
@@ -0,0 +1,162 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedactionMode is how secrets found in outgoing text are handled (see
+// `Settings.Redaction`).
+type RedactionMode string
+
+// Redaction modes.
+const (
+	RedactWarn  RedactionMode = "warn"  // Send the text unchanged and report the secrets found. The default.
+	RedactMask  RedactionMode = "mask"  // Replace each secret with a placeholder naming its kind before sending.
+	RedactBlock RedactionMode = "block" // Refuse to send text containing secrets.
+	RedactOff   RedactionMode = "off"   // Do not scan outgoing text.
+)
+
+// RedactionModes lists the redaction modes, for help and validation.
+var RedactionModes = []RedactionMode{RedactWarn, RedactMask, RedactBlock, RedactOff}
+
+// ParseRedactionMode converts a mode name into a `RedactionMode`.
+func ParseRedactionMode(s string) (RedactionMode, error) {
+	for _, m := range RedactionModes {
+		if string(m) == strings.ToLower(s) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("invalid redaction mode '%s': expected one of %v", s, RedactionModes)
+}
+
+// SecretFinding locates a secret found in outgoing text. The secret itself
+// is never recorded.
+type SecretFinding struct {
+	Kind   string `json:"kind"`   // Kind of secret (e.g., "AWS access key").
+	Source string `json:"source"` // Where the text came from (e.g., "prompt", "context", or a file path).
+	Line   int    `json:"line"`   // 1-based line of the secret within the source.
+}
+
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("%s in %s, line %d", f.Kind, f.Source, f.Line)
+}
+
+// secretPattern recognizes one kind of secret. When the expression has a
+// capture group, only the group is the secret, as in `KEY=value`
+// assignments whose name is kept for context.
+type secretPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// secretPatterns lists the secrets outgoing text is scanned for. Provider
+// key formats come first, so they are named precisely when they also match
+// the generic assignment patterns.
+var secretPatterns = []secretPattern{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{16,}\b`)},
+	{"API secret key", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}\b`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b`)},
+	{"environment secret", regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?[A-Z0-9_]*(?:SECRET|TOKEN|PASSWORD|PASSWD|API_KEY|APIKEY|ACCESS_KEY|PRIVATE_KEY|CREDENTIALS?)[A-Z0-9_]*[ \t]*=[ \t]*["']?([^\s"'#$][^\s"'#]{7,})`)},
+	{"JSON secret", regexp.MustCompile(`(?i)"[a-z0-9_-]*(?:secret|token|password|api_?key|access_?key)[a-z0-9_-]*"\s*:\s*"([^"\s]{8,})"`)},
+}
+
+// secretMatch is the byte range of a secret within a text.
+type secretMatch struct {
+	start, end int
+	kind       string
+}
+
+// findSecrets returns the non-overlapping secrets in text, in order.
+func findSecrets(text string) []secretMatch {
+	var matches []secretMatch
+	taken := func(start, end int) bool {
+		for _, m := range matches {
+			if start < m.end && m.start < end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range secretPatterns {
+		for _, loc := range p.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			if !taken(start, end) {
+				matches = append(matches, secretMatch{start: start, end: end, kind: p.kind})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+// ScanSecrets reports the secrets in text, which came from source.
+func ScanSecrets(source, text string) []SecretFinding {
+	var findings []SecretFinding
+	for _, m := range findSecrets(text) {
+		findings = append(findings, SecretFinding{Kind: m.kind, Source: source, Line: strings.Count(text[:m.start], "\n") + 1})
+	}
+	return findings
+}
+
+// maskSecrets replaces the secrets in text with placeholders such as
+// `[REDACTED AWS access key]`.
+func maskSecrets(text string) string {
+	matches := findSecrets(text)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.start])
+		b.WriteString("[REDACTED " + m.kind + "]")
+		last = m.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// redactionMode returns the configured redaction mode, defaulting to RedactWarn.
+// `Redact` also treats unknown modes as RedactWarn.
+func (w *Workspace) redactionMode() RedactionMode {
+	if w.Context.Settings.Redaction == "" {
+		return RedactWarn
+	}
+	return w.Context.Settings.Redaction
+}
+
+// Redact scans text, which came from source, for secrets before it is sent
+// to the model provider, and applies `Settings.Redaction`: it returns the
+// text to send, masked if configured, and the secrets found. In block mode
+// text containing secrets yields a `*SecretsError` instead.
+func (w *Workspace) Redact(source, text string) (string, []SecretFinding, error) {
+	mode := w.redactionMode()
+	if mode == RedactOff {
+		return text, nil, nil
+	}
+	findings := ScanSecrets(source, text)
+	if len(findings) == 0 {
+		return text, nil, nil
+	}
+	switch mode {
+	case RedactBlock:
+		w.logAction(fmt.Sprintf("Blocked sending %d secret(s) from %s", len(findings), source))
+		return "", findings, &SecretsError{Findings: findings}
+	case RedactMask:
+		w.logAction(fmt.Sprintf("Masked %d secret(s) in %s before sending", len(findings), source))
+		return maskSecrets(text), findings, nil
+	}
+	w.logAction(fmt.Sprintf("Warning: Sending %d possible secret(s) from %s", len(findings), source))
+	return text, findings, nil
+}
@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+// defaultReformatAttempts is the number of times a malformed reply is sent
+// back for correction when `Settings.ReformatAttempts` is not set.
+const defaultReformatAttempts = 2
+
+// reformatPrompt asks the model to resend a reply that failed to parse.
+const reformatPrompt = "Your last reply was not valid JSON matching the response schema (%v). " +
+	"Resend the same reply as a single JSON object matching the schema, with no other text."
+
+// reformat asks the model to resend raw, a reply of the live chat that failed
+// to parse with parseErr, up to `Settings.ReformatAttempts` times. On success
+// the corrected reply replaces the malformed one in the chat, and it is
+// returned with the provider response it came in. Otherwise raw is kept as
+// the content of a default response. usage is the usage of the malformed
+// reply, to which that of each attempt is added. Only a done ctx fails.
+func (g *GeminiAIClient) reformat(ctx context.Context, raw string, parseErr error, resp *genai.GenerateContentResponse, usage *Usage) (Response, *genai.GenerateContentResponse, *Usage, error) {
+	attempts := g.workspace.Context.Settings.ReformatAttempts
+	if attempts == 0 {
+		attempts = defaultReformatAttempts
+	}
+	for attempt := 1; attempt <= attempts; attempt++ {
+		history := g.chat.History(false)
+		if len(history) == 0 || history[len(history)-1].Role != genai.RoleModel {
+			break
+		}
+		prompt := genai.NewContentFromText(fmt.Sprintf(reformatPrompt, parseErr), genai.RoleUser)
+		corrected, err := g.client.Models.GenerateContent(ctx, g.model, append(history, prompt), g.config)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Response{}, nil, nil, ctx.Err()
+			}
+			g.workspace.logAction(fmt.Sprintf("Warning: Failed to ask the model to resend a malformed reply: %v", providerError(err)))
+			break
+		}
+		if u := geminiUsage(corrected.UsageMetadata); u != nil {
+			if usage != nil {
+				total := usage.Add(*u)
+				u = &total
+			}
+			usage = u
+		}
+
+		text := corrected.Text()
+		response, err := parseAIResponse(text, g.schema)
+		g.workspace.recordParse(response.Repairs, err)
+		if err != nil {
+			parseErr = err
+			continue
+		}
+		// Continue the chat from the corrected reply rather than the malformed one.
+		history[len(history)-1] = genai.NewContentFromText(text, genai.RoleModel)
+		if chat, err := g.client.Chats.Create(ctx, g.model, g.config, history); err == nil {
+			g.chat = chat
+		}
+		g.workspace.updateParseStats(func(stats *ParseStats) { stats.Reformatted++ })
+		g.workspace.logAction(fmt.Sprintf("Model resent a malformed reply correctly after %d request(s)", attempt))
+		return response, corrected, usage, nil
+	}
+
+	g.workspace.updateParseStats(func(stats *ParseStats) { stats.Fallbacks++ })
+	g.workspace.logAction(fmt.Sprintf("Warning: Keeping a malformed model reply as raw content: %v", parseErr))
+	return defaultResponse(raw), resp, usage, nil
+}
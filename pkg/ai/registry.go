@@ -0,0 +1,170 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// KnownWorkspace is a project recorded in the workspace registry, so it can be
+// listed and opened by name from anywhere.
+type KnownWorkspace struct {
+	Name     string    `json:"name"`     // Project name, from the workspace context.
+	Path     string    `json:"path"`     // Absolute path of the project directory.
+	LastUsed time.Time `json:"lastUsed"` // When nani last opened the workspace.
+}
+
+// DefaultRegistryPath returns the location of the workspace registry,
+// `workspaces.json` next to the global workspace (see `DefaultGlobalDir`), or
+// an empty string if the home directory cannot be determined.
+func DefaultRegistryPath() string {
+	global := DefaultGlobalDir()
+	if global == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(global), "workspaces.json")
+}
+
+// FindProjectDir walks up from dir to the nearest directory holding an
+// initialized `.AIWorkspace`, like git finds a repository, and returns it.
+// It returns an empty string if no parent directory has a workspace.
+func FindProjectDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".AIWorkspace", "context.json")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// KnownWorkspaces returns the registered workspaces, most recently used first.
+func KnownWorkspaces() ([]KnownWorkspace, error) {
+	path := DefaultRegistryPath()
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read workspace registry: %w", err)
+	}
+	var known []KnownWorkspace
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace registry %s: %w", path, err)
+	}
+	sort.Slice(known, func(i, j int) bool { return known[i].LastUsed.After(known[j].LastUsed) })
+	return known, nil
+}
+
+// saveKnownWorkspaces writes the workspace registry.
+func saveKnownWorkspaces(known []KnownWorkspace) error {
+	path := DefaultRegistryPath()
+	if path == "" {
+		return errors.New("cannot locate the workspace registry: home directory unknown")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode workspace registry: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write workspace registry: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Register records the workspace in the registry, or refreshes its name and
+// last use if it is already known.
+func (w *Workspace) Register() error {
+	dir, err := filepath.Abs(w.projectDir())
+	if err != nil {
+		return fmt.Errorf("failed to resolve project directory: %w", err)
+	}
+	known, err := KnownWorkspaces()
+	if err != nil {
+		return err
+	}
+	entry := KnownWorkspace{Name: w.Context.Project.Name, Path: dir, LastUsed: w.now()}
+	for i, k := range known {
+		if k.Path == dir {
+			known[i] = entry
+			return saveKnownWorkspaces(known)
+		}
+	}
+	return saveKnownWorkspaces(append(known, entry))
+}
+
+// ForgetWorkspace removes the workspace with the given name or path from the
+// registry. The workspace itself is left untouched.
+func ForgetWorkspace(nameOrPath string) error {
+	known, err := KnownWorkspaces()
+	if err != nil {
+		return err
+	}
+	dir, _ := filepath.Abs(nameOrPath)
+	for i, k := range known {
+		if k.Name == nameOrPath || k.Path == dir {
+			return saveKnownWorkspaces(append(known[:i], known[i+1:]...))
+		}
+	}
+	return fmt.Errorf("no known workspace '%s'", nameOrPath)
+}
+
+// ResolveWorkspaceDir returns the project directory named by nameOrPath: the
+// path of a registered workspace with that name, or otherwise the nearest
+// directory at or above the path holding a workspace, or the path itself if
+// none does.
+func ResolveWorkspaceDir(nameOrPath string) (string, error) {
+	if known, err := KnownWorkspaces(); err == nil {
+		for _, k := range known {
+			if k.Name == nameOrPath {
+				return k.Path, nil
+			}
+		}
+	}
+	info, err := os.Stat(nameOrPath)
+	if err != nil {
+		return "", fmt.Errorf("no workspace named '%s' and no such directory", nameOrPath)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", nameOrPath)
+	}
+	dir, err := FindProjectDir(nameOrPath)
+	if err != nil || dir != "" {
+		return dir, err
+	}
+	return filepath.Abs(nameOrPath)
+}
+
+// OpenWorkspace creates the workspace of the project directory dir if needed,
+// initializes it, and records it in the registry.
+func OpenWorkspace(ctx context.Context, dir string) (*Workspace, error) {
+	w, err := NewWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Init(ctx); err != nil {
+		return nil, err
+	}
+	if err := w.Register(); err != nil {
+		w.logAction(fmt.Sprintf("Warning: Could not register workspace: %v", err))
+	}
+	return w, nil
+}
@@ -0,0 +1,277 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// defaultRepoMapBudget caps the characters of the repo map injected into the
+// system instruction when `Settings.RepoMapBudget` is not set.
+const defaultRepoMapBudget = 6000
+
+// RepoMapFile describes a source file in the repo map: its package and the
+// exported symbols it declares. Go files are parsed; Python, TypeScript,
+// JavaScript, and Rust files are scanned for declarations (see
+// `sourceLanguage`).
+type RepoMapFile struct {
+	Path    string    `json:"path"`              // Path relative to the project root, with forward slashes.
+	Size    int64     `json:"size"`              // Size of the file in bytes.
+	ModTime time.Time `json:"modTime"`           // Modification time the symbols were read at, to tell when to read them again.
+	Package string    `json:"package,omitempty"` // Name of the Go package the file belongs to.
+	Symbols []string  `json:"symbols,omitempty"` // Exported declarations, as "func Name", "class Name", or "method Type.Name".
+}
+
+// repoMapPath returns the location of the cached repo map. Scoped workspaces
+// keep a map of their own under `repomap/scopes/`.
+func (w *Workspace) repoMapPath() string {
+	if w.scope != "" {
+		return filepath.Join(w.RootDir, "repomap", "scopes", filepath.FromSlash(w.scope), "index.json")
+	}
+	return filepath.Join(w.RootDir, "repomap", "index.json")
+}
+
+// RepoMap returns the source files of the workspace's scope, sorted by
+// directory and name, with their packages and exported symbols. Files are
+// read again only when their size or modification time changed since the map
+// was last built; the map is cached in `repomap/`. Hidden, vendor,
+// node_modules, and testdata directories are skipped, as are test files.
+// Files that do not parse are listed without symbols.
+func (w *Workspace) RepoMap(ctx context.Context) ([]RepoMapFile, error) {
+	cached := make(map[string]RepoMapFile)
+	if data, err := w.storage.ReadFile(w.repoMapPath()); err == nil {
+		var files []RepoMapFile
+		if json.Unmarshal(data, &files) == nil {
+			for _, f := range files {
+				cached[f.Path] = f
+			}
+		}
+	}
+
+	root := w.ScopeDir()
+	var files []RepoMapFile
+	changed := false
+	err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if file != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		lang := sourceLanguageFor(file)
+		if lang == nil || !isDocumentableSource(file) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking.
+		}
+		ref, err := w.sourceRef(file)
+		if err != nil {
+			return err
+		}
+		if f, ok := cached[ref]; ok && f.Size == info.Size() && f.ModTime.Equal(info.ModTime()) {
+			files = append(files, f)
+			return nil
+		}
+		changed = true
+		f := RepoMapFile{Path: ref, Size: info.Size(), ModTime: info.ModTime()}
+		if lang != goSource {
+			if data, err := os.ReadFile(file); err == nil {
+				f.Symbols = lang.symbols(string(data))
+			}
+		} else if pkg, symbols, err := goSymbols(file); err == nil {
+			f.Package, f.Symbols = pkg, symbols
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to map repository: %w", err)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		di, dj := path.Dir(files[i].Path), path.Dir(files[j].Path)
+		if di != dj {
+			return di < dj
+		}
+		return files[i].Path < files[j].Path
+	})
+	if changed || len(files) != len(cached) {
+		if err := w.saveRepoMap(files); err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// saveRepoMap writes the repo map cache. Like the vector store, it is a
+// derived cache, so it is written directly and is not tracked by the manifest.
+func (w *Workspace) saveRepoMap(files []RepoMapFile) error {
+	if err := w.storage.MkdirAll(filepath.Dir(w.repoMapPath()), 0755); err != nil {
+		return fmt.Errorf("failed to create repomap directory: %w", err)
+	}
+	data, err := json.Marshal(files)
+	if err != nil {
+		return fmt.Errorf("failed to encode repo map: %w", err)
+	}
+	if err := w.storage.WriteFile(w.repoMapPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write repo map: %w", err)
+	}
+	return nil
+}
+
+// goSymbols returns the package name of the Go file at path and its exported
+// declarations. Methods are listed only for exported receiver types.
+func goSymbols(path string) (string, []string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return "", nil, err
+	}
+	var symbols []string
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			if decl.Recv == nil || len(decl.Recv.List) == 0 {
+				symbols = append(symbols, "func "+decl.Name.Name)
+			} else if recv := receiverType(decl.Recv.List[0].Type); ast.IsExported(recv) {
+				symbols = append(symbols, "method "+recv+"."+decl.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.IsExported() {
+						symbols = append(symbols, "type "+spec.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							symbols = append(symbols, decl.Tok.String()+" "+name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return file.Name.Name, symbols, nil
+}
+
+// receiverType returns the name of the type of a method receiver, without
+// pointers or type parameters.
+func receiverType(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(expr.X)
+	case *ast.IndexExpr:
+		return receiverType(expr.X)
+	case *ast.IndexListExpr:
+		return receiverType(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// RepoMapMarkdown renders the repo map compactly: one line per directory with
+// its package, then one per file with its size and exported symbols. It stops
+// before exceeding budget characters, noting how many files were left out.
+func RepoMapMarkdown(files []RepoMapFile, budget int) string {
+	packages := make(map[string]string)
+	for _, f := range files {
+		if d := path.Dir(f.Path); packages[d] == "" {
+			packages[d] = f.Package
+		}
+	}
+	var b strings.Builder
+	dir := ""
+	for i, f := range files {
+		var entry strings.Builder
+		if d := path.Dir(f.Path); d != dir || i == 0 {
+			dir = d
+			entry.WriteString(fmt.Sprintf("- `%s/`", d))
+			if packages[d] != "" {
+				entry.WriteString(" (package " + packages[d] + ")")
+			}
+			entry.WriteString("\n")
+		}
+		entry.WriteString(fmt.Sprintf("  - `%s` (%s)", path.Base(f.Path), formatSize(f.Size)))
+		if len(f.Symbols) > 0 {
+			entry.WriteString(": " + strings.Join(f.Symbols, ", "))
+		}
+		entry.WriteString("\n")
+		if budget > 0 && b.Len()+entry.Len() > budget {
+			b.WriteString(fmt.Sprintf("- … %d more file(s)\n", len(files)-i))
+			break
+		}
+		b.WriteString(entry.String())
+	}
+	return b.String()
+}
+
+// formatSize formats a file size in bytes for the repo map.
+func formatSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f KB", float64(size)/1024)
+}
+
+// repoMapPrompt describes the project's structure to the model when
+// `Settings.RepoMap` is on. A map that cannot be built is logged and left out.
+func (w *Workspace) repoMapPrompt() string {
+	if !w.Context.Settings.RepoMap {
+		return ""
+	}
+	// The system instruction is built outside any request, so the walk is
+	// bounded only by the project's size.
+	files, err := w.RepoMap(context.Background())
+	if err != nil {
+		w.logAction(fmt.Sprintf("Warning: Leaving out the repo map: %v", err))
+		return ""
+	}
+	if len(files) == 0 {
+		return ""
+	}
+	budget := w.Context.Settings.RepoMapBudget
+	if budget <= 0 {
+		budget = defaultRepoMapBudget
+	}
+	return "**Repository Map** (source files, sizes, and exported symbols):\n" + RepoMapMarkdown(files, budget)
+}
+
+// refreshInstructions rebuilds the system instruction of the current chat
+// when it includes the repo map, so the model sees files changed since the
+// chat started. Only files that changed are read again (see `RepoMap`).
+func (g *GeminiAIClient) refreshInstructions() {
+	if !g.workspace.Context.Settings.RepoMap || g.config == nil {
+		return
+	}
+	instructions, err := g.workspace.roleInstructions(g.role)
+	if err != nil {
+		g.workspace.logAction(fmt.Sprintf("Warning: Keeping the previous system instruction: %v", err))
+		return
+	}
+	g.config.SystemInstruction = genai.NewContentFromText(instructions, genai.Role(g.role.Name))
+}
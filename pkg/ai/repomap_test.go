@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepoMap(t *testing.T) {
+	w := newTestWorkspace(t)
+	project := w.projectDir()
+	files := map[string]string{
+		"main.go":           "package main\n\nfunc main() {}\n",
+		"lib/lib.go":        "package lib\n\nconst Version = \"1\"\n\ntype Store struct{}\n\nfunc (s *Store) Get() {}\nfunc (s *Store) put() {}\nfunc New() *Store { return nil }\nfunc helper() {}\n",
+		"lib/lib_test.go":   "package lib\n\nfunc TestX() {}\n",
+		"lib/broken.go":     "package lib\nfunc {\n",
+		"vendor/v/v.go":     "package v\n\nfunc V() {}\n",
+		".hidden/h.go":      "package h\n\nfunc H() {}\n",
+		"README.md":         "# Readme\n",
+		"tools/run.py":      "def run():\n    pass\n",
+		"tools/test_run.py": "def test_run():\n    pass\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(project, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := w.RepoMap(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range got {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"main.go", "lib/broken.go", "lib/lib.go", "tools/run.py"}; !slices.Equal(paths, want) {
+		t.Fatalf("RepoMap() paths = %v, want %v", paths, want)
+	}
+	lib := got[2]
+	if want := []string{"const Version", "type Store", "method Store.Get", "func New"}; lib.Package != "lib" || !slices.Equal(lib.Symbols, want) {
+		t.Errorf("lib.go = %s %v, want lib %v", lib.Package, lib.Symbols, want)
+	}
+	if want := []string{"def run"}; !slices.Equal(got[3].Symbols, want) {
+		t.Errorf("run.py symbols = %v, want %v", got[3].Symbols, want)
+	}
+	if _, err := w.storage.Stat(w.repoMapPath()); err != nil {
+		t.Errorf("repo map not cached: %v", err)
+	}
+
+	// A changed file is read again.
+	libPath := filepath.Join(project, "lib", "lib.go")
+	if err := os.WriteFile(libPath, []byte("package lib\n\nfunc Open() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(libPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	got, err = w.RepoMap(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"func Open"}; !slices.Equal(got[2].Symbols, want) {
+		t.Errorf("symbols after change = %v, want %v", got[2].Symbols, want)
+	}
+
+	w.Context.Settings.RepoMap = true
+	prompt, err := w.SystemPrompt(Role{Name: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := prompt.String(); !strings.Contains(text, "`lib/` (package lib)") || !strings.Contains(text, "`lib.go` (") {
+		t.Errorf("system prompt lacks the repo map:\n%s", text)
+	}
+
+	if md := RepoMapMarkdown(got, 40); !strings.Contains(md, "more file(s)") {
+		t.Errorf("RepoMapMarkdown() within 40 characters = %q, want files left out", md)
+	}
+}
@@ -0,0 +1,365 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+const (
+	// defaultReviewRole is the role reviewing diffs when none is given.
+	defaultReviewRole = "reviewer"
+	// maxReviewChunk is the size in bytes above which the hunks of a file are
+	// split across several review requests.
+	maxReviewChunk = 12 * 1024
+	// reviewContextLines is the number of lines of the new version of a file
+	// shown above and below each hunk.
+	reviewContextLines = 20
+)
+
+// DiffHunk is a hunk of a unified diff.
+type DiffHunk struct {
+	File  string // Path of the file in the new version.
+	Start int    // First line of the hunk in the new version.
+	Count int    // Number of lines of the hunk in the new version.
+	Text  string // The hunk, including its "@@" header.
+}
+
+// ParseDiff splits a unified diff, as printed by `git diff`, into hunks.
+// Hunks of deleted files are dropped, since there is nothing left to review.
+func ParseDiff(diff string) []DiffHunk {
+	var (
+		hunks []DiffHunk
+		file  string
+		hunk  *DiffHunk
+		text  strings.Builder
+	)
+	flush := func() {
+		if hunk != nil {
+			hunk.Text = text.String()
+			hunks = append(hunks, *hunk)
+			hunk = nil
+		}
+		text.Reset()
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			flush()
+			file = ""
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			file = strings.TrimSpace(strings.TrimPrefix(line, "+++ "))
+			if file == "/dev/null" {
+				file = ""
+			}
+			file = strings.TrimPrefix(file, "b/")
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			if file == "" {
+				continue
+			}
+			start, count := parseHunkHeader(line)
+			hunk = &DiffHunk{File: file, Start: start, Count: count}
+			text.WriteString(line)
+		case hunk != nil:
+			text.WriteString(line)
+		}
+	}
+	flush()
+	return hunks
+}
+
+// parseHunkHeader returns the new-version range of a hunk header such as
+// "@@ -10,7 +12,9 @@ func main() {".
+func parseHunkHeader(header string) (start, count int) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0
+	}
+	from, length, found := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	start, _ = strconv.Atoi(from)
+	count = 1
+	if found {
+		count, _ = strconv.Atoi(length)
+	}
+	return start, count
+}
+
+// FindingsReviewer is implemented by model clients that can constrain an
+// answer to a list of findings with a response schema, rather than relying on
+// the prompt alone. `GeminiAIClient` implements it.
+type FindingsReviewer interface {
+	ReviewFindings(ctx context.Context, role Role, prompt string) ([]Finding, error)
+}
+
+// ReviewOptions controls a review run by `ReviewDiff`.
+type ReviewOptions struct {
+	Role        string // Role reviewing the diff; empty uses "reviewer".
+	Concurrency int    // Requests run at once; 0 uses `Settings.BatchConcurrency`.
+
+	// Source returns the new version of a file, shown around each hunk. Nil,
+	// or an error, reviews the file's hunks without surrounding code.
+	Source func(file string) (string, error)
+	// Progress, if not nil, is called after each chunk of the diff is reviewed.
+	Progress func(done, total int)
+}
+
+// ReviewReport holds the findings of a review.
+type ReviewReport struct {
+	Findings []Finding        // Findings ordered by severity, file, and line.
+	Files    int              // Number of files reviewed.
+	Failed   map[string]error // Files that could not be (fully) reviewed, with the reason.
+}
+
+// reviewChunk is a group of hunks of one file reviewed by a single request.
+type reviewChunk struct {
+	file  string
+	hunks []DiffHunk
+}
+
+// ReviewDiff reviews a unified diff with runner, in the voice of the review
+// role. Each file's hunks are sent together, split into chunks of at most
+// maxReviewChunk bytes, along with the surrounding code of the new version,
+// and the findings of all chunks are aggregated into a single report.
+// Failures of single chunks are recorded in the report; only cancellation
+// stops the review early.
+func (w *Workspace) ReviewDiff(ctx context.Context, runner PromptRunner, diff string, opts ReviewOptions) (ReviewReport, error) {
+	report := ReviewReport{Failed: make(map[string]error)}
+	roleName := opts.Role
+	if roleName == "" {
+		roleName = defaultReviewRole
+	}
+	role, err := w.ResolveRole(roleName)
+	if err != nil {
+		return report, err
+	}
+
+	chunks := reviewChunks(ParseDiff(diff))
+	files := make(map[string]bool)
+	for _, c := range chunks {
+		files[c.file] = true
+	}
+	report.Files = len(files)
+	sources := make(map[string][]string)
+	for file := range files {
+		if opts.Source == nil {
+			break
+		}
+		if source, err := opts.Source(file); err == nil {
+			sources[file] = strings.Split(source, "\n")
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		done int
+	)
+	batchErr := RunBatch(ctx, len(chunks), w.batchOptions(runner, opts.Concurrency), func(ctx context.Context, i int) error {
+		chunk := chunks[i]
+		findings, err := w.reviewChunk(ctx, runner, role, chunk, sources[chunk.file])
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		done++
+		if opts.Progress != nil {
+			opts.Progress(done, len(chunks))
+		}
+		if err != nil {
+			report.Failed[chunk.file] = err
+			return err
+		}
+		report.Findings = append(report.Findings, findings...)
+		return nil
+	})
+	sortFindings(report.Findings)
+	if errors.Is(batchErr, context.Canceled) || errors.Is(batchErr, context.DeadlineExceeded) {
+		return report, batchErr
+	}
+	return report, w.logAction(fmt.Sprintf("Reviewed %d files with role %s (%d findings, %d failed)",
+		report.Files, role.Name, len(report.Findings), len(report.Failed)))
+}
+
+// reviewChunks groups hunks by file, splitting a file's hunks when they
+// exceed maxReviewChunk bytes.
+func reviewChunks(hunks []DiffHunk) []reviewChunk {
+	var chunks []reviewChunk
+	size := 0
+	for _, h := range hunks {
+		last := len(chunks) - 1
+		if last < 0 || chunks[last].file != h.File || size+len(h.Text) > maxReviewChunk {
+			chunks = append(chunks, reviewChunk{file: h.File})
+			last, size = last+1, 0
+		}
+		chunks[last].hunks = append(chunks[last].hunks, h)
+		size += len(h.Text)
+	}
+	return chunks
+}
+
+// reviewChunk asks the model to review one chunk of a file's changes, showing
+// the surrounding lines of source, the file's new version, when known.
+func (w *Workspace) reviewChunk(ctx context.Context, runner PromptRunner, role Role, chunk reviewChunk, source []string) ([]Finding, error) {
+	var (
+		b   strings.Builder
+		err error
+	)
+	fmt.Fprintf(&b, "Review the following changes to `%s`. Report bugs, security issues, error handling gaps, "+
+		"and maintainability problems introduced or exposed by the change; skip style nits.\n\n", chunk.file)
+	b.WriteString("Respond with only a JSON array of findings, each an object with the fields " +
+		`"file" (string), "line" (the line number in the new version of the file, or 0 for the whole file), ` +
+		`"severity" (one of "` + strings.Join(Severities, `", "`) + `"), and "comment" (the problem and a concrete fix). ` +
+		"Respond with [] if the changes look good.\n\n")
+	b.WriteString("```diff\n")
+	for _, h := range chunk.hunks {
+		b.WriteString(h.Text)
+	}
+	b.WriteString("```\n")
+	if excerpt := hunkContext(chunk.hunks, source); excerpt != "" {
+		fmt.Fprintf(&b, "\nSurrounding code of the new version of `%s`, with line numbers:\n\n```\n%s```\n", chunk.file, excerpt)
+	}
+
+	var findings []Finding
+	if reviewer, ok := runner.(FindingsReviewer); ok {
+		findings, err = reviewer.ReviewFindings(ctx, role, b.String())
+	} else {
+		var answer string
+		if answer, err = runner.RunPrompt(ctx, role, b.String()); err == nil {
+			findings, err = parseFindings(answer)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range findings {
+		if findings[i].File == "" {
+			findings[i].File = chunk.file
+		}
+	}
+	return findings, nil
+}
+
+// hunkContext returns the numbered lines of source around the hunks, with
+// overlapping ranges merged, or "" if source is not known.
+func hunkContext(hunks []DiffHunk, source []string) string {
+	if len(source) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	next := 1 // First line not yet written.
+	for _, h := range hunks {
+		from := max(h.Start-reviewContextLines, next, 1)
+		to := min(h.Start+h.Count+reviewContextLines, len(source))
+		if from > next && next > 1 {
+			b.WriteString("...\n")
+		}
+		for n := from; n <= to; n++ {
+			fmt.Fprintf(&b, "%5d| %s\n", n, source[n-1])
+		}
+		next = max(next, to+1)
+	}
+	return b.String()
+}
+
+// parseFindings decodes the JSON array of findings in a model's answer,
+// ignoring any surrounding prose or code fence, and normalizes severities.
+func parseFindings(answer string) ([]Finding, error) {
+	start, end := strings.Index(answer, "["), strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("model did not return a JSON array of findings")
+	}
+	var findings []Finding
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &findings); err != nil {
+		return nil, fmt.Errorf("failed to parse findings: %w", err)
+	}
+	for i := range findings {
+		findings[i].Severity = normalizeSeverity(findings[i].Severity)
+	}
+	return findings, nil
+}
+
+// Markdown renders the report with one section per severity, each finding
+// anchored at its file and line.
+func (r ReviewReport) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# Code Review\n\n")
+	fmt.Fprintf(&b, "%d findings in %d files reviewed.\n", len(r.Findings), r.Files)
+	for _, severity := range Severities {
+		section := false
+		for _, f := range r.Findings {
+			if f.Severity != severity {
+				continue
+			}
+			if !section {
+				fmt.Fprintf(&b, "\n## %s\n\n", strings.ToUpper(severity[:1])+severity[1:])
+				section = true
+			}
+			fmt.Fprintf(&b, "- `%s` — %s\n", f.Anchor(), strings.TrimSpace(f.Comment))
+		}
+	}
+	if len(r.Failed) > 0 {
+		files := make([]string, 0, len(r.Failed))
+		for file := range r.Failed {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		b.WriteString("\n## Not Reviewed\n\n")
+		for _, file := range files {
+			fmt.Fprintf(&b, "- `%s`: %v\n", file, r.Failed[file])
+		}
+	}
+	return b.String()
+}
+
+// ReviewFindings implements `FindingsReviewer` with a single Gemini request
+// whose answer must be a JSON array of findings, using the role's system
+// instruction (see `Workspace.SystemPrompt`), model, and sampling parameters.
+func (g *GeminiAIClient) ReviewFindings(ctx context.Context, role Role, prompt string) ([]Finding, error) {
+	instructions, err := g.workspace.roleInstructions(role)
+	if err != nil {
+		return nil, err
+	}
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(instructions, genai.Role(role.Name)),
+		Temperature:       role.Parameters.Temperature,
+		TopP:              role.Parameters.TopP,
+		ResponseMIMEType:  "application/json",
+		ResponseSchema: &genai.Schema{
+			Type:  genai.TypeArray,
+			Items: findingSchema(),
+		},
+	}
+
+	prompt, _, err = g.workspace.Redact("review", prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, _, err := g.generate(ctx, g.workspace.ModelsFor(TaskReview, role.Parameters.Model), genai.Text(prompt), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review from Gemini: %w", err)
+	}
+	return parseFindings(resp.Text())
+}
+
+// findingSchema is the response schema of a single `Finding`.
+func findingSchema() *genai.Schema {
+	return &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"file":     {Type: genai.TypeString},
+			"line":     {Type: genai.TypeInteger},
+			"severity": {Type: genai.TypeString, Enum: Severities},
+			"comment":  {Type: genai.TypeString},
+		},
+		Required: []string{"file", "line", "severity", "comment"},
+	}
+}
@@ -0,0 +1,252 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// roleIndexName is the name of a role registry's index in a git repository
+// or directory.
+const roleIndexName = "index.json"
+
+// maxRoleFileSize limits how much of a role registry's index or role
+// definitions is read.
+const maxRoleFileSize = 1 << 20
+
+// RoleListing describes a role a role registry offers.
+type RoleListing struct {
+	Name        string `json:"name"`        // Name of the role, as installed in `roles/<name>.json`.
+	Label       string `json:"label"`       // Display label of the role.
+	Description string `json:"description"` // What the role does.
+	Path        string `json:"path"`        // Location of the role's definition, relative to the index.
+	SHA256      string `json:"sha256"`      // Hex SHA-256 checksum of the definition.
+}
+
+// roleRegistryClient fetches the index and roles of registries served over
+// HTTPS.
+var roleRegistryClient = &http.Client{Timeout: 30 * time.Second}
+
+// roleIndex is the index of a role registry: `index.json` in a git
+// repository or directory, or any JSON document served over HTTP.
+type roleIndex struct {
+	Roles []RoleListing `json:"roles"`
+}
+
+// roleRegistry reads a role registry named by `Settings.RoleRegistry`.
+type roleRegistry struct {
+	base    string // URL or directory the listings' paths are relative to.
+	index   roleIndex
+	cleanup func() // Removes the clone of a git registry; nil for others.
+}
+
+// openRoleRegistry reads the index of the role registry at location: a git
+// repository (a URL ending in ".git" or starting with "git@" or "git+",
+// cloned afresh), an HTTPS URL of the index, or a local directory holding
+// `index.json` or the path of the index itself. The checksums come from the
+// same place as the roles, so remote registries must be reached over HTTPS or
+// SSH; plain HTTP and git:// locations fail with `ErrInsecureRegistry`. The
+// registry must be closed.
+func openRoleRegistry(ctx context.Context, location string) (*roleRegistry, error) {
+	r := &roleRegistry{base: location}
+	switch {
+	case strings.HasSuffix(location, ".git") || strings.HasPrefix(location, "git@") || strings.HasPrefix(location, "git+"):
+		repo := strings.TrimPrefix(location, "git+")
+		if strings.HasPrefix(repo, "http://") || strings.HasPrefix(repo, "git://") {
+			return nil, fmt.Errorf("%w: %s; use an https:// or SSH URL", ErrInsecureRegistry, location)
+		}
+		dir, err := os.MkdirTemp("", "nani-roles-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create directory for role registry: %w", err)
+		}
+		r.base, r.cleanup = filepath.Join(dir, roleIndexName), func() { os.RemoveAll(dir) }
+		// "--" keeps a location starting with "-" from being read as an option.
+		cmd := exec.CommandContext(ctx, "git", "clone", "--quiet", "--depth", "1", "--", repo, dir)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to clone role registry %s: %w: %s", location, err, strings.TrimSpace(string(out)))
+		}
+	case strings.HasPrefix(location, "http://"):
+		return nil, fmt.Errorf("%w: %s; use an https:// URL", ErrInsecureRegistry, location)
+	case strings.HasPrefix(location, "https://"):
+	default:
+		if info, err := os.Stat(location); err == nil && info.IsDir() {
+			r.base = filepath.Join(location, roleIndexName)
+		}
+	}
+
+	data, err := r.read(ctx, r.base)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to read role registry index: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.index); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to parse role registry index %s: %w", r.base, err)
+	}
+	return r, nil
+}
+
+// Close removes the local clone of a git registry.
+func (r *roleRegistry) Close() {
+	if r.cleanup != nil {
+		r.cleanup()
+	}
+}
+
+// resolve returns the location of ref, a path relative to the index. Paths in
+// local and git registries cannot leave the registry's directory.
+func (r *roleRegistry) resolve(ref string) (string, error) {
+	if base, err := url.Parse(r.base); err == nil && base.Scheme == "https" {
+		rel, err := url.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid role path %q: %w", ref, err)
+		}
+		resolved := base.ResolveReference(rel)
+		if resolved.Scheme != "https" {
+			return "", fmt.Errorf("%w: role path %q: registries served over HTTPS must list HTTPS locations", ErrInsecureRegistry, ref)
+		}
+		return resolved.String(), nil
+	}
+	clean := path.Clean("/" + filepath.ToSlash(ref))
+	return filepath.Join(filepath.Dir(r.base), filepath.FromSlash(clean)), nil
+}
+
+// read returns at most maxRoleFileSize bytes of the file or URL at location.
+func (r *roleRegistry) read(ctx context.Context, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "https://") {
+		file, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(io.LimitReader(file, maxRoleFileSize))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := roleRegistryClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", location, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRoleFileSize))
+}
+
+// roleRegistryLocation returns the role registry to use: location if given,
+// or else `Settings.RoleRegistry`.
+func (w *Workspace) roleRegistryLocation(location string) (string, error) {
+	if location == "" {
+		location = w.Context.Settings.RoleRegistry
+	}
+	if location == "" {
+		return "", ErrNoRoleRegistry
+	}
+	return location, nil
+}
+
+// SearchRoleRegistry returns the roles offered by the role registry at
+// location, or by `Settings.RoleRegistry` if location is empty, whose name,
+// label, or description contains query, ignoring case. An empty query
+// returns all of them.
+func (w *Workspace) SearchRoleRegistry(ctx context.Context, location, query string) ([]RoleListing, error) {
+	location, err := w.roleRegistryLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := openRoleRegistry(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	defer registry.Close()
+
+	query = strings.ToLower(query)
+	var found []RoleListing
+	for _, l := range registry.index.Roles {
+		text := strings.ToLower(l.Name + "\n" + l.Label + "\n" + l.Description)
+		if strings.Contains(text, query) {
+			found = append(found, l)
+		}
+	}
+	return found, nil
+}
+
+// InstallRegistryRole installs the role with the given name from the role
+// registry at location, or from `Settings.RoleRegistry` if location is empty,
+// into the workspace's `roles/` directory. The role's definition must match
+// the SHA-256 checksum listed in the registry's index, or `ErrChecksumMismatch`
+// is returned and nothing is installed. Like `InstallBuiltinRole`, it refuses
+// to overwrite an existing role with the same name.
+func (w *Workspace) InstallRegistryRole(ctx context.Context, location, name string) (Role, error) {
+	if err := validateName("role", name); err != nil {
+		return Role{}, err
+	}
+	location, err := w.roleRegistryLocation(location)
+	if err != nil {
+		return Role{}, err
+	}
+	rolePath := filepath.Join(w.RootDir, "roles", name+".json")
+	if _, err := w.storage.Stat(rolePath); err == nil {
+		return Role{}, fmt.Errorf("role '%s' already exists in the workspace", name)
+	} else if !os.IsNotExist(err) {
+		return Role{}, fmt.Errorf("failed to check role file %s: %w", rolePath, err)
+	}
+
+	registry, err := openRoleRegistry(ctx, location)
+	if err != nil {
+		return Role{}, err
+	}
+	defer registry.Close()
+
+	var listing *RoleListing
+	for i, l := range registry.index.Roles {
+		if l.Name == name {
+			listing = &registry.index.Roles[i]
+		}
+	}
+	if listing == nil {
+		return Role{}, fmt.Errorf("%w: no role named '%s' in registry %s", ErrRoleNotFound, name, location)
+	}
+	if listing.SHA256 == "" {
+		return Role{}, fmt.Errorf("%w: registry lists no checksum for role '%s'", ErrChecksumMismatch, name)
+	}
+	source, err := registry.resolve(listing.Path)
+	if err != nil {
+		return Role{}, err
+	}
+	data, err := registry.read(ctx, source)
+	if err != nil {
+		return Role{}, fmt.Errorf("failed to fetch role '%s': %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, listing.SHA256) {
+		return Role{}, fmt.Errorf("%w: role '%s' has checksum %s, the registry lists %s", ErrChecksumMismatch, name, got, listing.SHA256)
+	}
+
+	var role Role
+	if err := json.Unmarshal(data, &role); err != nil {
+		return Role{}, fmt.Errorf("failed to parse role '%s' from the registry: %w", name, err)
+	}
+	if role.Name != name {
+		return Role{}, fmt.Errorf("registry role '%s' is defined as '%s'", name, role.Name)
+	}
+	if err := w.saveRole(role); err != nil {
+		return Role{}, fmt.Errorf("failed to install role %s: %w", name, err)
+	}
+	return role, w.logAction(fmt.Sprintf("Installed role %s from registry %s", name, location))
+}
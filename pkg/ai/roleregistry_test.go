@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRoleRegistry writes a role registry with the given roles to a
+// temporary directory and returns it. The checksum of the role named
+// tampered is listed wrong.
+func writeRoleRegistry(t *testing.T, tampered string, roles ...Role) string {
+	t.Helper()
+	dir := t.TempDir()
+	var index roleIndex
+	for _, role := range roles {
+		data, err := json.Marshal(role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, role.Name+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		if role.Name == tampered {
+			sum = sha256.Sum256([]byte("something else"))
+		}
+		index.Roles = append(index.Roles, RoleListing{Name: role.Name, Label: role.Label, Description: role.Description, Path: role.Name + ".json", SHA256: hex.EncodeToString(sum[:])})
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, roleIndexName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRoleRegistry(t *testing.T) {
+	dir := writeRoleRegistry(t, "tampered",
+		Role{Name: "translator", Label: "Translator", Description: "Translates documentation.", Persona: "You translate."},
+		Role{Name: "tampered", Label: "Tampered", Description: "Altered after listing.", Persona: "You misbehave."},
+	)
+	server := httptest.NewTLSServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+	client := roleRegistryClient
+	roleRegistryClient = server.Client()
+	defer func() { roleRegistryClient = client }()
+
+	tests := []struct {
+		name     string
+		location string
+	}{
+		{"directory", dir},
+		{"https", server.URL + "/" + roleIndexName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkspace(t)
+			found, err := w.SearchRoleRegistry(t.Context(), tt.location, "TRANSLATES")
+			if err != nil || len(found) != 1 || found[0].Name != "translator" {
+				t.Fatalf("SearchRoleRegistry = %v, %v, want translator", found, err)
+			}
+			role, err := w.InstallRegistryRole(t.Context(), tt.location, "translator")
+			if err != nil || role.Persona != "You translate." {
+				t.Fatalf("InstallRegistryRole(translator) = %+v, %v", role, err)
+			}
+			if _, err := w.loadRole("translator"); err != nil {
+				t.Errorf("loadRole after install: %v", err)
+			}
+			if _, err := w.InstallRegistryRole(t.Context(), tt.location, "translator"); err == nil {
+				t.Error("installing translator twice succeeded, want an error")
+			}
+			if _, err := w.InstallRegistryRole(t.Context(), tt.location, "tampered"); !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("InstallRegistryRole(tampered) = %v, want ErrChecksumMismatch", err)
+			}
+			if _, err := w.InstallRegistryRole(t.Context(), tt.location, "missing"); !errors.Is(err, ErrRoleNotFound) {
+				t.Errorf("InstallRegistryRole(missing) = %v, want ErrRoleNotFound", err)
+			}
+		})
+	}
+
+	w := newTestWorkspace(t)
+	if _, err := w.SearchRoleRegistry(t.Context(), "", ""); !errors.Is(err, ErrNoRoleRegistry) {
+		t.Errorf("SearchRoleRegistry without a registry = %v, want ErrNoRoleRegistry", err)
+	}
+	for _, location := range []string{"http://example.com/index.json", "git+http://example.com/roles.git", "git://example.com/roles.git"} {
+		if _, err := w.SearchRoleRegistry(t.Context(), location, ""); !errors.Is(err, ErrInsecureRegistry) {
+			t.Errorf("SearchRoleRegistry(%s) = %v, want ErrInsecureRegistry", location, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// float32Ptr returns a pointer to v, for populating optional generation parameters.
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+// builtinRoles is the library of role presets shipped with nani.
+// They are not present in a workspace until installed with `InstallBuiltinRole`,
+// after which they behave like any other role and can be edited freely.
+var builtinRoles = map[string]Role{
+	"reviewer": {
+		Name:        "reviewer",
+		Label:       "Code Reviewer",
+		Persona:     "You are a senior engineer performing a careful code review. You look for correctness bugs, race conditions, error handling gaps, security issues, and unclear naming before style nits. For every finding you cite the file and line, explain the impact, and propose a concrete fix. You are direct but respectful, and you explicitly say when code looks good.",
+		Description: "Reviews code and diffs for bugs, risks, and maintainability issues with actionable suggestions.",
+		Parameters:  RoleParameters{Temperature: float32Ptr(0.2), TopP: float32Ptr(0.9)},
+	},
+	"tester": {
+		Name:        "tester",
+		Label:       "Test Writer",
+		Persona:     "You are a pragmatic test engineer. Given source code, you write focused, deterministic tests in the project's existing test style and framework, covering the happy path, edge cases, and error paths. You prefer table-driven tests where the language idiom supports them, avoid mocking what you do not own, and never change production code unless asked.",
+		Description: "Writes idiomatic unit and integration tests for the provided code.",
+		Parameters:  RoleParameters{Temperature: float32Ptr(0.2)},
+	},
+	"refactorer": {
+		Name:        "refactorer",
+		Label:       "Refactorer",
+		Persona:     "You are an expert at behaviour-preserving refactoring. You improve structure, naming, and duplication in small, reviewable steps, explain the motivation for each step, and call out anything that could change observable behaviour. You keep public APIs stable unless told otherwise and match the conventions already present in the code. You explain the steps as your content and give the changes themselves as a unified diff in the patch field.",
+		Description: "Restructures existing code in safe, incremental, behaviour-preserving steps.",
+		Parameters:  RoleParameters{Temperature: float32Ptr(0.3)},
+		Schema: &FieldSchema{
+			Type: "object",
+			Properties: map[string]*FieldSchema{
+				PatchField: {Type: "string", Description: "The changes as a unified diff, as printed by `git diff`, with paths relative to the project root and at least three lines of context around each change; empty if there are none."},
+			},
+		},
+	},
+	"committer": {
+		Name:        "committer",
+		Label:       "Commit Message Writer",
+		Persona:     "You write clear, conventional commit messages from diffs. The subject line is imperative, at most 72 characters, and follows the Conventional Commits format (type(scope): subject). The body explains what changed and why in wrapped plain text, and mentions breaking changes explicitly. You reply with the full commit message as your content, and with its type, scope, subject, and body as separate fields.",
+		Description: "Turns diffs into concise Conventional Commits messages.",
+		Parameters:  RoleParameters{Temperature: float32Ptr(0.1)},
+		Schema: &FieldSchema{
+			Type: "object",
+			Properties: map[string]*FieldSchema{
+				"type":    {Type: "string", Description: "Conventional Commits type.", Enum: []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}},
+				"scope":   {Type: "string", Description: "Area of the code changed, if any; empty otherwise."},
+				"subject": {Type: "string", Description: "Imperative subject line, without the type and scope."},
+				"body":    {Type: "string", Description: "Wrapped explanation of what changed and why; empty if the subject suffices."},
+			},
+			Required: []string{"type", "subject"},
+		},
+	},
+	"explainer": {
+		Name:        "explainer",
+		Label:       "Code Explainer",
+		Persona:     "You are a patient mentor who explains code to developers new to a codebase. You start with the big picture, then walk through the control flow and key data structures, define jargon the first time it appears, and use short examples or analogies where they help. You point out non-obvious behaviour and likely pitfalls.",
+		Description: "Explains how code works, from architecture down to individual functions.",
+		Parameters:  RoleParameters{Temperature: float32Ptr(0.6)},
+	},
+	"auditor": {
+		Name:        "auditor",
+		Label:       "Security Auditor",
+		Persona:     "You are an application security auditor. You analyse code for injection, authentication and authorization flaws, unsafe deserialization, path traversal, secrets in source, insecure defaults, and dependency risks. Each finding includes a severity (critical, high, medium, low), the affected location, an exploitation scenario, and a remediation. You do not speculate without evidence from the code.",
+		Description: "Audits code for security vulnerabilities and rates findings by severity.",
+		Parameters:  RoleParameters{Temperature: float32Ptr(0.1), TopP: float32Ptr(0.8)},
+	},
+}
+
+// BuiltinRoles returns summaries of all role presets shipped with nani, sorted by name.
+// The presets can be installed into the workspace with `InstallBuiltinRole`.
+func BuiltinRoles() []RoleSummary {
+	roles := make([]RoleSummary, 0, len(builtinRoles))
+	for _, r := range builtinRoles {
+		roles = append(roles, RoleSummary{
+			Name:        r.Name,
+			Label:       r.Label,
+			Description: r.Description,
+		})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+// InstallBuiltinRole copies a built-in role preset into the workspace's `roles/` directory
+// and adds it to the `RolesIndex`. It refuses to overwrite an existing role with the same
+// name so that locally tuned personas are never clobbered.
+func (w *Workspace) InstallBuiltinRole(name string) error {
+	role, ok := builtinRoles[name]
+	if !ok {
+		return fmt.Errorf("%w: no built-in role named '%s'", ErrRoleNotFound, name)
+	}
+
+	rolePath := filepath.Join(w.RootDir, "roles", fmt.Sprintf("%s.json", name))
+	if _, err := w.storage.Stat(rolePath); err == nil {
+		return fmt.Errorf("role '%s' already exists in the workspace", name)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check role file %s: %w", rolePath, err)
+	}
+
+	if err := w.saveRole(role); err != nil {
+		return fmt.Errorf("failed to install built-in role %s: %w", name, err)
+	}
+	return w.logAction(fmt.Sprintf("Installed built-in role %s", name))
+}
+
+// InstallGlobalBuiltinRole copies a built-in role preset into the global workspace
+// so it is available in every project. Like `InstallBuiltinRole`, it refuses to
+// overwrite an existing global role with the same name.
+func (w *Workspace) InstallGlobalBuiltinRole(name string) error {
+	role, ok := builtinRoles[name]
+	if !ok {
+		return fmt.Errorf("%w: no built-in role named '%s'", ErrRoleNotFound, name)
+	}
+	if _, err := w.storage.Stat(filepath.Join(w.globalDir, "roles", fmt.Sprintf("%s.json", name))); err == nil {
+		return fmt.Errorf("global role '%s' already exists", name)
+	}
+	return w.SaveGlobalRole(role)
+}
+
+// ResolveRole returns the role with the given name from the workspace, or the
+// built-in preset of that name if the workspace has no such role, so features
+// built on a preset work before it is installed.
+func (w *Workspace) ResolveRole(name string) (Role, error) {
+	role, err := w.loadRole(name)
+	if builtin, ok := builtinRoles[name]; ok && errors.Is(err, ErrRoleNotFound) {
+		return builtin, nil
+	}
+	return role, err
+}
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"google.golang.org/genai"
+)
+
+// Tasks the router picks models for. Each has a route in `Settings.Routes`.
+const (
+	TaskChat      = "chat"      // Messages of a chat session.
+	TaskLongChat  = "long-chat" // Chat messages longer than `Settings.LongPromptChars`; falls back to the chat route.
+	TaskDocs      = "docs"      // Documentation generated by `nani docs`.
+	TaskReview    = "review"    // Reviews by `nani review`.
+	TaskPrompt    = "prompt"    // Single prompts, such as scheduled runs, API completions, and memory extraction.
+	TaskSummary   = "summary"   // Housekeeping: compacting and titling sessions.
+	TaskEmbedding = "embedding" // Embeddings for semantic search.
+)
+
+// defaultLongPromptChars is the size of a chat message, in characters, above
+// which it takes the long-chat route when `Settings.LongPromptChars` is not set.
+const defaultLongPromptChars = 8000
+
+// ModelsFor returns the models to try for task, in order: the route
+// configured in `Settings.Routes`, or the built-in default. A non-empty
+// override, such as the model of a role, comes first, and the route's other
+// models remain as its fallbacks.
+func (w *Workspace) ModelsFor(task, override string) []string {
+	models := w.Context.Settings.Routes[task]
+	if len(models) == 0 && task == TaskLongChat {
+		models = w.Context.Settings.Routes[TaskChat]
+	}
+	if len(models) == 0 {
+		models = []string{defaultModel}
+		if task == TaskEmbedding {
+			models = []string{embeddingModel}
+		}
+	}
+	if override == "" {
+		return models
+	}
+	routed := []string{override}
+	for _, m := range models {
+		if m != override {
+			routed = append(routed, m)
+		}
+	}
+	return routed
+}
+
+// chatTask returns the task a chat message whose text is size characters
+// long is routed as.
+func (w *Workspace) chatTask(size int) string {
+	limit := w.Context.Settings.LongPromptChars
+	if limit <= 0 {
+		limit = defaultLongPromptChars
+	}
+	if size > limit {
+		return TaskLongChat
+	}
+	return TaskChat
+}
+
+// validateRoutes checks that routes name known tasks and list models.
+func validateRoutes(routes map[string][]string) error {
+	tasks := []string{TaskChat, TaskLongChat, TaskDocs, TaskReview, TaskPrompt, TaskSummary, TaskEmbedding}
+	for _, task := range sortedKeys(routes) {
+		if !slices.Contains(tasks, task) {
+			return fmt.Errorf("unknown route %q, expected one of %v", task, tasks)
+		}
+		if slices.Contains(routes[task], "") {
+			return fmt.Errorf("route %q has an empty model name", task)
+		}
+	}
+	return nil
+}
+
+// generate sends contents to the first of models, moving on to the next
+// whenever one is unavailable (see `ErrProviderUnavailable`). It returns the
+// response and the model that produced it. Errors are mapped by
+// `providerError`.
+func (g *GeminiAIClient) generate(ctx context.Context, models []string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, string, error) {
+	var err error
+	for i, model := range models {
+		var resp *genai.GenerateContentResponse
+		if resp, err = g.client.Models.GenerateContent(ctx, model, contents, config); err == nil {
+			return resp, model, nil
+		}
+		err = providerError(err)
+		if !errors.Is(err, ErrProviderUnavailable) || i == len(models)-1 {
+			break
+		}
+		g.workspace.logAction(fmt.Sprintf("Warning: Model %s unavailable, falling back to %s: %v", model, models[i+1], err))
+	}
+	return nil, "", err
+}
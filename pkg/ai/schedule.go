@@ -0,0 +1,392 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/genai"
+)
+
+// Schedule triggers.
+const (
+	TriggerCron   = "cron"   // Run when the schedule's cron expression comes due.
+	TriggerCommit = "commit" // Run after each git commit, via the post-commit hook.
+)
+
+// Schedule runs a prompt template automatically and stores the result. It is
+// stored in `schedules/<name>.json`.
+type Schedule struct {
+	Name     string    `json:"name"`              // Unique name, also the file name.
+	Template string    `json:"template"`          // Name of the prompt template to render.
+	Trigger  string    `json:"trigger"`           // TriggerCron or TriggerCommit.
+	Cron     string    `json:"cron,omitempty"`    // Five-field cron expression or macro, for TriggerCron.
+	Role     string    `json:"role,omitempty"`    // Role answering the prompt; the default role if empty.
+	Input    string    `json:"input,omitempty"`   // Value of {{.Input}} when rendering the template.
+	Output   string    `json:"output,omitempty"`  // File the result is written to; an archived session if empty.
+	Created  time.Time `json:"created"`           // When the schedule was added.
+	LastRun  time.Time `json:"lastRun,omitempty"` // When the schedule last ran successfully.
+}
+
+// PromptRunner answers a single prompt outside any session. `GeminiAIClient`
+// implements it.
+type PromptRunner interface {
+	RunPrompt(ctx context.Context, role Role, prompt string) (string, error)
+}
+
+// validate checks the schedule's name, trigger, and cron expression, and that
+// its output file is a relative path inside the project. Schedules are synced
+// from teammates and run unattended, so they must not write anywhere else.
+func (s Schedule) validate() error {
+	if err := validateName("schedule", s.Name); err != nil {
+		return err
+	}
+	if s.Template == "" {
+		return errors.New("schedule template is required")
+	}
+	if out := filepath.Clean(filepath.FromSlash(s.Output)); s.Output != "" && (filepath.IsAbs(out) || out == ".." || strings.HasPrefix(out, ".."+string(filepath.Separator))) {
+		return fmt.Errorf("schedule output %s must be a path inside the project", s.Output)
+	}
+	switch s.Trigger {
+	case TriggerCron:
+		if _, err := parseCron(s.Cron); err != nil {
+			return err
+		}
+	case TriggerCommit:
+	default:
+		return fmt.Errorf("unknown schedule trigger '%s', expected %s or %s", s.Trigger, TriggerCron, TriggerCommit)
+	}
+	return nil
+}
+
+// SaveSchedule validates s and writes it to `schedules/<name>.json`,
+// replacing any schedule with the same name.
+func (w *Workspace) SaveSchedule(s Schedule) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	if s.Created.IsZero() {
+		s.Created = w.now()
+	}
+	path := filepath.Join(w.RootDir, "schedules", fmt.Sprintf("%s.json", s.Name))
+	if err := w.writeJSON(path, s); err != nil {
+		return fmt.Errorf("failed to save schedule %s: %w", s.Name, err)
+	}
+	return w.logAction(fmt.Sprintf("Saved schedule %s", s.Name))
+}
+
+// LoadSchedule loads a schedule by name from `schedules/<name>.json`.
+func (w *Workspace) LoadSchedule(name string) (*Schedule, error) {
+	if err := validateName("schedule", name); err != nil {
+		return nil, err
+	}
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, "schedules", fmt.Sprintf("%s.json", name)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read schedule %s: %w", name, err)
+	}
+	var s Schedule
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse schedule %s: %w", name, err)
+	}
+	return &s, nil
+}
+
+// ListSchedules returns the workspace's schedules sorted by name. Schedules
+// that cannot be loaded are logged and skipped.
+func (w *Workspace) ListSchedules() ([]Schedule, error) {
+	entries, err := w.storage.ReadDir(filepath.Join(w.RootDir, "schedules"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read schedules directory: %w", err)
+	}
+	var schedules []Schedule
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		s, err := w.LoadSchedule(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not load schedule '%s': %v", entry.Name(), err))
+			continue
+		}
+		schedules = append(schedules, *s)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Name < schedules[j].Name })
+	return schedules, nil
+}
+
+// DeleteSchedule deletes `schedules/<name>.json`.
+func (w *Workspace) DeleteSchedule(name string) error {
+	if err := validateName("schedule", name); err != nil {
+		return err
+	}
+	path := filepath.Join(w.RootDir, "schedules", fmt.Sprintf("%s.json", name))
+	if err := w.storage.Remove(path); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete schedule file %s: %w", name, err)
+	}
+	if err := w.recordRemove(path); err != nil {
+		return fmt.Errorf("failed to update manifest after deleting schedule: %w", err)
+	}
+	return w.logAction(fmt.Sprintf("Deleted schedule %s", name))
+}
+
+// since returns the start of the period the next run of s covers: its last
+// run, or its creation if it has never run.
+func (s Schedule) since() time.Time {
+	if s.LastRun.IsZero() {
+		return s.Created
+	}
+	return s.LastRun
+}
+
+// NextRun returns when a cron schedule next comes due after its last run, or
+// the zero time for other triggers and expressions that never match.
+func (s Schedule) NextRun() time.Time {
+	if s.Trigger != TriggerCron {
+		return time.Time{}
+	}
+	spec, err := parseCron(s.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+	return spec.next(s.since())
+}
+
+// DueSchedules returns the schedules to run for trigger at now. Commit
+// schedules are always due when trigger is TriggerCommit; cron schedules are
+// due when their expression has matched a minute since they last ran, so runs
+// missed while nothing was checking are caught up once, not repeatedly.
+func (w *Workspace) DueSchedules(trigger string, now time.Time) ([]Schedule, error) {
+	schedules, err := w.ListSchedules()
+	if err != nil {
+		return nil, err
+	}
+	var due []Schedule
+	for _, s := range schedules {
+		if s.Trigger != trigger {
+			continue
+		}
+		if trigger == TriggerCron {
+			next := s.NextRun()
+			if next.IsZero() || next.After(now) {
+				continue
+			}
+		}
+		due = append(due, s)
+	}
+	return due, nil
+}
+
+// RunSchedule renders the schedule's template, has runner answer it, and
+// stores the answer: in the schedule's output file, or otherwise as a new
+// archived session labelled after the schedule. {{.Diff}} holds the last
+// commit for commit schedules and the commits since the last run for cron
+// schedules. On success the schedule's `LastRun` is set to now and a
+// description of where the result was stored is returned.
+func (w *Workspace) RunSchedule(ctx context.Context, runner PromptRunner, s Schedule, now time.Time) (string, error) {
+	if err := s.validate(); err != nil {
+		return "", fmt.Errorf("invalid schedule %s: %w", s.Name, err)
+	}
+	t, err := w.LoadTemplate(s.Template)
+	if err != nil {
+		return "", err
+	}
+	data := TemplateData{Input: s.Input}
+	if t.UsesPlaceholder("Diff") {
+		if data.Diff, err = w.scheduleDiff(s); err != nil {
+			return "", err
+		}
+	}
+	prompt, err := w.RenderTemplate(s.Template, data)
+	if err != nil {
+		return "", err
+	}
+
+	roleName := s.Role
+	if roleName == "" {
+		roleName = w.Context.Settings.DefaultRole
+	}
+	role, err := w.loadRole(roleName)
+	if err != nil {
+		return "", fmt.Errorf("failed to load role %s for schedule %s: %w", roleName, s.Name, err)
+	}
+
+	answer, err := runner.RunPrompt(ctx, role, prompt)
+	if err != nil {
+		return "", fmt.Errorf("schedule %s failed: %w", s.Name, err)
+	}
+
+	var stored string
+	if s.Output != "" {
+		stored, err = w.writeScheduleOutput(s, answer, now)
+	} else {
+		stored, err = w.archiveScheduleRun(s, role, prompt, answer, now)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.LastRun = now
+	if err := w.SaveSchedule(s); err != nil {
+		return "", err
+	}
+	return stored, w.logAction(fmt.Sprintf("Ran schedule %s, result in %s", s.Name, stored))
+}
+
+// scheduleDiff returns the changes a run of s is about, as produced by git in
+// the project directory.
+func (w *Workspace) scheduleDiff(s Schedule) (string, error) {
+	args := []string{"show", "--format=medium", "HEAD"}
+	if s.Trigger == TriggerCron {
+		args = []string{"log", "-p", "--since=" + s.since().Format(time.RFC3339)}
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = w.projectDir()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get changes for schedule %s: %w", s.Name, err)
+	}
+	return string(out), nil
+}
+
+// writeScheduleOutput writes answer to the schedule's output file, resolved
+// against the project directory, which it must not leave. "{date}" and
+// "{time}" in the path are replaced with the run's date and time, so each run
+// can keep its own report.
+func (w *Workspace) writeScheduleOutput(s Schedule, answer string, now time.Time) (string, error) {
+	out := strings.NewReplacer("{date}", now.Format("2006-01-02"), "{time}", now.Format("150405")).Replace(s.Output)
+	ref, err := w.sourceRef(filepath.Join(w.projectDir(), filepath.FromSlash(out)))
+	if err != nil {
+		return "", fmt.Errorf("failed to write output of schedule %s: %w", s.Name, err)
+	}
+	out = w.sourcePath(ref)
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory for %s: %w", out, err)
+	}
+	if err := os.WriteFile(out, []byte(strings.TrimSpace(answer)+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write schedule output %s: %w", out, err)
+	}
+	return out, nil
+}
+
+// archiveScheduleRun stores a run as a new archived session with a single turn.
+func (w *Workspace) archiveScheduleRun(s Schedule, role Role, prompt, answer string, now time.Time) (string, error) {
+	session := &Session{
+		ID:    uuid.New().String(),
+		Label: fmt.Sprintf("%s %s", s.Name, now.Format("2006-01-02 15:04")),
+		Role:  role,
+		Chat: []Chat{{
+			ID:       uuid.New().String(),
+			Message:  SavedMessage{Content: prompt, Timestamp: now},
+			Response: SavedResponse{Content: answer, Timestamp: w.now()},
+		}},
+		Metadata: Metadata{CreatedAt: now, LastUpdated: w.now()},
+	}
+	archiveName := w.archiveFileName(session)
+	if err := w.writeJSON(filepath.Join(w.RootDir, "sessions", archiveName), session); err != nil {
+		return "", fmt.Errorf("failed to archive run of schedule %s: %w", s.Name, err)
+	}
+	w.Context.Indexes.ArchivedSessions[session.ID] = sessionSummary(session, archiveName)
+	if err := w.saveContext(w.Context); err != nil {
+		return "", fmt.Errorf("failed to update context after running schedule: %w", err)
+	}
+	return fmt.Sprintf("session %s", session.ID), nil
+}
+
+// RunPrompt implements `PromptRunner`, answering prompt in the given role
+// without reading or recording any session. The system instruction is built
+// in the same layers as a chat's (see `Workspace.SystemPrompt`). A structured reply is reduced to
+// its content.
+func (g *GeminiAIClient) RunPrompt(ctx context.Context, role Role, prompt string) (string, error) {
+	instructions, err := g.workspace.roleInstructions(role)
+	if err != nil {
+		return "", err
+	}
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(instructions, genai.Role(role.Name)),
+		Temperature:       role.Parameters.Temperature,
+		TopP:              role.Parameters.TopP,
+	}
+
+	prompt, _, err = g.workspace.Redact("prompt", prompt)
+	if err != nil {
+		return "", err
+	}
+
+	resp, _, err := g.generate(ctx, g.workspace.ModelsFor(TaskPrompt, role.Parameters.Model), genai.Text(prompt), config)
+	if err != nil {
+		return "", fmt.Errorf("failed to get response from Gemini: %w", err)
+	}
+	answer := strings.TrimSpace(resp.Text())
+	if answer == "" {
+		return "", errors.New("model returned an empty response")
+	}
+	if parsed, err := parseAIResponse(answer, nil); err == nil && parsed.Content != "" {
+		answer = parsed.Content
+	}
+	return answer, nil
+}
+
+// scheduleHookMarker identifies the line `InstallScheduleHook` adds to the
+// post-commit hook, so installing twice does not duplicate it.
+const scheduleHookMarker = "# nani: run commit schedules"
+
+// InstallScheduleHook adds a line running `nani schedule run --trigger commit`
+// from the project directory to its git post-commit hook, creating the hook
+// if needed. The command runs in the background so commits are not slowed
+// down. It returns the hook's path and whether the hook was changed.
+func (w *Workspace) InstallScheduleHook() (string, bool, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	cmd.Dir = w.projectDir()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to locate git hooks directory: %w", err)
+	}
+	hooksDir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(w.projectDir(), hooksDir)
+	}
+	hookPath := filepath.Join(hooksDir, "post-commit")
+
+	existing, err := os.ReadFile(hookPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, fmt.Errorf("failed to read %s: %w", hookPath, err)
+	}
+	if strings.Contains(string(existing), scheduleHookMarker) {
+		return hookPath, false, nil
+	}
+	content := string(existing)
+	if content == "" {
+		content = "#!/bin/sh\n"
+	} else if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	projectDir, err := filepath.Abs(w.projectDir())
+	if err != nil {
+		return "", false, fmt.Errorf("failed to resolve project directory: %w", err)
+	}
+	content += fmt.Sprintf("%s\n(cd '%s' && nani schedule run --trigger %s >/dev/null 2>&1 &)\n",
+		scheduleHookMarker, strings.ReplaceAll(projectDir, "'", `'\''`), TriggerCommit)
+
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return "", false, fmt.Errorf("failed to create %s: %w", hooksDir, err)
+	}
+	if err := os.WriteFile(hookPath, []byte(content), 0755); err != nil {
+		return "", false, fmt.Errorf("failed to write %s: %w", hookPath, err)
+	}
+	if err := os.Chmod(hookPath, 0755); err != nil {
+		return "", false, fmt.Errorf("failed to make %s executable: %w", hookPath, err)
+	}
+	return hookPath, true, w.logAction(fmt.Sprintf("Installed schedule hook in %s", hookPath))
+}
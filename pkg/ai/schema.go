@@ -0,0 +1,242 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"google.golang.org/genai"
+)
+
+// FieldSchema describes a value in a role's structured replies, in the
+// subset of JSON Schema that model providers accept for structured output.
+type FieldSchema struct {
+	Type        string                  `json:"type"`                  // "string", "number", "integer", "boolean", "array", or "object".
+	Description string                  `json:"description,omitempty"` // What the value holds; passed to the model.
+	Enum        []string                `json:"enum,omitempty"`        // Allowed values of a string.
+	Items       *FieldSchema            `json:"items,omitempty"`       // Schema of the elements of an array.
+	Properties  map[string]*FieldSchema `json:"properties,omitempty"`  // Fields of an object.
+	Required    []string                `json:"required,omitempty"`    // Fields of an object that must be present.
+}
+
+// schemaTypes maps the field types `FieldSchema` supports to their Gemini types.
+var schemaTypes = map[string]genai.Type{
+	"string":  genai.TypeString,
+	"number":  genai.TypeNumber,
+	"integer": genai.TypeInteger,
+	"boolean": genai.TypeBoolean,
+	"array":   genai.TypeArray,
+	"object":  genai.TypeObject,
+}
+
+// baseResponseFields are the fields every structured reply has, which a
+// role's schema may not redefine.
+var baseResponseFields = []string{"think", "summary", "content", "actions", "findings", "remember"}
+
+// validateRoleSchema checks that schema, the schema of a role's replies, is
+// an object schema whose fields are well formed and do not redefine
+// baseResponseFields. A nil schema is valid.
+func validateRoleSchema(schema *FieldSchema) error {
+	if schema == nil {
+		return nil
+	}
+	if schema.Type != "object" {
+		return fmt.Errorf("invalid role schema: type must be \"object\", not %q", schema.Type)
+	}
+	for name := range schema.Properties {
+		if slices.Contains(baseResponseFields, name) {
+			return fmt.Errorf("invalid role schema: field %q is part of every reply and cannot be redefined", name)
+		}
+	}
+	if patch, ok := schema.Properties[PatchField]; ok && patch != nil && patch.Type != "string" {
+		return fmt.Errorf("invalid role schema: field %q holds a unified diff and must be a string", PatchField)
+	}
+	return schema.check("schema")
+}
+
+// check reports the first malformed part of s, naming it by its path.
+func (s *FieldSchema) check(path string) error {
+	if s == nil {
+		return fmt.Errorf("invalid role schema: %s is empty", path)
+	}
+	if _, ok := schemaTypes[s.Type]; !ok {
+		return fmt.Errorf("invalid role schema: %s has unknown type %q", path, s.Type)
+	}
+	if len(s.Enum) > 0 && s.Type != "string" {
+		return fmt.Errorf("invalid role schema: %s has an enum but is not a string", path)
+	}
+	switch s.Type {
+	case "array":
+		return s.Items.check(path + "[]")
+	case "object":
+		for _, name := range s.Required {
+			if _, ok := s.Properties[name]; !ok {
+				return fmt.Errorf("invalid role schema: %s requires undefined field %q", path, name)
+			}
+		}
+		for _, name := range sortedKeys(s.Properties) {
+			if err := s.Properties[name].check(path + "." + name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// genaiSchema converts s into a Gemini response schema.
+func (s *FieldSchema) genaiSchema() *genai.Schema {
+	schema := &genai.Schema{
+		Type:        schemaTypes[s.Type],
+		Description: s.Description,
+		Enum:        s.Enum,
+		Required:    s.Required,
+	}
+	if s.Items != nil {
+		schema.Items = s.Items.genaiSchema()
+	}
+	if len(s.Properties) > 0 {
+		schema.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, field := range s.Properties {
+			schema.Properties[name] = field.genaiSchema()
+		}
+	}
+	return schema
+}
+
+// responseSchema builds the Gemini response schema of role's replies: the
+// think, summary, and content fields, optional actions, findings, and facts
+// to remember, and the fields of the role's own schema, if any.
+func responseSchema(role Role) *genai.Schema {
+	schema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"think":   {Type: genai.TypeString},
+			"summary": {Type: genai.TypeString},
+			"content": {Type: genai.TypeString},
+			"actions": {
+				Type:        genai.TypeArray,
+				Description: "Optional follow-up work items the user should act on, if any.",
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"description": {Type: genai.TypeString},
+						"file":        {Type: genai.TypeString},
+						"type":        {Type: genai.TypeString},
+					},
+					Required: []string{"description"},
+				},
+			},
+			"findings": {
+				Type:        genai.TypeArray,
+				Description: "Review findings anchored to lines of project files, when the response reviews or annotates code.",
+				Items:       findingSchema(),
+			},
+			"remember": {
+				Type:        genai.TypeArray,
+				Description: "Optional durable facts about the project learned in this exchange and worth knowing in later sessions (e.g., libraries used, where code lives), one sentence each. Not style instructions, and not facts already listed as project facts.",
+				Items:       &genai.Schema{Type: genai.TypeString},
+			},
+		},
+		Required: []string{"think", "summary", "content"},
+	}
+	if role.Schema != nil {
+		for name, field := range role.Schema.Properties {
+			schema.Properties[name] = field.genaiSchema()
+		}
+		schema.Required = append(schema.Required, role.Schema.Required...)
+	}
+	return schema
+}
+
+// roleFields extracts the fields defined by schema from a reply's JSON
+// object and validates them against it. It returns nil if schema is nil.
+func roleFields(raw []byte, schema *FieldSchema) (map[string]any, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	var reply map[string]any
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	fields := make(map[string]any)
+	for name := range schema.Properties {
+		if value, ok := reply[name]; ok {
+			fields[name] = value
+		}
+	}
+	if err := schema.validate(fields, "reply"); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// validate reports the first part of value that does not match s, naming it
+// by its path. Values are as decoded by `encoding/json` into an `any`.
+func (s *FieldSchema) validate(value any, path string) error {
+	mismatch := func(expected string) error {
+		return fmt.Errorf("%w: %s is not %s", ErrSchemaMismatch, path, expected)
+	}
+	switch s.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return mismatch("a string")
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return mismatch(fmt.Sprintf("one of %v", s.Enum))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return mismatch("a number")
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return mismatch("an integer")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch("a boolean")
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return mismatch("an array")
+		}
+		for i, item := range items {
+			if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return mismatch("an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%w: %s.%s is missing", ErrSchemaMismatch, path, name)
+			}
+		}
+		for _, name := range sortedKeys(s.Properties) {
+			if field, ok := object[name]; ok {
+				if err := s.Properties[name].validate(field, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order, so schema errors are reported
+// deterministically.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
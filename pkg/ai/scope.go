@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithScope returns a view of the workspace confined to the project
+// subdirectory dir, for working on one part of a monorepo. dir is relative to
+// the project root, or absolute; "" or "." removes the scope.
+//
+// Sessions started through the scoped workspace record the scope, and only
+// sessions within it are listed. Sources and generated documents must lie in
+// the subtree, the vector index is kept per scope, and the model is told which
+// subdirectory it works in. Projects that need their own roles or settings per
+// subdirectory can instead give the subdirectory its own `.AIWorkspace`, which
+// `FindProjectDir` picks up when nani runs inside it.
+//
+// The returned workspace shares its files with w but keeps its own in-memory
+// context, so callers should use it in place of w rather than alongside it.
+func (w *Workspace) WithScope(dir string) (*Workspace, error) {
+	projectDir, err := filepath.Abs(w.projectDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve project directory: %w", err)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(projectDir, dir)
+	}
+	rel, err := filepath.Rel(projectDir, filepath.Clean(dir))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("scope %s is outside the project %s", dir, projectDir)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to resolve scope: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("scope %s is not a directory", dir)
+	}
+
+	scoped := *w
+	scoped.scope = filepath.ToSlash(rel)
+	if scoped.scope == "." {
+		scoped.scope = ""
+	}
+	return &scoped, nil
+}
+
+// Scope returns the project subdirectory the workspace is confined to, with
+// forward slashes, or an empty string for the whole project.
+func (w *Workspace) Scope() string {
+	return w.scope
+}
+
+// ScopeDir returns the directory the workspace is confined to: the scope's
+// directory, or the project directory if the workspace is not scoped.
+func (w *Workspace) ScopeDir() string {
+	return filepath.Join(w.projectDir(), filepath.FromSlash(w.scope))
+}
+
+// InScope reports whether path, relative to the current directory or
+// absolute, lies within the workspace's scope.
+func (w *Workspace) InScope(path string) bool {
+	dir, err := filepath.Abs(w.ScopeDir())
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sessionInScope reports whether a session recorded with scope belongs to the
+// workspace's scope: it was started in the scope or in a subdirectory of it.
+// Every session belongs to an unscoped workspace.
+func (w *Workspace) sessionInScope(scope string) bool {
+	return w.scope == "" || scope == w.scope || strings.HasPrefix(scope, w.scope+"/")
+}
+
+// scopePrompt tells the model which part of the project it works on, or
+// returns an empty string if the workspace is not scoped.
+func (w *Workspace) scopePrompt() string {
+	if w.scope == "" {
+		return ""
+	}
+	return fmt.Sprintf("**Scope**: Work is confined to the `%s` directory of the project. Paths are relative to the project root.\n", w.scope)
+}
@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// maxCommandOutput is the number of bytes of a command's output kept for the
+// chat. Longer output keeps its end, where failures are usually reported.
+const maxCommandOutput = 32 * 1024
+
+// shellMetacharacters are the characters that let a command line run more
+// than its first program. Command lines containing them always need confirmation.
+const shellMetacharacters = ";&|`$()<>\n"
+
+// CommandOutput is the captured result of a shell command run from the chat
+// with `/run`, attached to the next prompt.
+type CommandOutput struct {
+	Command   string // The command line as given.
+	Output    string // Combined standard output and standard error.
+	ExitCode  int    // Exit status of the command.
+	Truncated bool   // Whether the start of the output was dropped to fit maxCommandOutput.
+}
+
+// Markdown formats the output as a fenced block for the chat.
+func (o CommandOutput) Markdown() string {
+	note := ""
+	if o.Truncated {
+		note = fmt.Sprintf(", last %d bytes", maxCommandOutput)
+	}
+	return fmt.Sprintf("Output of `%s` (exit status %d%s):\n\n```\n%s\n```", o.Command, o.ExitCode, note, strings.TrimRight(o.Output, "\n"))
+}
+
+// CommandProgram returns the program a command line runs.
+func CommandProgram(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// CommandAllowed reports whether command may run without confirmation: its
+// program is in `Settings.RunAllowlist` and it runs nothing else.
+func (w *Workspace) CommandAllowed(command string) bool {
+	if strings.ContainsAny(command, shellMetacharacters) {
+		return false
+	}
+	return slices.Contains(w.Context.Settings.RunAllowlist, CommandProgram(command))
+}
+
+// AllowCommand adds program to `Settings.RunAllowlist` and saves the context.
+func (w *Workspace) AllowCommand(program string) error {
+	if program == "" {
+		return errors.New("no program to allow")
+	}
+	if slices.Contains(w.Context.Settings.RunAllowlist, program) {
+		return nil
+	}
+	w.Context.Settings.RunAllowlist = append(w.Context.Settings.RunAllowlist, program)
+	if err := w.saveContext(w.Context); err != nil {
+		return fmt.Errorf("failed to save run allowlist: %w", err)
+	}
+	return w.logAction(fmt.Sprintf("Allowed /run to execute %s", program))
+}
+
+// RunShellCommand runs command with `sh -c` in the project directory and
+// captures its output. A command exiting with a non-zero status is not an
+// error; its status is recorded in the result.
+func (w *Workspace) RunShellCommand(ctx context.Context, command string) (CommandOutput, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = w.projectDir()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+
+	result := CommandOutput{Command: command, Output: out.String()}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return result, fmt.Errorf("command %s did not finish: %w", command, ctx.Err())
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return result, fmt.Errorf("failed to run %s: %w", command, err)
+	}
+	if len(result.Output) > maxCommandOutput {
+		tail := result.Output[len(result.Output)-maxCommandOutput:]
+		if i := strings.IndexByte(tail, '\n'); i >= 0 {
+			tail = tail[i+1:] // Start at a whole line.
+		}
+		result.Output = tail
+		result.Truncated = true
+	}
+	if err := w.logAction(fmt.Sprintf("Ran %s (exit status %d)", command, result.ExitCode)); err != nil {
+		return result, err
+	}
+	return result, nil
+}
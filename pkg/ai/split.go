@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// SplitSession carves the turns `Chat[start:end]` of the active session out into
+// a new archived session with the given label, keeping the role and sources of
+// the original. Unless keep is true, the turns are also removed from the active
+// session; a compaction summary that covered any removed turn is discarded so
+// it can be regenerated without them. It returns the new session's summary.
+func (w *Workspace) SplitSession(start, end int, label string, keep bool) (SessionSummary, error) {
+	session, err := w.loadSession()
+	if err != nil {
+		return SessionSummary{}, fmt.Errorf("failed to load session to split: %w", err)
+	}
+	if start < 0 || end > len(session.Chat) || start >= end {
+		return SessionSummary{}, fmt.Errorf("invalid turn range %d-%d for session %s with %d turns", start+1, end, session.ID, len(session.Chat))
+	}
+
+	now := w.now()
+	turns := append([]Chat(nil), session.Chat[start:end]...)
+	split := &Session{
+		ID:      uuid.New().String(),
+		Label:   label,
+		Role:    session.Role,
+		Sources: append([]string{}, session.Sources...),
+		Scope:   session.Scope,
+		Chat:    turns,
+		Metadata: Metadata{
+			CreatedAt:       turns[0].Message.Timestamp,
+			Priority:        session.Metadata.Priority,
+			SessionDuration: session.Metadata.SessionDuration,
+			LastUpdated:     now,
+			ArchiveAfter:    session.Metadata.ArchiveAfter,
+			Pinned:          session.Metadata.Pinned,
+		},
+	}
+
+	if err := w.writeArchive(split); err != nil {
+		return SessionSummary{}, err
+	}
+	summary := w.Context.Indexes.ArchivedSessions[split.ID]
+	if err := w.saveContext(w.Context); err != nil {
+		return SessionSummary{}, fmt.Errorf("failed to update context after splitting session: %w", err)
+	}
+	w.emit(w.archivedEvent(split))
+
+	if !keep {
+		session.Chat = append(session.Chat[:start], session.Chat[end:]...)
+		if session.Compaction != nil && start < session.Compaction.Turns {
+			session.Compaction = nil
+		}
+		session.Metadata.LastUpdated = now
+		if err := w.saveSession(*session); err != nil {
+			return SessionSummary{}, fmt.Errorf("failed to save session after splitting: %w", err)
+		}
+	}
+
+	return summary, w.logAction(fmt.Sprintf("Split turns %d-%d of session %s into archived session %s", start+1, end, session.ID, split.ID))
+}
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SessionState holds volatile UI state for the active session, such as the
+// scroll positions. It is flushed periodically to the `session.state.json`
+// sidecar so that a crash loses at most a few seconds of it. The unsent draft
+// is kept in `drafts/` instead (see `SaveDraft`).
+type SessionState struct {
+	SessionID     string    `json:"sessionId"`     // ID of the session this state belongs to.
+	HistoryOffset int       `json:"historyOffset"` // Scroll offset of the history pane.
+	PreviewOffset int       `json:"previewOffset"` // Scroll offset of the preview pane.
+	SavedAt       time.Time `json:"savedAt"`       // Timestamp of the last flush.
+}
+
+// SaveSessionState writes the volatile state of the active session to the sidecar file.
+// The sidecar is written directly rather than through `writeJSON` because it changes
+// frequently and is not a durable artifact, so it is excluded from the integrity manifest.
+func (w *Workspace) SaveSessionState(state SessionState) error {
+	session, err := w.loadSession()
+	if err != nil {
+		return fmt.Errorf("failed to load session to save state: %w", err)
+	}
+	state.SessionID = session.ID
+	state.SavedAt = w.now()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session state: %w", err)
+	}
+	if err := w.storage.WriteFile(filepath.Join(w.RootDir, "session.state.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write session state: %w", err)
+	}
+	return nil
+}
+
+// LoadSessionState returns the last flushed volatile state of the active session.
+// It returns `nil, nil` if there is no sidecar or if it belongs to a different session.
+func (w *Workspace) LoadSessionState() (*SessionState, error) {
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, "session.state.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read session state: %w", err)
+	}
+
+	var state SessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse session state: %w", err)
+	}
+
+	session, err := w.GetActiveSession()
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.ID != state.SessionID {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// clearSessionState removes the sidecar file, if any. It is called when the
+// active session is archived, since the state no longer applies.
+func (w *Workspace) clearSessionState() error {
+	err := w.storage.Remove(filepath.Join(w.RootDir, "session.state.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove session state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package ai
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage is the file system a workspace keeps its files in: `context.json`,
+// roles, preferences, sessions, logs, and every other artifact under
+// `Workspace.RootDir` and the global workspace. Names are the same paths the
+// workspace would use on disk. Errors for missing files must match
+// `fs.ErrNotExist`, so that `os.IsNotExist` recognizes them.
+//
+// Features that work on the project itself rather than the workspace, such as
+// doc generation, git hooks, `Sync`, and `Watch`, always use the operating
+// system's file system.
+type Storage interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	AppendFile(name string, data []byte, perm fs.FileMode) error // Appends data, creating the file if needed.
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error) // Entries sorted by name.
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+}
+
+// OSStorage is the `Storage` of the operating system's file system, used by
+// workspaces unless another is given.
+type OSStorage struct{}
+
+func (OSStorage) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (OSStorage) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (OSStorage) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (OSStorage) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (OSStorage) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
+func (OSStorage) Remove(name string) error                     { return os.Remove(name) }
+func (OSStorage) RemoveAll(name string) error                  { return os.RemoveAll(name) }
+func (OSStorage) Rename(oldname, newname string) error         { return os.Rename(oldname, newname) }
+
+func (OSStorage) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// MemoryStorage is a `Storage` held entirely in memory, for embedding
+// workspaces in other programs and for tests that must not touch the disk.
+// Its contents are lost when it is garbage collected. The zero value is
+// empty and ready to use; it is safe for concurrent use.
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string]*memoryFile // Keyed by cleaned, slash-separated path; directories have nil data.
+}
+
+// NewMemoryStorage returns an empty in-memory storage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{}
+}
+
+// memoryFile is a file or directory of a `MemoryStorage`.
+type memoryFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// memoryPath returns the key of name in a `MemoryStorage`.
+func memoryPath(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+// lookup returns the entry at name, or nil. The caller must hold s.mu.
+func (s *MemoryStorage) lookup(name string) *memoryFile {
+	if s.files == nil {
+		s.files = make(map[string]*memoryFile)
+	}
+	return s.files[memoryPath(name)]
+}
+
+// isDir reports whether name is a directory. The caller must hold s.mu.
+func (s *MemoryStorage) isDir(name string) bool {
+	f := s.lookup(name)
+	return isMemoryRoot(name) || (f != nil && f.mode.IsDir())
+}
+
+func (s *MemoryStorage) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.lookup(name)
+	if f == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return bytes.Clone(f.data), nil
+}
+
+func (s *MemoryStorage) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(name, bytes.Clone(data), perm)
+}
+
+func (s *MemoryStorage) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var content []byte
+	if f := s.lookup(name); f != nil && !f.mode.IsDir() {
+		content = f.data
+		perm = f.mode
+	}
+	return s.write(name, append(bytes.Clone(content), data...), perm)
+}
+
+// write stores data at name, whose parent directory must exist. The caller
+// must hold s.mu.
+func (s *MemoryStorage) write(name string, data []byte, perm fs.FileMode) error {
+	if f := s.lookup(name); f != nil && f.mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+	if !s.isDir(path.Dir(memoryPath(name))) {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	s.files[memoryPath(name)] = &memoryFile{data: data, mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) Stat(name string) (fs.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isMemoryRoot(name) {
+		return memoryInfo{name: memoryPath(name), file: &memoryFile{mode: fs.ModeDir | 0755}}, nil
+	}
+	f := s.lookup(name)
+	if f == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memoryInfo{name: path.Base(memoryPath(name)), file: f}, nil
+}
+
+func (s *MemoryStorage) ReadDir(name string) ([]fs.DirEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := memoryPath(name)
+	if !s.isDir(dir) {
+		if s.lookup(dir) == nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	var entries []fs.DirEntry
+	for p, f := range s.files {
+		if p != dir && path.Dir(p) == dir {
+			entries = append(entries, fs.FileInfoToDirEntry(memoryInfo{name: path.Base(p), file: f}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (s *MemoryStorage) MkdirAll(name string, perm fs.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p := memoryPath(name); !isMemoryRoot(p); p = path.Dir(p) {
+		if f := s.lookup(p); f != nil {
+			if !f.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: p, Err: errors.New("not a directory")}
+			}
+			continue
+		}
+		s.files[p] = &memoryFile{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := memoryPath(name)
+	f := s.lookup(p)
+	if f == nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		for other := range s.files {
+			if strings.HasPrefix(other, p+"/") {
+				return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+			}
+		}
+	}
+	delete(s.files, p)
+	return nil
+}
+
+func (s *MemoryStorage) RemoveAll(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := memoryPath(name)
+	for other := range s.files {
+		if other == p || strings.HasPrefix(other, p+"/") {
+			delete(s.files, other)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) Rename(oldname, newname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, to := memoryPath(oldname), memoryPath(newname)
+	f := s.lookup(from)
+	if f == nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if !s.isDir(path.Dir(to)) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	moved := map[string]*memoryFile{to: f}
+	for other, g := range s.files {
+		if strings.HasPrefix(other, from+"/") {
+			moved[to+strings.TrimPrefix(other, from)] = g
+		}
+	}
+	for other := range s.files {
+		if other == from || strings.HasPrefix(other, from+"/") || other == to || strings.HasPrefix(other, to+"/") {
+			delete(s.files, other)
+		}
+	}
+	for p, g := range moved {
+		s.files[p] = g
+	}
+	return nil
+}
+
+// isMemoryRoot reports whether name is a root of a `MemoryStorage`, such as
+// "/" or ".", which always exists.
+func isMemoryRoot(name string) bool {
+	p := memoryPath(name)
+	return path.Dir(p) == p
+}
+
+// memoryInfo is the `fs.FileInfo` of a `MemoryStorage` entry.
+type memoryInfo struct {
+	name string
+	file *memoryFile
+}
+
+func (i memoryInfo) Name() string       { return i.name }
+func (i memoryInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i memoryInfo) Mode() fs.FileMode  { return i.file.mode }
+func (i memoryInfo) ModTime() time.Time { return i.file.modTime }
+func (i memoryInfo) IsDir() bool        { return i.file.mode.IsDir() }
+func (i memoryInfo) Sys() any           { return nil }
+
+// walkStorage calls fn for root and every file and directory below it in s,
+// in lexical order, like `filepath.WalkDir`. fn may return `fs.SkipDir` to
+// skip a directory, or `fs.SkipAll` to stop.
+func walkStorage(s Storage, root string, fn fs.WalkDirFunc) error {
+	info, err := s.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkStorageDir(s, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkStorageDir walks name, whose entry is d, for `walkStorage`.
+func walkStorageDir(s Storage, name string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, fs.SkipDir) && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := s.ReadDir(name)
+	if err != nil {
+		if err = fn(name, d, err); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := walkStorageDir(s, filepath.Join(name, entry.Name()), entry, fn); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
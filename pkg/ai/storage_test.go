@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	s := NewMemoryStorage()
+	if err := s.WriteFile("/a/b.txt", []byte("x"), 0644); !os.IsNotExist(err) {
+		t.Fatalf("WriteFile without parent = %v, want not exist", err)
+	}
+	if err := s.MkdirAll("/a/c", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteFile("/a/b.txt", []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendFile("/a/b.txt", []byte("y"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.ReadFile("/a/b.txt"); err != nil || string(data) != "xy" {
+		t.Fatalf("ReadFile = %q, %v, want \"xy\"", data, err)
+	}
+
+	entries, err := s.ReadDir("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || names[0] != "b.txt" || names[1] != "c" || !entries[1].IsDir() {
+		t.Fatalf("ReadDir = %v, want [b.txt c/]", names)
+	}
+
+	if err := s.Remove("/a"); err == nil {
+		t.Fatal("Remove of a non-empty directory succeeded")
+	}
+	if err := s.Rename("/a", "/d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Stat("/a/b.txt"); !os.IsNotExist(err) {
+		t.Fatalf("Stat after rename = %v, want not exist", err)
+	}
+	if info, err := s.Stat("/d/b.txt"); err != nil || info.Size() != 2 {
+		t.Fatalf("Stat of renamed file = %v, %v", info, err)
+	}
+
+	var walked []string
+	if err := walkStorage(s, "/d", func(p string, d os.DirEntry, err error) error {
+		walked = append(walked, filepath.ToSlash(p))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(walked) != 3 || walked[0] != "/d" || walked[1] != "/d/b.txt" || walked[2] != "/d/c" {
+		t.Fatalf("walkStorage = %v", walked)
+	}
+
+	if err := s.RemoveAll("/d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReadFile("/d/b.txt"); !os.IsNotExist(err) {
+		t.Fatalf("ReadFile after RemoveAll = %v, want not exist", err)
+	}
+}
+
+func TestWorkspaceOnMemoryStorage(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	w, err := NewWorkspace(dir, WithStorage(NewMemoryStorage()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetGlobalDir("")
+	if err := w.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SavePreference(Preference{ID: "tabs", Content: "Indent with tabs."}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.StartSession(ctx, "memory", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.EndSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if prefs, err := w.ListPreferences(); err != nil || len(prefs) != 1 {
+		t.Fatalf("ListPreferences = %v, %v, want one preference", prefs, err)
+	}
+	if roles, err := w.ListRoles(); err != nil || len(roles) == 0 {
+		t.Fatalf("ListRoles = %v, %v, want the default roles", roles, err)
+	}
+	if sessions, err := w.ListArchivedSessions(); err != nil || len(sessions) != 1 {
+		t.Fatalf("ListArchivedSessions = %v, %v, want one session", sessions, err)
+	}
+	if err := w.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if prefs, _ := w.ListPreferences(); len(prefs) != 1 {
+		t.Fatalf("ListPreferences after Reload = %v, want one preference", prefs)
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("project directory holds %v, %v, want nothing on disk", entries, err)
+	}
+}
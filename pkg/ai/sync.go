@@ -0,0 +1,350 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultSyncBranch is the branch `Sync` uses when `SyncConfig.Branch` is empty.
+const defaultSyncBranch = "main"
+
+// syncedDirs lists the artifact directories `Sync` shares. Every artifact is a
+// file of its own, so teammates editing different roles or templates never
+// conflict. Sessions, logs, caches, and machine-local state stay on each
+// machine.
+var syncedDirs = []string{"roles", "preferences", "templates", "flows", "facts", "schedules"}
+
+// syncedFiles lists the files `Sync` shares besides those in syncedDirs.
+// `context.json` holds neither indexes nor machine-local settings, which are
+// kept in `local.json`, and git merges it with `MergeContext`.
+var syncedFiles = []string{"context.json"}
+
+// contextMergeDriver is the name of the git merge driver `Sync` configures
+// for `context.json`.
+const contextMergeDriver = "nani-context"
+
+// SyncConfig is the git remote that `Sync` shares the workspace with, set
+// with `Settings.Sync`.
+type SyncConfig struct {
+	Remote string `json:"remote"`           // URL of the git repository shared by the team.
+	Branch string `json:"branch,omitempty"` // Branch to sync; empty uses "main".
+}
+
+// SyncReport describes what `Sync` did.
+type SyncReport struct {
+	Committed bool     // Whether local changes were committed.
+	Pulled    []string // Artifacts changed by teammates and pulled from the remote, relative to the workspace root.
+}
+
+// SetSyncConfig sets `Settings.Sync` and saves the context.
+func (w *Workspace) SetSyncConfig(config SyncConfig) error {
+	w.Context.Settings.Sync = &config
+	if err := w.saveContext(w.Context); err != nil {
+		return fmt.Errorf("failed to save sync settings: %w", err)
+	}
+	return w.logAction(fmt.Sprintf("Set sync remote to %s", config.Remote))
+}
+
+// Sync shares the workspace's settings, project metadata, roles, preferences,
+// templates, facts, and schedules with a team through the git remote in
+// `Settings.Sync`. The workspace directory is made the work tree of a git
+// repository kept outside the project (see `syncGitDir`) on first use, with
+// generated exclude rules that leave everything else out. Local changes are committed, rebased onto the remote branch, and
+// pushed; artifacts pulled from the remote are indexed and accepted into the
+// integrity manifest. `context.json` is merged field by field with
+// `MergeContext`, so teammates changing different settings do not conflict.
+// Machine-local settings, such as the sync remote itself and
+// `Settings.RunAllowlist`, are kept in `local.json` and never shared.
+// Sync refuses to commit artifacts containing secrets (see `ScanSecrets`),
+// and leaves the repository as it was if the rebase conflicts. git is killed
+// if ctx is done, as when the user interrupts a slow fetch.
+func (w *Workspace) Sync(ctx context.Context) (SyncReport, error) {
+	var report SyncReport
+	config := w.Context.Settings.Sync
+	if config == nil || config.Remote == "" {
+		return report, errors.New("no sync remote configured in settings")
+	}
+	branch := config.Branch
+	if branch == "" {
+		branch = defaultSyncBranch
+	}
+	if err := w.Flush(); err != nil {
+		return report, err
+	}
+
+	gitDir, err := w.syncGitDir()
+	if err != nil {
+		return report, err
+	}
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		if err := w.initSyncRepo(ctx, gitDir, branch); err != nil {
+			return report, err
+		}
+	} else if err != nil {
+		return report, fmt.Errorf("failed to check workspace repository: %w", err)
+	}
+	if url, err := w.git(ctx, "remote", "get-url", "origin"); err != nil {
+		if _, err := w.git(ctx, "remote", "add", "origin", config.Remote); err != nil {
+			return report, err
+		}
+	} else if url != config.Remote {
+		if _, err := w.git(ctx, "remote", "set-url", "origin", config.Remote); err != nil {
+			return report, err
+		}
+	}
+	if err := w.writeSyncIgnore(); err != nil {
+		return report, err
+	}
+	if err := w.configureContextMerge(ctx); err != nil {
+		return report, err
+	}
+	if err := w.checkSyncSecrets(); err != nil {
+		return report, err
+	}
+
+	if _, err := w.git(ctx, "add", "-A"); err != nil {
+		return report, err
+	}
+	if _, err := w.git(ctx, "diff", "--cached", "--quiet"); err != nil {
+		if _, err := w.git(ctx, "commit", "-m", "Sync nani workspace"); err != nil {
+			return report, err
+		}
+		report.Committed = true
+	}
+
+	if _, err := w.git(ctx, "fetch", "origin"); err != nil {
+		return report, err
+	}
+	if _, err := w.git(ctx, "rev-parse", "--verify", "--quiet", "origin/"+branch); err == nil {
+		before, err := w.git(ctx, "rev-parse", "--verify", "--quiet", "HEAD")
+		if err != nil {
+			// Nothing committed locally yet: start from the remote branch.
+			if _, err := w.git(ctx, "reset", "--hard", "origin/"+branch); err != nil {
+				return report, err
+			}
+		} else if _, err := w.git(ctx, "rebase", "origin/"+branch); err != nil {
+			w.git(ctx, "rebase", "--abort")
+			return report, fmt.Errorf("local changes conflict with the remote; resolve them with GIT_DIR=%s GIT_WORK_TREE=%s and sync again: %w", gitDir, w.RootDir, err)
+		}
+		diff := []string{"ls-tree", "-r", "--name-only", "HEAD"}
+		if before != "" {
+			diff = []string{"diff", "--name-only", before, "HEAD"}
+		}
+		changed, err := w.git(ctx, diff...)
+		if err != nil {
+			return report, err
+		}
+		if report.Pulled, err = w.acceptPulled(ctx, changed); err != nil {
+			return report, err
+		}
+	}
+
+	if _, err := w.git(ctx, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		if _, err := w.git(ctx, "push", "-u", "origin", branch); err != nil {
+			return report, err
+		}
+	}
+	return report, w.logAction(fmt.Sprintf("Synced workspace with %s (%d artifacts pulled)", config.Remote, len(report.Pulled)))
+}
+
+// syncGitDir returns the git directory of the workspace's sync repository, in
+// the user cache directory and named after the workspace path. The project's
+// own repository often tracks the workspace, and a repository nested inside
+// it would turn the workspace into an embedded repository there.
+func (w *Workspace) syncGitDir() (string, error) {
+	root, err := filepath.Abs(w.RootDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace directory: %w", err)
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(cache, "nani", "sync", hex.EncodeToString(sum[:8])+".git"), nil
+}
+
+// initSyncRepo creates the sync repository at gitDir with branch checked out.
+// A repository that older versions of `Sync` created inside the workspace is
+// moved there instead.
+func (w *Workspace) initSyncRepo(ctx context.Context, gitDir, branch string) error {
+	if err := os.MkdirAll(filepath.Dir(gitDir), 0755); err != nil {
+		return fmt.Errorf("failed to create sync repository directory: %w", err)
+	}
+	legacy := filepath.Join(w.RootDir, ".git")
+	if _, err := os.Stat(legacy); err == nil {
+		if err := os.Rename(legacy, gitDir); err != nil {
+			return fmt.Errorf("failed to move workspace repository %s to %s: %w", legacy, gitDir, err)
+		}
+		return nil
+	}
+	if _, err := w.git(ctx, "init"); err != nil {
+		return err
+	}
+	_, err := w.git(ctx, "symbolic-ref", "HEAD", "refs/heads/"+branch)
+	return err
+}
+
+// git runs git with args on the sync repository, with the workspace directory
+// as its work tree, and returns its trimmed output. The process is killed if
+// ctx is done first.
+func (w *Workspace) git(ctx context.Context, args ...string) (string, error) {
+	gitDir, err := w.syncGitDir()
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.Abs(w.RootDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace directory: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "GIT_DIR="+gitDir, "GIT_WORK_TREE="+root)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// writeSyncIgnore writes the exclude rules that limit the sync repository to
+// the synced artifacts. They are kept in the repository's `info/exclude`, as
+// a `.gitignore` in the workspace would also apply to the project's repository.
+func (w *Workspace) writeSyncIgnore() error {
+	var b strings.Builder
+	b.WriteString("# Generated by nani sync; changes are overwritten.\n")
+	b.WriteString("# Only shared artifacts are synced. Sessions, logs, caches, and\n")
+	b.WriteString("# machine-local state stay on each machine.\n")
+	b.WriteString("/*\n")
+	for _, file := range syncedFiles {
+		fmt.Fprintf(&b, "!/%s\n", file)
+	}
+	for _, dir := range syncedDirs {
+		fmt.Fprintf(&b, "!/%s/\n", dir)
+	}
+	return w.writeSyncInfo("exclude", b.String())
+}
+
+// configureContextMerge makes git merge `context.json` by running this
+// executable's `ContextMergeCommand`, through the sync repository's
+// `info/attributes` and configuration.
+func (w *Workspace) configureContextMerge(ctx context.Context) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate nani executable: %w", err)
+	}
+	quoted := "'" + strings.ReplaceAll(exe, "'", `'\''`) + "'"
+	if _, err := w.git(ctx, "config", "merge."+contextMergeDriver+".name", "nani context.json merge"); err != nil {
+		return err
+	}
+	if _, err := w.git(ctx, "config", "merge."+contextMergeDriver+".driver", quoted+" "+ContextMergeCommand+" %O %A %B"); err != nil {
+		return err
+	}
+	attributes := "# Generated by nani sync; changes are overwritten.\n/context.json merge=" + contextMergeDriver + "\n"
+	return w.writeSyncInfo("attributes", attributes)
+}
+
+// writeSyncInfo writes content to the file name under the sync repository's
+// `info` directory.
+func (w *Workspace) writeSyncInfo(name, content string) error {
+	gitDir, err := w.syncGitDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(gitDir, "info"), 0755); err != nil {
+		return fmt.Errorf("failed to create sync repository info directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "info", name), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write sync repository %s: %w", name, err)
+	}
+	return nil
+}
+
+// checkSyncSecrets returns an error listing the secrets found in the synced
+// artifacts, if any.
+func (w *Workspace) checkSyncSecrets() error {
+	var findings []string
+	for _, file := range syncedFiles {
+		data, err := os.ReadFile(filepath.Join(w.RootDir, file))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		for _, f := range ScanSecrets(file, string(data)) {
+			findings = append(findings, f.String())
+		}
+	}
+	for _, dir := range syncedDirs {
+		entries, err := os.ReadDir(filepath.Join(w.RootDir, dir))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s directory: %w", dir, err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			rel := dir + "/" + e.Name()
+			data, err := os.ReadFile(filepath.Join(w.RootDir, dir, e.Name()))
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", rel, err)
+			}
+			for _, f := range ScanSecrets(rel, string(data)) {
+				findings = append(findings, f.String())
+			}
+		}
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("%w; remove them before syncing: %s", ErrSecretsDetected, strings.Join(findings, "; "))
+	}
+	return nil
+}
+
+// acceptPulled records the synced artifacts among the paths listed in
+// changed, one per line, in the integrity manifest, reloads the context if it
+// changed, and rebuilds the indexes, so that teammates' changes are not
+// reported as edits made outside nani. It returns the artifacts.
+func (w *Workspace) acceptPulled(ctx context.Context, changed string) ([]string, error) {
+	var paths []string
+	for _, rel := range strings.Split(changed, "\n") {
+		if dir, _, ok := strings.Cut(rel, "/"); (ok && slices.Contains(syncedDirs, dir)) || slices.Contains(syncedFiles, rel) {
+			paths = append(paths, rel)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	for _, rel := range paths {
+		path := filepath.Join(w.RootDir, filepath.FromSlash(rel))
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			err = w.recordRemove(path)
+		} else if err == nil {
+			err = w.recordWrite(path, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to record pulled %s: %w", rel, err)
+		}
+	}
+	if slices.Contains(paths, "context.json") {
+		if err := w.loadContext(); err != nil {
+			return nil, fmt.Errorf("failed to reload pulled context: %w", err)
+		}
+	}
+	if err := w.rebuildIndexes(ctx); err != nil {
+		return nil, fmt.Errorf("failed to index pulled artifacts: %w", err)
+	}
+	return paths, nil
+}
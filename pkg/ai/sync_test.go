@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncExcludeRules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	w := newTestWorkspace(t)
+	gitDir, err := w.syncGitDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.initSyncRepo(t.Context(), gitDir, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.writeSyncIgnore(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		synced bool
+	}{
+		{"context.json", true},
+		{"roles/coder.json", true},
+		{"preferences/style.json", true},
+		{"templates/review.json", true},
+		{"flows/intake.yaml", true},
+		{"facts/f1.json", true},
+		{"schedules/weekly.json", true},
+		{"local.json", false},
+		{"session.json", false},
+		{"ui.json", false},
+		{"manifest.json", false},
+		{"sessions/s1.json", false},
+		{"logs/2026-01-01.log", false},
+		{"audit/2026-01-01.jsonl", false},
+		{"vectors/index.json", false},
+		{"backups/roles/coder.json", false},
+		{"drafts/s1.md", false},
+		{"nested/roles/x.json", false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(w.RootDir, filepath.FromSlash(tt.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := w.git(t.Context(), "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		listed[line] = true
+	}
+	for _, tt := range tests {
+		if listed[tt.path] != tt.synced {
+			t.Errorf("%s synced = %v, want %v", tt.path, listed[tt.path], tt.synced)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Template is a reusable prompt stored in `templates/<name>.json`. Its content
+// is a Go text/template rendered with `TemplateData`, so a recurring prompt
+// such as "review {{.File}}" can be sent without retyping it.
+type Template struct {
+	Name        string `json:"name"`                  // Unique name, also the file name.
+	Description string `json:"description,omitempty"` // One-line summary shown in listings.
+	Content     string `json:"content"`               // Prompt text with Go-template placeholders.
+}
+
+// TemplateData holds the values available to a template's placeholders.
+type TemplateData struct {
+	File      string // Path of the file the prompt is about ({{.File}}).
+	Selection string // Selected text, such as a range of lines of File ({{.Selection}}).
+	Diff      string // Uncommitted changes of the project as a unified diff ({{.Diff}}).
+	Input     string // Free text given when the template is used ({{.Input}}).
+}
+
+// SaveTemplate validates that the template's content parses and writes it to
+// `templates/<name>.json`, replacing any template with the same name.
+func (w *Workspace) SaveTemplate(t Template) error {
+	if err := validateName("template", t.Name); err != nil {
+		return err
+	}
+	if _, err := parseTemplate(t); err != nil {
+		return err
+	}
+	path := filepath.Join(w.RootDir, "templates", fmt.Sprintf("%s.json", t.Name))
+	if err := w.writeJSON(path, t); err != nil {
+		return fmt.Errorf("failed to save template %s: %w", t.Name, err)
+	}
+	return w.logAction(fmt.Sprintf("Saved template %s", t.Name))
+}
+
+// LoadTemplate loads a template by name from `templates/<name>.json`, falling
+// back to the global workspace if the project does not define it.
+func (w *Workspace) LoadTemplate(name string) (*Template, error) {
+	if err := validateName("template", name); err != nil {
+		return nil, err
+	}
+	data, err := w.readArtifact("templates", name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+	}
+	var t Template
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	return &t, nil
+}
+
+// ListTemplates returns the project and global templates sorted by name. A
+// project template hides a global template with the same name.
+func (w *Workspace) ListTemplates() ([]Template, error) {
+	byName := make(map[string]Template)
+	w.globalArtifacts("templates", func(data []byte) error {
+		var t Template
+		if err := json.Unmarshal(data, &t); err != nil {
+			return err
+		}
+		byName[t.Name] = t
+		return nil
+	})
+
+	entries, err := w.storage.ReadDir(filepath.Join(w.RootDir, "templates"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		t, err := w.LoadTemplate(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not load template '%s': %v", entry.Name(), err))
+			continue
+		}
+		byName[t.Name] = *t
+	}
+
+	templates := make([]Template, 0, len(byName))
+	for _, t := range byName {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// DeleteTemplate deletes `templates/<name>.json` from the project workspace.
+func (w *Workspace) DeleteTemplate(name string) error {
+	if err := validateName("template", name); err != nil {
+		return err
+	}
+	path := filepath.Join(w.RootDir, "templates", fmt.Sprintf("%s.json", name))
+	if err := w.storage.Remove(path); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete template file %s: %w", name, err)
+	}
+	if err := w.recordRemove(path); err != nil {
+		return fmt.Errorf("failed to update manifest after deleting template: %w", err)
+	}
+	return w.logAction(fmt.Sprintf("Deleted template %s", name))
+}
+
+// RenderTemplate loads the named template and executes it with data. Unknown
+// placeholders are reported as errors rather than rendered as "<no value>".
+func (w *Workspace) RenderTemplate(name string, data TemplateData) (string, error) {
+	t, err := w.LoadTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := parseTemplate(*t)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// UsesPlaceholder reports whether the template's content refers to the named
+// field of `TemplateData` (e.g., "Diff"), so callers can skip gathering values
+// that would not be used.
+func (t Template) UsesPlaceholder(field string) bool {
+	return strings.Contains(t.Content, "."+field)
+}
+
+// parseTemplate parses the content of t as a Go text/template.
+func parseTemplate(t Template) (*template.Template, error) {
+	tmpl, err := template.New(t.Name).Option("missingkey=error").Parse(t.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", t.Name, err)
+	}
+	return tmpl, nil
+}
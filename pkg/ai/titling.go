@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const (
+	// defaultSessionLabel is the label of sessions the chat starts on its own.
+	// Sessions still carrying it are named by the model (see `titleLive`).
+	defaultSessionLabel = "Session"
+
+	// defaultAutoTitleTurns is the number of exchanges after which a session
+	// is named when `Settings.AutoTitleTurns` is not set.
+	defaultAutoTitleTurns = 2
+
+	// maxTitleLength caps the runes of a generated title.
+	maxTitleLength = 60
+)
+
+// titleLive asks the model for a short title for the active session once it
+// has `Settings.AutoTitleTurns` exchanges, and sets it as the session's label.
+// Only sessions still labeled `defaultSessionLabel` are named, so labels the
+// user chose are kept. Failures are logged rather than returned because the
+// interaction itself has already succeeded.
+func (g *GeminiAIClient) titleLive(ctx context.Context) {
+	turns := g.workspace.Context.Settings.AutoTitleTurns
+	if turns < 0 {
+		return
+	}
+	if turns == 0 {
+		turns = defaultAutoTitleTurns
+	}
+	session, err := g.workspace.GetActiveSession()
+	if err != nil || session == nil || session.Label != defaultSessionLabel || len(session.Chat) != turns {
+		return
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("Write a title of at most six words for the following conversation between a user and an AI assistant. ")
+	prompt.WriteString("Reply with the title only, without quotes or trailing punctuation.\n\n")
+	for _, c := range session.Chat {
+		prompt.WriteString(fmt.Sprintf("[user-message]: %s\n[agent-response]: %s\n", excerptText(c.Message.Content), excerptText(c.Response.Content)))
+	}
+
+	resp, _, err := g.generate(ctx, g.workspace.ModelsFor(TaskSummary, ""), genai.Text(prompt.String()), &genai.GenerateContentConfig{
+		MaxOutputTokens: 32,
+	})
+	if err != nil {
+		g.workspace.logAction(fmt.Sprintf("Warning: Failed to title session %s: %v", session.ID, err))
+		return
+	}
+	title := cleanTitle(resp.Text())
+	if title == "" {
+		return
+	}
+	if err := g.workspace.RenameSession(session.ID, title); err != nil {
+		g.workspace.logAction(fmt.Sprintf("Warning: Failed to title session %s: %v", session.ID, err))
+	}
+}
+
+// excerptText shortens text to its first 1000 characters, enough for the
+// model to tell what a turn is about.
+func excerptText(text string) string {
+	if len(text) <= 1000 {
+		return text
+	}
+	return strings.ToValidUTF8(text[:1000], "") + "…"
+}
+
+// cleanTitle reduces a model reply to a one-line title: the first non-empty
+// line, without surrounding quotes, Markdown emphasis, or a trailing period,
+// shortened to `maxTitleLength` runes.
+func cleanTitle(reply string) string {
+	var title string
+	for _, line := range strings.Split(reply, "\n") {
+		if title = strings.TrimSpace(line); title != "" {
+			break
+		}
+	}
+	title = strings.TrimPrefix(title, "Title:")
+	title = strings.Trim(strings.TrimSpace(title), "\"'`*#“”")
+	title = strings.TrimRight(strings.TrimSpace(title), ".")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+	}
+	return title
+}
@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+)
+
+// TrainingFormat selects the record layout written by `ExportTrainingData`.
+type TrainingFormat string
+
+// Supported fine-tuning record formats.
+const (
+	TrainingOpenAI TrainingFormat = "openai" // {"messages": [{"role": "system"|"user"|"assistant", "content": ...}]}
+	TrainingGemini TrainingFormat = "gemini" // {"systemInstruction": {...}, "contents": [{"role": "user"|"model", "parts": [...]}]}
+)
+
+// TrainingFormats lists the supported formats, for help and completion.
+var TrainingFormats = []TrainingFormat{TrainingOpenAI, TrainingGemini}
+
+// TrainingFilter selects the turns `ExportTrainingData` writes and how.
+type TrainingFilter struct {
+	Format     TrainingFormat // Record layout; empty uses TrainingOpenAI.
+	Roles      []string       // Only sessions using one of these roles; empty for all.
+	PinnedOnly bool           // Only pinned sessions.
+	Since      time.Time      // Only turns sent at or after this time; zero for all.
+	ScrubPII   bool           // Replace email addresses, phone numbers, and IP addresses with placeholders.
+}
+
+// piiPatterns maps personal data found in text to the placeholders that
+// replace it when scrubbing.
+var piiPatterns = []struct {
+	re          *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]\d{3}[\s.-]\d{4}\b`), "[PHONE]"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), "[IP]"},
+}
+
+// scrubPII replaces the personal data matched by piiPatterns in text.
+func scrubPII(text string) string {
+	for _, p := range piiPatterns {
+		text = p.re.ReplaceAllString(text, p.placeholder)
+	}
+	return text
+}
+
+// openAIRecord is a conversation in the OpenAI chat fine-tuning format.
+type openAIRecord struct {
+	Messages []openAIMessage `json:"messages"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// geminiRecord is a conversation in the Gemini supervised tuning format.
+type geminiRecord struct {
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent `json:"contents"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// trainingRecord builds the record for a single exchange in the given format.
+func trainingRecord(format TrainingFormat, system, user, assistant string) any {
+	if format == TrainingGemini {
+		record := geminiRecord{Contents: []geminiContent{
+			{Role: "user", Parts: []geminiPart{{Text: user}}},
+			{Role: "model", Parts: []geminiPart{{Text: assistant}}},
+		}}
+		if system != "" {
+			record.SystemInstruction = &geminiContent{Role: "system", Parts: []geminiPart{{Text: system}}}
+		}
+		return record
+	}
+	var record openAIRecord
+	if system != "" {
+		record.Messages = append(record.Messages, openAIMessage{Role: "system", Content: system})
+	}
+	record.Messages = append(record.Messages,
+		openAIMessage{Role: "user", Content: user},
+		openAIMessage{Role: "assistant", Content: assistant})
+	return record
+}
+
+// ExportTrainingData writes the turns of the active and archived sessions in
+// scope that match filter to path as JSON Lines, one system/user/assistant
+// record per turn, for fine-tuning a model. The system message is the
+// instruction the session's role is given in the chat. Turns without a text
+// prompt or response are skipped. It returns the number of records written,
+// stopping early with ctx's error when ctx is done.
+func (w *Workspace) ExportTrainingData(ctx context.Context, path string, filter TrainingFilter) (int, error) {
+	format := filter.Format
+	if format == "" {
+		format = TrainingOpenAI
+	}
+	if !slices.Contains(TrainingFormats, format) {
+		return 0, fmt.Errorf("unknown training data format '%s'; expected one of %v", format, TrainingFormats)
+	}
+
+	sessions, err := w.trainingSessions(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+	out := bufio.NewWriter(file)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+
+	instructions := make(map[string]string)
+	count := 0
+	for _, session := range sessions {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		system, ok := instructions[session.Role.Name]
+		if !ok {
+			role, err := w.loadRole(session.Role.Name)
+			if err != nil {
+				w.logAction(fmt.Sprintf("Warning: Could not load role '%s' for training export: %v", session.Role.Name, err))
+			} else if system, err = w.roleInstructions(role); err != nil {
+				return count, err
+			}
+			instructions[session.Role.Name] = system
+		}
+
+		for _, c := range session.Chat {
+			if c.Message.Content == "" || c.Response.Content == "" {
+				continue
+			}
+			if !filter.Since.IsZero() && c.Message.Timestamp.Before(filter.Since) {
+				continue
+			}
+			system, user, assistant := system, c.Message.Content, c.Response.Content
+			if filter.ScrubPII {
+				system, user, assistant = scrubPII(system), scrubPII(user), scrubPII(assistant)
+			}
+			if err := enc.Encode(trainingRecord(format, system, user, assistant)); err != nil {
+				return count, fmt.Errorf("failed to write training record: %w", err)
+			}
+			count++
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		return count, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return count, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return count, w.logAction(fmt.Sprintf("Exported %d training records in %s format to %s", count, format, path))
+}
+
+// trainingSessions loads the sessions in scope that match filter, oldest
+// first. Roles are left as names.
+func (w *Workspace) trainingSessions(filter TrainingFilter) ([]*Session, error) {
+	matches := func(role string, pinned bool) bool {
+		return (len(filter.Roles) == 0 || slices.Contains(filter.Roles, role)) && (pinned || !filter.PinnedOnly)
+	}
+
+	var sessions []*Session
+	for id, summary := range w.Context.Indexes.ArchivedSessions {
+		if !w.sessionInScope(summary.Scope) || !matches(summary.RoleName, summary.Pinned) {
+			continue
+		}
+		session, err := w.readArchive(w.archivedSessionPath(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archived session %s: %w", id, err)
+		}
+		sessions = append(sessions, session)
+	}
+	active, err := w.GetActiveSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load active session: %w", err)
+	}
+	if active != nil && w.sessionInScope(active.Scope) && matches(active.Role.Name, active.Metadata.Pinned) {
+		sessions = append(sessions, active)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Metadata.CreatedAt.Before(sessions[j].Metadata.CreatedAt)
+	})
+	return sessions, nil
+}
@@ -0,0 +1,193 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Kinds of artifacts moved to the trash.
+const (
+	TrashRole       = "role"
+	TrashPreference = "preference"
+	TrashSession    = "session"
+)
+
+// trashEntryFile is the name of the metadata file kept beside each trashed
+// artifact in its `trash/<id>/` directory.
+const trashEntryFile = "entry.json"
+
+// TrashEntry describes an artifact deleted with `DeleteRole`,
+// `DeletePreference`, or `DeleteSession`. Deleted artifacts are moved to
+// `trash/<id>/` unchanged, where they stay until `Restore` puts them back or
+// `EmptyTrash` removes them for good.
+type TrashEntry struct {
+	ID        string    `json:"id"`        // Name of the entry's directory in `trash/`, starting with the deletion time.
+	Kind      string    `json:"kind"`      // One of TrashRole, TrashPreference, or TrashSession.
+	Name      string    `json:"name"`      // Role name, preference ID, or session ID of the artifact.
+	Path      string    `json:"path"`      // Original path of the artifact, relative to the workspace root.
+	DeletedAt time.Time `json:"deletedAt"` // Timestamp when the artifact was deleted.
+}
+
+// trashDir returns the directory of the trash entry with the given ID.
+func (w *Workspace) trashDir(id string) string {
+	return filepath.Join(w.RootDir, "trash", id)
+}
+
+// moveToTrash moves the artifact at path, of the given kind and name, to a
+// new trash entry and drops it from the integrity manifest. It does nothing
+// if there is no file at path. The name, which becomes part of the entry's
+// directory name, must be valid for `validateName`.
+func (w *Workspace) moveToTrash(kind, name, path string) error {
+	if err := validateName(kind, name); err != nil {
+		return err
+	}
+	if _, err := w.storage.Stat(path); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to check %s: %w", path, err)
+	}
+
+	now := w.now()
+	base := fmt.Sprintf("%s-%s-%s", now.Format("20060102-150405"), kind, name)
+	id := base
+	for version := 2; ; version++ {
+		if _, err := w.storage.Stat(w.trashDir(id)); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", base, version)
+	}
+	dir := w.trashDir(id)
+	if err := w.storage.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create trash entry: %w", err)
+	}
+	entry := TrashEntry{ID: id, Kind: kind, Name: name, Path: w.relPath(path), DeletedAt: now}
+	content, err := encodeJSON(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode trash entry: %w", err)
+	}
+	if err := w.storage.WriteFile(filepath.Join(dir, trashEntryFile), content, 0644); err != nil {
+		return fmt.Errorf("failed to write trash entry: %w", err)
+	}
+	if err := w.storage.Rename(path, filepath.Join(dir, filepath.Base(path))); err != nil {
+		w.storage.RemoveAll(dir)
+		return fmt.Errorf("failed to move %s to the trash: %w", entry.Path, err)
+	}
+	if err := w.recordRemove(path); err != nil {
+		return fmt.Errorf("failed to update manifest after trashing %s: %w", entry.Path, err)
+	}
+	return nil
+}
+
+// DeleteSession moves the archived session with the given ID to the trash
+// and removes it from the `ArchivedSessions` index. The active session must be
+// ended first.
+func (w *Workspace) DeleteSession(id string) error {
+	if _, ok := w.Context.Indexes.ArchivedSessions[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	if err := w.moveToTrash(TrashSession, id, w.archivedSessionPath(id)); err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", id, err)
+	}
+
+	delete(w.Context.Indexes.ArchivedSessions, id)
+	if err := w.saveContext(w.Context); err != nil {
+		return fmt.Errorf("failed to update context after deleting session: %w", err)
+	}
+	return w.logAction(fmt.Sprintf("Deleted session %s", id))
+}
+
+// ListTrash returns the entries in the trash, most recently deleted first.
+func (w *Workspace) ListTrash() ([]TrashEntry, error) {
+	dirs, err := w.storage.ReadDir(filepath.Join(w.RootDir, "trash"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+	var entries []TrashEntry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entry, err := w.trashEntry(d.Name())
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not read trash entry '%s': %v", d.Name(), err))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].DeletedAt.After(entries[j].DeletedAt) })
+	return entries, nil
+}
+
+// trashEntry reads the metadata of the trash entry with the given ID.
+func (w *Workspace) trashEntry(id string) (TrashEntry, error) {
+	data, err := w.storage.ReadFile(filepath.Join(w.trashDir(id), trashEntryFile))
+	if os.IsNotExist(err) {
+		return TrashEntry{}, fmt.Errorf("%w: %s", ErrTrashEntryNotFound, id)
+	} else if err != nil {
+		return TrashEntry{}, fmt.Errorf("failed to read trash entry %s: %w", id, err)
+	}
+	var entry TrashEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return TrashEntry{}, fmt.Errorf("failed to parse trash entry %s: %w", id, err)
+	}
+	return entry, nil
+}
+
+// Restore moves the trash entry with the given ID back to where it was
+// deleted from and adds it to its index again. It fails rather than
+// overwrite an artifact created in its place since.
+func (w *Workspace) Restore(id string) error {
+	entry, err := w.trashEntry(id)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(w.RootDir, filepath.FromSlash(entry.Path))
+	if _, err := w.storage.Stat(target); err == nil {
+		return fmt.Errorf("cannot restore %s: %s already exists", id, entry.Path)
+	}
+	// Restored artifacts are indexed like the unindexed files `Verify` reports.
+	index := map[string]string{TrashRole: IndexRoles, TrashPreference: IndexPreferences, TrashSession: IndexSessions}[entry.Kind]
+	if index == "" {
+		return fmt.Errorf("unknown trash entry kind '%s'", entry.Kind)
+	}
+	if entry.Kind == TrashSession {
+		if _, ok := w.Context.Indexes.ArchivedSessions[entry.Name]; ok {
+			return fmt.Errorf("cannot restore %s: session %s is archived again", id, entry.Name)
+		}
+	}
+	data, err := w.storage.ReadFile(filepath.Join(w.trashDir(id), filepath.Base(target)))
+	if err != nil {
+		return fmt.Errorf("failed to read trashed %s: %w", entry.Kind, err)
+	}
+	if err := w.storage.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+	}
+	if err := w.writeFile(target, data); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+	}
+
+	if err := w.repairIssue(Issue{Kind: DriftUnindexed, Index: index, Key: entry.Name, Path: entry.Path}); err != nil {
+		return fmt.Errorf("failed to index restored %s: %w", entry.Kind, err)
+	}
+	if err := w.saveContext(w.Context); err != nil {
+		return fmt.Errorf("failed to update context after restoring %s: %w", entry.Path, err)
+	}
+	if err := w.storage.RemoveAll(w.trashDir(id)); err != nil {
+		return fmt.Errorf("failed to remove trash entry %s: %w", id, err)
+	}
+	return w.logAction(fmt.Sprintf("Restored %s %s from the trash", entry.Kind, entry.Name))
+}
+
+// EmptyTrash permanently deletes every entry in the trash.
+func (w *Workspace) EmptyTrash() error {
+	if err := w.storage.RemoveAll(filepath.Join(w.RootDir, "trash")); err != nil {
+		return fmt.Errorf("failed to empty trash: %w", err)
+	}
+	return w.logAction("Emptied trash")
+}
@@ -7,13 +7,42 @@ import (
 
 // Message represents a chat message
 type Message struct {
-	Role    string
-	Content string
-	Time    time.Time
+	Role        string
+	Content     string
+	Time        time.Time
+	Attachments []Attachment
+	Meta        *ResponseMeta // How a model response was produced; nil for other messages.
+	ChatID      string        // ID of the saved `Chat` entry the message belongs to, if any.
+	ReplyTo     string        // ID of the `Chat` entry a user message quotes, if any.
+
+	Think         string // Thought process behind an assistant message, shown on demand.
+	ThinkExpanded bool   // Whether Think is currently expanded in the history pane.
+}
+
+// ResponseMeta describes how a model response was produced, for debugging
+// quality and cost regressions.
+type ResponseMeta struct {
+	Provider     string        // Provider that served the response (e.g., ProviderGemini).
+	Model        string        // Model that generated the response.
+	Latency      time.Duration // Time taken by the request.
+	FinishReason string        // Why the model stopped generating (e.g., "STOP", "MAX_TOKENS").
+	Usage        *Usage        // Token usage reported by the provider, if any.
 }
 
 // AIClient interface for AI communication
 type AIClient interface {
 	StartSession(ctx context.Context) (Response, error)
-	SendMessage(ctx context.Context, message string, history []Message, save bool) (Response, error)
+	SendMessage(ctx context.Context, message SavedMessage, history []Message, save bool) (Response, error)
+}
+
+// MessageStreamer is implemented by AI clients that can deliver a response
+// while it is being generated. `GeminiAIClient` implements it.
+type MessageStreamer interface {
+	StreamMessage(ctx context.Context, message SavedMessage, save bool, onChunk func(text string)) (Response, error)
+}
+
+// ResponseContinuer is implemented by AI clients that can resume a reply cut
+// off with `ErrResponseIncomplete`. `GeminiAIClient` implements it.
+type ResponseContinuer interface {
+	ContinueResponse(ctx context.Context, onChunk func(text string)) (Response, error)
 }
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UIPreferences holds per-workspace terminal UI state that should survive
+// restarts, so the TUI reopens where the user left off. It is stored in `ui.json`.
+type UIPreferences struct {
+	Focused     int     `json:"focused"`               // Focused pane: 0 input, 1 history, 2 preview.
+	PreviewMode bool    `json:"previewMode"`           // Whether the preview pane is in preview mode.
+	LeftRatio   float64 `json:"leftRatio"`             // Fraction of the terminal width given to the left column, or of the height when stacked.
+	InputRatio  float64 `json:"inputRatio"`            // Fraction of the terminal height given to the input box.
+	Layout      string  `json:"layout"`                // "split", "preview" or "history" (maximized), or "stacked"; split stacks in narrow terminals.
+	Theme       string  `json:"theme"`                 // Glamour style: a name ("dark", "light", "dracula", ...), a JSON style file, or "auto" to match the terminal.
+	Wrap        bool    `json:"wrap"`                  // Whether preview content is wrapped to the pane width.
+	WrapWidth   int     `json:"wrapWidth,omitempty"`   // Column at which preview Markdown is word-wrapped; 0 wraps at the pane width.
+	LastSession string  `json:"lastSession,omitempty"` // ID of the session that was open when the UI last exited.
+	EnterMode   string  `json:"enterMode"`             // "send" (Enter sends) or "newline" (Enter inserts a newline).
+	EnterChord  string  `json:"enterChord,omitempty"`  // Key doing what Enter does not (e.g., "alt+enter"); empty uses the mode's defaults.
+	ShowDetails bool    `json:"showDetails"`           // Whether response metadata is expanded in the history pane.
+	HideThink   bool    `json:"hideThink"`             // Whether the model's thought process is hidden from the history pane.
+	Timestamps  string  `json:"timestamps"`            // "absolute" (clock time), "relative" (age), or "off": how the history pane shows when messages were sent.
+}
+
+// DefaultUIPreferences returns the UI preferences used when `ui.json` does not exist.
+func DefaultUIPreferences() UIPreferences {
+	return UIPreferences{
+		LeftRatio:  0.4,
+		InputRatio: 0.25,
+		Layout:     "split",
+		Timestamps: "absolute",
+		Theme:      "auto",
+		Wrap:       true,
+		EnterMode:  "send",
+	}
+}
+
+// LoadUIPreferences reads `ui.json`. Missing files and unset fields fall back to
+// `DefaultUIPreferences`.
+func (w *Workspace) LoadUIPreferences() (UIPreferences, error) {
+	prefs := DefaultUIPreferences()
+	data, err := w.storage.ReadFile(filepath.Join(w.RootDir, "ui.json"))
+	if os.IsNotExist(err) {
+		return prefs, nil
+	} else if err != nil {
+		return prefs, fmt.Errorf("failed to read UI preferences: %w", err)
+	}
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return DefaultUIPreferences(), fmt.Errorf("failed to parse UI preferences: %w", err)
+	}
+
+	defaults := DefaultUIPreferences()
+	if prefs.LeftRatio <= 0 || prefs.LeftRatio >= 1 {
+		prefs.LeftRatio = defaults.LeftRatio
+	}
+	if prefs.InputRatio <= 0 || prefs.InputRatio >= 1 {
+		prefs.InputRatio = defaults.InputRatio
+	}
+	if prefs.Focused < 0 || prefs.Focused > 2 {
+		prefs.Focused = defaults.Focused
+	}
+	switch prefs.Layout {
+	case "split", "preview", "history", "stacked":
+	default:
+		prefs.Layout = defaults.Layout
+	}
+	if prefs.Timestamps != "absolute" && prefs.Timestamps != "relative" && prefs.Timestamps != "off" {
+		prefs.Timestamps = defaults.Timestamps
+	}
+	if prefs.Theme == "" {
+		prefs.Theme = defaults.Theme
+	}
+	if prefs.EnterMode != "send" && prefs.EnterMode != "newline" {
+		prefs.EnterMode = defaults.EnterMode
+	}
+	return prefs, nil
+}
+
+// SaveUIPreferences writes the UI preferences to `ui.json`.
+func (w *Workspace) SaveUIPreferences(prefs UIPreferences) error {
+	if err := w.writeJSON(filepath.Join(w.RootDir, "ui.json"), prefs); err != nil {
+		return fmt.Errorf("failed to save UI preferences: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package ai
+
+import "fmt"
+
+// Usage is the token accounting of a single model call, normalized across
+// providers. Each provider fills it from its native usage metadata so that
+// budgeting and reporting never need to know which provider answered.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`           // Tokens in the request, including history and system instructions.
+	CompletionTokens int `json:"completionTokens"`       // Tokens generated by the model, including any reasoning tokens.
+	TotalTokens      int `json:"totalTokens"`            // Total tokens billed for the call.
+	CachedTokens     int `json:"cachedTokens,omitempty"` // Portion of PromptTokens served from a provider-side cache.
+}
+
+// Add returns the sum of u and other.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+	}
+}
+
+// SessionUsage returns the accumulated token usage of every turn in the active
+// session. Turns recorded before usage tracking existed contribute nothing.
+func (w *Workspace) SessionUsage() (Usage, error) {
+	session, err := w.loadSession()
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to load session to compute usage: %w", err)
+	}
+	var total Usage
+	for _, c := range session.Chat {
+		if c.Response.Usage != nil {
+			total = total.Add(*c.Response.Usage)
+		}
+	}
+	return total, nil
+}
@@ -1,27 +1,67 @@
 package ai
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"strings"
+	"time"
 )
 
 // Response represents the structured output format.
-// It uses a JSON object as the root, containing think, summary, and content fields.
+// It uses a JSON object as the root, containing think, summary, and content fields,
+// plus optional lists of action items, of review findings anchored to file
+// lines, and of facts about the project to remember. Roles may define further fields, which are collected in Fields. Usage and the request metadata (model, latency, finish reason) are
+// filled in by the provider and are not part of the model's output.
 type Response struct {
-	Think   string `json:"think"`
-	Summary string `json:"summary"`
-	Content string `json:"content"`
+	Think    string         `json:"think"`
+	Summary  string         `json:"summary"`
+	Content  string         `json:"content"`
+	Actions  []Action       `json:"actions,omitempty"`
+	Findings []Finding      `json:"findings,omitempty"`
+	Fields   map[string]any `json:"fields,omitempty"`   // Values of the fields defined by the role's schema (see `Role.Schema`).
+	Remember []string       `json:"remember,omitempty"` // Facts about the project the model asked to record (see `Fact`).
+	Usage    *Usage         `json:"-"`
+
+	Provider     string        `json:"-"` // Provider that served the response (see `Settings.Providers`).
+	Model        string        `json:"-"` // Model that generated the response.
+	Latency      time.Duration `json:"-"` // Time taken by the request.
+	FinishReason string        `json:"-"` // Why the model stopped generating.
+	ChatID       string        `json:"-"` // ID of the `Chat` entry the response was saved as, if saved.
+
+	Repairs []string        `json:"-"` // Repairs needed to parse the model's output (e.g., RepairTrailingComma); empty if it was valid JSON.
+	Secrets []SecretFinding `json:"-"` // Secrets found in the prompt and attachments; masked or sent as-is per `Settings.Redaction`.
+
+	Candidates []Response `json:"-"` // Every reply generated when the role asks for several, the first being this one; see `CandidateSelector`.
+}
+
+// saved returns the response as recorded in the chat history of a session.
+func (r Response) saved() SavedResponse {
+	return SavedResponse{
+		Content:  r.Summary,
+		Actions:  r.Actions,
+		Findings: r.Findings,
+		Fields:   r.Fields,
+		Usage:    r.Usage,
+
+		Provider:     r.Provider,
+		Model:        r.Model,
+		LatencyMs:    r.Latency.Milliseconds(),
+		FinishReason: r.FinishReason,
+	}
+}
+
+// Meta returns the metadata describing how the response was produced.
+func (r Response) Meta() ResponseMeta {
+	return ResponseMeta{Provider: r.Provider, Model: r.Model, Latency: r.Latency, FinishReason: r.FinishReason, Usage: r.Usage}
 }
 
 // Errors for specific validation failures.
 var (
-	ErrEmptyInput      = errors.New("input string is empty or whitespace-only")
-	ErrInvalidJSON     = errors.New("failed to parse JSON")
-	ErrEmptyThink      = errors.New("think field is empty or missing")
-	ErrEmptySummary    = errors.New("summary field is empty or missing")
-	ErrEmptyContent    = errors.New("content field is empty or missing")
+	ErrEmptyInput     = errors.New("input string is empty or whitespace-only")
+	ErrInvalidJSON    = errors.New("failed to parse JSON")
+	ErrEmptyThink     = errors.New("think field is empty or missing")
+	ErrEmptySummary   = errors.New("summary field is empty or missing")
+	ErrEmptyContent   = errors.New("content field is empty or missing")
+	ErrSchemaMismatch = errors.New("response does not match the role schema")
 )
 
 // defaultResponse returns a default Response with the original input as Content.
@@ -34,40 +74,26 @@ func defaultResponse(input string) Response {
 }
 
 // parseAIResponse parses a JSON string into a Response struct and validates its fields.
-// It strips only the outermost code fences (e.g., ```json and ```) from the input, then parses and validates the JSON.
-// It returns a default Response with the original input in Content and an error if parsing or validation fails.
-func parseAIResponse(responseText string) (Response, error) {
+// Output that is not valid JSON as is, such as an object wrapped in code fences or prose,
+// is repaired first (see `repairJSON`), and the repairs are listed in the Response.
+// The fields defined by schema, the role's schema if any, are validated against it and collected in Fields.
+// It returns a default Response with the original input in Content and an error if parsing or validation fails;
+// JSON errors are a `*ParseError` locating the problem.
+func parseAIResponse(responseText string, schema *FieldSchema) (Response, error) {
 	// Check for empty or whitespace-only input
 	if strings.TrimSpace(responseText) == "" {
 		return defaultResponse(responseText), ErrEmptyInput
 	}
 
-	// Strip outermost code fences (e.g., ```json and ```)
-	cleanedText := strings.TrimSpace(responseText)
-	if strings.HasPrefix(cleanedText, "```") {
-		// Find the end of the opening fence
-		lines := strings.SplitN(cleanedText, "\n", 2)
-		if len(lines) > 1 && strings.HasPrefix(lines[0], "```") {
-			// Remove the opening fence and process the rest
-			remaining := lines[1]
-			// Find the last closing fence (```)
-			lastFenceIndex := strings.LastIndex(remaining, "\n```")
-			if lastFenceIndex >= 0 {
-				// Extract content before the closing fence
-				cleanedText = strings.TrimSpace(remaining[:lastFenceIndex])
-			} else {
-				// No closing fence; use the content after the opening fence
-				cleanedText = strings.TrimSpace(remaining)
-			}
-		}
-	}
-
-	// Parse JSON
+	// Parse JSON, repairing it if needed
 	var aiResponse Response
-	err := json.Unmarshal([]byte(cleanedText), &aiResponse)
+	cleanedText, repairs, err := decodeReply(responseText, &aiResponse)
 	if err != nil {
-		return defaultResponse(responseText), fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+		fallback := defaultResponse(responseText)
+		fallback.Repairs = repairs
+		return fallback, err
 	}
+	aiResponse.Repairs = repairs
 
 	// Validate fields
 	if strings.TrimSpace(aiResponse.Think) == "" {
@@ -79,6 +105,14 @@ func parseAIResponse(responseText string) (Response, error) {
 	if strings.TrimSpace(aiResponse.Content) == "" {
 		return defaultResponse(responseText), ErrEmptyContent
 	}
+	for i := range aiResponse.Findings {
+		aiResponse.Findings[i].Severity = normalizeSeverity(aiResponse.Findings[i].Severity)
+	}
+	if aiResponse.Fields, err = roleFields([]byte(cleanedText), schema); err != nil {
+		fallback := defaultResponse(responseText)
+		fallback.Repairs = repairs
+		return fallback, err
+	}
 
 	return aiResponse, nil
 }
@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/httptransport"
+	"google.golang.org/genai"
+)
+
+// VertexConfig sends Gemini requests through Vertex AI, authenticated with
+// Application Default Credentials (e.g., from `gcloud auth
+// application-default login` or a service account) instead of an API key. It
+// is set with `Settings.Vertex`.
+type VertexConfig struct {
+	Project  string `json:"project,omitempty"`  // Google Cloud project billed for requests; empty uses GOOGLE_CLOUD_PROJECT.
+	Location string `json:"location,omitempty"` // Region serving requests (e.g., "us-central1" or "global"); empty uses GOOGLE_CLOUD_LOCATION.
+}
+
+// NewVertexAIClient creates a Gemini client that reaches the models through
+// the Vertex AI backend described by vertex, using Application Default
+// Credentials.
+func NewVertexAIClient(vertex VertexConfig, workspace *Workspace) (*GeminiAIClient, error) {
+	creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+		Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Application Default Credentials: %w", err)
+	}
+	quotaProject, err := creds.QuotaProjectID(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota project: %w", err)
+	}
+	// The audit log sits in front of authentication, so it never records
+	// access tokens.
+	authClient, err := httptransport.NewClient(&httptransport.Options{
+		Credentials: creds,
+		Headers:     http.Header{"X-Goog-User-Project": []string{quotaProject}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vertex AI transport: %w", err)
+	}
+	return newGenAIClient(&genai.ClientConfig{
+		Backend:     genai.BackendVertexAI,
+		Project:     vertex.Project,
+		Location:    vertex.Location,
+		Credentials: creds,
+	}, authClient.Transport, workspace)
+}
@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often `Watch` looks for external changes.
+const watchInterval = 2 * time.Second
+
+// watchedDirs lists the artifact directories whose files feed the in-memory indexes.
+var watchedDirs = []string{"roles", "preferences", "sessions"}
+
+// File change kinds reported by `Watch`, alongside `ChangeModified`.
+const (
+	ChangeCreated = "created" // The file appeared since the last check.
+	ChangeRemoved = "removed" // The file was deleted since the last check.
+)
+
+// FileChange is a single artifact changed outside nani.
+type FileChange struct {
+	Path string `json:"path"` // Path relative to the workspace root, using forward slashes.
+	Kind string `json:"kind"` // One of ChangeCreated, ChangeModified, or ChangeRemoved.
+}
+
+// WorkspaceChange reports the artifacts changed outside nani, for example by
+// hand edits or a `git pull`, since the previous change was sent.
+type WorkspaceChange struct {
+	Files []FileChange `json:"files"` // Changed files, sorted by path.
+}
+
+// Affects reports whether any of the changed files is in the artifact
+// directory dir (e.g., "sessions").
+func (c WorkspaceChange) Affects(dir string) bool {
+	for _, f := range c.Files {
+		if strings.HasPrefix(f.Path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// fileStamp identifies a version of a file without reading it.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watch checks the `roles/`, `preferences/`, and `sessions/` directories for
+// changes made outside nani and sends them on the returned channel until ctx is
+// done, when the channel is closed. Files nani wrote itself are recognized by
+// their hash in the integrity manifest and not reported.
+//
+// The watcher polls rather than relying on OS notifications, so it works the
+// same on every platform and file system. It never modifies the workspace;
+// receivers apply changes with `ApplyChange` from the goroutine that owns the
+// workspace, as the indexes are not safe for concurrent use.
+func (w *Workspace) Watch(ctx context.Context) (<-chan WorkspaceChange, error) {
+	stamps, err := w.watchedStamps()
+	if err != nil {
+		return nil, err
+	}
+	changes := make(chan WorkspaceChange)
+	go func() {
+		defer close(changes)
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current, err := w.watchedStamps()
+			if err != nil {
+				// The directories may be mid-update, as during a checkout; try again next tick.
+				continue
+			}
+			files := w.externalChanges(stamps, current)
+			stamps = current
+			if len(files) == 0 {
+				continue
+			}
+			select {
+			case changes <- WorkspaceChange{Files: files}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// ApplyChange brings the in-memory indexes in line with files changed outside
+// nani, as reported by `Watch`.
+func (w *Workspace) ApplyChange(ctx context.Context, change WorkspaceChange) error {
+	if len(change.Files) == 0 {
+		return nil
+	}
+	if err := w.rebuildIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to update indexes after external changes: %w", err)
+	}
+	paths := make([]string, len(change.Files))
+	for i, f := range change.Files {
+		paths[i] = fmt.Sprintf("%s (%s)", f.Path, f.Kind)
+	}
+	return w.logAction(fmt.Sprintf("Updated indexes after external changes to %s", strings.Join(paths, ", ")))
+}
+
+// watchedStamps returns the size and modification time of every artifact in
+// the watched directories, keyed by manifest path.
+func (w *Workspace) watchedStamps() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	for _, dir := range watchedDirs {
+		entries, err := os.ReadDir(filepath.Join(w.RootDir, dir))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s directory: %w", dir, err)
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+				continue
+			}
+			info, err := e.Info()
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to stat %s/%s: %w", dir, e.Name(), err)
+			}
+			stamps[dir+"/"+e.Name()] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return stamps, nil
+}
+
+// externalChanges compares two sets of stamps and returns the files that
+// changed between them, leaving out those whose current state nani recorded
+// in the integrity manifest.
+func (w *Workspace) externalChanges(before, after map[string]fileStamp) []FileChange {
+	var files []FileChange
+	for rel, stamp := range after {
+		old, existed := before[rel]
+		if existed && old == stamp {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(w.RootDir, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		if recorded, ok := w.manifestHash(rel); ok && recorded == hashBytes(data) {
+			continue
+		}
+		kind := ChangeModified
+		if !existed {
+			kind = ChangeCreated
+		}
+		files = append(files, FileChange{Path: rel, Kind: kind})
+	}
+	for rel := range before {
+		if _, ok := after[rel]; ok {
+			continue
+		}
+		if _, ok := w.manifestHash(rel); !ok {
+			continue
+		}
+		files = append(files, FileChange{Path: rel, Kind: ChangeRemoved})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
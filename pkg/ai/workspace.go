@@ -6,10 +6,15 @@
 package ai
 
 import (
+	"context"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,11 +25,15 @@ import (
 // It is used primarily for listing available sessions without loading their
 // entire content (like chat history or source code lists).
 type SessionSummary struct {
-	ID          string    `json:"id"`          // Unique identifier for the session.
-	Label       string    `json:"label"`       // A human-readable label for the session.
-	RoleName    string    `json:"roleName"`    // The name of the AI role used in this session.
-	CreatedAt   time.Time `json:"createdAt"`   // Timestamp when the session was created.
-	LastUpdated time.Time `json:"lastUpdated"` // Timestamp when the session was last updated.
+	ID          string    `json:"id"`                 // Unique identifier for the session.
+	Label       string    `json:"label"`              // A human-readable label for the session.
+	RoleName    string    `json:"roleName"`           // The name of the AI role used in this session.
+	CreatedAt   time.Time `json:"createdAt"`          // Timestamp when the session was created.
+	LastUpdated time.Time `json:"lastUpdated"`        // Timestamp when the session was last updated.
+	File        string    `json:"file,omitempty"`     // Filename of the archive within `sessions/`.
+	Scope       string    `json:"scope,omitempty"`    // Project subdirectory the session was started in, if scoped.
+	Priority    Priority  `json:"priority,omitempty"` // Importance of the session.
+	Pinned      bool      `json:"pinned,omitempty"`   // Whether the session is pinned, listing it first.
 }
 
 // RoleSummary provides a lightweight summary of an AI role.
@@ -33,6 +42,7 @@ type RoleSummary struct {
 	Name        string `json:"name"`        // Unique name of the role (e.g., "documenter").
 	Label       string `json:"label"`       // Human-readable label for the role (e.g., "Code Documenter").
 	Description string `json:"description"` // A brief description of the role's purpose.
+	Global      bool   `json:"-"`           // Whether the role comes from the global (user-level) workspace.
 }
 
 // PreferenceSummary provides a lightweight summary of a user preference.
@@ -41,6 +51,7 @@ type PreferenceSummary struct {
 	ID             string    `json:"id"`                       // Unique identifier for the preference.
 	Timestamp      time.Time `json:"timestamp"`                // Timestamp when the preference was created or last updated.
 	ContentSnippet string    `json:"contentSnippet,omitempty"` // A truncated snippet of the preference's content.
+	Global         bool      `json:"-"`                        // Whether the preference comes from the global (user-level) workspace.
 }
 
 // ArtifactIndexes groups all artifact indexes together within the workspace context.
@@ -54,38 +65,73 @@ type ArtifactIndexes struct {
 
 // Context represents the overall workspace configuration.
 // It is stored in `context.json` and includes global settings, project metadata,
-// and in-memory indexes of various artifacts for quick lookup.
+// and in-memory indexes of various artifacts for quick lookup. The indexes are
+// derived from the artifact files and rebuilt whenever the context is loaded,
+// so they are never written to `context.json`: a file holding only settings
+// and project metadata merges cleanly when shared (see `Sync` and `MergeContext`).
 type Context struct {
 	Workspace string          `json:"workspace"` // A unique ID for the workspace itself.
 	Settings  Settings        `json:"settings"`  // Workspace-wide settings.
 	Project   Project         `json:"project"`   // Project-specific metadata.
-	Indexes   ArtifactIndexes `json:"indexes"`   // Nested indexes for better organization and quick lookup.
+	Indexes   ArtifactIndexes `json:"-"`         // Nested indexes for better organization and quick lookup.
 }
 
 // Settings holds workspace-wide configuration settings.
+// Machine-local settings (see `localSettings`) are stored in `local.json`
+// rather than `context.json`, so that `Sync` never shares them.
 type Settings struct {
-	DefaultLanguage string `json:"defaultLanguage"` // The default language setting for the AI.
-	DefaultRole     string `json:"defaultRole"`     // The name of the default AI role to use.
-	SystemPrompt    string `json:"systemPrompt"`    // A global system prompt applied to all AI interactions.
+	DefaultLanguage     string              `json:"defaultLanguage"`               // The default language setting for the AI.
+	DefaultRole         string              `json:"defaultRole"`                   // The name of the default AI role to use.
+	SystemPrompt        string              `json:"systemPrompt"`                  // A global system prompt applied to all AI interactions.
+	PreferenceBudget    int                 `json:"preferenceBudget,omitempty"`    // Maximum characters of preferences injected into the system prompt; 0 uses the default.
+	FactBudget          int                 `json:"factBudget,omitempty"`          // Maximum characters of project facts injected into the system prompt; 0 uses the default.
+	Routes              map[string][]string `json:"routes,omitempty"`              // Models per task (e.g., {"docs": ["gemini-2.5-pro", "gemini-2.5-flash"]}), each tried when the one before is unavailable; see `ModelsFor`.
+	LongPromptChars     int                 `json:"longPromptChars,omitempty"`     // Characters above which a chat message takes the "long-chat" route; 0 uses the default.
+	Vertex              *VertexConfig       `json:"vertex,omitempty"`              // Vertex AI project and location; when set, Gemini requests go through Vertex AI with Application Default Credentials instead of an API key.
+	Providers           []string            `json:"providers,omitempty"`           // Chat providers tried in order when the one before is unavailable (e.g., ["gemini", "mock"]); see `FailoverClient`. Ignored when --provider is given.
+	Sync                *SyncConfig         `json:"sync,omitempty"`                // Git remote shared with the team by `nani sync` (see `Sync`).
+	ExtractMemories     bool                `json:"extractMemories,omitempty"`     // Whether ending a session asks the model for facts and preferences worth keeping, queued for approval (see `ExtractMemories`).
+	ArchiveNamePattern  string              `json:"archiveNamePattern,omitempty"`  // Filename pattern for archived sessions (e.g., "{date}-{label}-{shortid}").
+	CompactionThreshold int                 `json:"compactionThreshold,omitempty"` // Tokens of session context above which older turns are summarized; 0 uses the default.
+	RetrievalTopK       int                 `json:"retrievalTopK,omitempty"`       // Number of semantically relevant chunks prepended to each prompt; 0 disables retrieval.
+	BatchConcurrency    int                 `json:"batchConcurrency,omitempty"`    // Requests run at once by batch operations such as doc generation; 0 uses the default.
+	RateLimits          map[string]int      `json:"rateLimits,omitempty"`          // Maximum requests per minute per provider (e.g., {"gemini": 60}) for batch operations.
+	RunAllowlist        []string            `json:"runAllowlist,omitempty"`        // Programs `/run` executes without asking for confirmation (e.g., ["go", "make"]).
+	AutoTitleTurns      int                 `json:"autoTitleTurns,omitempty"`      // Exchanges after which the model names a session still labeled "Session"; 0 uses the default, negative disables.
+	WatchFiles          bool                `json:"watchFiles,omitempty"`          // Whether the chat picks up roles, preferences, and sessions changed outside nani (see `Watch`).
+	Redaction           RedactionMode       `json:"redaction,omitempty"`           // How secrets in prompts and attachments are handled before sending: "warn" (default), "mask", "block", or "off".
+	Audit               bool                `json:"audit,omitempty"`               // Whether every provider request and response is appended to `audit/<date>.jsonl`.
+	AuditMaxBytes       int                 `json:"auditMaxBytes,omitempty"`       // Bytes of each request and response body kept in the audit log; 0 uses the default.
+	ReformatAttempts    int                 `json:"reformatAttempts,omitempty"`    // Times a reply that is not valid structured output is sent back for correction before its raw text is kept; 0 uses the default, negative disables.
+	RoleRegistry        string              `json:"roleRegistry,omitempty"`        // Role registry `nani roles search` and `nani roles install` use: a git repository, an HTTP(S) URL of an index, or a local directory (see `SearchRoleRegistry`).
+	RepoMap             bool                `json:"repoMap,omitempty"`             // Whether the system prompt includes a map of the project's source files, sizes, and exported symbols, refreshed as files change (see `RepoMap`).
+	RepoMapBudget       int                 `json:"repoMapBudget,omitempty"`       // Maximum characters of the repo map injected into the system prompt; 0 uses the default.
+	ChunkSizes          map[string]int      `json:"chunkSizes,omitempty"`          // Target characters of indexed source chunks per language ("go", "python", "typescript", or "rust"), overriding the defaults.
 }
 
 // Project holds metadata specific to the AI project associated with the workspace.
 type Project struct {
-	Name       string `json:"name"`       // The name of the project.
-	Owner      string `json:"owner"`      // The owner or creator of the project.
-	Repository string `json:"repository"` // URL or identifier of the project's source code repository.
+	Name       string `json:"name"`               // The name of the project.
+	Owner      string `json:"owner"`              // The owner or creator of the project.
+	Repository string `json:"repository"`         // URL or identifier of the project's source code repository.
+	Module     string `json:"module,omitempty"`   // Module or package path (e.g., the `go.mod` module path), if any.
+	Language   string `json:"language,omitempty"` // Primary programming language of the project.
 }
 
 // Session represents an active or archived interaction session with the AI.
 // Active sessions are stored in `session.json`, while archived sessions are
 // moved to `sessions/<id>.json`.
 type Session struct {
-	ID       string   `json:"id"`       // Unique identifier for this session.
-	Label    string   `json:"label"`    // A descriptive label for the session.
-	Role     Role     `json:"role"`     // The full AI role configuration for this session.
-	Sources  []string `json:"sources"`  // A list of file paths that are relevant to this session.
-	Chat     []Chat   `json:"chat"`     // A chronological list of user-AI interactions.
-	Metadata Metadata `json:"metadata"` // Internal session management data.
+	ID         string         `json:"id"`                   // Unique identifier for this session.
+	Label      string         `json:"label"`                // A descriptive label for the session.
+	Role       Role           `json:"role"`                 // The full AI role configuration for this session.
+	Sources    []string       `json:"sources"`              // Files relevant to this session, relative to the project root with forward slashes (see `AddSource`).
+	Scope      string         `json:"scope,omitempty"`      // Project subdirectory the session is confined to (see `WithScope`).
+	Chat       []Chat         `json:"chat"`                 // A chronological list of user-AI interactions.
+	Metadata   Metadata       `json:"metadata"`             // Internal session management data.
+	Compaction *Compaction    `json:"compaction,omitempty"` // Summary replacing the oldest turns when rebuilding context.
+	Pins       []ContextPin   `json:"pins,omitempty"`       // Messages and snippets always replayed when rebuilding context.
+	Patches    []AppliedPatch `json:"patches,omitempty"`    // Patches applied to the project from the session's replies (see `ApplyPatch`).
 }
 
 // MarshalJSON customizes Session JSON serialization.
@@ -134,42 +180,81 @@ type Chat struct {
 
 // SavedMessage is a user's prompt or input, stored persistently.
 type SavedMessage struct {
-	Content   string    `json:"content"`   // The textual content of the user's message.
-	Timestamp time.Time `json:"timestamp"` // The timestamp when the message was created.
+	Content     string       `json:"content"`               // The textual content of the user's message.
+	Timestamp   time.Time    `json:"timestamp"`             // The timestamp when the message was created.
+	Attachments []Attachment `json:"attachments,omitempty"` // Media files sent with the message.
+	ReplyTo     string       `json:"replyTo,omitempty"`     // ID of the earlier `Chat` entry the message quotes, for threading.
 }
 
 // SavedResponse is the AI's reply to a user's message, stored persistently.
 type SavedResponse struct {
-	Content   string    `json:"content"`   // The textual content of the AI's response.
-	Timestamp time.Time `json:"timestamp"` // The timestamp when the response was generated.
+	Content   string         `json:"content"`            // The textual content of the AI's response.
+	Timestamp time.Time      `json:"timestamp"`          // The timestamp when the response was generated.
+	Actions   []Action       `json:"actions,omitempty"`  // Action items extracted from the response.
+	Findings  []Finding      `json:"findings,omitempty"` // Review findings anchored to file lines, if the response annotates code.
+	Fields    map[string]any `json:"fields,omitempty"`   // Values of the fields defined by the role's schema, if any.
+	Usage     *Usage         `json:"usage,omitempty"`    // Token usage reported by the provider for this turn.
+
+	Provider     string `json:"provider,omitempty"`     // Provider that served the response, which may be a fallback (see `Settings.Providers`).
+	Model        string `json:"model,omitempty"`        // Model that generated the response.
+	LatencyMs    int64  `json:"latencyMs,omitempty"`    // Request latency, in milliseconds.
+	FinishReason string `json:"finishReason,omitempty"` // Why the model stopped generating (e.g., "STOP", "MAX_TOKENS").
 }
 
 // Metadata holds internal management data for a session, useful for tracking
 // its lifecycle and characteristics.
 type Metadata struct {
-	CreatedAt       time.Time `json:"createdAt"`       // Timestamp when the session was originally created.
-	Priority        string    `json:"priority"`        // Indication of session importance (e.g., "low", "medium", "high").
-	SessionDuration string    `json:"sessionDuration"` // Expected or actual duration of the session in seconds (as string).
-	LastUpdated     time.Time `json:"lastUpdated"`     // Timestamp of the last modification to the session.
-	ArchiveAfter    time.Time `json:"archiveAfter"`    // Timestamp after which the session is eligible for archiving.
+	CreatedAt       time.Time `json:"createdAt"`        // Timestamp when the session was originally created.
+	Priority        Priority  `json:"priority"`         // Importance of the session, used to order session lists.
+	SessionDuration string    `json:"sessionDuration"`  // Expected or actual duration of the session in seconds (as string).
+	LastUpdated     time.Time `json:"lastUpdated"`      // Timestamp of the last modification to the session.
+	ArchiveAfter    time.Time `json:"archiveAfter"`     // Timestamp after which the session is eligible for archiving; zero for pinned sessions.
+	Pinned          bool      `json:"pinned,omitempty"` // Whether the session is pinned (see `PinSession`).
 }
 
 // Preference represents a user-defined AI prompt tweak or instruction.
 // Preferences are stored as individual JSON files in the `preferences/` directory.
 type Preference struct {
-	ID        string    `json:"id"`        // Unique identifier for the preference.
-	Content   string    `json:"content"`   // The detailed textual content of the preference.
-	Timestamp time.Time `json:"timestamp"` // The timestamp when the preference was created or last updated.
+	ID        string    `json:"id"`              // Unique identifier for the preference.
+	Content   string    `json:"content"`         // The detailed textual content of the preference.
+	Timestamp time.Time `json:"timestamp"`       // The timestamp when the preference was created or last updated.
+	Roles     []string  `json:"roles,omitempty"` // Role names this preference applies to; empty means all roles.
+}
+
+// AppliesTo reports whether the preference should be used for the given role.
+// Preferences without any role tags apply to every role.
+func (p Preference) AppliesTo(roleName string) bool {
+	if len(p.Roles) == 0 {
+		return true
+	}
+	for _, r := range p.Roles {
+		if r == roleName {
+			return true
+		}
+	}
+	return false
 }
 
 // Role represents an AI persona or configuration.
 // Roles define how the AI should behave and are stored as individual JSON files
 // in the `roles/` directory.
 type Role struct {
-	Name        string `json:"name"`        // Unique name of the role (e.g., "documenter").
-	Label       string `json:"label"`       // Human-readable label for the role (e.g., "Code Documenter").
-	Persona     string `json:"persona"`     // The detailed prompt string that defines the AI's personality/instructions.
-	Description string `json:"description"` // A brief description of the role's purpose.
+	Name        string         `json:"name"`                  // Unique name of the role (e.g., "documenter").
+	Label       string         `json:"label"`                 // Human-readable label for the role (e.g., "Code Documenter").
+	Persona     string         `json:"persona"`               // The detailed prompt string that defines the AI's personality/instructions.
+	Description string         `json:"description"`           // A brief description of the role's purpose.
+	Parameters  RoleParameters `json:"parameters"`            // Optional model and generation parameters tuned for this role.
+	Schema      *FieldSchema   `json:"schema,omitempty"`      // Object schema of fields the role's replies carry besides think, summary, and content; see `Response.Fields`.
+	PostProcess []string       `json:"postProcess,omitempty"` // Transformers applied in order to the content of the role's replies (e.g., ["strip-fluff", "gofmt", "width:100"]); see `PostProcess`.
+}
+
+// RoleParameters holds optional model and generation parameters for a role.
+// Zero values leave the provider defaults in place.
+type RoleParameters struct {
+	Model          string   `json:"model,omitempty"`          // Overrides the default model for sessions using this role.
+	Temperature    *float32 `json:"temperature,omitempty"`    // Sampling temperature; lower values are more deterministic.
+	TopP           *float32 `json:"topP,omitempty"`           // Nucleus sampling probability mass.
+	CandidateCount int32    `json:"candidateCount,omitempty"` // Replies generated for each chat message; 0 or 1 generates one.
 }
 
 // Workspace manages the `.AIWorkspace` directory, which serves as the root
@@ -177,19 +262,38 @@ type Role struct {
 // initializing the workspace, managing sessions, roles, and preferences.
 type Workspace struct {
 	RootDir string  // The root directory where `.AIWorkspace` is located.
-	Context Context // The in-memory representation of the workspace's context.
+	Context Context // The in-memory representation of the workspace's context; other packages use the accessors in options.go instead.
+
+	embedder  Embedder         // Embedder used for semantic search; nil disables it (see embeddings.go).
+	globalDir string           // User-level workspace shared across projects; empty disables it (see global.go).
+	scope     string           // Project subdirectory the workspace is confined to; empty for the whole project (see scope.go).
+	persist   *persistState    // Deferred context writes and write serialization (see flush.go).
+	events    *eventBus        // Handlers registered with `Subscribe` (see events.go).
+	storage   Storage          // File system holding the workspace's files (see storage.go).
+	logger    *slog.Logger     // Receives the operational log instead of `logs/`; nil writes the files (see options.go).
+	clock     func() time.Time // Source of the current time; nil uses `time.Now` (see options.go).
 }
 
-// NewWorkspace creates a new Workspace instance.
+// NewWorkspace creates a new Workspace instance, configured by opts.
 // It initializes the `.AIWorkspace` directory and its required subdirectories
 // (`preferences`, `sessions`, `roles`, `logs`) if they don’t already exist.
 // This function primarily handles the physical setup of the workspace directory structure.
-func NewWorkspace(rootDir string) (*Workspace, error) {
-	aiDir := filepath.Join(rootDir, ".AIWorkspace")
+func NewWorkspace(rootDir string, opts ...Option) (*Workspace, error) {
+	w := &Workspace{
+		RootDir:   filepath.Join(rootDir, ".AIWorkspace"),
+		globalDir: DefaultGlobalDir(),
+		persist:   &persistState{},
+		events:    &eventBus{},
+		storage:   OSStorage{},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	aiDir := w.RootDir
 
 	// Check if .AIWorkspace exists, create if not
-	if _, err := os.Stat(aiDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(aiDir, 0755); err != nil { // 0755: owner rwx, group rx, others rx
+	if _, err := w.storage.Stat(aiDir); os.IsNotExist(err) {
+		if err := w.storage.MkdirAll(aiDir, 0755); err != nil { // 0755: owner rwx, group rx, others rx
 			return nil, fmt.Errorf("failed to create workspace directory %s: %w", aiDir, err)
 		}
 	} else if err != nil {
@@ -197,10 +301,10 @@ func NewWorkspace(rootDir string) (*Workspace, error) {
 	}
 
 	// Ensure subdirectories exist
-	for _, dir := range []string{"preferences", "sessions", "roles", "logs"} {
+	for _, dir := range []string{"preferences", "sessions", "roles", "templates", "schedules", "facts", "drafts", "logs"} {
 		subDir := filepath.Join(aiDir, dir)
-		if _, err := os.Stat(subDir); os.IsNotExist(err) {
-			if err := os.MkdirAll(subDir, 0755); err != nil {
+		if _, err := w.storage.Stat(subDir); os.IsNotExist(err) {
+			if err := w.storage.MkdirAll(subDir, 0755); err != nil {
 				return nil, fmt.Errorf("failed to create %s directory: %w", dir, err)
 			}
 		} else if err != nil {
@@ -208,24 +312,27 @@ func NewWorkspace(rootDir string) (*Workspace, error) {
 		}
 	}
 
-	return &Workspace{
-		RootDir: aiDir,
-	}, nil
+	return w, nil
 }
 
 // Init initializes a new workspace project, or loads an existing one.
 // It checks for `context.json`, creates a default one if it doesn't exist,
 // or loads the existing one. It ensures default roles are present and
 // rebuilds all in-memory artifact indexes to synchronize with disk.
-// This method is typically called once at application startup.
-func (w *Workspace) Init(projectName, owner, repo string) error {
+// Project metadata is detected from the project directory (see `DetectProject`)
+// when the context is created, and refreshed whenever the repository URL changes.
+// This method is typically called once at application startup; cancelling ctx
+// abandons the index scan.
+func (w *Workspace) Init(ctx context.Context) error {
 	contextPath := filepath.Join(w.RootDir, "context.json")
 
-	// Flag to track if a new context was created
-	newContextCreated := false
+	// Workspaces created before the integrity manifest existed get one built
+	// from their current on-disk state once initialization is complete.
+	_, err := w.storage.Stat(filepath.Join(w.RootDir, "manifest.json"))
+	manifestMissing := os.IsNotExist(err)
 
 	// Check if context.json exists
-	if _, err := os.Stat(contextPath); os.IsNotExist(err) {
+	if _, err := w.storage.Stat(contextPath); os.IsNotExist(err) {
 		// Create default context if not found
 		context := Context{
 			Workspace: uuid.New().String(),
@@ -234,7 +341,7 @@ func (w *Workspace) Init(projectName, owner, repo string) error {
 				DefaultRole:     "documenter",
 				SystemPrompt:    "You are a general-purpose AI assistant. Provide concise and helpful responses.", // Default system prompt
 			},
-			Project: Project{Name: projectName, Owner: owner, Repository: repo},
+			Project: DetectProject(filepath.Dir(w.RootDir)),
 			Indexes: ArtifactIndexes{ // Initialize nested struct for indexes
 				ArchivedSessions: make(map[string]SessionSummary),
 				RolesIndex:       make(map[string]RoleSummary),
@@ -245,7 +352,6 @@ func (w *Workspace) Init(projectName, owner, repo string) error {
 			return fmt.Errorf("failed to save context: %w", err)
 		}
 		w.Context = context // Set the workspace's context
-		newContextCreated = true
 	} else if err != nil {
 		return fmt.Errorf("failed to check context file %s: %w", contextPath, err)
 	} else {
@@ -253,6 +359,9 @@ func (w *Workspace) Init(projectName, owner, repo string) error {
 		if err := w.loadContext(); err != nil {
 			return fmt.Errorf("failed to load context: %w", err)
 		}
+		if err := w.refreshProject(); err != nil {
+			return err
+		}
 	}
 
 	// Backward compatibility: If SystemPrompt is empty in an existing context, set a default.
@@ -277,20 +386,18 @@ func (w *Workspace) Init(projectName, owner, repo string) error {
 		w.Context.Indexes.PreferencesIndex = make(map[string]PreferenceSummary)
 	}
 
-	// Rebuild/Reconcile indexes (important for new workspaces or schema migrations from old schema)
-	// Only rebuild if a new context wasn't just created (as it would be empty anyway)
-	// or if we are loading an existing context which might be out of sync.
-	if !newContextCreated {
-		if err := w.rebuildIndexes(); err != nil {
-			return fmt.Errorf("failed to rebuild indexes: %w", err)
-		}
+	// Indexes are not stored in `context.json`, so they are always rebuilt,
+	// even for a new context: its artifacts may already exist, such as after
+	// cloning a synced workspace.
+	if err := w.rebuildIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild indexes: %w", err)
 	}
 
 
 	// Create default documenter role if its file doesn't exist.
 	// This will also add it to the index via saveRole.
 	rolePath := filepath.Join(w.RootDir, "roles", "documenter.json")
-	if _, err := os.Stat(rolePath); os.IsNotExist(err) {
+	if _, err := w.storage.Stat(rolePath); os.IsNotExist(err) {
 		role := Role{
 			Name:        "documenter",
 			Label:       "Code Documenter",
@@ -304,114 +411,97 @@ func (w *Workspace) Init(projectName, owner, repo string) error {
 		return fmt.Errorf("failed to check documenter role file %s: %w", rolePath, err)
 	}
 
+	// New workspaces must exist on disk once Init returns, as other processes
+	// find workspaces by their `context.json`.
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if manifestMissing {
+		if err := w.RebuildManifest(ctx); err != nil {
+			return fmt.Errorf("failed to create integrity manifest: %w", err)
+		}
+	}
+
 	return w.logAction("Initialized workspace")
 }
 
 // rebuildIndexes scans the file system directories for sessions, roles, and preferences
 // and rebuilds the in-memory indexes within the Workspace's Context.
 // This is an internal helper function called by `Init()` and `RefreshIndexes()`.
-func (w *Workspace) rebuildIndexes() error {
-	// Re-initialize all index maps to ensure a clean rebuild
-	w.Context.Indexes.ArchivedSessions = make(map[string]SessionSummary)
-	w.Context.Indexes.RolesIndex = make(map[string]RoleSummary)
-	w.Context.Indexes.PreferencesIndex = make(map[string]PreferenceSummary)
+// If ctx is done before the scan completes, ctx's error is returned and the
+// previous indexes are kept.
+func (w *Workspace) rebuildIndexes(ctx context.Context) error {
+	// Build fresh index maps to ensure a clean rebuild
+	indexes := ArtifactIndexes{
+		ArchivedSessions: make(map[string]SessionSummary),
+		RolesIndex:       make(map[string]RoleSummary),
+		PreferencesIndex: make(map[string]PreferenceSummary),
+	}
 
 	// Rebuild session index
 	sessionsDir := filepath.Join(w.RootDir, "sessions")
-	files, err := os.ReadDir(sessionsDir)
+	files, err := w.storage.ReadDir(sessionsDir)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to read sessions directory for rebuilding index: %w", err)
 	}
 	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
-			sessionPath := filepath.Join(sessionsDir, file.Name())
-			data, err := os.ReadFile(sessionPath)
+			summary, err := w.readSessionSummary(sessionsDir, file.Name())
 			if err != nil {
-				w.logAction(fmt.Sprintf("Warning: Could not read archived session file '%s' during index rebuild: %v\n", sessionPath, err))
+				w.logAction(fmt.Sprintf("Warning: Could not index archived session during index rebuild: %v\n", err))
 				continue // Continue processing other files
 			}
-			// Use a temporary anonymous struct for unmarshaling just the summary parts
-			temp := struct {
-				ID       string   `json:"id"`
-				Label    string   `json:"label"`
-				Role     string   `json:"role"` // Unmarshal role name from JSON
-				Metadata Metadata `json:"metadata"`
-			}{}
-			if err := json.Unmarshal(data, &temp); err != nil {
-				w.logAction(fmt.Sprintf("Warning: Could not parse archived session summary from '%s' during index rebuild: %v\n", sessionPath, err))
-				continue // Continue processing other files
-			}
-
-			// Create a SessionSummary from the parsed data
-			w.Context.Indexes.ArchivedSessions[temp.ID] = SessionSummary{
-				ID:        temp.ID,
-				Label:     temp.Label,
-				RoleName:  temp.Role, // Use the unmarshaled role name
-				CreatedAt: temp.Metadata.CreatedAt,
-				LastUpdated: temp.Metadata.LastUpdated,
-			}
+			indexes.ArchivedSessions[summary.ID] = summary
 		}
 	}
 
 	// Rebuild roles index
 	rolesDir := filepath.Join(w.RootDir, "roles")
-	files, err = os.ReadDir(rolesDir)
+	files, err = w.storage.ReadDir(rolesDir)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to read roles directory for rebuilding index: %w", err)
 	}
 	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
-			rolePath := filepath.Join(rolesDir, file.Name())
-			data, err := os.ReadFile(rolePath)
+			summary, err := w.readRoleSummary(filepath.Join(rolesDir, file.Name()))
 			if err != nil {
-				w.logAction(fmt.Sprintf("Warning: Could not read role file '%s' during index rebuild: %v\n", rolePath, err))
+				w.logAction(fmt.Sprintf("Warning: Could not index role during index rebuild: %v\n", err))
 				continue
 			}
-			var r Role
-			if err := json.Unmarshal(data, &r); err != nil {
-				w.logAction(fmt.Sprintf("Warning: Could not parse role from '%s' during index rebuild: %v\n", rolePath, err))
-				continue
-			}
-			w.Context.Indexes.RolesIndex[r.Name] = RoleSummary{
-				Name:        r.Name,
-				Label:       r.Label,
-				Description: r.Description,
-			}
+			indexes.RolesIndex[summary.Name] = summary
 		}
 	}
 
 	// Rebuild preferences index
 	preferencesDir := filepath.Join(w.RootDir, "preferences")
-	files, err = os.ReadDir(preferencesDir)
+	files, err = w.storage.ReadDir(preferencesDir)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to read preferences directory for rebuilding index: %w", err)
 	}
 	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
-			prefPath := filepath.Join(preferencesDir, file.Name())
-			data, err := os.ReadFile(prefPath)
+			summary, err := w.readPreferenceSummary(filepath.Join(preferencesDir, file.Name()))
 			if err != nil {
-				w.logAction(fmt.Sprintf("Warning: Could not read preference file '%s' during index rebuild: %v\n", prefPath, err))
-				continue
-			}
-			var p Preference
-			if err := json.Unmarshal(data, &p); err != nil {
-				w.logAction(fmt.Sprintf("Warning: Could not parse preference from '%s' during index rebuild: %v\n", prefPath, err))
+				w.logAction(fmt.Sprintf("Warning: Could not index preference during index rebuild: %v\n", err))
 				continue
-				}
-			snippet := p.Content
-			if len(snippet) > 100 { // Limit snippet length for snippet
-				snippet = snippet[:100] + "..."
-			}
-			w.Context.Indexes.PreferencesIndex[p.ID] = PreferenceSummary{
-				ID:             p.ID,
-				Timestamp:      p.Timestamp,
-				ContentSnippet: snippet,
 			}
+			indexes.PreferencesIndex[summary.ID] = summary
 		}
 	}
 
-	// After rebuilding, save the context to persist the new indexes
+	w.Context.Indexes = indexes
+	// After rebuilding, save the context, which also drops the indexes older
+	// versions stored in `context.json`
 	return w.saveContext(w.Context)
 }
 
@@ -419,9 +509,11 @@ func (w *Workspace) rebuildIndexes() error {
 // This method can be called by the user of the package if manual changes to artifact files (roles, preferences, archived sessions)
 // are suspected or have occurred outside of the package's direct API calls, to synchronize the in-memory state.
 // It performs a synchronous operation. For non-blocking behavior, call it within a goroutine from your application.
-func (w *Workspace) RefreshIndexes() error {
+// Long-running callers can instead use `Watch` and `ApplyChange` to pick up such changes as they happen.
+// The scan stops with ctx's error when ctx is done, leaving the old indexes in place.
+func (w *Workspace) RefreshIndexes(ctx context.Context) error {
 	w.logAction("Refreshing workspace indexes initiated.")
-	if err := w.rebuildIndexes(); err != nil {
+	if err := w.rebuildIndexes(ctx); err != nil {
 		return fmt.Errorf("failed to refresh indexes: %w", err)
 	}
 	return w.logAction("Workspace indexes refreshed successfully.")
@@ -436,7 +528,7 @@ func (w *Workspace) RefreshIndexes() error {
 // or if the specified role cannot be found, the `DefaultRole` from settings will be used.
 //
 // This method encapsulates the common pattern of ensuring an active session is always available.
-func (w *Workspace) GetSession(defaultLabel string, defaultRoleName string) (*Session, error) {
+func (w *Workspace) GetSession(ctx context.Context, defaultLabel string, defaultRoleName string) (*Session, error) {
 	session, err := w.GetActiveSession()
 	if err != nil {
 		return nil, fmt.Errorf("error checking for active session: %w", err)
@@ -444,7 +536,7 @@ func (w *Workspace) GetSession(defaultLabel string, defaultRoleName string) (*Se
 
 	if session == nil {
 		// StartSession handles default role fallback if defaultRoleName is empty or invalid
-		newSession, createErr := w.StartSession(defaultLabel, defaultRoleName)
+		newSession, createErr := w.StartSession(ctx, defaultLabel, defaultRoleName)
 		if createErr != nil {
 			return nil, fmt.Errorf("failed to create new session: %w", createErr)
 		}
@@ -464,12 +556,12 @@ func (w *Workspace) GetSession(defaultLabel string, defaultRoleName string) (*Se
 //
 // The new session is initialized with a unique ID, a human-readable label,
 // the determined role, and current metadata. The active session data is saved to `session.json`.
-func (w *Workspace) StartSession(label string, desiredRoleName string) (*Session, error) {
+func (w *Workspace) StartSession(ctx context.Context, label string, desiredRoleName string) (*Session, error) {
 	sessionPath := filepath.Join(w.RootDir, "session.json")
 
 	// Archive existing session if present
-	if _, err := os.Stat(sessionPath); err == nil {
-		if err := w.EndSession(); err != nil { // EndSession will update the index
+	if _, err := w.storage.Stat(sessionPath); err == nil {
+		if err := w.EndSession(ctx); err != nil { // EndSession will update the index
 			return nil, fmt.Errorf("failed to archive existing session: %w", err)
 		}
 	} else if !os.IsNotExist(err) {
@@ -480,9 +572,8 @@ func (w *Workspace) StartSession(label string, desiredRoleName string) (*Session
 	// Determine which role to use
 	roleToUse := w.Context.Settings.DefaultRole
 	if desiredRoleName != "" {
-		// Check if the desired role exists in our index
-		_, found := w.Context.Indexes.RolesIndex[desiredRoleName]
-		if found {
+		// Check if the desired role exists in the project or global workspace
+		if w.roleExists(desiredRoleName) {
 			roleToUse = desiredRoleName
 		} else {
 			// Log a warning if the desired role wasn't found and fallback to default
@@ -499,19 +590,20 @@ func (w *Workspace) StartSession(label string, desiredRoleName string) (*Session
 	}
 
 	// Create new session
-	now := time.Now()
+	now := w.now()
 	session := &Session{
 		ID:      uuid.New().String(),
 		Label:   label,
 		Role:    role,
 		Sources: []string{},
+		Scope:   w.scope,
 		Chat:    []Chat{},
 		Metadata: Metadata{
 			CreatedAt:       now,
-			Priority:        "medium",           // Example default priority
-			SessionDuration: "3600",             // Example default: 1 hour in seconds as string
+			Priority:        PriorityMedium,
+			SessionDuration: "3600", // Example default: 1 hour in seconds as string
 			LastUpdated:     now,
-			ArchiveAfter:    now.Add(7 * 24 * time.Hour), // Automatically archive after 7 days
+			ArchiveAfter:    now.Add(defaultArchiveAfter), // Automatically archive after 7 days
 		},
 	}
 	if err := w.saveSession(*session); err != nil {
@@ -521,17 +613,22 @@ func (w *Workspace) StartSession(label string, desiredRoleName string) (*Session
 	if err := w.logAction(fmt.Sprintf("Started session %s with label '%s' and role '%s'", session.ID, session.Label, role.Name)); err != nil {
 		return nil, fmt.Errorf("failed to log session start: %w", err)
 	}
+	w.emit(Event{Type: EventSessionStarted, SessionID: session.ID, Label: session.Label})
 
 	return session, nil
 }
 
 // EndSession archives the current active session.
-// The `session.json` file is moved to the `sessions/` subdirectory (named `sessions/<id>.json`),
-// and its summary is added to the `ArchivedSessions` index in the `Context`.
+// The `session.json` file is moved to the `sessions/` subdirectory, named according to
+// `Settings.ArchiveNamePattern` (see `archiveFileName`), and its summary is added to the
+// `ArchivedSessions` index in the `Context`.
 // The `session.json` file is then removed. If no active session exists, the method does nothing.
-func (w *Workspace) EndSession() error {
+// Archives never overwrite another session's archive, and an earlier archive of the same
+// session is only replaced by a continuation of it; otherwise an `*ArchiveConflictError`
+// is returned and the session stays active (see `archiveTarget`).
+func (w *Workspace) EndSession(ctx context.Context) error {
 	sessionPath := filepath.Join(w.RootDir, "session.json")
-	if _, err := os.Stat(sessionPath); os.IsNotExist(err) {
+	if _, err := w.storage.Stat(sessionPath); os.IsNotExist(err) {
 		return nil // No active session to archive, gracefully exit
 	}
 
@@ -540,34 +637,34 @@ func (w *Workspace) EndSession() error {
 		return fmt.Errorf("failed to load session for archiving: %w", err)
 	}
 
-	// Save to sessions/<id>.json
-	archivePath := filepath.Join(w.RootDir, "sessions", fmt.Sprintf("%s.json", session.ID))
-	if err := w.writeJSON(archivePath, session); err != nil {
-		return fmt.Errorf("failed to archive session %s: %w", session.ID, err)
+	// Save to sessions/ using the configured archive naming pattern
+	if err := w.writeArchive(session); err != nil {
+		return err
 	}
 
 	// Remove session.json
-	if err := os.Remove(sessionPath); err != nil {
+	if err := w.storage.Remove(sessionPath); err != nil {
 		return fmt.Errorf("failed to remove active session file %s after archiving: %w", sessionPath, err)
 	}
-
-	// Add to archived sessions index
-	w.Context.Indexes.ArchivedSessions[session.ID] = SessionSummary{
-		ID:        session.ID,
-		Label:     session.Label,
-		RoleName:  session.Role.Name,
-		CreatedAt: session.Metadata.CreatedAt,
-		LastUpdated: session.Metadata.LastUpdated,
+	if err := w.recordRemove(sessionPath); err != nil {
+		return fmt.Errorf("failed to update manifest after archiving session: %w", err)
+	}
+	if err := w.clearSessionState(); err != nil {
+		return err
 	}
+
+	// writeArchive added the session to the archived sessions index
 	if err := w.saveContext(w.Context); err != nil {
 		return fmt.Errorf("failed to update context after archiving session: %w", err)
 	}
+	w.emit(w.archivedEvent(session))
 
 	return w.logAction(fmt.Sprintf("Archived session %s", session.ID))
 }
 
 // AddSource adds a source file path to the `Sources` list of the current active session.
 // It validates that the source file exists and ensures no duplicate paths are added.
+// The path is recorded relative to the project root, with forward slashes (see `sourceRef`).
 // The session's `LastUpdated` timestamp is updated, and the session is saved back to disk.
 func (w *Workspace) AddSource(sourcePath string) error {
 	session, err := w.loadSession(); // loadSession handles Role hydration
@@ -576,50 +673,59 @@ func (w *Workspace) AddSource(sourcePath string) error {
 	}
 
 	// Validate source path (basic check for existence)
-	// Consider making this path absolute or relative to RootDir for consistency if not already.
 	if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
 		return fmt.Errorf("source file %s does not exist: %w", sourcePath, err)
 	} else if err != nil {
 		return fmt.Errorf("failed to stat source file %s: %w", sourcePath, err)
 	}
+	if !w.InScope(sourcePath) {
+		return fmt.Errorf("source file %s is outside the scope %s", sourcePath, w.scope)
+	}
 
-	// Add source if not already present
+	ref, err := w.sourceRef(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	// Add source if not already present, however it was written
 	for _, src := range session.Sources {
-		if src == sourcePath {
+		if filepath.Clean(w.sourcePath(src)) == filepath.Clean(w.sourcePath(ref)) {
 			return nil // Source already added, no action needed
 		}
 	}
-	session.Sources = append(session.Sources, sourcePath)
-	session.Metadata.LastUpdated = time.Now()
+	session.Sources = append(session.Sources, ref)
+	session.Metadata.LastUpdated = w.now()
 
 	if err := w.saveSession(*session); err != nil {
 		return fmt.Errorf("failed to save session after adding source %s: %w", sourcePath, err)
 	}
 
-	return w.logAction(fmt.Sprintf("Added source %s to session %s", sourcePath, session.ID))
+	return w.logAction(fmt.Sprintf("Added source %s to session %s", ref, session.ID))
 }
 
 // AddInteraction adds a user-AI interaction to the `Chat` history of the current active session.
-// A new `Chat` entry is created with the provided user prompt and AI response,
+// A new `Chat` entry is created with the provided user message and AI response,
 // and the session's `LastUpdated` timestamp is updated. The session is saved back to disk.
-func (w *Workspace) AddInteraction(userPrompt, aiResponse string) error {
+// If the message has no `Timestamp` it is stamped with the current time, and if the
+// response has none, one is assigned just after the message's. It returns the new entry.
+func (w *Workspace) AddInteraction(message SavedMessage, response SavedResponse) (Chat, error) {
 	session, err := w.loadSession(); // loadSession handles Role hydration
 	if err != nil {
-		return fmt.Errorf("failed to load session to add interaction: %w", err)
+		return Chat{}, fmt.Errorf("failed to load session to add interaction: %w", err)
 	}
 
 	// Create new chat entry
-	now := time.Now()
+	now := w.now()
+	if message.Timestamp.IsZero() {
+		message.Timestamp = now
+	}
+	if response.Timestamp.IsZero() {
+		response.Timestamp = message.Timestamp.Add(1 * time.Second) // Slight offset for response timestamp
+	}
 	chat := Chat{
-		ID: uuid.New().String(),
-		Message: SavedMessage{
-			Content:   userPrompt,
-			Timestamp: now,
-		},
-		Response: SavedResponse{
-			Content:   aiResponse,
-			Timestamp: now.Add(1 * time.Second), // Slight offset for response timestamp
-		},
+		ID:       uuid.New().String(),
+		Message:  message,
+		Response: response,
 	}
 
 	// Append chat and update metadata
@@ -627,10 +733,74 @@ func (w *Workspace) AddInteraction(userPrompt, aiResponse string) error {
 	session.Metadata.LastUpdated = now
 
 	if err := w.saveSession(*session); err != nil {
-		return fmt.Errorf("failed to save session after adding interaction: %w", err)
+		return Chat{}, fmt.Errorf("failed to save session after adding interaction: %w", err)
+	}
+
+	return chat, w.logAction(fmt.Sprintf("Added interaction (chat ID: %s) to session %s", chat.ID, session.ID))
+}
+
+// RemoveInteraction removes the chat entry chatID from the active or archived
+// session sessionID, such as to undo an exchange that sent the conversation
+// astray, or to delete a turn from the history. A compaction summary that
+// covered the entry is discarded so it can be regenerated without it. A live
+// chat of the active session must be reopened to forget the exchange.
+func (w *Workspace) RemoveInteraction(sessionID, chatID string) error {
+	found := false
+	err := w.updateSession(sessionID, func(session *Session) {
+		for i, c := range session.Chat {
+			if c.ID != chatID {
+				continue
+			}
+			session.Chat = append(session.Chat[:i], session.Chat[i+1:]...)
+			if session.Compaction != nil && i < session.Compaction.Turns {
+				session.Compaction = nil
+			}
+			session.Metadata.LastUpdated = w.now()
+			found = true
+			return
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove interaction: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: %s in session %s", ErrChatNotFound, chatID, sessionID)
+	}
+	return w.logAction(fmt.Sprintf("Removed interaction (chat ID: %s) from session %s", chatID, sessionID))
+}
+
+// ReplaceResponse replaces the response of the chat entry with the given ID in
+// the active session, such as when another candidate reply is kept. The
+// replaced response's timestamp is kept if response has none.
+func (w *Workspace) ReplaceResponse(chatID string, response SavedResponse) error {
+	session, err := w.loadSession()
+	if err != nil {
+		return fmt.Errorf("failed to load session to replace response: %w", err)
+	}
+	index := -1
+	for i, c := range session.Chat {
+		if c.ID == chatID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("%w: %s in session %s", ErrChatNotFound, chatID, session.ID)
+	}
+
+	if response.Timestamp.IsZero() {
+		response.Timestamp = session.Chat[index].Response.Timestamp
+	}
+	session.Chat[index].Response = response
+	if session.Compaction != nil && index < session.Compaction.Turns {
+		session.Compaction = nil
+	}
+	session.Metadata.LastUpdated = w.now()
+	if err := w.saveSession(*session); err != nil {
+		return fmt.Errorf("failed to save session after replacing response: %w", err)
 	}
 
-	return w.logAction(fmt.Sprintf("Added interaction (chat ID: %s) to session %s", chat.ID, session.ID))
+	return w.logAction(fmt.Sprintf("Replaced response (chat ID: %s) in session %s", chatID, session.ID))
 }
 
 // SwitchRole changes the AI role for the current active session.
@@ -650,7 +820,7 @@ func (w *Workspace) SwitchRole(roleName string) error {
 
 	// Update session role and metadata
 	session.Role = role
-	session.Metadata.LastUpdated = time.Now()
+	session.Metadata.LastUpdated = w.now()
 
 	if err := w.saveSession(*session); err != nil {
 		return fmt.Errorf("failed to save session after switching to role %s: %w", roleName, err)
@@ -666,8 +836,7 @@ func (w *Workspace) SwitchRole(roleName string) error {
 func (w *Workspace) GetActiveSession() (*Session, error) {
 	session, err := w.loadSession()
 	if err != nil {
-		// Specifically check for the "no active session found" error by message content
-		if os.IsNotExist(err) || strings.Contains(err.Error(), "no active session found") {
+		if errors.Is(err, ErrNoActiveSession) {
 			return nil, nil // No active session, not an error state for this public API
 		}
 		return nil, fmt.Errorf("failed to get active session: %w", err)
@@ -679,23 +848,23 @@ func (w *Workspace) GetActiveSession() (*Session, error) {
 // If an active session currently exists, it is first archived using `EndSession()`.
 // The specified archived session file is read, parsed, made the new active session,
 // its summary is removed from the `ArchivedSessions` index, and the original archived file is optionally removed.
-func (w *Workspace) ResumeArchivedSession(sessionID string) (*Session, error) {
+func (w *Workspace) ResumeArchivedSession(ctx context.Context, sessionID string) (*Session, error) {
 	// First, archive any currently active session to ensure a clean state
-	if err := w.EndSession(); err != nil {
+	if err := w.EndSession(ctx); err != nil {
 		return nil, fmt.Errorf("failed to archive current session before resuming archived one: %w", err)
 	}
 
-	archivePath := filepath.Join(w.RootDir, "sessions", fmt.Sprintf("%s.json", sessionID))
+	archivePath := w.archivedSessionPath(sessionID)
 
 	// Check if the archived session file exists
-	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("archived session with ID '%s' not found at '%s': %w", sessionID, archivePath, err)
+	if _, err := w.storage.Stat(archivePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: archived session '%s' has no file at '%s'", ErrSessionNotFound, sessionID, archivePath)
 	} else if err != nil {
 		return nil, fmt.Errorf("failed to check archived session file '%s': %w", archivePath, err)
 	}
 
 	// Load the archived session data
-	data, err := os.ReadFile(archivePath)
+	data, err := w.storage.ReadFile(archivePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read archived session file '%s': %w", archivePath, err)
 	}
@@ -725,9 +894,11 @@ func (w *Workspace) ResumeArchivedSession(sessionID string) (*Session, error) {
 	}
 
 	// Optionally, remove the original archived file if the intent is to "move" it, not copy.
-	if err := os.Remove(archivePath); err != nil {
+	if err := w.storage.Remove(archivePath); err != nil {
 		// Log this as a warning, but don't fail the entire resume operation as the active session is now set.
 		w.logAction(fmt.Sprintf("Warning: Failed to remove original archived session file '%s' after resuming: %v\n", archivePath, err))
+	} else if err := w.recordRemove(archivePath); err != nil {
+		w.logAction(fmt.Sprintf("Warning: Failed to update manifest after resuming session '%s': %v\n", sessionID, err))
 	}
 
 	// Log the successful resumption of the session
@@ -741,45 +912,62 @@ func (w *Workspace) ResumeArchivedSession(sessionID string) (*Session, error) {
 // ListArchivedSessions returns a slice of all archived session summaries.
 // This data is retrieved directly from the in-memory `ArchivedSessions` index in the `Context`,
 // making it a very efficient operation as it avoids reading individual session files from disk.
+// A scoped workspace lists only the sessions within its scope (see `WithScope`).
+// Pinned sessions come first, then sessions by descending priority, each
+// group most recently updated first. `ListArchivedSessionsWith` filters,
+// sorts, and pages them.
 func (w *Workspace) ListArchivedSessions() ([]SessionSummary, error) {
 	// Convert map values to slice
 	sessions := make([]SessionSummary, 0, len(w.Context.Indexes.ArchivedSessions))
 	for _, s := range w.Context.Indexes.ArchivedSessions {
-		sessions = append(sessions, s)
+		if w.sessionInScope(s.Scope) {
+			sessions = append(sessions, s)
+		}
 	}
+	sortSessions(sessions)
 	return sessions, nil
 }
 
 // ListRoles returns a slice of all role summaries.
-// This data is retrieved directly from the in-memory `RolesIndex` in the `Context`,
+// Project roles are retrieved directly from the in-memory `RolesIndex` in the `Context`,
 // providing quick access to role metadata without reading full role definitions from disk.
+// Global roles not overridden by a project role are included with `Global` set.
+// Roles are sorted by name; `ListRolesWith` filters and pages them.
 func (w *Workspace) ListRoles() ([]RoleSummary, error) {
 	roles := make([]RoleSummary, 0, len(w.Context.Indexes.RolesIndex))
 	for _, r := range w.Context.Indexes.RolesIndex {
 		roles = append(roles, r)
 	}
+	roles = append(roles, w.globalRoles()...)
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
 	return roles, nil
 }
 
 // ListPreferences returns a slice of all preference summaries.
-// This data is retrieved directly from the in-memory `PreferencesIndex` in the `Context`,
-// enabling efficient listing of user preferences.
+// Project preferences are retrieved directly from the in-memory `PreferencesIndex` in the `Context`,
+// enabling efficient listing of user preferences. Global preferences not overridden by a
+// project preference with the same ID are included with `Global` set.
+// Preferences are sorted by ID; `ListPreferencesWith` filters and pages them.
 func (w *Workspace) ListPreferences() ([]PreferenceSummary, error) {
 	preferences := make([]PreferenceSummary, 0, len(w.Context.Indexes.PreferencesIndex))
 	for _, p := range w.Context.Indexes.PreferencesIndex {
 		preferences = append(preferences, p)
 	}
+	preferences = append(preferences, w.globalPreferences()...)
+	sort.Slice(preferences, func(i, j int) bool { return preferences[i].ID < preferences[j].ID })
 	return preferences, nil
 }
 
 
-// loadRole loads a role by its name from `roles/<name>.json`.
+// loadRole loads a role by its name from `roles/<name>.json`, falling back to
+// the global workspace if the project does not define it.
 // This is an internal helper function.
 func (w *Workspace) loadRole(name string) (Role, error) {
-	rolePath := filepath.Join(w.RootDir, "roles", fmt.Sprintf("%s.json", name))
 	var role Role
-	data, err := os.ReadFile(rolePath)
-	if err != nil {
+	data, err := w.readArtifact("roles", name)
+	if os.IsNotExist(err) {
+		return Role{}, fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+	} else if err != nil {
 		return Role{}, fmt.Errorf("failed to read role file %s: %w", name, err)
 	}
 	if err := json.Unmarshal(data, &role); err != nil {
@@ -792,6 +980,9 @@ func (w *Workspace) loadRole(name string) (Role, error) {
 // After saving the file, it updates the `PreferencesIndex` in the `Context`
 // and persists the updated `Context` to disk.
 func (w *Workspace) SavePreference(pref Preference) error {
+	if err := validateName("preference", pref.ID); err != nil {
+		return err
+	}
 	prefPath := filepath.Join(w.RootDir, "preferences", fmt.Sprintf("%s.json", pref.ID))
 	if err := w.writeJSON(prefPath, pref); err != nil {
 		return fmt.Errorf("failed to save preference %s: %w", pref.ID, err)
@@ -812,13 +1003,15 @@ func (w *Workspace) SavePreference(pref Preference) error {
 	return w.logAction(fmt.Sprintf("Saved preference %s", pref.ID))
 }
 
-// LoadPreference loads a single preference by its unique ID from `preferences/<id>.json`.
+// LoadPreference loads a single preference by its unique ID from `preferences/<id>.json`,
+// falling back to the global workspace if the project does not define it.
 // It returns a pointer to the `Preference` struct or an error if the file
 // cannot be read or parsed.
 func (w *Workspace) LoadPreference(id string) (*Preference, error) {
-	prefPath := filepath.Join(w.RootDir, "preferences", fmt.Sprintf("%s.json", id))
-	data, err := os.ReadFile(prefPath)
-	if err != nil {
+	data, err := w.readArtifact("preferences", id)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrPreferenceNotFound, id)
+	} else if err != nil {
 		return nil, fmt.Errorf("failed to read preference %s: %w", id, err)
 	}
 	var pref Preference
@@ -828,12 +1021,38 @@ func (w *Workspace) LoadPreference(id string) (*Preference, error) {
 	return &pref, nil
 }
 
-// DeletePreference deletes a preference file from `preferences/<id>.json`
-// and removes its entry from the `PreferencesIndex` in the `Context`.
-// The updated `Context` is then saved to disk.
+// PreferencesForRole loads the full content of every project and global preference
+// that applies to the given role, ordered from oldest to newest by `Timestamp`.
+// Preferences whose files cannot be loaded are logged and skipped.
+func (w *Workspace) PreferencesForRole(roleName string) ([]Preference, error) {
+	summaries, err := w.ListPreferences()
+	if err != nil {
+		return nil, err
+	}
+	preferences := make([]Preference, 0, len(summaries))
+	for _, summary := range summaries {
+		id := summary.ID
+		pref, err := w.LoadPreference(id)
+		if err != nil {
+			w.logAction(fmt.Sprintf("Warning: Could not load preference '%s' for role '%s': %v\n", id, roleName, err))
+			continue
+		}
+		if pref.AppliesTo(roleName) {
+			preferences = append(preferences, *pref)
+		}
+	}
+	sort.Slice(preferences, func(i, j int) bool {
+		return preferences[i].Timestamp.Before(preferences[j].Timestamp)
+	})
+	return preferences, nil
+}
+
+// DeletePreference moves a preference file from `preferences/<id>.json` to
+// the trash (see `Restore`) and removes its entry from the `PreferencesIndex`
+// in the `Context`. The updated `Context` is then saved to disk.
 func (w *Workspace) DeletePreference(id string) error {
 	prefPath := filepath.Join(w.RootDir, "preferences", fmt.Sprintf("%s.json", id))
-	if err := os.Remove(prefPath); err != nil && !os.IsNotExist(err) {
+	if err := w.moveToTrash(TrashPreference, id, prefPath); err != nil {
 		return fmt.Errorf("failed to delete preference file %s: %w", id, err)
 	}
 
@@ -852,11 +1071,11 @@ func (w *Workspace) DeletePreference(id string) error {
 // `Session` struct is complete.
 func (w *Workspace) loadSession() (*Session, error) {
 	sessionPath := filepath.Join(w.RootDir, "session.json")
-	if _, err := os.Stat(sessionPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("no active session found at %s: %w", sessionPath, err)
+	if _, err := w.storage.Stat(sessionPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w at %s", ErrNoActiveSession, sessionPath)
 	}
 
-	data, err := os.ReadFile(sessionPath)
+	data, err := w.storage.ReadFile(sessionPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read active session file %s: %w", sessionPath, err)
 	}
@@ -878,11 +1097,12 @@ func (w *Workspace) loadSession() (*Session, error) {
 	return &session, nil
 }
 
-// loadContext loads the `context.json` file into the Workspace's `Context` field.
+// loadContext loads the `context.json` file into the Workspace's `Context` field,
+// together with the machine-local settings in `local.json` (see `localSettings`).
 // This is an internal helper function.
 func (w *Workspace) loadContext() error {
 	contextPath := filepath.Join(w.RootDir, "context.json")
-	data, err := os.ReadFile(contextPath)
+	data, err := w.storage.ReadFile(contextPath)
 	if err != nil {
 		return fmt.Errorf("failed to read context: %w", err)
 	}
@@ -891,20 +1111,69 @@ func (w *Workspace) loadContext() error {
 		return fmt.Errorf("failed to parse context: %w", err)
 	}
 	w.Context = context
+	return w.loadLocal()
+}
+
+// Reload re-reads `context.json` and rebuilds the indexes, picking up changes
+// made by other processes sharing the workspace, such as terminals attached to
+// a `nani serve` daemon.
+func (w *Workspace) Reload(ctx context.Context) error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := w.loadContext(); err != nil {
+		return err
+	}
+	if err := w.rebuildIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild indexes: %w", err)
+	}
 	return nil
 }
 
-// saveContext saves the current Workspace's `Context` to `context.json`.
+// SocketPath returns the path of the Unix socket a `nani serve --socket`
+// daemon listens on for this workspace.
+func (w *Workspace) SocketPath() string {
+	return filepath.Join(w.RootDir, "nani.sock")
+}
+
+// saveContext saves the current Workspace's `Context` to `context.json`, and
+// its machine-local settings to `local.json` (see `localSettings`).
 // This is an internal helper function, typically called after any modifications
-// to the `Context` (including its indexes) to persist changes.
+// to the `Context` to persist changes.
+// The write is deferred: a snapshot of context is taken now and written within
+// `contextFlushDelay` together with any later changes (see `Flush`).
 func (w *Workspace) saveContext(context Context) error {
-	return w.writeJSON(filepath.Join(w.RootDir, "context.json"), context)
+	shared, local := splitLocal(context)
+	data, err := encodeJSON(shared)
+	if err != nil {
+		return fmt.Errorf("failed to encode context: %w", err)
+	}
+	localData, err := encodeJSON(local)
+	if err != nil {
+		return fmt.Errorf("failed to encode local settings: %w", err)
+	}
+	p := w.persist
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = data
+	p.pendingLocal = localData
+	w.scheduleFlush()
+	return nil
 }
 
 // saveRole saves an AI role configuration to `roles/<name>.json`.
 // After saving the role file, it updates the `RolesIndex` in the `Context`
 // and persists the updated `Context` to disk.
 func (w *Workspace) saveRole(role Role) error {
+	if err := validateName("role", role.Name); err != nil {
+		return err
+	}
+	if err := validateRoleSchema(role.Schema); err != nil {
+		return err
+	}
+	if err := validatePostProcess(role.PostProcess); err != nil {
+		return err
+	}
 	rolePath := filepath.Join(w.RootDir, "roles", fmt.Sprintf("%s.json", role.Name))
 	if err := w.writeJSON(rolePath, role); err != nil {
 		return fmt.Errorf("failed to save role %s: %w", role.Name, err)
@@ -922,11 +1191,16 @@ func (w *Workspace) saveRole(role Role) error {
 	return w.logAction(fmt.Sprintf("Saved role %s", role.Name))
 }
 
-// DeleteRole deletes a role file from `roles/<name>.json` and removes its entry
-// from the `RolesIndex` in the `Context`. The updated `Context` is then saved to disk.
+// DeleteRole moves a role file from `roles/<name>.json` to the trash (see
+// `Restore`) and removes its entry from the `RolesIndex` in the `Context`.
+// The updated `Context` is then saved to disk. Roles of the global workspace
+// cannot be deleted this way and yield `ErrRoleNotFound`.
 func (w *Workspace) DeleteRole(name string) error {
+	if _, ok := w.Context.Indexes.RolesIndex[name]; !ok {
+		return fmt.Errorf("%w: %s is not a workspace role", ErrRoleNotFound, name)
+	}
 	rolePath := filepath.Join(w.RootDir, "roles", fmt.Sprintf("%s.json", name))
-	if err := os.Remove(rolePath); err != nil && !os.IsNotExist(err) {
+	if err := w.moveToTrash(TrashRole, name, rolePath); err != nil {
 		return fmt.Errorf("failed to delete role file %s: %w", name, err)
 	}
 
@@ -947,20 +1221,38 @@ func (w *Workspace) saveSession(session Session) error {
 
 // writeJSON is a utility helper function that writes data to a JSON file.
 // It ensures proper indentation (2 spaces) and file permissions (0644 - owner rw, group r, others r).
+// Every write is recorded in the integrity manifest so external edits can be detected.
 // This is an internal helper function used by various save operations.
 func (w *Workspace) writeJSON(path string, data interface{}) error {
-	// 0644: owner rw, group r, others r
-	file, err := os.Create(path)
+	content, err := encodeJSON(data)
 	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", path, err)
+		return fmt.Errorf("failed to encode JSON for %s: %w", path, err)
 	}
-	defer file.Close() // Ensure the file is closed even if an error occurs
+	return w.writeFile(path, content)
+}
 
-	encoder := json.NewEncoder(file)
+// encodeJSON encodes data the way workspace artifacts are stored: indented
+// with 2 spaces and ending in a newline.
+func encodeJSON(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
 	encoder.SetIndent("", "  ") // Use 2 spaces for indentation
 	if err := encoder.Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFile writes encoded artifact content to path and records it in the
+// integrity manifest.
+func (w *Workspace) writeFile(path string, content []byte) error {
+	// 0644: owner rw, group r, others r
+	if err := w.storage.WriteFile(path, content, 0644); err != nil {
 		return fmt.Errorf("failed to write JSON to %s: %w", path, err)
 	}
+	if err := w.recordWrite(path, content); err != nil {
+		return fmt.Errorf("failed to update manifest for %s: %w", path, err)
+	}
 	return nil
 }
 
@@ -968,18 +1260,15 @@ func (w *Workspace) writeJSON(path string, data interface{}) error {
 // Log files are stored in the `logs/` subdirectory, named by date (e.g., `2006-01-02.log`).
 // This is an internal helper function for logging operational events within the workspace.
 func (w *Workspace) logAction(action string) error {
-	logDir := filepath.Join(w.RootDir, "logs")
-	logFile := filepath.Join(logDir, fmt.Sprintf("%s.log", time.Now().Format("2006-01-02"))) // e.g., 2024-07-30.log
-
-	// Open file in append mode, create if it doesn't exist, write-only
-	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+	if w.logger != nil {
+		w.logToLogger(action)
+		return nil
 	}
-	defer file.Close()
+	logDir := filepath.Join(w.RootDir, "logs")
+	logFile := filepath.Join(logDir, fmt.Sprintf("%s.log", w.now().Format("2006-01-02"))) // e.g., 2024-07-30.log
 
-	logEntry := fmt.Sprintf("%s: %s\n", time.Now().Format(time.RFC3339), action)
-	if _, err := file.WriteString(logEntry); err != nil {
+	logEntry := fmt.Sprintf("%s: %s\n", w.now().Format(time.RFC3339), action)
+	if err := w.storage.AppendFile(logFile, []byte(logEntry), 0644); err != nil {
 		return fmt.Errorf("failed to write log: %w", err)
 	}
 	return nil
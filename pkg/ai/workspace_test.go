@@ -0,0 +1,20 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRefreshIndexesCancelled(t *testing.T) {
+	w := newTestWorkspace(t)
+	roles := len(w.Context.Indexes.RolesIndex)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if err := w.RefreshIndexes(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RefreshIndexes = %v, want context.Canceled", err)
+	}
+	if got := len(w.Context.Indexes.RolesIndex); got != roles {
+		t.Errorf("cancelled refresh left %d roles indexed, want %d", got, roles)
+	}
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/asaidimu/nani/pkg/server"
+)
+
+// Output formats of `nani ask` and `nani batch`.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// batchResult is one line of `nani batch --output json`.
+type batchResult struct {
+	Prompt string `json:"prompt"`
+	*server.MessageResponse
+	Error string `json:"error,omitempty"`
+}
+
+// runAsk implements `nani ask`, which sends a prompt to the active session and
+// prints the answer. The prompt is read from standard input when it is "-" or
+// not given.
+func runAsk(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("ask", flag.ContinueOnError)
+	output := fs.String("output", outputText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	prompt := strings.Join(fs.Args(), " ")
+	if prompt == "" || prompt == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt: %w", err)
+		}
+		prompt = string(data)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return errors.New("usage: nani ask [--output text|json] <prompt>")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client, err := openChat(ctx, ws)
+	if err != nil {
+		return err
+	}
+	response, err := sendComplete(ctx, client, prompt)
+	if errors.Is(err, ai.ErrResponseIncomplete) && *output == outputText {
+		fmt.Println(strings.TrimSpace(response.Content))
+	}
+	if err != nil {
+		return err
+	}
+	reportSecrets(response.Secrets)
+	if *output == outputJSON {
+		return json.NewEncoder(os.Stdout).Encode(server.NewMessageResponse(response))
+	}
+	fmt.Println(strings.TrimSpace(response.Content))
+	return nil
+}
+
+// runBatch implements `nani batch`, which sends each non-empty line of a file
+// (or standard input, for "-") to the active session in turn. With
+// --output json it prints one JSON object per prompt (JSON Lines), including
+// failed prompts; a failure does not stop the batch.
+func runBatch(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	output := fs.String("output", outputText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: nani batch [--output text|json] <file|->")
+	}
+	prompts, err := readPrompts(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client, err := openChat(ctx, ws)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	failed := 0
+	for _, prompt := range prompts {
+		if ctx.Err() != nil {
+			return errors.New("interrupted")
+		}
+		response, err := sendComplete(ctx, client, prompt)
+		if err != nil {
+			failed++
+		}
+		reportSecrets(response.Secrets)
+		switch {
+		case *output == outputJSON && err != nil:
+			encoder.Encode(batchResult{Prompt: prompt, Error: err.Error()})
+		case *output == outputJSON:
+			reply := server.NewMessageResponse(response)
+			encoder.Encode(batchResult{Prompt: prompt, MessageResponse: &reply})
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", prompt, err)
+		default:
+			fmt.Printf("## %s\n\n%s\n\n", prompt, strings.TrimSpace(response.Content))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d prompts failed", failed, len(prompts))
+	}
+	return nil
+}
+
+// maxContinues is how many times `sendComplete` asks the model to finish a
+// reply that was cut off.
+const maxContinues = 2
+
+// sendComplete sends prompt to the active session. Replies cut off by the
+// output token limit or a failure mid-stream are continued, up to
+// maxContinues times, when the client supports it; if the reply is still
+// incomplete, the longest partial reply is returned with the error.
+func sendComplete(ctx context.Context, client ai.AIClient, prompt string) (ai.Response, error) {
+	response, err := client.SendMessage(ctx, ai.SavedMessage{Content: prompt}, nil, true)
+	continuer, ok := client.(ai.ResponseContinuer)
+	for i := 0; ok && i < maxContinues && errors.Is(err, ai.ErrResponseIncomplete); i++ {
+		fmt.Fprintf(os.Stderr, "Warning: %v; continuing\n", err)
+		next, nextErr := continuer.ContinueResponse(ctx, nil)
+		if nextErr != nil && !errors.Is(nextErr, ai.ErrResponseIncomplete) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to continue the reply: %v\n", nextErr)
+			break
+		}
+		response, err = next, nextErr
+	}
+	return response, err
+}
+
+// reportSecrets warns on standard error about secrets found in a prompt,
+// which were masked or sent depending on the workspace's redaction setting.
+func reportSecrets(secrets []ai.SecretFinding) {
+	for _, f := range secrets {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", f)
+	}
+}
+
+// checkOutput validates an --output format.
+func checkOutput(output string) error {
+	if output != outputText && output != outputJSON {
+		return fmt.Errorf("unknown output format '%s', expected %s or %s", output, outputText, outputJSON)
+	}
+	return nil
+}
+
+// readPrompts reads the non-empty lines of path, or of standard input if path
+// is "-".
+func readPrompts(path string) ([]string, error) {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open prompts: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	var prompts []string
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			prompts = append(prompts, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompts: %w", err)
+	}
+	return prompts, nil
+}
+
+// sessionOpener is implemented by AI clients that can open the chat of the
+// active session without sending anything, such as `ai.GeminiAIClient`.
+type sessionOpener interface {
+	OpenSession(ctx context.Context) (*ai.Session, error)
+}
+
+// openChat returns a client ready to send messages to the active session:
+// the workspace daemon if one is running, or a chat of the selected provider
+// opened without a greeting otherwise (see `UsesDaemon`).
+func openChat(ctx context.Context, ws *ai.Workspace) (ai.AIClient, error) {
+	if daemon, err := server.Dial(ws.SocketPath()); err == nil && UsesDaemon() {
+		if _, err := daemon.StartSession(ctx); err != nil {
+			return nil, err
+		}
+		return daemon, nil
+	}
+	client, err := NewChatClient(ws)
+	if err != nil {
+		return nil, err
+	}
+	if opener, ok := client.(sessionOpener); ok {
+		if _, err := opener.OpenSession(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
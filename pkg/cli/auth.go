@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"golang.org/x/term"
+)
+
+// keyringService is the service name API keys are stored under in the OS
+// keyring, with one entry per provider.
+const keyringService = "nani"
+
+// errKeyNotFound is returned by the keyring functions when no key is stored
+// for a provider.
+var errKeyNotFound = errors.New("no key stored in the keyring")
+
+// providerKeyEnv maps the providers that need an API key to the environment
+// variable read when the keyring holds none, as in CI.
+var providerKeyEnv = map[string]string{
+	ai.ProviderGemini: "GEMINI_API_KEY",
+}
+
+// apiKey returns the API key of provider: the one stored with `nani auth
+// login`, or else the provider's environment variable. It returns "" when
+// neither is set.
+func apiKey(provider string) string {
+	if key, err := keyringGet(provider); err == nil {
+		return key
+	}
+	return os.Getenv(providerKeyEnv[provider])
+}
+
+// runAuth implements `nani auth`. Without arguments it shows where the API
+// key of each provider comes from, or that Gemini uses Vertex AI
+// credentials; `login` stores a key, read from the
+// terminal without echo or from standard input, in the OS keyring; `logout`
+// removes it.
+func runAuth(ws *ai.Workspace, args []string) error {
+	if len(args) == 0 || args[0] == "status" {
+		for _, provider := range keyProviders() {
+			source := "not set"
+			if provider == ai.ProviderGemini && ws.Settings().Vertex != nil {
+				source = "Vertex AI (Application Default Credentials)"
+			} else if _, err := keyringGet(provider); err == nil {
+				source = "keyring"
+			} else if os.Getenv(providerKeyEnv[provider]) != "" {
+				source = "environment (" + providerKeyEnv[provider] + ")"
+			}
+			fmt.Printf("  %-10s %s\n", provider, source)
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "login", "logout":
+		provider := ai.ProviderGemini
+		if len(args) > 2 {
+			return fmt.Errorf("usage: nani auth %s [provider]", args[0])
+		} else if len(args) == 2 {
+			provider = args[1]
+		}
+		if _, ok := providerKeyEnv[provider]; !ok {
+			return fmt.Errorf("provider '%s' takes no API key; expected one of %v", provider, keyProviders())
+		}
+		if args[0] == "logout" {
+			if err := keyringDelete(provider); errors.Is(err, errKeyNotFound) {
+				return fmt.Errorf("no %s API key stored in the keyring", provider)
+			} else if err != nil {
+				return err
+			}
+			fmt.Printf("Removed the %s API key from the keyring.\n", provider)
+			return nil
+		}
+		key, err := readAPIKey(provider)
+		if err != nil {
+			return err
+		}
+		if err := keyringSet(provider, key); err != nil {
+			return err
+		}
+		fmt.Printf("Stored the %s API key in the keyring.\n", provider)
+		return nil
+	default:
+		return fmt.Errorf("unknown auth subcommand '%s'", args[0])
+	}
+}
+
+// readAPIKey reads the API key of provider, without echo when standard input
+// is a terminal.
+func readAPIKey(provider string) (string, error) {
+	var key string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Printf("%s API key: ", provider)
+		data, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read API key: %w", err)
+		}
+		key = string(data)
+	} else {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read API key: %w", err)
+		}
+		key = line
+	}
+	if key = strings.TrimSpace(key); key == "" {
+		return "", errors.New("API key is empty")
+	}
+	return key, nil
+}
+
+// keyProviders returns the providers that take an API key, sorted by name.
+func keyProviders() []string {
+	providers := make([]string, 0, len(providerKeyEnv))
+	for provider := range providerKeyEnv {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
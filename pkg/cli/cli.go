@@ -0,0 +1,209 @@
+// Package cli implements nani's non-interactive subcommands, such as
+// `nani roles install`. Each command operates on an initialized workspace
+// and writes its results to standard output.
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// command describes a single top-level subcommand.
+type command struct {
+	name    string                                      // Name used on the command line (e.g., "roles").
+	usage   string                                      // Synopsis of the command and its arguments shown in help output.
+	summary string                                      // One-line description shown in help output.
+	run     func(ws *ai.Workspace, args []string) error // Handler invoked with the remaining arguments.
+
+	// complete returns shell completion candidates for the argument following args,
+	// the arguments already typed after the command name. Nil completes file names.
+	complete func(ws *ai.Workspace, args []string) []string
+	hidden   bool // Whether the command is left out of help output and completion.
+}
+
+// commands returns the table of all registered subcommands.
+func commands() []command {
+	return []command{
+		{name: "init", usage: "init [--yes]", summary: "Detect and confirm the project's name, owner, and repository", run: runInit},
+		{name: "ask", usage: "ask [--output text|json] <prompt>|-", summary: "Send a prompt to the active session and print the answer", run: runAsk},
+		{name: "batch", usage: "batch [--output text|json] <file>|-", summary: "Send each line of a file to the active session in turn", run: runBatch},
+		{name: "roles", usage: "roles [list|search [--registry r] [query]|install [--global] [--registry r] <name>...|delete <name>...]", summary: "Manage workspace roles", run: runRoles,
+			complete: subcommands([]string{"list", "search", "install", "delete"}, func(ws *ai.Workspace, sub string, args []string) []string {
+				switch sub {
+				case "install":
+					return append(completeBuiltinRoles(), "--global", "--registry")
+				case "delete":
+					return completeRoles(ws)
+				}
+				return nil
+			})},
+		{name: "doctor", usage: "doctor [--fix] [--verify] [--parse]", summary: "Check the workspace for problems and repair them", run: runDoctor},
+		{name: "export", usage: "export [--logs] [--cache] <file>", summary: "Write the workspace to a tar.gz archive", run: runExport},
+		{name: "import", usage: "import <file>", summary: "Merge an exported archive into the workspace", run: runImport},
+		{name: "index", usage: "index", summary: "Embed workspace artifacts for semantic search", run: runIndex},
+		{name: "docs", usage: "docs [generate [--role r] [--out dir] [--force] [--jobs n] <pattern>...|status|refresh [--role r] [--jobs n]]", summary: "Generate Markdown docs for source files and keep them fresh", run: runDocs,
+			complete: subcommands([]string{"generate", "status", "refresh"}, nil)},
+		{name: "review", usage: "review [--staged|--range A..B|--pr URL] [--role r] [--jobs n] [--format markdown|sarif|rdjson] [--out file]", summary: "Review a diff or pull request and report findings by severity", run: runReview},
+		{name: "schedule", usage: "schedule [list|add (--cron expr|--on-commit) [--out file] <name> <template>|remove <name>|run [--trigger t] [--watch] [<name>...]|install-hook]", summary: "Run prompt templates on a schedule or after each commit", run: runSchedule,
+			complete: subcommands([]string{"list", "add", "remove", "run", "install-hook"}, func(ws *ai.Workspace, sub string, args []string) []string {
+				switch sub {
+				case "add":
+					// The template follows the schedule's name.
+					if positionals(args, "--cron", "--role", "--input", "--out") == 1 {
+						return completeTemplates(ws)
+					}
+				case "remove", "run":
+					return completeSchedules(ws)
+				}
+				return nil
+			})},
+		{name: "lsp", usage: "lsp", summary: "Run a language server on stdin/stdout for editor plugins", run: runLSP},
+		{name: "serve", usage: "serve [--addr host:port|--socket]", summary: "Serve the workspace and chat over a local HTTP API", run: runServe},
+		{name: "sessions", usage: "sessions [list|resume <id>|end [--copy]|rename <id> <label>|pin <id>|unpin <id>|priority <id> <level>|delete <id>...|export [--format f] [--role r,...] [--pinned] [--since date] [--scrub] <file>]", summary: "List, resume, archive, rename, pin, prioritize, delete, or export sessions", run: runSessions,
+			complete: subcommands([]string{"list", "resume", "end", "rename", "pin", "unpin", "priority", "delete", "export"}, func(ws *ai.Workspace, sub string, args []string) []string {
+				switch {
+				case sub == "resume" && len(args) == 0, sub == "delete":
+					return completeSessions(ws, false)
+				case (sub == "rename" || sub == "pin" || sub == "unpin" || sub == "priority") && len(args) == 0:
+					return completeSessions(ws, true)
+				case sub == "end" && len(args) == 0:
+					return []string{"--copy"}
+				case sub == "priority" && len(args) == 1:
+					return []string{string(ai.PriorityLow), string(ai.PriorityMedium), string(ai.PriorityHigh)}
+				}
+				return nil
+			})},
+		{name: "memories", usage: "memories [list|approve <n>...|all|reject <n>...|all|extract <session-id>]", summary: "Review facts and preferences extracted from ended sessions", run: runMemories,
+			complete: subcommands([]string{"list", "approve", "reject", "extract"}, func(ws *ai.Workspace, sub string, args []string) []string {
+				switch sub {
+				case "approve", "reject":
+					return completeMemoryNumbers(ws)
+				case "extract":
+					if len(args) == 0 {
+						return completeSessions(ws, false)
+					}
+				}
+				return nil
+			})},
+		{name: "auth", usage: "auth [status|login [provider]|logout [provider]]", summary: "Store provider API keys in the OS keyring", run: runAuth,
+			complete: subcommands([]string{"status", "login", "logout"}, func(ws *ai.Workspace, sub string, args []string) []string {
+				if (sub == "login" || sub == "logout") && len(args) == 0 {
+					return keyProviders()
+				}
+				return nil
+			})},
+		{name: "sync", usage: "sync [--remote url] [--branch name]", summary: "Share settings, roles, preferences, templates, facts, and schedules through a git remote", run: runSync,
+			complete: func(ws *ai.Workspace, args []string) []string { return []string{"--remote", "--branch"} }},
+		{name: "trash", usage: "trash [list|restore <id>...|empty]", summary: "List, restore, or permanently delete deleted roles, preferences, and sessions", run: runTrash,
+			complete: subcommands([]string{"list", "restore", "empty"}, func(ws *ai.Workspace, sub string, args []string) []string {
+				if sub == "restore" {
+					return completeTrash(ws)
+				}
+				return nil
+			})},
+		{name: "workspace", usage: "workspace [list|forget <name|dir>]", summary: "List the projects nani has opened, or forget one", run: runWorkspace,
+			complete: subcommands([]string{"list", "forget"}, func(ws *ai.Workspace, sub string, args []string) []string {
+				if sub == "forget" && len(args) == 0 {
+					return completeWorkspaces()
+				}
+				return nil
+			})},
+		{name: "search", usage: "search [-k n] <query>", summary: "Semantic search over workspace artifacts", run: runSearch},
+		{name: "completion", usage: "completion bash|zsh|fish", summary: "Print the shell completion script for a shell", run: runCompletion,
+			complete: subcommands([]string{"bash", "zsh", "fish"}, nil)},
+		{name: "man", usage: "man", summary: "Print the nani man page in roff format", run: runMan},
+		{name: completeCommand, run: runComplete, hidden: true},
+		{name: ai.ContextMergeCommand, run: runMergeContext, hidden: true},
+	}
+}
+
+// Run dispatches args to the matching subcommand. The first element of args
+// is the subcommand name; the rest are passed to its handler.
+func Run(ws *ai.Workspace, args []string) error {
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(os.Stdout)
+		return nil
+	}
+	for _, c := range commands() {
+		if c.name == args[0] {
+			return c.run(ws, args[1:])
+		}
+	}
+	printUsage(os.Stderr)
+	return fmt.Errorf("unknown command '%s'", args[0])
+}
+
+// GlobalFlags holds the flags given before the command, which select what
+// nani works on.
+type GlobalFlags struct {
+	Workspace string // Registered workspace name or project directory.
+	Scope     string // Project subdirectory to confine the workspace to (see `ai.Workspace.WithScope`).
+	Provider  string // Model provider to use: "gemini" (default) or "mock" (see `SetProvider`).
+	Record    string // Cassette file to capture provider interactions to (see `SetCassette`).
+	Replay    string // Cassette file to replay provider interactions from.
+
+	// Accessible starts the chat in accessible mode, for screen readers and
+	// simple terminals (see `ui.WithAccessible`).
+	Accessible bool
+}
+
+// ParseGlobalFlags removes the leading `--workspace <name|dir>`,
+// `--scope <dir>`, `--provider <name>`, `--record <file>`, and
+// `--replay <file>` flags (or their `--flag=value` forms) and the
+// `--accessible` switch from args and returns their values and the remaining
+// arguments.
+func ParseGlobalFlags(args []string) (GlobalFlags, []string, error) {
+	var flags GlobalFlags
+	targets := map[string]*string{
+		"--workspace": &flags.Workspace,
+		"--scope":     &flags.Scope,
+		"--provider":  &flags.Provider,
+		"--record":    &flags.Record,
+		"--replay":    &flags.Replay,
+	}
+	for len(args) > 0 {
+		if args[0] == "--accessible" {
+			flags.Accessible, args = true, args[1:]
+			continue
+		}
+		name, value, inline := strings.Cut(args[0], "=")
+		target, ok := targets[name]
+		if !ok {
+			break
+		}
+		if !inline {
+			if len(args) < 2 {
+				return flags, nil, fmt.Errorf("%s requires a value", name)
+			}
+			value, args = args[1], args[1:]
+		}
+		*target = value
+		args = args[1:]
+	}
+	return flags, args, nil
+}
+
+// printUsage writes the list of available subcommands to out.
+func printUsage(out io.Writer) {
+	var b strings.Builder
+	b.WriteString("Usage: nani [--workspace name|dir] [--scope dir] [--provider gemini|mock] [--record|--replay file] [--accessible] [command]\n\nRun without a command to start the interactive chat.\n")
+	b.WriteString("The workspace defaults to the nearest .AIWorkspace at or above the current directory.\n")
+	b.WriteString("--scope confines sessions, sources, and indexes to a subdirectory of the project.\n")
+	b.WriteString("--provider mock replies with canned responses from .AIWorkspace/mock.json instead of calling Gemini.\n")
+	b.WriteString("--record captures Gemini requests and responses to a cassette file; --replay answers them from one, offline.\n")
+	b.WriteString("--accessible shows the chat without borders, colors, or animation, for screen readers and simple terminals.\n\nCommands:\n")
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, c := range commands() {
+		if c.hidden {
+			continue
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", c.usage, c.summary)
+	}
+	tw.Flush()
+	fmt.Fprint(out, b.String())
+}
@@ -0,0 +1,310 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// completeCommand is the hidden command the completion scripts call to list
+// the candidates for the next word of a command line.
+const completeCommand = "__complete"
+
+// Standalone reports whether the named command works outside a workspace, so
+// the caller should not create one in the current directory to run it. Shell
+// completion runs in whatever directory the shell is in, and the workspace
+// registry is global.
+func Standalone(name string) bool {
+	return name == "completion" || name == "man" || name == "workspace" || name == completeCommand || name == ai.ContextMergeCommand
+}
+
+// runCompletion implements `nani completion`, which prints the completion
+// script for a shell.
+func runCompletion(ws *ai.Workspace, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: nani completion bash|zsh|fish")
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		return fmt.Errorf("unsupported shell '%s', expected bash, zsh, or fish", args[0])
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// completionScripts holds the completion script for each supported shell.
+// All of them ask `nani __complete` for candidates, so they follow the
+// command table and the workspace without being regenerated.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for nani; add to ~/.bashrc:
+#   source <(nani completion bash)
+_nani() {
+    local cur=${COMP_WORDS[COMP_CWORD]} IFS=$'\n'
+    local candidates=($(nani __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null | cut -f1))
+    if [ ${#candidates[@]} -eq 0 ]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+        return
+    fi
+    COMPREPLY=($(compgen -W "${candidates[*]}" -- "$cur"))
+}
+complete -o filenames -F _nani nani
+`,
+	"zsh": `#compdef nani
+# zsh completion for nani; add to ~/.zshrc:
+#   source <(nani completion zsh)
+_nani() {
+    local -a candidates
+    candidates=("${(@f)$(nani __complete "${(@)words[2,CURRENT-1]}" 2>/dev/null)}")
+    candidates=("${(@)candidates//$'\t'/:}")
+    if [[ -z "${candidates[1]}" ]]; then
+        _files
+        return
+    fi
+    _describe 'nani' candidates
+}
+if [ "$funcstack[1]" = "_nani" ]; then
+    _nani "$@"
+else
+    compdef _nani nani
+fi
+`,
+	"fish": `# fish completion for nani; add to ~/.config/fish/config.fish:
+#   nani completion fish | source
+function __nani_complete
+    nani __complete (commandline -opc)[2..-1] 2>/dev/null
+end
+complete -c nani -f -n 'test -n "$(__nani_complete)"' -a '(__nani_complete)'
+`,
+}
+
+// runComplete implements the hidden `nani __complete`. Its arguments are the
+// words already typed after "nani"; it prints the candidates for the next
+// word, one per line, each optionally followed by a tab and a description.
+// Printing nothing lets the shell complete file names instead. ws is nil
+// outside a workspace, in which case workspace artifacts are not offered.
+func runComplete(ws *ai.Workspace, args []string) error {
+	for _, c := range completions(ws, args) {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+// completions returns the candidates for the word following args.
+func completions(ws *ai.Workspace, args []string) []string {
+	if _, rest, err := ParseGlobalFlags(args); err == nil {
+		args = rest
+	}
+	if len(args) == 0 {
+		var names []string
+		for _, c := range commands() {
+			if !c.hidden {
+				names = append(names, c.name+"\t"+c.summary)
+			}
+		}
+		return names
+	}
+	switch args[len(args)-1] {
+	case "--workspace":
+		return completeWorkspaces()
+	case "--provider":
+		return []string{ai.ProviderGemini, ai.ProviderMock}
+	case "--role":
+		return completeRoles(ws)
+	case "--output":
+		return []string{outputText, outputJSON}
+	case "--format":
+		if args[0] == "sessions" {
+			var formats []string
+			for _, f := range ai.TrainingFormats {
+				formats = append(formats, string(f))
+			}
+			return formats
+		}
+		return []string{"markdown", ai.FindingsSARIF, ai.FindingsRDJSON}
+	case "--trigger":
+		return []string{ai.TriggerCron, ai.TriggerCommit}
+	}
+	for _, c := range commands() {
+		if c.name == args[0] && c.complete != nil {
+			return c.complete(ws, args[1:])
+		}
+	}
+	return nil
+}
+
+// subcommands returns a completer offering names as the first argument and
+// deferring to next, if not nil, for the arguments of a subcommand.
+func subcommands(names []string, next func(ws *ai.Workspace, sub string, args []string) []string) func(*ai.Workspace, []string) []string {
+	return func(ws *ai.Workspace, args []string) []string {
+		if len(args) == 0 {
+			return names
+		}
+		if next == nil {
+			return nil
+		}
+		return next(ws, args[0], args[1:])
+	}
+}
+
+// completeRoles returns the names of the roles available to the workspace.
+func completeRoles(ws *ai.Workspace) []string {
+	if ws == nil {
+		return nil
+	}
+	roles, err := ws.ListRoles()
+	if err != nil {
+		return nil
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, r.Name+"\t"+r.Description)
+	}
+	return names
+}
+
+// completeWorkspaces returns the names of the registered workspaces.
+func completeWorkspaces() []string {
+	known, err := ai.KnownWorkspaces()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(known))
+	for _, k := range known {
+		names = append(names, k.Name+"\t"+k.Path)
+	}
+	return names
+}
+
+// completeBuiltinRoles returns the names of the built-in role presets.
+func completeBuiltinRoles() []string {
+	var names []string
+	for _, r := range ai.BuiltinRoles() {
+		names = append(names, r.Name+"\t"+r.Description)
+	}
+	return names
+}
+
+// completeTrash returns the IDs of the entries in the trash, described by
+// what was deleted.
+func completeTrash(ws *ai.Workspace) []string {
+	if ws == nil {
+		return nil
+	}
+	entries, err := ws.ListTrash()
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID+"\t"+e.Kind+" "+e.Name)
+	}
+	return ids
+}
+
+// completeSessions returns the IDs of the archived sessions, pinned sessions
+// first, described by their labels. With active set, the active session's ID
+// is offered first.
+func completeSessions(ws *ai.Workspace, active bool) []string {
+	if ws == nil {
+		return nil
+	}
+	sessions, err := ws.ListArchivedSessions()
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(sessions)+1)
+	if session, err := ws.GetActiveSession(); active && err == nil && session != nil {
+		ids = append(ids, session.ID+"\t"+session.Label+" (active)")
+	}
+	for _, s := range sessions {
+		ids = append(ids, s.ID+"\t"+s.Label)
+	}
+	return ids
+}
+
+// completeTemplates returns the names of the workspace's prompt templates.
+func completeTemplates(ws *ai.Workspace) []string {
+	if ws == nil {
+		return nil
+	}
+	templates, err := ws.ListTemplates()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(templates))
+	for _, t := range templates {
+		names = append(names, t.Name+"\t"+t.Description)
+	}
+	return names
+}
+
+// completeSchedules returns the names of the workspace's schedules.
+func completeSchedules(ws *ai.Workspace) []string {
+	if ws == nil {
+		return nil
+	}
+	schedules, err := ws.ListSchedules()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(schedules))
+	for _, s := range schedules {
+		names = append(names, s.Name+"\t"+s.Template)
+	}
+	return names
+}
+
+// runMan implements `nani man`, which prints a man page for nani, generated
+// from the command table, in roff format.
+func runMan(ws *ai.Workspace, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: nani man")
+	}
+	var b strings.Builder
+	b.WriteString(".TH NANI 1\n")
+	b.WriteString(".SH NAME\nnani \\- chat with an AI assistant about your project\n")
+	b.WriteString(".SH SYNOPSIS\n.B nani\n[\\fIcommand\\fR] [\\fIargs\\fR]\n")
+	b.WriteString(".SH DESCRIPTION\nRun without a command to start the interactive chat. ")
+	b.WriteString("Sessions, roles, preferences, and other artifacts are kept in the \\fI.AIWorkspace\\fR directory of the project.\n")
+	b.WriteString(".SH COMMANDS\n")
+	for _, c := range commands() {
+		if c.hidden {
+			continue
+		}
+		fmt.Fprintf(&b, ".TP\n.B %s\n%s\n", roffEscape("nani "+c.usage), roffEscape(c.summary))
+	}
+	b.WriteString(".SH ENVIRONMENT\n.TP\n.B GEMINI_API_KEY\nAPI key used to reach Gemini when none is stored in the OS keyring with \\fBnani auth login\\fR.\n")
+	b.WriteString(".SH FILES\n.TP\n.I .AIWorkspace/\nThe project workspace.\n")
+	fmt.Print(b.String())
+	return nil
+}
+
+// roffEscape escapes text for use in a roff line.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// positionals counts the positional arguments in args, skipping flags and
+// the values of the given flags.
+func positionals(args []string, valueFlags ...string) int {
+	n := 0
+	for i := 0; i < len(args); i++ {
+		switch {
+		case slices.Contains(valueFlags, args[i]):
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			n++
+		}
+	}
+	return n
+}
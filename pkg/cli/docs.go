@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runDocs implements `nani docs`, which manages generated documentation.
+func runDocs(ws *ai.Workspace, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: nani docs [generate|status|refresh] ...")
+	}
+	switch args[0] {
+	case "generate":
+		return runDocsGenerate(ws, args[1:])
+	case "status":
+		return runDocsStatus(ws)
+	case "refresh":
+		return runDocsRefresh(ws, args[1:])
+	default:
+		return fmt.Errorf("unknown docs subcommand '%s'", args[0])
+	}
+}
+
+// runDocsGenerate implements `nani docs generate`, which documents every source
+// file matched by the patterns (e.g., `./pkg/...`) with the documenter role.
+// Interrupting it with Ctrl+C keeps the documents written so far; running it
+// again resumes with the remaining files.
+func runDocsGenerate(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("docs generate", flag.ContinueOnError)
+	role := fs.String("role", "", "role generating the documents (default \"documenter\")")
+	out := fs.String("out", "", "output directory relative to the project root (default \"docs\")")
+	force := fs.Bool("force", false, "regenerate files that are already documented")
+	jobs := fs.Int("jobs", 0, "files documented at once (default from settings, or 4)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: nani docs generate [--role r] [--out dir] [--force] [--jobs n] <pattern>...")
+	}
+
+	files, err := ai.ExpandSourcePatterns(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Println("No source files matched.")
+		return nil
+	}
+	client, err := newGeminiClient(ws)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := ws.GenerateDocs(ctx, client, files, ai.DocsOptions{
+		Role:        *role,
+		OutDir:      *out,
+		Force:       *force,
+		Concurrency: *jobs,
+		Progress:    printDocProgress,
+	})
+	return printDocsReport(report, err)
+}
+
+// runDocsStatus implements `nani docs status`, listing generated documents that
+// are out of date with their sources.
+func runDocsStatus(ws *ai.Workspace) error {
+	stale, err := ws.StaleDocs()
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		fmt.Println("All generated documentation is up to date.")
+		return nil
+	}
+	for _, s := range stale {
+		fmt.Printf("  %-24s %s -> %s\n", s.Reason, s.Source, s.Doc)
+	}
+	fmt.Printf("\n%d stale documents. Run `nani docs refresh` to regenerate them.\n", len(stale))
+	return nil
+}
+
+// runDocsRefresh implements `nani docs refresh`, which regenerates only the
+// documents whose sources changed since they were generated.
+func runDocsRefresh(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("docs refresh", flag.ContinueOnError)
+	role := fs.String("role", "", "role generating the documents (default \"documenter\")")
+	jobs := fs.Int("jobs", 0, "files documented at once (default from settings, or 4)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newGeminiClient(ws)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := ws.RefreshDocs(ctx, client, ai.DocsOptions{Role: *role, Concurrency: *jobs, Progress: printDocProgress})
+	return printDocsReport(report, err)
+}
+
+// printDocsReport prints the totals and failures of a documentation batch and
+// turns them into the command's error.
+func printDocsReport(report ai.DocsReport, err error) error {
+	fmt.Printf("\n%d generated, %d already documented, %d failed\n", len(report.Generated), len(report.Skipped), len(report.Failed))
+	if errors.Is(err, context.Canceled) {
+		return errors.New("interrupted; run the command again to resume")
+	} else if err != nil {
+		return err
+	}
+	if len(report.Failed) > 0 {
+		sources := make([]string, 0, len(report.Failed))
+		for source := range report.Failed {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			fmt.Printf("  %s: %v\n", source, report.Failed[source])
+		}
+		return fmt.Errorf("failed to document %d files", len(report.Failed))
+	}
+	return nil
+}
+
+// printDocProgress writes one line per processed file.
+func printDocProgress(p ai.DocProgress) {
+	status := "generated"
+	switch {
+	case p.Err != nil:
+		status = "failed   "
+	case p.Skipped:
+		status = "skipped  "
+	}
+	fmt.Printf("[%d/%d] %s %s -> %s\n", p.Index, p.Total, status, p.Source, p.Doc)
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runDoctor implements `nani doctor`, which checks the workspace for problems
+// and offers to repair them. `--fix` repairs them without asking.
+func runDoctor(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "repair the problems found without asking")
+	verify := fs.Bool("verify", false, "also report artifacts modified outside nani using the integrity manifest")
+	parse := fs.Bool("parse", false, "also report how often model replies needed repair or could not be parsed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := checkHealth(ctx, ws, *fix); err != nil {
+		return err
+	}
+	if *parse {
+		if err := reportParseStats(ws); err != nil {
+			return err
+		}
+	}
+	if *verify {
+		return verifyManifest(ctx, ws)
+	}
+	return nil
+}
+
+// checkHealth reports the problems found in the workspace and repairs those
+// that can be repaired automatically, after asking unless fix is true.
+func checkHealth(ctx context.Context, ws *ai.Workspace, fix bool) error {
+	issues, err := ws.CheckHealth(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check workspace health: %w", err)
+	}
+	if len(issues) == 0 {
+		fmt.Println("Workspace healthy: no problems found.")
+		return nil
+	}
+
+	repairable := 0
+	fmt.Printf("Found %d problem(s):\n", len(issues))
+	for _, issue := range issues {
+		fmt.Printf("  %-12s %s: %s\n", issue.Kind, issue.Path, issue.Detail)
+		if issue.Repair != "" {
+			repairable++
+			fmt.Printf("  %-12s repair: %s\n", "", issue.Repair)
+		} else {
+			fmt.Printf("  %-12s repair by hand\n", "")
+		}
+	}
+	if repairable == 0 {
+		return nil
+	}
+	if !fix && ask(fmt.Sprintf("Repair %d problem(s) [y/N]? ", repairable)) != "y" {
+		fmt.Println("No changes made.")
+		return nil
+	}
+	for _, issue := range issues {
+		if issue.Repair == "" {
+			continue
+		}
+		if err := ws.RepairHealth(ctx, issue); err != nil {
+			fmt.Printf("  failed   %s: %v\n", issue.Path, err)
+			continue
+		}
+		fmt.Printf("  repaired %s: %s\n", issue.Path, issue.Detail)
+	}
+	return nil
+}
+
+// reportParseStats prints how the workspace's model replies were parsed:
+// as is, after repairs (and which), or not at all.
+func reportParseStats(ws *ai.Workspace) error {
+	stats, err := ws.ParseStats()
+	if err != nil {
+		return err
+	}
+	total := stats.Strict + stats.Repaired + stats.Failed
+	if total == 0 {
+		fmt.Println("No model replies parsed yet.")
+		return nil
+	}
+	percent := func(n int) float64 { return 100 * float64(n) / float64(total) }
+	fmt.Printf("Parsed %d model replies: %d valid (%.1f%%), %d repaired (%.1f%%), %d failed (%.1f%%).\n",
+		total, stats.Strict, percent(stats.Strict), stats.Repaired, percent(stats.Repaired), stats.Failed, percent(stats.Failed))
+	repairs := make([]string, 0, len(stats.Repairs))
+	for repair := range stats.Repairs {
+		repairs = append(repairs, repair)
+	}
+	sort.Strings(repairs)
+	for _, repair := range repairs {
+		fmt.Printf("  %-20s %d\n", repair, stats.Repairs[repair])
+	}
+	if stats.Reformatted+stats.Fallbacks > 0 {
+		fmt.Printf("Of the failed replies, %d were resent correctly when asked and %d were kept as raw text.\n", stats.Reformatted, stats.Fallbacks)
+	}
+	if stats.LastFailure != "" {
+		fmt.Printf("Last failure, %s: %s\n", stats.LastFailed.Format("2006-01-02 15:04"), stats.LastFailure)
+	}
+	return nil
+}
+
+// verifyManifest reports integrity manifest changes and interactively offers to
+// accept them (reindex) or restore the affected files from backup.
+func verifyManifest(ctx context.Context, ws *ai.Workspace) error {
+	changes, err := ws.VerifyManifest(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify workspace: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("Workspace integrity verified: no external modifications found.")
+		return nil
+	}
+
+	fmt.Printf("Found %d artifact(s) changed outside nani:\n", len(changes))
+	for _, c := range changes {
+		fmt.Printf("  %-9s %s\n", c.Kind, c.Path)
+	}
+
+	switch ask("Reindex and accept these changes [r], restore from backup [b], or do nothing [n]? ") {
+	case "r":
+		if err := ws.RefreshIndexes(ctx); err != nil {
+			return err
+		}
+		if err := ws.RebuildManifest(ctx); err != nil {
+			return err
+		}
+		fmt.Println("Indexes rebuilt and current files accepted.")
+	case "b":
+		for _, c := range changes {
+			if c.Kind == ai.ChangeUntracked {
+				fmt.Printf("  skipped %s: not written by nani, no backup exists\n", c.Path)
+				continue
+			}
+			if err := ws.RestoreFromBackup(ctx, c.Path); err != nil {
+				fmt.Printf("  failed  %s: %v\n", c.Path, err)
+				continue
+			}
+			fmt.Printf("  restored %s\n", c.Path)
+		}
+	default:
+		fmt.Println("No changes made.")
+	}
+	return nil
+}
+
+// stdin buffers standard input for interactive prompts. It is shared so that
+// consecutive prompts never lose input buffered by an earlier one.
+var stdin = bufio.NewReader(os.Stdin)
+
+// ask prints a prompt and returns the user's trimmed, lowercased answer.
+// It returns an empty string if standard input is closed.
+func ask(prompt string) string {
+	fmt.Print(prompt)
+	line, _ := stdin.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(line))
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runInit implements `nani init`, a wizard that detects the project's name,
+// owner, and repository, lets the user confirm or correct each value, and
+// saves them to the workspace. The module path and language are taken as detected.
+func runInit(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "accept the detected values without prompting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	project := ai.DetectProject(filepath.Dir(ws.RootDir))
+	if !*yes {
+		fmt.Println("Setting up the nani workspace. Press Enter to accept a value in brackets.")
+		project.Name = askDefault("Project name", project.Name)
+		project.Owner = askDefault("Owner", project.Owner)
+		project.Repository = askDefault("Repository URL", project.Repository)
+	}
+
+	if err := ws.SetProject(project); err != nil {
+		return err
+	}
+	fmt.Printf("Workspace initialized for %s\n", project.Name)
+	return nil
+}
+
+// askDefault prompts for a value, returning def if the user enters nothing.
+// Unlike ask, the answer's case is preserved.
+func askDefault(prompt, def string) string {
+	fmt.Printf("%s [%s]: ", prompt, def)
+	line, _ := stdin.ReadString('\n')
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
@@ -0,0 +1,97 @@
+//go:build !windows
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringGet returns the secret stored for account in the OS keyring: the
+// login keychain on macOS, or the Secret Service (e.g., GNOME Keyring or
+// KWallet) elsewhere, through its secret-tool client.
+func keyringGet(account string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	}
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		// Both tools exit with a failure status when nothing is stored.
+		return "", errKeyNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read the keyring: %w", err)
+	}
+	secret := strings.TrimRight(string(out), "\r\n")
+	if secret == "" {
+		return "", errKeyNotFound
+	}
+	return secret, nil
+}
+
+// keyringSet stores secret for account in the OS keyring, replacing any
+// secret stored before. The secret is always written to the tool's standard
+// input, never passed as an argument, where other users could read it.
+func keyringSet(account, secret string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		// `security -i` reads its commands from standard input.
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			securityQuote(keyringService), securityQuote(account), securityQuote(secret)))
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label", fmt.Sprintf("nani %s API key", account), "service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	}
+	if err := runKeyringTool(cmd); err != nil {
+		return fmt.Errorf("failed to write the keyring: %w", err)
+	}
+	if runtime.GOOS == "darwin" {
+		// `security -i` may exit successfully after a failed command.
+		if stored, err := keyringGet(account); err != nil || stored != secret {
+			return errors.New("failed to write the keyring: the key was not stored")
+		}
+	}
+	return nil
+}
+
+// keyringDelete removes the secret stored for account from the OS keyring.
+func keyringDelete(account string) error {
+	if _, err := keyringGet(account); err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", account)
+	} else {
+		cmd = exec.Command("secret-tool", "clear", "service", keyringService, "account", account)
+	}
+	if err := runKeyringTool(cmd); err != nil {
+		return fmt.Errorf("failed to update the keyring: %w", err)
+	}
+	return nil
+}
+
+// securityQuote quotes s as a single argument of a `security -i` command.
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// runKeyringTool runs cmd, adding anything it wrote to standard error to its
+// error.
+func runKeyringTool(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
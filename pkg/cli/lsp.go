@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/asaidimu/nani/pkg/lsp"
+)
+
+// runLSP implements `nani lsp`, which runs a language server on standard
+// input and output for editor plugins.
+func runLSP(ws *ai.Workspace, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: nani lsp")
+	}
+	client, err := newGeminiClient(ws)
+	if err != nil {
+		return err
+	}
+	return lsp.New(ws, client).Serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runMemories implements `nani memories`. Without arguments it lists the
+// facts and preferences extracted from ended sessions that await approval;
+// `approve` and `reject` take candidate numbers from that list, or "all";
+// `extract` asks the model for candidates from an archived session.
+func runMemories(ws *ai.Workspace, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		pending, err := ws.PendingMemories()
+		if err != nil {
+			return err
+		}
+		fmt.Print(ai.MemoriesMarkdown(pending))
+		return nil
+	}
+
+	switch args[0] {
+	case "approve", "reject":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: nani memories %s <n>...|all", args[0])
+		}
+		pending, err := ws.PendingMemories()
+		if err != nil {
+			return err
+		}
+		chosen, err := memoryCandidates(pending, args[1:])
+		if err != nil {
+			return err
+		}
+		for _, c := range chosen {
+			if args[0] == "approve" {
+				err = ws.ApproveMemory(c.ID)
+			} else {
+				err = ws.RejectMemory(c.ID)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("  %-10s %s: %s\n", args[0]+"d", c.Kind, c.Content)
+		}
+		return nil
+	case "extract":
+		if len(args) != 2 {
+			return errors.New("usage: nani memories extract <session-id>")
+		}
+		return extractMemories(ws, args[1])
+	default:
+		return fmt.Errorf("unknown memories subcommand '%s'", args[0])
+	}
+}
+
+// memoryCandidates returns the candidates of pending chosen by args: one-based
+// numbers in the list, or "all".
+func memoryCandidates(pending []ai.MemoryCandidate, args []string) ([]ai.MemoryCandidate, error) {
+	if len(args) == 1 && args[0] == "all" {
+		return pending, nil
+	}
+	chosen := make([]ai.MemoryCandidate, 0, len(args))
+	for _, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(pending) {
+			return nil, fmt.Errorf("invalid memory number '%s'", arg)
+		}
+		chosen = append(chosen, pending[n-1])
+	}
+	return chosen, nil
+}
+
+// extractMemories asks the model for facts and preferences stated in the
+// archived session sessionID and reports those queued for approval.
+func extractMemories(ws *ai.Workspace, sessionID string) error {
+	client, err := newGeminiClient(ws)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	queued, err := ws.ExtractMemories(ctx, client, sessionID)
+	if err != nil {
+		return err
+	}
+	if len(queued) == 0 {
+		fmt.Println("No new facts or preferences found.")
+		return nil
+	}
+	fmt.Print(ai.MemoriesMarkdown(queued))
+	fmt.Printf("Queued %d for approval; run `nani memories approve <n>...|all` to keep them.\n", len(queued))
+	return nil
+}
+
+// completeMemoryNumbers completes the numbers of the pending memory candidates.
+func completeMemoryNumbers(ws *ai.Workspace) []string {
+	pending, err := ws.PendingMemories()
+	if err != nil {
+		return nil
+	}
+	numbers := []string{"all"}
+	for i := range pending {
+		numbers = append(numbers, strconv.Itoa(i+1))
+	}
+	return numbers
+}
+
+// endedSessionMemories extracts memories from the session sessionID, just
+// archived, when `Settings.ExtractMemories` is enabled. Extraction only
+// helps, so failures are reported as warnings.
+func endedSessionMemories(ws *ai.Workspace, sessionID string) {
+	if !ws.Settings().ExtractMemories {
+		return
+	}
+	if err := extractMemories(ws, sessionID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
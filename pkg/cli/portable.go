@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runExport implements `nani export`, which writes the workspace to a tar.gz archive.
+func runExport(ws *ai.Workspace, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	logs := fs.Bool("logs", false, "include action and audit logs")
+	cache := fs.Bool("cache", false, "include the vector store and backups")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: nani export [--logs] [--cache] <file.tar.gz>")
+	}
+
+	if err := ws.Export(ctx, fs.Arg(0), ai.ExportOptions{IncludeLogs: *logs, IncludeCache: *cache}); err != nil {
+		return err
+	}
+	fmt.Printf("Exported workspace to %s\n", fs.Arg(0))
+	return nil
+}
+
+// runImport implements `nani import`, which merges an exported archive into the workspace.
+func runImport(ws *ai.Workspace, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if len(args) != 1 {
+		return errors.New("usage: nani import <file.tar.gz>")
+	}
+	report, err := ws.Import(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	for _, rel := range report.Added {
+		fmt.Printf("  added    %s\n", rel)
+	}
+	for _, rel := range report.Skipped {
+		fmt.Printf("  skipped  %s (already exists)\n", rel)
+	}
+	fmt.Printf("Imported %d artifact(s), skipped %d\n", len(report.Added), len(report.Skipped))
+	return nil
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runReview implements `nani review`, which reviews a diff with the reviewer
+// role and prints a Markdown report of the findings, or with --format SARIF
+// or reviewdog diagnostics for CI. Without a flag it reviews the uncommitted
+// changes of the working tree.
+func runReview(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	staged := fs.Bool("staged", false, "review the changes staged for commit")
+	revisions := fs.String("range", "", "review the changes between two revisions (A..B)")
+	pr := fs.String("pr", "", "review a GitHub pull request, by URL or number (needs the gh CLI)")
+	role := fs.String("role", "", "role reviewing the diff (default \"reviewer\")")
+	jobs := fs.Int("jobs", 0, "requests run at once (default from settings, or 4)")
+	out := fs.String("out", "", "write the report to a file instead of standard output")
+	format := fs.String("format", "markdown", "report format: markdown, sarif, or rdjson (reviewdog)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: nani review [--staged|--range A..B|--pr URL] [--role r] [--jobs n] [--format f] [--out file]")
+	}
+	if *format != "markdown" && *format != ai.FindingsSARIF && *format != ai.FindingsRDJSON {
+		return fmt.Errorf("unknown format '%s', expected markdown, %s, or %s", *format, ai.FindingsSARIF, ai.FindingsRDJSON)
+	}
+
+	diff, source, err := reviewTarget(*staged, *revisions, *pr)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Println("No changes to review.")
+		return nil
+	}
+	client, err := newGeminiClient(ws)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := ws.ReviewDiff(ctx, client, diff, ai.ReviewOptions{
+		Role:        *role,
+		Concurrency: *jobs,
+		Source:      source,
+		Progress: func(done, total int) {
+			fmt.Fprintf(os.Stderr, "Reviewed %d/%d chunks\n", done, total)
+		},
+	})
+	if errors.Is(err, context.Canceled) {
+		return errors.New("interrupted")
+	} else if err != nil {
+		return err
+	}
+
+	output := []byte(report.Markdown())
+	if *format != "markdown" {
+		if output, err = ai.ExportFindings(report.Findings, *format); err != nil {
+			return err
+		}
+	}
+	if *out != "" {
+		if err := os.WriteFile(*out, output, 0644); err != nil {
+			return fmt.Errorf("failed to write review: %w", err)
+		}
+		fmt.Printf("Wrote %d findings to %s\n", len(report.Findings), *out)
+	} else {
+		os.Stdout.Write(output)
+	}
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("failed to review %d of %d files", len(report.Failed), report.Files)
+	}
+	return nil
+}
+
+// reviewTarget returns the diff selected by the flags of `nani review` and a
+// function reading the new version of a file of the diff.
+func reviewTarget(staged bool, revisions, pr string) (string, func(string) (string, error), error) {
+	selected := 0
+	for _, set := range []bool{staged, revisions != "", pr != ""} {
+		if set {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return "", nil, errors.New("--staged, --range, and --pr cannot be combined")
+	}
+
+	switch {
+	case staged:
+		diff, err := gitOutput("diff", "--cached")
+		return diff, gitRevisionSource(""), err
+	case revisions != "":
+		sep := ".."
+		if strings.Contains(revisions, "...") {
+			sep = "..."
+		}
+		_, to, found := strings.Cut(revisions, sep)
+		if !found {
+			return "", nil, fmt.Errorf("invalid range '%s', expected A..B", revisions)
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+		diff, err := gitOutput("diff", revisions)
+		return diff, gitRevisionSource(to), err
+	case pr != "":
+		out, err := exec.Command("gh", "pr", "diff", pr).Output()
+		if err != nil {
+			return "", nil, fmt.Errorf("gh pr diff failed: %w", commandError(err))
+		}
+		// The pull request's head is only readable if it has been fetched.
+		head, err := exec.Command("gh", "pr", "view", pr, "--json", "headRefOid", "--jq", ".headRefOid").Output()
+		if err != nil {
+			return string(out), nil, nil
+		}
+		return string(out), gitRevisionSource(strings.TrimSpace(string(head))), nil
+	default:
+		diff, err := gitOutput("diff", "HEAD")
+		return diff, func(file string) (string, error) {
+			data, err := os.ReadFile(file)
+			return string(data), err
+		}, err
+	}
+}
+
+// gitRevisionSource returns a function reading a file at revision, or from
+// the index if revision is empty.
+func gitRevisionSource(revision string) func(string) (string, error) {
+	return func(file string) (string, error) {
+		return gitOutput("show", revision+":"+file)
+	}
+}
+
+// gitOutput runs git with args and returns its standard output.
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w", args[0], commandError(err))
+	}
+	return string(out), nil
+}
+
+// commandError adds the standard error of a failed command to err.
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
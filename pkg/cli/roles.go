@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runRoles implements `nani roles`. Without arguments it lists the roles available
+// to the workspace, including global ones; `install` with no names lists the
+// built-in presets, and `install --global` installs presets for every project;
+// `search` lists the roles of the role registry, and `install` installs from
+// it the names that are not built-in presets, or all names with `--registry`;
+// `delete` moves workspace roles to the trash.
+func runRoles(ws *ai.Workspace, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		roles, err := ws.ListRoles()
+		if err != nil {
+			return fmt.Errorf("failed to list roles: %w", err)
+		}
+		sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+		printRoles(roles)
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	switch args[0] {
+	case "search":
+		fs := flag.NewFlagSet("roles search", flag.ContinueOnError)
+		registry := fs.String("registry", "", "role registry to search instead of settings.roleRegistry")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		found, err := ws.SearchRoleRegistry(ctx, *registry, strings.Join(fs.Args(), " "))
+		if err != nil {
+			return fmt.Errorf("failed to search role registry: %w", err)
+		}
+		if len(found) == 0 {
+			fmt.Println("No roles found in the registry.")
+			return nil
+		}
+		roles := make([]ai.RoleSummary, len(found))
+		for i, l := range found {
+			roles[i] = ai.RoleSummary{Name: l.Name, Label: l.Label, Description: l.Description}
+		}
+		printRoles(roles)
+		return nil
+	case "install":
+		fs := flag.NewFlagSet("roles install", flag.ContinueOnError)
+		global := fs.Bool("global", false, "install into the global workspace shared by all projects")
+		registry := fs.String("registry", "", "role registry to install from instead of settings.roleRegistry")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			fmt.Println("Available built-in roles:")
+			printRoles(ai.BuiltinRoles())
+			return nil
+		}
+		builtin := make(map[string]bool)
+		for _, r := range ai.BuiltinRoles() {
+			builtin[r.Name] = true
+		}
+		for _, name := range fs.Args() {
+			if *registry != "" || !builtin[name] {
+				if *global {
+					return fmt.Errorf("role %s is not built in; roles from a registry can only be installed into the workspace", name)
+				}
+				role, err := ws.InstallRegistryRole(ctx, *registry, name)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Installed role %s (%s) from the registry after verifying its checksum\n", role.Name, role.Label)
+				continue
+			}
+			if *global {
+				if err := ws.InstallGlobalBuiltinRole(name); err != nil {
+					return err
+				}
+				fmt.Printf("Installed global role %s\n", name)
+				continue
+			}
+			if err := ws.InstallBuiltinRole(name); err != nil {
+				return err
+			}
+			fmt.Printf("Installed role %s\n", name)
+		}
+		return nil
+	case "delete":
+		if len(args) < 2 {
+			return errors.New("usage: nani roles delete <name>...")
+		}
+		for _, name := range args[1:] {
+			if err := ws.DeleteRole(name); err != nil {
+				return err
+			}
+			fmt.Printf("Moved role %s to the trash; `nani trash restore` brings it back\n", name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown roles subcommand '%s'", args[0])
+	}
+}
+
+// printRoles writes role summaries as an aligned table to standard output.
+func printRoles(roles []ai.RoleSummary) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, r := range roles {
+		label := r.Label
+		if r.Global {
+			label += " (global)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, label, r.Description)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runSchedule implements `nani schedule`, which manages prompt templates run
+// automatically on a cron schedule or after each git commit.
+func runSchedule(ws *ai.Workspace, args []string) error {
+	if len(args) == 0 {
+		return runScheduleList(ws)
+	}
+	switch args[0] {
+	case "list":
+		return runScheduleList(ws)
+	case "add":
+		return runScheduleAdd(ws, args[1:])
+	case "remove":
+		if len(args) != 2 {
+			return errors.New("usage: nani schedule remove <name>")
+		}
+		if err := ws.DeleteSchedule(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Removed schedule %s\n", args[1])
+		return nil
+	case "run":
+		return runScheduleRun(ws, args[1:])
+	case "install-hook":
+		hook, changed, err := ws.InstallScheduleHook()
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Printf("Commit schedules will run after each commit (%s).\n", hook)
+		} else {
+			fmt.Printf("The hook is already installed (%s).\n", hook)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown schedule subcommand '%s'", args[0])
+	}
+}
+
+// runScheduleList prints the workspace's schedules and when each runs next.
+func runScheduleList(ws *ai.Workspace) error {
+	schedules, err := ws.ListSchedules()
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		fmt.Println("No schedules. Add one with `nani schedule add`.")
+		return nil
+	}
+	for _, s := range schedules {
+		when := "after each commit"
+		if s.Trigger == ai.TriggerCron {
+			when = fmt.Sprintf("%s (next %s)", s.Cron, formatTime(s.NextRun()))
+		}
+		output := s.Output
+		if output == "" {
+			output = "archived session"
+		}
+		fmt.Printf("  %-16s %-16s %s -> %s\n", s.Name, s.Template, when, output)
+	}
+	return nil
+}
+
+// formatTime formats t for listings, or "never" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// runScheduleAdd implements `nani schedule add`.
+func runScheduleAdd(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("schedule add", flag.ContinueOnError)
+	cron := fs.String("cron", "", "cron expression, e.g. \"0 18 * * 1-5\" or @daily")
+	onCommit := fs.Bool("on-commit", false, "run after each git commit (see `nani schedule install-hook`)")
+	role := fs.String("role", "", "role answering the prompt (default from settings)")
+	input := fs.String("input", "", "value of {{.Input}} in the template")
+	out := fs.String("out", "", "file in the project the result is written to, may contain {date} and {time} (default: an archived session)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || (*cron == "") == !*onCommit {
+		return errors.New("usage: nani schedule add (--cron expr|--on-commit) [--role r] [--input text] [--out file] <name> <template>")
+	}
+	if _, err := ws.LoadTemplate(fs.Arg(1)); err != nil {
+		return err
+	}
+
+	s := ai.Schedule{
+		Name:     fs.Arg(0),
+		Template: fs.Arg(1),
+		Trigger:  ai.TriggerCron,
+		Cron:     *cron,
+		Role:     *role,
+		Input:    *input,
+		Output:   *out,
+	}
+	if *onCommit {
+		s.Trigger = ai.TriggerCommit
+	}
+	if err := ws.SaveSchedule(s); err != nil {
+		return err
+	}
+	fmt.Printf("Added schedule %s\n", s.Name)
+	if s.Trigger == ai.TriggerCron {
+		fmt.Println("Run `nani schedule run --watch`, or `nani schedule run` from cron every minute, to run it when due.")
+	} else {
+		fmt.Println("Run `nani schedule install-hook` once to run it after each commit.")
+	}
+	return nil
+}
+
+// runScheduleRun implements `nani schedule run`. With names it runs those
+// schedules now; otherwise it runs the schedules due for the trigger. With
+// --watch it keeps checking for due cron schedules every minute until
+// interrupted.
+func runScheduleRun(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("schedule run", flag.ContinueOnError)
+	trigger := fs.String("trigger", ai.TriggerCron, "run the schedules due for this trigger: cron or commit")
+	watch := fs.Bool("watch", false, "keep running due cron schedules every minute")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newGeminiClient(ws)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if fs.NArg() > 0 {
+		var schedules []ai.Schedule
+		for _, name := range fs.Args() {
+			s, err := ws.LoadSchedule(name)
+			if err != nil {
+				return err
+			}
+			schedules = append(schedules, *s)
+		}
+		return runSchedules(ctx, ws, client, schedules)
+	}
+
+	if !*watch {
+		due, err := ws.DueSchedules(*trigger, time.Now())
+		if err != nil {
+			return err
+		}
+		return runSchedules(ctx, ws, client, due)
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		due, err := ws.DueSchedules(ai.TriggerCron, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := runSchedules(ctx, ws, client, due); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runSchedules runs each schedule in turn, printing where its result was
+// stored. A failing schedule does not stop the others.
+func runSchedules(ctx context.Context, ws *ai.Workspace, runner ai.PromptRunner, schedules []ai.Schedule) error {
+	var failed int
+	for _, s := range schedules {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		stored, err := ws.RunSchedule(ctx, runner, s, time.Now())
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "  failed     %s: %v\n", s.Name, err)
+			continue
+		}
+		fmt.Printf("  ran        %s -> %s\n", s.Name, stored)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d schedules failed", failed, len(schedules))
+	}
+	return nil
+}
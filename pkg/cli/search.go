@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// provider is the model provider selected with --provider.
+var provider = ai.ProviderGemini
+
+// providerChosen reports whether --provider was given, which overrides the
+// failover chain of `Settings.Providers`.
+var providerChosen bool
+
+// SetProvider selects the model provider commands use: "gemini", or "mock"
+// for canned responses (see `ai.MockAIClient`). Only chat commands support
+// the mock provider.
+func SetProvider(name string) error {
+	switch name {
+	case "":
+		provider, providerChosen = ai.ProviderGemini, false
+	case ai.ProviderGemini, ai.ProviderMock:
+		provider, providerChosen = name, true
+	default:
+		return fmt.Errorf("unknown provider '%s': expected %s or %s", name, ai.ProviderGemini, ai.ProviderMock)
+	}
+	return nil
+}
+
+// cassette is the cassette selected with --record or --replay, if any.
+var cassette *ai.Cassette
+
+// SetCassette makes Gemini clients capture their provider interactions to
+// the cassette file record, or replay them from the file replay (see
+// `ai.Cassette`). At most one may be given; with neither, requests go to the
+// network as usual.
+func SetCassette(record, replay string) error {
+	var err error
+	switch {
+	case record != "" && replay != "":
+		return errors.New("--record and --replay cannot be used together")
+	case record != "":
+		cassette, err = ai.OpenCassette(record, ai.CassetteRecord)
+	case replay != "":
+		cassette, err = ai.OpenCassette(replay, ai.CassetteReplay)
+	}
+	return err
+}
+
+// UsesDaemon reports whether chat commands may attach to a running workspace
+// daemon. They do not with the mock provider or a cassette, whose replies the
+// daemon would not see.
+func UsesDaemon() bool {
+	return provider == ai.ProviderGemini && cassette == nil
+}
+
+// NewChatClient creates the chat client of the selected provider for the
+// workspace. Unless --provider is given, a chain of several providers in
+// `Settings.Providers` is wrapped in an `ai.FailoverClient`. A Gemini client
+// also becomes the workspace's embedder.
+func NewChatClient(ws *ai.Workspace) (ai.AIClient, error) {
+	chain := ws.Settings().Providers
+	if providerChosen || len(chain) == 0 {
+		return newProviderClient(ws, provider)
+	}
+	if len(chain) == 1 {
+		return newProviderClient(ws, chain[0])
+	}
+	clients := make([]ai.AIClient, len(chain))
+	for i, name := range chain {
+		client, err := newProviderClient(ws, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create failover provider %s: %w", name, err)
+		}
+		clients[i] = client
+	}
+	return ai.NewFailoverClient(ws, chain, clients)
+}
+
+// newProviderClient creates the chat client of the named provider.
+func newProviderClient(ws *ai.Workspace, name string) (ai.AIClient, error) {
+	switch name {
+	case ai.ProviderMock:
+		return ai.NewMockAIClient(ws, ai.MockFixturesPath(ws))
+	case ai.ProviderGemini:
+		client, err := geminiClient(ws)
+		if err != nil {
+			return nil, err
+		}
+		ws.SetEmbedder(client)
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown provider '%s': expected %s or %s", name, ai.ProviderGemini, ai.ProviderMock)
+	}
+}
+
+// newGeminiClient creates a Gemini client for commands that only work with
+// the gemini provider.
+func newGeminiClient(ws *ai.Workspace) (*ai.GeminiAIClient, error) {
+	if provider != ai.ProviderGemini {
+		return nil, fmt.Errorf("this command needs the %s provider; the %s provider only supports chat", ai.ProviderGemini, provider)
+	}
+	return geminiClient(ws)
+}
+
+// geminiClient creates a Gemini client for the workspace. With
+// `Settings.Vertex` it reaches Vertex AI using Application Default
+// Credentials; otherwise it uses the API key stored with `nani auth login`
+// or, failing that, the GEMINI_API_KEY environment variable. Replaying a
+// cassette needs no key.
+func geminiClient(ws *ai.Workspace) (*ai.GeminiAIClient, error) {
+	var client *ai.GeminiAIClient
+	var err error
+	if vertex := ws.Settings().Vertex; vertex != nil {
+		if client, err = ai.NewVertexAIClient(*vertex, ws); err != nil {
+			return nil, fmt.Errorf("failed to initialize Vertex AI client: %w", err)
+		}
+	} else {
+		apiKey := apiKey(ai.ProviderGemini)
+		if apiKey == "" && cassette != nil && cassette.Mode() == ai.CassetteReplay {
+			apiKey = "replay"
+		}
+		if apiKey == "" {
+			return nil, errors.New("no Gemini API key: run `nani auth login` or set GEMINI_API_KEY")
+		}
+		if client, err = ai.NewGeminiAIClient(apiKey, ws); err != nil {
+			return nil, fmt.Errorf("failed to initialize Gemini client: %w", err)
+		}
+	}
+	if cassette != nil {
+		client.SetTransport(cassette)
+	}
+	return client, nil
+}
+
+// useGeminiEmbedder configures the workspace to embed text with Gemini.
+func useGeminiEmbedder(ws *ai.Workspace) error {
+	client, err := newGeminiClient(ws)
+	if err != nil {
+		return err
+	}
+	ws.SetEmbedder(client)
+	return nil
+}
+
+// runIndex implements `nani index`, which refreshes the local vector store.
+func runIndex(ws *ai.Workspace, args []string) error {
+	if err := useGeminiEmbedder(ws); err != nil {
+		return err
+	}
+	embedded, err := ws.IndexEmbeddings(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Indexed workspace artifacts (%d chunks newly embedded)\n", embedded)
+	return nil
+}
+
+// runSearch implements `nani search`, printing the chunks most relevant to a query.
+func runSearch(ws *ai.Workspace, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	k := fs.Int("k", 5, "number of results to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		return errors.New("usage: nani search [-k n] <query>")
+	}
+
+	if err := useGeminiEmbedder(ws); err != nil {
+		return err
+	}
+	results, err := ws.SemanticSearch(ctx, query, *k)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("No results. Run `nani index` to build the vector store.")
+		return nil
+	}
+	for _, r := range results {
+		fmt.Printf("%.3f  %s (%s)\n", r.Score, r.Ref, r.Kind)
+		for _, line := range strings.Split(strings.TrimSpace(r.Text), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+		fmt.Println()
+	}
+	return nil
+}
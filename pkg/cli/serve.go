@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/asaidimu/nani/pkg/server"
+)
+
+// runServe implements `nani serve`, which serves the workspace and its chat
+// over a local HTTP/JSON API until interrupted. With --socket it listens on
+// the workspace socket instead, as the daemon the interactive chat attaches
+// to when it is running.
+func runServe(ws *ai.Workspace, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:7733", "address to listen on")
+	socket := fs.Bool("socket", false, "listen on the workspace socket for the interactive chat to attach to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := NewChatClient(ws)
+	if err != nil {
+		return err
+	}
+
+	listener, err := serveListener(ws, *addr, *socket)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	srv := &http.Server{
+		Handler:     server.New(ws, client).Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx }, // Ends event streams on shutdown.
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if *socket {
+		fmt.Printf("Serving workspace daemon on %s; nani attaches to it while it runs (Ctrl+C to stop)\n", ws.SocketPath())
+	} else {
+		fmt.Printf("Serving workspace API on http://%s/api and OpenAI-compatible API on http://%[1]s/v1 (Ctrl+C to stop)\n", *addr)
+	}
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+	return nil
+}
+
+// serveListener listens on addr, or on the workspace socket if socket is
+// true. A socket left behind by a daemon that is no longer running is
+// replaced; the socket is removed when the listener is closed.
+func serveListener(ws *ai.Workspace, addr string, socket bool) (net.Listener, error) {
+	if !socket {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		return listener, nil
+	}
+
+	path := ws.SocketPath()
+	if _, err := server.Dial(path); err == nil {
+		return nil, fmt.Errorf("a daemon is already serving this workspace on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	return listener, nil
+}
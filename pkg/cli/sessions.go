@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runSessions implements `nani sessions`. Without arguments it lists the
+// archived sessions, pinned sessions first, then by priority and most recent
+// update; `resume` makes an archived session the active one, archiving the
+// current session; `end` archives the active session, or with --copy archives
+// it under a new ID when it conflicts with an earlier archive, then extracts
+// memories from it if `extractMemories` is set; `rename`, `pin`,
+// `unpin`, and `priority` change how an active or archived session is listed;
+// `delete` moves archived sessions to the trash; `export` writes the turns of
+// the sessions as fine-tuning data.
+func runSessions(ws *ai.Workspace, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if len(args) == 0 || args[0] == "list" {
+		sessions, err := ws.ListArchivedSessions()
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, s := range sessions {
+			pin := ""
+			if s.Pinned {
+				pin = "pinned"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.RoleName, formatTime(s.LastUpdated), pin, s.Priority, s.Label)
+		}
+		tw.Flush()
+		return nil
+	}
+
+	switch args[0] {
+	case "resume":
+		if len(args) != 2 {
+			return errors.New("usage: nani sessions resume <id>")
+		}
+		session, err := ws.ResumeArchivedSession(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Resumed session %s\n", session.ID)
+		return nil
+	case "end":
+		if len(args) > 2 || (len(args) == 2 && args[1] != "--copy") {
+			return errors.New("usage: nani sessions end [--copy]")
+		}
+		if len(args) == 2 {
+			id, err := ws.EndSessionAsCopy(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Archived the active session as %s\n", id)
+			endedSessionMemories(ws, id)
+			return nil
+		}
+		active, err := ws.GetActiveSession()
+		if err != nil || active == nil {
+			return err
+		}
+		err = ws.EndSession(ctx)
+		if errors.Is(err, ai.ErrArchiveConflict) {
+			return fmt.Errorf("%w; run `nani sessions end --copy` to keep both", err)
+		} else if err != nil {
+			return err
+		}
+		endedSessionMemories(ws, active.ID)
+		return nil
+	case "rename":
+		if len(args) < 3 {
+			return errors.New("usage: nani sessions rename <id> <label>")
+		}
+		return ws.RenameSession(args[1], strings.Join(args[2:], " "))
+	case "pin", "unpin":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: nani sessions %s <id>", args[0])
+		}
+		if args[0] == "pin" {
+			return ws.PinSession(args[1])
+		}
+		return ws.UnpinSession(args[1])
+	case "priority":
+		if len(args) != 3 {
+			return errors.New("usage: nani sessions priority <id> low|medium|high")
+		}
+		priority, err := ai.ParsePriority(args[2])
+		if err != nil {
+			return err
+		}
+		return ws.SetSessionPriority(args[1], priority)
+	case "delete":
+		if len(args) < 2 {
+			return errors.New("usage: nani sessions delete <id>...")
+		}
+		for _, id := range args[1:] {
+			if err := ws.DeleteSession(id); err != nil {
+				return err
+			}
+			fmt.Printf("Moved session %s to the trash; `nani trash restore` brings it back\n", id)
+		}
+		return nil
+	case "export":
+		return runSessionsExport(ws, args[1:])
+	default:
+		return fmt.Errorf("unknown sessions subcommand '%s'", args[0])
+	}
+}
+
+// runSessionsExport implements `nani sessions export`, which writes session
+// turns to a JSON Lines file for fine-tuning a model.
+func runSessionsExport(ws *ai.Workspace, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fs := flag.NewFlagSet("sessions export", flag.ContinueOnError)
+	format := fs.String("format", string(ai.TrainingOpenAI), "record format: openai or gemini")
+	roles := fs.String("role", "", "comma-separated roles whose sessions to export; all by default")
+	pinned := fs.Bool("pinned", false, "export pinned sessions only")
+	since := fs.String("since", "", "export turns sent on or after this date (YYYY-MM-DD) only")
+	scrub := fs.Bool("scrub", false, "replace email addresses, phone numbers, and IP addresses with placeholders")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: nani sessions export [--format openai|gemini] [--role r,...] [--pinned] [--since YYYY-MM-DD] [--scrub] <file.jsonl>")
+	}
+
+	filter := ai.TrainingFilter{Format: ai.TrainingFormat(*format), PinnedOnly: *pinned, ScrubPII: *scrub}
+	if *roles != "" {
+		filter.Roles = strings.Split(*roles, ",")
+	}
+	if *since != "" {
+		t, err := time.ParseInLocation("2006-01-02", *since, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --since date '%s': expected YYYY-MM-DD", *since)
+		}
+		filter.Since = t
+	}
+
+	count, err := ws.ExportTrainingData(ctx, fs.Arg(0), filter)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d training records to %s\n", count, fs.Arg(0))
+	return nil
+}
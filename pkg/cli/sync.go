@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runSync implements `nani sync`, which shares the workspace's settings,
+// roles, preferences, templates, facts, and schedules with a team through a
+// git remote. `--remote` and `--branch` set the remote, saved in the settings
+// for later runs.
+func runSync(ws *ai.Workspace, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	remote := fs.String("remote", "", "URL of the git repository to sync with, saved for later runs")
+	branch := fs.String("branch", "", "branch to sync (default main)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: nani sync [--remote url] [--branch name]")
+	}
+
+	if *remote != "" || *branch != "" {
+		config := ai.SyncConfig{}
+		if sync := ws.Settings().Sync; sync != nil {
+			config = *sync
+		}
+		if *remote != "" {
+			config.Remote = *remote
+		}
+		if *branch != "" {
+			config.Branch = *branch
+		}
+		if err := ws.SetSyncConfig(config); err != nil {
+			return err
+		}
+	}
+	sync := ws.Settings().Sync
+	if sync == nil || sync.Remote == "" {
+		return fmt.Errorf("no sync remote configured; run `nani sync --remote <url>` once to set it")
+	}
+
+	report, err := ws.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	if report.Committed {
+		fmt.Println("Committed local changes.")
+	}
+	for _, path := range report.Pulled {
+		fmt.Printf("  pulled %s\n", path)
+	}
+	fmt.Printf("Workspace synced with %s.\n", sync.Remote)
+	return nil
+}
+
+// runMergeContext implements the hidden `nani __merge-context`, the git merge
+// driver `ai.Workspace.Sync` configures for `context.json`. It merges the
+// current and other versions given as paths, writes the result over the
+// current one, and fails if both changed a field differently so that git
+// reports the conflict. It runs in the middle of a rebase and never loads a
+// workspace; ws is nil.
+func runMergeContext(ws *ai.Workspace, args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: nani " + ai.ContextMergeCommand + " <base> <current> <other>")
+	}
+	var versions [3][]byte
+	for i, path := range args {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		versions[i] = data
+	}
+	merged, conflicts, err := ai.MergeContext(versions[0], versions[1], versions[2])
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(args[1], merged, 0644); err != nil {
+		return fmt.Errorf("failed to write merged context: %w", err)
+	}
+	if len(conflicts) > 0 {
+		// git passes the driver's standard error on to `Sync`'s error.
+		err := fmt.Errorf("context.json: both sides changed %s", strings.Join(conflicts, ", "))
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runTrash implements `nani trash`, which lists the roles, preferences, and
+// sessions deleted from the workspace, most recent first; `restore` puts
+// entries back where they were deleted from, and `empty` deletes every entry
+// for good after asking.
+func runTrash(ws *ai.Workspace, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		entries, err := ws.ListTrash()
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("The trash is empty.")
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, e := range entries {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.ID, e.Kind, e.Name, formatTime(e.DeletedAt))
+		}
+		tw.Flush()
+		return nil
+	}
+
+	switch args[0] {
+	case "restore":
+		if len(args) < 2 {
+			return errors.New("usage: nani trash restore <id>...")
+		}
+		for _, id := range args[1:] {
+			if err := ws.Restore(id); err != nil {
+				return err
+			}
+			fmt.Printf("Restored %s\n", id)
+		}
+		return nil
+	case "empty":
+		if ask("Permanently delete everything in the trash [y/N]? ") != "y" {
+			fmt.Println("No changes made.")
+			return nil
+		}
+		return ws.EmptyTrash()
+	default:
+		return fmt.Errorf("unknown trash subcommand '%s'", args[0])
+	}
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// runWorkspace implements `nani workspace`, which lists the projects nani has
+// opened, as recorded in the workspace registry, or forgets one of them.
+func runWorkspace(ws *ai.Workspace, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		known, err := ai.KnownWorkspaces()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, k := range known {
+			status := formatTime(k.LastUsed)
+			if _, err := os.Stat(k.Path); err != nil {
+				status = "missing"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", k.Name, k.Path, status)
+		}
+		tw.Flush()
+		return nil
+	}
+
+	switch args[0] {
+	case "forget":
+		if len(args) != 2 {
+			return errors.New("usage: nani workspace forget <name|dir>")
+		}
+		if err := ai.ForgetWorkspace(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Forgot workspace %s\n", args[1])
+		return nil
+	default:
+		return fmt.Errorf("unknown workspace subcommand '%s'", args[0])
+	}
+}
@@ -0,0 +1,518 @@
+// Package lsp implements `nani lsp`, a language server speaking the Language
+// Server Protocol over standard input and output. Editors call into the
+// workspace through code actions and commands ("explain selection",
+// "document symbol", "suggest fix"), or through the custom methods
+// "nani/explain", "nani/document", and "nani/fix", without starting nani for
+// every request.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// Commands offered as code actions and run with "workspace/executeCommand".
+// Each takes a single `SelectionParams` argument.
+const (
+	CommandExplain  = "nani.explainSelection"
+	CommandDocument = "nani.documentSymbol"
+	CommandFix      = "nani.suggestFix"
+)
+
+// Roles answering each kind of request. Workspace roles with these names
+// override the built-in presets.
+const (
+	explainRole  = "explainer"
+	documentRole = "documenter"
+	fixRole      = "refactorer"
+)
+
+// maxContextLines is the number of lines of the surrounding document sent
+// above and below a selection.
+const maxContextLines = 200
+
+// document is an open text document.
+type document struct {
+	text     string
+	language string
+}
+
+// Server is a language server answering requests with the workspace's roles.
+type Server struct {
+	ws     *ai.Workspace
+	runner ai.PromptRunner
+
+	out     io.Writer
+	writeMu sync.Mutex // Serializes messages written to out.
+
+	mu        sync.Mutex
+	documents map[string]document           // Open documents by URI.
+	running   map[string]context.CancelFunc // Cancels requests in progress, by ID.
+	utf8      bool                          // Whether positions count UTF-8 bytes rather than UTF-16 code units.
+	shutdown  bool
+	nextID    int // ID of the next request sent to the client.
+}
+
+// New returns a server answering requests for ws with runner.
+func New(ws *ai.Workspace, runner ai.PromptRunner) *Server {
+	return &Server{
+		ws:        ws,
+		runner:    runner,
+		documents: make(map[string]document),
+		running:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Serve reads messages from in and writes responses to out until the client
+// sends "exit" or in is closed. Requests are answered concurrently, so a slow
+// model call does not hold up the editor's other requests.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+	reader := textproto.NewReader(bufio.NewReader(in))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		header, err := reader.ReadMIMEHeader()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read message header: %w", err)
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			return fmt.Errorf("invalid Content-Length: %w", err)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader.R, body); err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.respond(nil, nil, &rpcError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		switch {
+		case msg.Method == "":
+			continue // A response to a request sent to the client, such as workspace/applyEdit.
+		case msg.Method == "exit":
+			return nil
+		case msg.ID == nil:
+			s.notify(msg)
+		default:
+			ctx, cancel := context.WithCancel(context.Background())
+			s.mu.Lock()
+			s.running[string(msg.ID)] = cancel
+			s.mu.Unlock()
+			answer := func() {
+				defer func() {
+					s.mu.Lock()
+					delete(s.running, string(msg.ID))
+					s.mu.Unlock()
+					cancel()
+				}()
+				result, err := s.handle(ctx, msg)
+				if ctx.Err() != nil && err != nil {
+					err = &rpcError{Code: codeCancelled, Message: "request cancelled"}
+				}
+				s.respond(msg.ID, result, err)
+			}
+			if msg.Method == "initialize" || msg.Method == "shutdown" {
+				answer() // Later messages depend on their outcome.
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				answer()
+			}()
+		}
+	}
+}
+
+// notify handles a notification, which gets no response.
+func (s *Server) notify(msg message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if json.Unmarshal(msg.Params, &p) == nil {
+			s.documents[p.TextDocument.URI] = document{text: p.TextDocument.Text, language: p.TextDocument.LanguageID}
+		}
+	case "textDocument/didChange":
+		var p didChangeParams
+		if json.Unmarshal(msg.Params, &p) == nil && len(p.ContentChanges) > 0 {
+			doc := s.documents[p.TextDocument.URI]
+			doc.text = p.ContentChanges[len(p.ContentChanges)-1].Text // Full sync sends the whole document.
+			s.documents[p.TextDocument.URI] = doc
+		}
+	case "textDocument/didClose":
+		var p didCloseParams
+		if json.Unmarshal(msg.Params, &p) == nil {
+			delete(s.documents, p.TextDocument.URI)
+		}
+	case "$/cancelRequest":
+		var p cancelParams
+		if json.Unmarshal(msg.Params, &p) == nil {
+			if cancel, ok := s.running[string(p.ID)]; ok {
+				cancel()
+			}
+		}
+	}
+}
+
+// handle answers a request.
+func (s *Server) handle(ctx context.Context, msg message) (any, error) {
+	s.mu.Lock()
+	shutdown := s.shutdown
+	s.mu.Unlock()
+	if shutdown && msg.Method != "shutdown" {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var p initializeParams
+		json.Unmarshal(msg.Params, &p)
+		return s.initialize(p), nil
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		return nil, nil
+	case "textDocument/codeAction":
+		var p codeActionParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return codeActions(p), nil
+	case "workspace/executeCommand":
+		var p executeCommandParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil || len(p.Arguments) != 1 {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "expected a single selection argument"}
+		}
+		return s.execute(ctx, p.Command, p.Arguments[0])
+	case "nani/explain":
+		return s.withSelection(msg.Params, func(sel SelectionParams) (any, error) { return s.Explain(ctx, sel) })
+	case "nani/document":
+		return s.withSelection(msg.Params, func(sel SelectionParams) (any, error) { return s.Document(ctx, sel) })
+	case "nani/fix":
+		return s.withSelection(msg.Params, func(sel SelectionParams) (any, error) { return s.Fix(ctx, sel) })
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s is not supported", msg.Method)}
+}
+
+// initialize negotiates the position encoding and reports the server's capabilities.
+func (s *Server) initialize(p initializeParams) map[string]any {
+	encoding := "utf-16"
+	for _, e := range p.Capabilities.General.PositionEncodings {
+		if e == "utf-8" {
+			encoding = e
+		}
+	}
+	s.mu.Lock()
+	s.utf8 = encoding == "utf-8"
+	s.mu.Unlock()
+	return map[string]any{
+		"capabilities": map[string]any{
+			"positionEncoding":       encoding,
+			"textDocumentSync":       1, // Full: every change sends the whole document.
+			"codeActionProvider":     true,
+			"executeCommandProvider": map[string]any{"commands": []string{CommandExplain, CommandDocument, CommandFix}},
+		},
+		"serverInfo": map[string]string{"name": "nani"},
+	}
+}
+
+// codeActions offers nani's commands for the selected range. Suggest fix is
+// offered only when the editor reports diagnostics for the range.
+func codeActions(p codeActionParams) []CodeAction {
+	sel := SelectionParams{TextDocument: p.TextDocument, Range: p.Range, Diagnostics: p.Context.Diagnostics}
+	actions := []CodeAction{
+		{Title: "nani: Explain selection", Command: &Command{Title: "Explain selection", Command: CommandExplain, Arguments: []any{sel}}},
+		{Title: "nani: Document symbol", Kind: "refactor.rewrite", Command: &Command{Title: "Document symbol", Command: CommandDocument, Arguments: []any{sel}}},
+	}
+	if len(p.Context.Diagnostics) > 0 {
+		actions = append(actions, CodeAction{Title: "nani: Suggest fix", Kind: "quickfix",
+			Command: &Command{Title: "Suggest fix", Command: CommandFix, Arguments: []any{sel}}})
+	}
+	return actions
+}
+
+// execute runs one of nani's commands. Edits are also sent to the client
+// with "workspace/applyEdit", as editors apply the result of commands only
+// through that request.
+func (s *Server) execute(ctx context.Context, command string, argument json.RawMessage) (any, error) {
+	var sel SelectionParams
+	if err := json.Unmarshal(argument, &sel); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	var (
+		result EditResult
+		err    error
+	)
+	switch command {
+	case CommandExplain:
+		explanation, err := s.Explain(ctx, sel)
+		if err == nil {
+			s.send("window/showMessage", map[string]any{"type": 3, "message": explanation.Markdown}, false)
+		}
+		return explanation, err
+	case CommandDocument:
+		result, err = s.Document(ctx, sel)
+	case CommandFix:
+		result, err = s.Fix(ctx, sel)
+	default:
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown command %s", command)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.send("workspace/applyEdit", map[string]any{"label": "nani", "edit": result.Edit}, true)
+	return result, nil
+}
+
+// withSelection decodes params as a `SelectionParams` and passes them to run.
+func (s *Server) withSelection(params json.RawMessage, run func(SelectionParams) (any, error)) (any, error) {
+	var sel SelectionParams
+	if err := json.Unmarshal(params, &sel); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return run(sel)
+}
+
+// Explain explains the selected code with the explainer role.
+func (s *Server) Explain(ctx context.Context, sel SelectionParams) (ExplainResult, error) {
+	code, err := s.selection(sel)
+	if err != nil {
+		return ExplainResult{}, err
+	}
+	prompt := fmt.Sprintf("Explain what the following code from `%s` (lines %d-%d) does and why, in Markdown.\n\n%s\n\n%s",
+		code.path, sel.Range.Start.Line+1, sel.Range.End.Line+1, code.fenced(code.selected), code.surroundings())
+	answer, err := s.run(ctx, explainRole, prompt)
+	return ExplainResult{Markdown: answer}, err
+}
+
+// Document writes a documentation comment for the selected symbol with the
+// documenter role, returning an edit inserting it above the selection.
+func (s *Server) Document(ctx context.Context, sel SelectionParams) (EditResult, error) {
+	code, err := s.selection(sel)
+	if err != nil {
+		return EditResult{}, err
+	}
+	prompt := fmt.Sprintf("Write the documentation comment for the symbol declared in the following code from `%s`, "+
+		"in the idiomatic style of its language. Output only the comment, including comment markers, without code fences.\n\n%s\n\n%s",
+		code.path, code.fenced(code.selected), code.surroundings())
+	answer, err := s.run(ctx, documentRole, prompt)
+	if err != nil {
+		return EditResult{}, err
+	}
+	line := sel.Range.Start.Line
+	indent := code.indent(line)
+	var comment strings.Builder
+	for _, l := range strings.Split(stripFence(answer), "\n") {
+		comment.WriteString(indent + strings.TrimRight(l, " \t") + "\n")
+	}
+	start := Position{Line: line}
+	return editResult(sel.TextDocument.URI, TextEdit{Range: Range{Start: start, End: start}, NewText: comment.String()}), nil
+}
+
+// Fix proposes a fix for the selected code, addressing the given
+// diagnostics if any, with the refactorer role. The edit replaces the selection.
+func (s *Server) Fix(ctx context.Context, sel SelectionParams) (EditResult, error) {
+	code, err := s.selection(sel)
+	if err != nil {
+		return EditResult{}, err
+	}
+	var problems strings.Builder
+	for _, d := range sel.Diagnostics {
+		fmt.Fprintf(&problems, "- line %d: %s\n", d.Range.Start.Line+1, d.Message)
+	}
+	if problems.Len() == 0 {
+		problems.WriteString("- Find and fix the most likely bug.\n")
+	}
+	prompt := fmt.Sprintf("Fix the following code from `%s`. Problems reported:\n%s\n"+
+		"Output only the replacement for the selected code, without code fences or explanations, keeping its indentation.\n\n%s\n\n%s",
+		code.path, problems.String(), code.fenced(code.selected), code.surroundings())
+	answer, err := s.run(ctx, fixRole, prompt)
+	if err != nil {
+		return EditResult{}, err
+	}
+	replacement := stripFence(answer)
+	if code.rng.Start.Character == 0 && strings.TrimLeft(replacement, " \t") == replacement {
+		replacement = code.indent(code.start) + replacement // Answers come back trimmed.
+	}
+	if strings.HasSuffix(code.selected, "\n") && !strings.HasSuffix(replacement, "\n") {
+		replacement += "\n"
+	}
+	return editResult(sel.TextDocument.URI, TextEdit{Range: code.rng, NewText: replacement}), nil
+}
+
+// run answers prompt in the named role.
+func (s *Server) run(ctx context.Context, roleName, prompt string) (string, error) {
+	role, err := s.ws.ResolveRole(roleName)
+	if err != nil {
+		return "", &rpcError{Code: codeRequestFailed, Message: err.Error()}
+	}
+	answer, err := s.runner.RunPrompt(ctx, role, prompt)
+	if err != nil {
+		return "", &rpcError{Code: codeRequestFailed, Message: err.Error()}
+	}
+	return answer, nil
+}
+
+// selectedCode is the code a request works on.
+type selectedCode struct {
+	path     string   // Path of the document, relative to the project when inside it.
+	language string   // Language identifier of the document, used to label code fences.
+	lines    []string // Lines of the document.
+	selected string   // The selected text.
+	rng      Range    // Range of the selected text; the whole line for an empty selection.
+	start    int      // First selected line.
+	end      int      // Last selected line.
+}
+
+// selection returns the code sel refers to, read from the open document or,
+// if the editor has not opened it, from disk.
+func (s *Server) selection(sel SelectionParams) (selectedCode, error) {
+	s.mu.Lock()
+	doc, open := s.documents[sel.TextDocument.URI]
+	utf8Encoding := s.utf8
+	s.mu.Unlock()
+
+	path := sel.TextDocument.URI
+	if u, err := url.Parse(sel.TextDocument.URI); err == nil && u.Scheme == "file" {
+		path = filepath.FromSlash(u.Path)
+	}
+	if !open {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return selectedCode{}, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("failed to read %s: %v", sel.TextDocument.URI, err)}
+		}
+		doc.text = string(data)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+
+	code := selectedCode{path: filepath.ToSlash(path), language: doc.language, lines: strings.Split(doc.text, "\n")}
+	if code.language == "" {
+		code.language = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	r := sel.Range
+	if r.Start.Line < 0 || r.Start.Line >= len(code.lines) || r.End.Line < r.Start.Line {
+		return selectedCode{}, &rpcError{Code: codeInvalidParams, Message: "selection is outside the document"}
+	}
+	code.start, code.end, code.rng = r.Start.Line, min(r.End.Line, len(code.lines)-1), r
+	if r.Start == r.End {
+		// An empty selection stands for the line of the cursor.
+		code.selected = code.lines[code.start] + "\n"
+		code.rng = Range{Start: Position{Line: code.start}, End: Position{Line: code.start + 1}}
+		return code, nil
+	}
+	from := byteOffset(code.lines[code.start], r.Start.Character, utf8Encoding)
+	to := byteOffset(code.lines[code.end], r.End.Character, utf8Encoding)
+	if code.start == code.end {
+		code.selected = code.lines[code.start][from:max(from, to)]
+		return code, nil
+	}
+	parts := []string{code.lines[code.start][from:]}
+	parts = append(parts, code.lines[code.start+1:code.end]...)
+	code.selected = strings.Join(append(parts, code.lines[code.end][:to]), "\n")
+	return code, nil
+}
+
+// fenced wraps text in a code fence labelled with the document's language.
+func (c selectedCode) fenced(text string) string {
+	return fmt.Sprintf("```%s\n%s\n```", c.language, strings.TrimRight(text, "\n"))
+}
+
+// surroundings returns the lines around the selection, numbered, as context.
+func (c selectedCode) surroundings() string {
+	from, to := max(c.start-maxContextLines, 0), min(c.end+maxContextLines, len(c.lines)-1)
+	var b strings.Builder
+	for n := from; n <= to; n++ {
+		fmt.Fprintf(&b, "%5d| %s\n", n+1, c.lines[n])
+	}
+	return fmt.Sprintf("Surrounding code of `%s`, with line numbers:\n\n```%s\n%s```", c.path, c.language, b.String())
+}
+
+// indent returns the leading whitespace of a line.
+func (c selectedCode) indent(line int) string {
+	text := c.lines[line]
+	return text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+}
+
+// stripFence removes a code fence wrapping the whole of text, if any.
+func stripFence(text string) string {
+	text = strings.Trim(text, "\r\n")
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "```") {
+		return text
+	}
+	_, body, found := strings.Cut(trimmed, "\n")
+	if !found {
+		return trimmed
+	}
+	return strings.TrimRight(strings.TrimSuffix(strings.TrimRight(body, "\n"), "```"), "\n")
+}
+
+// editResult returns an edit result making a single edit to uri.
+func editResult(uri string, edit TextEdit) EditResult {
+	return EditResult{Edit: WorkspaceEdit{Changes: map[string][]TextEdit{uri: {edit}}}}
+}
+
+// respond writes the response to the request with the given ID.
+func (s *Server) respond(id json.RawMessage, result any, err error) {
+	response := map[string]any{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	s.write(response)
+}
+
+// send writes a notification, or a request if request is set, to the client.
+// Responses to requests are not awaited.
+func (s *Server) send(method string, params any, request bool) {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if request {
+		s.mu.Lock()
+		s.nextID++
+		msg["id"] = fmt.Sprintf("nani-%d", s.nextID)
+		s.mu.Unlock()
+	}
+	s.write(msg)
+}
+
+// write frames v as a message and writes it to the client.
+func (s *Server) write(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
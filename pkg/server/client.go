@@ -0,0 +1,164 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/google/uuid"
+)
+
+// Client is an `ai.AIClient` that sends messages through a daemon started
+// with `nani serve --socket`, so generations outlive the terminal and several
+// terminals can share a session.
+type Client struct {
+	http *http.Client
+	id   string
+}
+
+// Dial connects to the daemon listening on socketPath and checks that it
+// responds.
+func Dial(socketPath string) (*Client, error) {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	c := &Client{http: &http.Client{Transport: transport}, id: uuid.New().String()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.do(ctx, http.MethodGet, "/api/project", nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to reach daemon at %s: %w", socketPath, err)
+	}
+	return c, nil
+}
+
+// ID returns the identifier the client sends with its messages, as found in
+// the `Event.Client` of the events they cause.
+func (c *Client) ID() string {
+	return c.id
+}
+
+// StartSession implements `ai.AIClient`, starting the daemon's chat for the
+// active session if it is not already running.
+func (c *Client) StartSession(ctx context.Context) (ai.Response, error) {
+	var reply MessageResponse
+	if err := c.do(ctx, http.MethodPost, "/api/session/start", nil, &reply); err != nil {
+		return ai.Response{}, err
+	}
+	return reply.response(), nil
+}
+
+// SendMessage implements `ai.AIClient`. The daemon records every message in
+// the active session, so save and history are ignored. If ctx ends first the
+// generation still completes in the daemon.
+func (c *Client) SendMessage(ctx context.Context, message ai.SavedMessage, history []ai.Message, save bool) (ai.Response, error) {
+	req := MessageRequest{Content: message.Content, ReplyTo: message.ReplyTo, Attachments: message.Attachments}
+	var reply MessageResponse
+	if err := c.do(ctx, http.MethodPost, "/api/messages", req, &reply); err != nil {
+		return ai.Response{}, err
+	}
+	return reply.response(), nil
+}
+
+// Events subscribes to the daemon's chat events. The channel is closed when
+// ctx ends or the connection to the daemon is lost.
+func (c *Client) Events(ctx context.Context) (<-chan Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/api/events", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to daemon events: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var e Event
+			if json.Unmarshal([]byte(data), &e) != nil {
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// do sends a request with body encoded as JSON, if not nil, and decodes the
+// response into out, if not nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ClientHeader, c.id)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach daemon: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// responseError converts an error response from the daemon into an error,
+// restoring the sentinel errors callers test for.
+func responseError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error == "" {
+		body.Error = resp.Status
+	}
+	switch resp.StatusCode {
+	case http.StatusServiceUnavailable:
+		return fmt.Errorf("%w: %s", ai.ErrProviderUnavailable, body.Error)
+	case http.StatusRequestEntityTooLarge:
+		return fmt.Errorf("%w: %s", ai.ErrContextTooLarge, body.Error)
+	}
+	return fmt.Errorf("daemon: %s", body.Error)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Types of `Event`.
+const (
+	EventMessage  = "message"  // A message was sent and its generation started.
+	EventChunk    = "chunk"    // A piece of the reply was generated.
+	EventResponse = "response" // The reply is complete.
+	EventError    = "error"    // The generation failed.
+)
+
+// Event is a chat event broadcast to every client attached through
+// `GET /api/events`, so terminals sharing a daemon see each other's turns.
+type Event struct {
+	Type     string           `json:"type"`               // EventMessage, EventChunk, EventResponse, or EventError.
+	Request  string           `json:"request"`            // ID of the message request the event belongs to.
+	Client   string           `json:"client,omitempty"`   // ID of the client that sent the message, if it gave one.
+	Message  *MessageRequest  `json:"message,omitempty"`  // The message, for EventMessage.
+	Text     string           `json:"text,omitempty"`     // The generated piece, for EventChunk.
+	Response *MessageResponse `json:"response,omitempty"` // The reply, for EventResponse.
+	Error    string           `json:"error,omitempty"`    // The failure, for EventError.
+}
+
+// eventBuffer is the number of events queued for each subscriber. Events for
+// a subscriber that falls further behind are dropped.
+const eventBuffer = 256
+
+// hub broadcasts events to subscribers and remembers the message whose
+// generation is in progress, so subscribers attaching mid-generation learn
+// of it.
+type hub struct {
+	mu      sync.Mutex
+	subs    map[chan Event]struct{}
+	pending *Event // EventMessage of the generation in progress, if any.
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[chan Event]struct{})}
+}
+
+// subscribe returns a channel receiving every event published from now on,
+// preceded by the pending message if a generation is in progress, and a
+// function ending the subscription.
+func (h *hub) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pending != nil {
+		ch <- *h.pending
+	}
+	h.subs[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs, ch)
+	}
+}
+
+// publish sends e to every subscriber without blocking.
+func (h *hub) publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch e.Type {
+	case EventMessage:
+		h.pending = &e
+	case EventResponse, EventError:
+		h.pending = nil
+	}
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// handleEvents streams every chat event to the client as server-sent events
+// until it disconnects.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errStreamingUnsupported)
+		return
+	}
+	events, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case e := <-events:
+			writeEvent(w, e.Type, e)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
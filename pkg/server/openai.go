@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/google/uuid"
+)
+
+// defaultCompletionModel is the model name that selects the workspace's
+// default role in the OpenAI-compatible API.
+const defaultCompletionModel = "nani"
+
+// openAIRequest is the subset of an OpenAI chat completion request that is
+// honoured. Sampling parameters come from the workspace role instead.
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+}
+
+// openAIMessage is a chat message. Content is either a string or an array of
+// content parts, of which only text parts are used.
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text returns the message's text content.
+func (m openAIMessage) text() (string, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", fmt.Errorf("invalid content of %s message: %w", m.Role, err)
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+type openAIChoice struct {
+	Index        int          `json:"index"`
+	Message      *openAIReply `json:"message,omitempty"`
+	Delta        *openAIReply `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type openAIReply struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+// handleOpenAIModels lists the workspace roles as models, so clients can pick
+// the role answering their requests by model name.
+func (s *Server) handleOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	roles, err := s.ws.ListRoles()
+	if err != nil {
+		writeOpenAIError(w, statusFor(err), err)
+		return
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	models := []model{{ID: defaultCompletionModel, Object: "model", OwnedBy: "nani"}}
+	for _, role := range roles {
+		models = append(models, model{ID: role.Name, Object: "model", OwnedBy: "nani"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
+
+// handleOpenAICompletions implements an OpenAI-compatible chat completion
+// endpoint. The request's model names the workspace role answering it; any
+// other name, including "nani", uses the default role. The role's persona,
+// the workspace system prompt, and preferences are injected ahead of the
+// client's own system messages. Nothing is recorded in the session.
+func (s *Server) handleOpenAICompletions(w http.ResponseWriter, r *http.Request) {
+	completer, ok := s.client.(ai.Completer)
+	if !ok {
+		writeOpenAIError(w, http.StatusNotImplemented, errors.New("the configured provider does not support completions"))
+		return
+	}
+	var req openAIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	messages, err := completionMessages(req.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err)
+		return
+	}
+	role, err := s.completionRole(req.Model)
+	if err != nil {
+		writeOpenAIError(w, statusFor(err), err)
+		return
+	}
+	model := req.Model
+	if model == "" {
+		model = defaultCompletionModel
+	}
+	reply := openAIResponse{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+	}
+
+	flusher, canFlush := w.(http.Flusher)
+	if !req.Stream || !canFlush {
+		completion, err := completer.Complete(r.Context(), role, messages, nil)
+		if err != nil {
+			writeOpenAIError(w, statusFor(err), err)
+			return
+		}
+		finish := openAIFinishReason(completion.FinishReason)
+		reply.Choices = []openAIChoice{{Message: &openAIReply{Role: "assistant", Content: completion.Content}, FinishReason: &finish}}
+		reply.Usage = newOpenAIUsage(completion.Usage)
+		writeJSON(w, http.StatusOK, reply)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	reply.Object = "chat.completion.chunk"
+	first := true
+	completion, err := completer.Complete(r.Context(), role, messages, func(text string) {
+		delta := &openAIReply{Content: text}
+		if first {
+			delta.Role, first = "assistant", false
+		}
+		reply.Choices = []openAIChoice{{Delta: delta}}
+		writeOpenAIChunk(w, reply)
+		flusher.Flush()
+	})
+	if err != nil {
+		writeOpenAIChunk(w, map[string]any{"error": openAIErrorBody(err)})
+	} else {
+		finish := openAIFinishReason(completion.FinishReason)
+		reply.Choices = []openAIChoice{{Delta: &openAIReply{}, FinishReason: &finish}}
+		reply.Usage = newOpenAIUsage(completion.Usage)
+		writeOpenAIChunk(w, reply)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// completionRole returns the role named by an OpenAI model name, or "" for
+// the default role if the name is not a workspace role.
+func (s *Server) completionRole(model string) (string, error) {
+	roles, err := s.ws.ListRoles()
+	if err != nil {
+		return "", err
+	}
+	for _, role := range roles {
+		if role.Name == model {
+			return role.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// completionMessages converts OpenAI messages into completion messages.
+// Developer messages are treated as system messages; messages of other roles,
+// such as tool results, are not supported.
+func completionMessages(messages []openAIMessage) ([]ai.CompletionMessage, error) {
+	converted := make([]ai.CompletionMessage, 0, len(messages))
+	conversation := false
+	for _, m := range messages {
+		text, err := m.text()
+		if err != nil {
+			return nil, err
+		}
+		role := m.Role
+		switch role {
+		case "developer":
+			role = ai.CompletionSystem
+		case ai.CompletionSystem, ai.CompletionUser, ai.CompletionAssistant:
+		default:
+			return nil, fmt.Errorf("unsupported message role '%s'", m.Role)
+		}
+		converted = append(converted, ai.CompletionMessage{Role: role, Content: text})
+		conversation = conversation || role != ai.CompletionSystem
+	}
+	if !conversation {
+		return nil, errors.New("messages must include at least one user message")
+	}
+	return converted, nil
+}
+
+// openAIFinishReason maps a provider finish reason to its OpenAI equivalent.
+func openAIFinishReason(reason string) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return "content_filter"
+	}
+	return "stop"
+}
+
+// newOpenAIUsage converts token usage to its OpenAI form.
+func newOpenAIUsage(u *ai.Usage) *openAIUsage {
+	if u == nil {
+		return nil
+	}
+	return &openAIUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+// openAIErrorBody returns the OpenAI error object describing err.
+func openAIErrorBody(err error) map[string]string {
+	return map[string]string{"message": err.Error(), "type": "server_error"}
+}
+
+// writeOpenAIError writes err in the OpenAI error format.
+func writeOpenAIError(w http.ResponseWriter, status int, err error) {
+	body := openAIErrorBody(err)
+	if status < http.StatusInternalServerError {
+		body["type"] = "invalid_request_error"
+	}
+	writeJSON(w, status, map[string]any{"error": body})
+}
+
+// writeOpenAIChunk writes v as a server-sent event without an event type, as
+// OpenAI streaming clients expect.
+func writeOpenAIChunk(w http.ResponseWriter, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
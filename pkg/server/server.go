@@ -0,0 +1,452 @@
+// Package server exposes a workspace and its chat over a local HTTP/JSON API,
+// so editors and other tools can integrate with nani. It is started by
+// `nani serve`.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/google/uuid"
+)
+
+// ClientHeader is the request header a client identifies itself with, so it
+// can recognize its own messages among the broadcast events.
+const ClientHeader = "X-Nani-Client"
+
+// generationTimeout bounds a single generation. Generations are detached
+// from the request that started them, so they complete and are saved even
+// if the client disconnects.
+const generationTimeout = 5 * time.Minute
+
+var errStreamingUnsupported = errors.New("streaming is not supported by the connection")
+
+// Server serves the HTTP API of a workspace. Messages are sent one at a time,
+// since they all continue the workspace's single active session.
+type Server struct {
+	ws     *ai.Workspace
+	client ai.AIClient
+	events *hub
+
+	mu        sync.Mutex  // Serializes chat requests.
+	sessionID string      // ID of the session the client's chat was started for.
+	greeting  ai.Response // Reply to starting that session.
+}
+
+// New returns a server for the workspace, answering messages with client.
+func New(ws *ai.Workspace, client ai.AIClient) *Server {
+	return &Server{ws: ws, client: client, events: newHub()}
+}
+
+// MessageRequest is the body of `POST /api/messages`.
+type MessageRequest struct {
+	Content     string          `json:"content"`               // Prompt text.
+	ReplyTo     string          `json:"replyTo,omitempty"`     // ID of the chat entry the message quotes, if any.
+	Attachments []ai.Attachment `json:"attachments,omitempty"` // Files sent with the prompt, within the project and readable by the server.
+}
+
+// MessageResponse is the reply to a message: the model's structured response
+// with the ID of the saved chat entry and how the response was produced.
+type MessageResponse struct {
+	ai.Response
+	ChatID       string    `json:"chatId,omitempty"`
+	Provider     string    `json:"provider,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	LatencyMs    int64     `json:"latencyMs"`
+	FinishReason string    `json:"finishReason,omitempty"`
+	Usage        *ai.Usage `json:"usage,omitempty"`
+}
+
+// response converts r back into a client response.
+func (r MessageResponse) response() ai.Response {
+	response := r.Response
+	response.ChatID = r.ChatID
+	response.Provider = r.Provider
+	response.Model = r.Model
+	response.Latency = time.Duration(r.LatencyMs) * time.Millisecond
+	response.FinishReason = r.FinishReason
+	response.Usage = r.Usage
+	return response
+}
+
+// NewMessageResponse converts a client response into its JSON form, as
+// returned by the API and by `nani ask --output json`.
+func NewMessageResponse(r ai.Response) MessageResponse {
+	return MessageResponse{
+		Response:     r,
+		ChatID:       r.ChatID,
+		Provider:     r.Provider,
+		Model:        r.Model,
+		LatencyMs:    r.Latency.Milliseconds(),
+		FinishReason: r.FinishReason,
+		Usage:        r.Usage,
+	}
+}
+
+// Handler returns the HTTP handler serving the API:
+//
+//	GET  /api/project      project metadata
+//	GET  /api/session      the active session
+//	GET  /api/sessions     archived session summaries
+//	GET  /api/roles        role summaries
+//	GET  /api/preferences  preference summaries
+//	GET  /api/templates    prompt templates
+//	POST /api/session/start  start the chat for the active session
+//	POST /api/messages     send a message; streamed as server-sent events
+//	                       when the request accepts text/event-stream
+//	GET  /api/events       server-sent events for every message sent
+//
+// The session, role, and preference lists accept `sort`, `order`, `offset`,
+// `limit`, and `q` (filter) query parameters (see `ai.ListOptions`) and report
+// the number of matching items in the `X-Total-Count` header.
+//
+// and an OpenAI-compatible API answered in the workspace's roles:
+//
+//	GET  /v1/models            the workspace roles, as models
+//	POST /v1/chat/completions  chat completions, optionally streamed
+//
+// Requests must be addressed to a loopback host, come from no other origin,
+// and POST JSON; see `guard`.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/project", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.ws.Project())
+	})
+	mux.HandleFunc("GET /api/session", func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.ws.GetActiveSession()
+		if err == nil && session == nil {
+			err = ai.ErrNoActiveSession
+		}
+		respond(w, session, err)
+	})
+	mux.HandleFunc("GET /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		opts, err := listOptions(r)
+		if err != nil {
+			respond(w, nil, err)
+			return
+		}
+		sessions, total, err := s.ws.ListArchivedSessionsWith(opts)
+		respondPage(w, sessions, total, err)
+	})
+	mux.HandleFunc("GET /api/roles", func(w http.ResponseWriter, r *http.Request) {
+		opts, err := listOptions(r)
+		if err != nil {
+			respond(w, nil, err)
+			return
+		}
+		roles, total, err := s.ws.ListRolesWith(opts)
+		respondPage(w, roles, total, err)
+	})
+	mux.HandleFunc("GET /api/preferences", func(w http.ResponseWriter, r *http.Request) {
+		opts, err := listOptions(r)
+		if err != nil {
+			respond(w, nil, err)
+			return
+		}
+		preferences, total, err := s.ws.ListPreferencesWith(opts)
+		respondPage(w, preferences, total, err)
+	})
+	mux.HandleFunc("GET /api/templates", func(w http.ResponseWriter, r *http.Request) {
+		templates, err := s.ws.ListTemplates()
+		respond(w, templates, err)
+	})
+	mux.HandleFunc("POST /api/session/start", s.handleStart)
+	mux.HandleFunc("POST /api/messages", s.handleMessage)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	mux.HandleFunc("GET /v1/models", s.handleOpenAIModels)
+	mux.HandleFunc("POST /v1/chat/completions", s.handleOpenAICompletions)
+	return guard(mux)
+}
+
+// guard rejects requests a web page may have sent to the API: any page can
+// make the browser POST a form to a local port, and DNS rebinding lets it
+// read the responses under a host name of its own. Requests must name a
+// loopback host, carry no Origin other than the host itself, and POST with
+// an application/json body, which pages can only send after a CORS preflight
+// the API never answers.
+func guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !loopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed; use localhost", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeError(w, http.StatusForbidden, fmt.Errorf("cross-origin requests from %q are not allowed", origin))
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, errors.New("requests must have Content-Type application/json"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loopbackHost reports whether host, as in a Host header, names the local
+// machine: localhost or a loopback address, with or without a port.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handleStart starts the chat for the active session, creating a session if
+// there is none, and returns the model's greeting.
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	greeting, err := s.startSession(r.Context())
+	respond(w, NewMessageResponse(greeting), err)
+}
+
+// outcome is the result of a generation.
+type outcome struct {
+	response ai.Response
+	err      error
+}
+
+// handleMessage sends a message to the active session and returns the reply,
+// either as a single JSON document or, when the client accepts
+// text/event-stream, as server-sent events: "chunk" events carrying raw
+// output as it is generated, then a "response" or an "error" event. The
+// generation continues if the client disconnects.
+func (s *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	var req MessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid message: %w", err))
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("message content is required"))
+		return
+	}
+	for i, a := range req.Attachments {
+		attachment, err := s.attachment(a.Path)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		req.Attachments[i] = attachment
+	}
+
+	id := uuid.New().String()
+	flusher, canFlush := w.(http.Flusher)
+	stream := strings.Contains(r.Header.Get("Accept"), "text/event-stream") && canFlush
+	var events <-chan Event
+	if stream {
+		var unsubscribe func()
+		events, unsubscribe = s.events.subscribe()
+		defer unsubscribe()
+	}
+	done := make(chan outcome, 1)
+	go s.generate(id, r.Header.Get(ClientHeader), req, done)
+
+	if !stream {
+		select {
+		case out := <-done:
+			respond(w, NewMessageResponse(out.response), out.err)
+		case <-r.Context().Done():
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	writeChunk := func(e Event) {
+		if e.Request == id && e.Type == EventChunk {
+			writeEvent(w, EventChunk, map[string]string{"text": e.Text})
+		}
+	}
+	for {
+		select {
+		case e := <-events:
+			writeChunk(e)
+			flusher.Flush()
+		case out := <-done:
+			for len(events) > 0 {
+				writeChunk(<-events)
+			}
+			if out.err != nil {
+				writeEvent(w, EventError, map[string]string{"error": out.err.Error()})
+			} else {
+				writeEvent(w, EventResponse, NewMessageResponse(out.response))
+			}
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// attachment validates a file a client asked to attach. The server reads it
+// and sends it to the provider on the client's behalf, so only files within
+// the workspace's scope are accepted, after resolving symbolic links.
+func (s *Server) attachment(path string) (ai.Attachment, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ai.Attachment{}, fmt.Errorf("failed to resolve attachment %s: %w", path, err)
+	}
+	dir, err := filepath.EvalSymlinks(s.ws.ScopeDir())
+	if err != nil {
+		return ai.Attachment{}, fmt.Errorf("failed to resolve project directory: %w", err)
+	}
+	if rel, err := filepath.Rel(dir, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ai.Attachment{}, fmt.Errorf("attachment %s is outside the project", path)
+	}
+	return ai.NewAttachment(resolved)
+}
+
+// generate sends the message identified by id, broadcasting its progress,
+// and reports the outcome on done.
+func (s *Server) generate(id, clientID string, req MessageRequest, done chan<- outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), generationTimeout)
+	defer cancel()
+
+	response, err := s.send(ctx, id, clientID, req)
+	if err != nil {
+		s.events.publish(Event{Type: EventError, Request: id, Client: clientID, Error: err.Error()})
+	} else {
+		reply := NewMessageResponse(response)
+		s.events.publish(Event{Type: EventResponse, Request: id, Client: clientID, Response: &reply})
+	}
+	done <- outcome{response, err}
+}
+
+// send starts the chat if needed and sends the message, streaming the reply
+// to subscribers when the client supports it. The caller must hold s.mu.
+func (s *Server) send(ctx context.Context, id, clientID string, req MessageRequest) (ai.Response, error) {
+	if _, err := s.startSession(ctx); err != nil {
+		return ai.Response{}, err
+	}
+	s.events.publish(Event{Type: EventMessage, Request: id, Client: clientID, Message: &req})
+
+	message := ai.SavedMessage{Content: req.Content, ReplyTo: req.ReplyTo, Attachments: req.Attachments}
+	if streamer, ok := s.client.(ai.MessageStreamer); ok {
+		return streamer.StreamMessage(ctx, message, true, func(text string) {
+			s.events.publish(Event{Type: EventChunk, Request: id, Client: clientID, Text: text})
+		})
+	}
+	return s.client.SendMessage(ctx, message, nil, true)
+}
+
+// startSession (re)starts the client's chat when the active session differs
+// from the one it was started for, as after another process resumed a
+// different session, and returns the greeting. The caller must hold s.mu.
+func (s *Server) startSession(ctx context.Context) (ai.Response, error) {
+	if err := s.ws.Reload(ctx); err != nil {
+		return ai.Response{}, fmt.Errorf("failed to reload workspace: %w", err)
+	}
+	active, err := s.ws.GetActiveSession()
+	if err != nil {
+		return ai.Response{}, err
+	}
+	if active != nil && active.ID == s.sessionID {
+		return s.greeting, nil
+	}
+
+	greeting, err := s.client.StartSession(ctx)
+	if err != nil {
+		return ai.Response{}, err
+	}
+	if active, err = s.ws.GetActiveSession(); err == nil && active != nil {
+		s.sessionID = active.ID
+	}
+	s.greeting = greeting
+	return greeting, nil
+}
+
+// respond writes v as JSON, or err with a matching status code if it is not nil.
+func respond(w http.ResponseWriter, v any, err error) {
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// listOptions reads the `sort`, `order`, `offset`, `limit`, and `q` query
+// parameters of a list request.
+func listOptions(r *http.Request) (ai.ListOptions, error) {
+	q := r.URL.Query()
+	opts := ai.ListOptions{SortBy: q.Get("sort"), Order: ai.SortOrder(q.Get("order")), Filter: q.Get("q")}
+	for name, dst := range map[string]*int{"offset": &opts.Offset, "limit": &opts.Limit} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return opts, fmt.Errorf("%w: %s must be a number", ai.ErrInvalidListOptions, name)
+			}
+			*dst = n
+		}
+	}
+	return opts, nil
+}
+
+// respondPage writes a page of a list, with the number of items matching the
+// request's filter in the `X-Total-Count` header.
+func respondPage(w http.ResponseWriter, v any, total int, err error) {
+	if err == nil {
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	}
+	respond(w, v, err)
+}
+
+// statusFor maps workspace and client errors to HTTP status codes.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ai.ErrNoActiveSession), errors.Is(err, ai.ErrSessionNotFound),
+		errors.Is(err, ai.ErrRoleNotFound), errors.Is(err, ai.ErrChatNotFound),
+		errors.Is(err, ai.ErrPreferenceNotFound), errors.Is(err, ai.ErrTemplateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ai.ErrInvalidListOptions):
+		return http.StatusBadRequest
+	case errors.Is(err, ai.ErrContextTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ai.ErrProviderUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as a JSON `{"error": "..."}` response.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeEvent writes v as a JSON-encoded server-sent event of the given type.
+func writeEvent(w http.ResponseWriter, event string, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	handler := guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{name: "loopback GET", method: http.MethodGet, host: "127.0.0.1:8080", want: http.StatusOK},
+		{name: "localhost GET", method: http.MethodGet, host: "localhost:8080", want: http.StatusOK},
+		{name: "IPv6 loopback GET", method: http.MethodGet, host: "[::1]:8080", want: http.StatusOK},
+		{name: "JSON POST", method: http.MethodPost, host: "localhost:8080", contentType: "application/json; charset=utf-8", want: http.StatusOK},
+		{name: "same-origin POST", method: http.MethodPost, host: "localhost:8080", origin: "http://localhost:8080", contentType: "application/json", want: http.StatusOK},
+		{name: "rebound host name", method: http.MethodGet, host: "attacker.example:8080", want: http.StatusForbidden},
+		{name: "LAN address", method: http.MethodGet, host: "192.168.1.10:8080", want: http.StatusForbidden},
+		{name: "foreign origin", method: http.MethodPost, host: "localhost:8080", origin: "https://attacker.example", contentType: "application/json", want: http.StatusForbidden},
+		{name: "null origin", method: http.MethodGet, host: "localhost:8080", origin: "null", want: http.StatusForbidden},
+		{name: "form POST", method: http.MethodPost, host: "localhost:8080", contentType: "application/x-www-form-urlencoded", want: http.StatusUnsupportedMediaType},
+		{name: "text POST", method: http.MethodPost, host: "localhost:8080", contentType: "text/plain", want: http.StatusUnsupportedMediaType},
+		{name: "POST without content type", method: http.MethodPost, host: "localhost:8080", want: http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/messages", strings.NewReader(`{"content": "hi"}`))
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %q)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package ui
+
+import "github.com/charmbracelet/lipgloss"
+
+// Option configures the `Model` created by `New`.
+type Option func(*Model)
+
+// WithAccessible starts the UI in accessible mode, for screen readers and
+// simple terminals. Panes have no borders or colors and are stacked top to
+// bottom, messages start with plain "YOU:" and "AI:" prefixes, Markdown is
+// rendered as plain ASCII, and the spinner is replaced by a static
+// "Thinking..." so the screen only changes when there is something new.
+func WithAccessible() Option {
+	return func(m *Model) {
+		m.accessible = true
+	}
+}
+
+// useAccessibleStyles replaces the styles with ones that draw no borders and
+// use no colors, relying on bold and reverse video for emphasis. The pane
+// borders are hidden rather than removed, so the layout is unchanged.
+func useAccessibleStyles() {
+	plain := lipgloss.NewStyle()
+	HistoryStyle = HistoryStyle.Border(lipgloss.HiddenBorder()).UnsetBorderForeground()
+	PromptStyle = PromptStyle.Border(lipgloss.HiddenBorder()).UnsetBorderForeground()
+	PreviewStyle = PreviewStyle.Border(lipgloss.HiddenBorder()).UnsetBorderForeground()
+	ZenStyle = PreviewStyle
+	ErrorPanelStyle = ErrorPanelStyle.Border(lipgloss.HiddenBorder()).UnsetBorderForeground()
+	TitleStyle = plain.Bold(true)
+	FocusedTitleStyle = plain.Bold(true).Reverse(true)
+	UserMsgStyle = plain.Bold(true)
+	AIMsgStyle = plain
+	SelectedMsgStyle = plain.Border(lipgloss.Border{Left: ">"}, false, false, false, true).PaddingLeft(1)
+	NewBelowStyle = plain.Reverse(true)
+	HelpStyle = plain
+	ErrorStyle = plain.Bold(true)
+}
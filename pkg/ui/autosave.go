@@ -0,0 +1,162 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// autosaveInterval is how often volatile UI state is flushed to the workspace.
+const autosaveInterval = 5 * time.Second
+
+// autosaveMsg triggers a periodic flush of volatile UI state.
+type autosaveMsg struct{}
+
+// autosaveTick schedules the next autosaveMsg.
+func autosaveTick() tea.Cmd {
+	return tea.Tick(autosaveInterval, func(time.Time) tea.Msg {
+		return autosaveMsg{}
+	})
+}
+
+// sessionState snapshots the model's volatile UI state.
+func (m *Model) sessionState() ai.SessionState {
+	return ai.SessionState{
+		HistoryOffset: m.history.YOffset,
+		PreviewOffset: m.content.YOffset,
+	}
+}
+
+// autosave flushes the draft, the volatile UI state, and the UI preferences to
+// the workspace if they changed since the last flush. Failures are ignored:
+// autosave is best-effort and must never interrupt the user. Nothing is
+// flushed while the startup banner is shown, as the saved draft has not been
+// restored yet.
+func (m *Model) autosave() {
+	if m.banner != nil {
+		return
+	}
+	m.saveDraft()
+	if state := m.sessionState(); state != m.lastSaved {
+		if err := m.workspace.SaveSessionState(state); err == nil {
+			m.lastSaved = state
+		}
+	}
+	m.savePreferences()
+}
+
+// saveDraft keeps the text of the input box as the draft of the active
+// session in `drafts/` if it changed since it was last saved. An empty input
+// box removes the draft.
+func (m *Model) saveDraft() error {
+	session, err := m.workspace.GetActiveSession()
+	if err != nil || session == nil {
+		return err
+	}
+	text := m.textarea.Value()
+	if session.ID == m.draftSession && text == m.savedDraft {
+		return nil
+	}
+	if err := m.workspace.SaveDraft(session.ID, text); err != nil {
+		return err
+	}
+	m.draftSession, m.savedDraft = session.ID, text
+	return nil
+}
+
+// restoreDraft puts the saved draft of the active session, left by an earlier
+// run that exited or crashed before it was sent, back in the input box,
+// unless the user has already typed something.
+func (m *Model) restoreDraft() {
+	session, err := m.workspace.GetActiveSession()
+	if err != nil || session == nil {
+		return
+	}
+	text, err := m.workspace.LoadDraft(session.ID)
+	if err != nil {
+		m.warnings = append(m.warnings, fmt.Sprintf("**Warning:** failed to restore the draft: %v.", err))
+		return
+	}
+	if text != "" && m.textarea.Value() == "" {
+		m.textarea.SetValue(text)
+	}
+	m.draftSession, m.savedDraft = session.ID, text
+}
+
+// savePreferences writes the UI preferences if they changed since they were
+// last saved, recording the active session so it can be reopened next time.
+func (m *Model) savePreferences() {
+	if session, err := m.workspace.GetActiveSession(); err == nil && session != nil {
+		m.prefs.LastSession = session.ID
+	}
+	if m.prefs == m.savedPrefs {
+		return
+	}
+	if err := m.workspace.SaveUIPreferences(m.prefs); err == nil {
+		m.savedPrefs = m.prefs
+	}
+}
+
+// quit flushes the UI state and the workspace (see Shutdown) and ends the
+// program. Failures cannot be shown once the UI is gone; the caller of the
+// program reports them when it calls Shutdown.
+func (m *Model) quit() tea.Cmd {
+	m.Shutdown()
+	return tea.Quit
+}
+
+// Shutdown flushes everything needed to reopen the UI where it was left: the
+// draft and scroll positions of the active session, the UI preferences
+// including the layout, and the workspace with the session itself. Programs
+// running the model call it once the program has exited, however it was
+// stopped, as signals end it without a final update. It may be called more
+// than once.
+func (m *Model) Shutdown() error {
+	var errs []error
+	if m.banner == nil {
+		if err := m.saveDraft(); err != nil {
+			errs = append(errs, err)
+		}
+		if state := m.sessionState(); state != m.lastSaved {
+			err := m.workspace.SaveSessionState(state)
+			if err == nil {
+				m.lastSaved = state
+			} else if !errors.Is(err, ai.ErrNoActiveSession) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if session, err := m.workspace.GetActiveSession(); err == nil && session != nil {
+		m.prefs.LastSession = session.ID
+	}
+	if m.prefs != m.savedPrefs {
+		if err := m.workspace.SaveUIPreferences(m.prefs); err != nil {
+			errs = append(errs, err)
+		} else {
+			m.savedPrefs = m.prefs
+		}
+	}
+	if err := m.workspace.Flush(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// restoreSessionState applies previously flushed state. Scroll offsets are
+// applied once the viewports are sized.
+func (m *Model) restoreSessionState(state *ai.SessionState) {
+	m.pendingRestore = state
+}
+
+// applyPendingRestore applies restored scroll offsets once the viewports are sized.
+func (m *Model) applyPendingRestore() {
+	if !m.ready || m.pendingRestore == nil {
+		return
+	}
+	m.history.SetYOffset(m.pendingRestore.HistoryOffset)
+	m.content.SetYOffset(m.pendingRestore.PreviewOffset)
+	m.pendingRestore = nil
+}
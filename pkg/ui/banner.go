@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bannerRecent is the number of archived sessions offered on the startup banner.
+const bannerRecent = 3
+
+// startupBanner is shown on launch, before the first model call, so the user can
+// decide which session to work in.
+type startupBanner struct {
+	active *ai.Session         // The active session, if any.
+	recent []ai.SessionSummary // Archived sessions to offer, pinned first, then by priority and recency.
+	scope  string              // Project subdirectory the workspace is confined to, if any.
+}
+
+// SessionStartedMsg reports the result of starting the chat for the chosen session.
+type SessionStartedMsg struct {
+	Response ai.Response
+	Err      error
+}
+
+// newStartupBanner gathers the workspace summary shown on the banner. It returns
+// nil when there is nothing to choose from, in which case a session is started
+// immediately.
+func newStartupBanner(workspace *ai.Workspace) *startupBanner {
+	active, _ := workspace.GetActiveSession()
+	archived, _, _ := workspace.ListArchivedSessionsWith(ai.ListOptions{Limit: bannerRecent})
+	if active == nil && len(archived) == 0 {
+		return nil
+	}
+	return &startupBanner{active: active, recent: archived, scope: workspace.Scope()}
+}
+
+// markdown renders the banner for the preview pane.
+func (b *startupBanner) markdown(project ai.Project) string {
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("# %s\n\n", project.Name))
+	if project.Repository != "" {
+		s.WriteString(fmt.Sprintf("%s\n\n", project.Repository))
+	}
+	if b.scope != "" {
+		s.WriteString(fmt.Sprintf("**Scope:** `%s`\n\n", b.scope))
+	}
+
+	if b.active != nil {
+		s.WriteString(fmt.Sprintf("**Active session:** %s%s — role `%s`, %d turns, started %s, last used %s\n\n",
+			b.active.Label, sessionMarks(b.active.Metadata.Pinned, b.active.Metadata.Priority), b.active.Role.Name, len(b.active.Chat),
+			relativeTime(b.active.Metadata.CreatedAt), relativeTime(b.active.Metadata.LastUpdated)))
+	} else {
+		s.WriteString("**No active session.**\n\n")
+	}
+
+	if len(b.recent) > 0 {
+		s.WriteString("## Recent sessions\n\n")
+		for i, r := range b.recent {
+			s.WriteString(fmt.Sprintf("%d. %s%s — role `%s`, last used %s\n", i+1, r.Label, sessionMarks(r.Pinned, r.Priority), r.RoleName, relativeTime(r.LastUpdated)))
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString("## Choose\n\n")
+	if b.active != nil {
+		s.WriteString("- `c` / `Enter` — continue the active session\n")
+	}
+	if len(b.recent) > 0 {
+		s.WriteString(fmt.Sprintf("- `1`–`%d` — resume a recent session\n", len(b.recent)))
+	}
+	s.WriteString("- `n` — start a fresh session\n")
+	s.WriteString("- `Ctrl+C` — quit\n")
+	return s.String()
+}
+
+// sessionMarks returns the note shown after a session's label, such as
+// " (pinned, high priority)", or an empty string for an unpinned session of
+// the default priority.
+func sessionMarks(pinned bool, priority ai.Priority) string {
+	var marks []string
+	if pinned {
+		marks = append(marks, "pinned")
+	}
+	if priority != "" && priority != ai.PriorityMedium {
+		marks = append(marks, fmt.Sprintf("%s priority", priority))
+	}
+	if len(marks) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(marks, ", ") + ")"
+}
+
+// relativeTime describes t relative to now in coarse units (e.g., "3h ago").
+func relativeTime(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// handleBannerKey applies the user's choice on the startup banner. Keys that are
+// not a choice are ignored so nothing is typed into the input box meanwhile.
+func (m *Model) handleBannerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var err error
+	switch key := msg.String(); key {
+	case "ctrl+c":
+		return m, m.quit()
+	case "c", "enter":
+		if m.banner.active == nil {
+			return m, nil
+		}
+	case "n":
+		err = m.workspace.EndSession(context.Background())
+	default:
+		n := int(key[0] - '0')
+		if len(key) != 1 || n < 1 || n > len(m.banner.recent) {
+			return m, nil
+		}
+		_, err = m.workspace.ResumeArchivedSession(context.Background(), m.banner.recent[n-1].ID)
+	}
+	if err != nil {
+		return m, commandResult("", err)
+	}
+
+	// Starting or resuming a session ended the active one, if any.
+	var ended string
+	if m.banner.active != nil {
+		ended = m.banner.active.ID
+	}
+	m.banner = nil
+	m.loading = true
+	m.updateHistoryContent()
+	return m, tea.Batch(m.startSession(), m.spinner.Tick, m.extractMemories(ended))
+}
+
+// startSession starts the chat for the active session, creating one if needed.
+func (m *Model) startSession() tea.Cmd {
+	return func() tea.Msg {
+		response, err := m.aiClient.StartSession(context.Background())
+		return SessionStartedMsg{Response: response, Err: err}
+	}
+}
@@ -0,0 +1,1294 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// slashCommand is a local command typed into the input box with a leading "/".
+// Commands are handled by the UI and are never sent to the model.
+type slashCommand struct {
+	name  string                                 // Name without the leading slash (e.g., "todos").
+	usage string                                 // One-line usage shown by /help.
+	run   func(m *Model, args []string) tea.Cmd // Handler; returns a command producing a CommandResultMsg.
+}
+
+// CommandResultMsg carries the Markdown output of a slash command to the preview pane.
+type CommandResultMsg struct {
+	Output string
+	Err    error
+}
+
+// commandResult wraps an already computed result in a tea.Cmd.
+func commandResult(output string, err error) tea.Cmd {
+	return func() tea.Msg {
+		return CommandResultMsg{Output: output, Err: err}
+	}
+}
+
+// slashCommands returns the table of all registered slash commands.
+func slashCommands() []slashCommand {
+	return []slashCommand{
+		{name: "help", usage: "/help — list available commands", run: runHelp},
+		{name: "attach", usage: "/attach [<file>...|clear] — queue images or PDFs for the next prompt", run: runAttach},
+		{name: "run", usage: "/run [<command>|yes|always|clear] — run a shell command and send its output with the next prompt", run: runRun},
+		{name: "apply", usage: "/apply <file> [<n>] — write code block n (default 1) of the previewed reply to a file, format and check it, and send any errors back to the model", run: runApply},
+		{name: "patch", usage: "/patch — apply the unified diff in the previewed reply to the project, all or nothing, and record it in the session", run: runPatch},
+		{name: "details", usage: "/details [on|off] — expand or collapse response metadata in the history", run: runDetails},
+		{name: "think", usage: "/think [show|hide] — show or hide the model's thought process in the history", run: runThink},
+		{name: "timestamps", usage: "/timestamps [absolute|relative|off] — show when messages were sent in the history, with day separators", run: runTimestamps},
+		{name: "theme", usage: "/theme [<style>|<file.json>|auto] [wrap <columns>] — set the Markdown style and wrap width", run: runTheme},
+		{name: "layout", usage: "/layout [split|preview|history|stacked] — arrange the panes side by side, maximize one, or stack them", run: runLayout},
+		{name: "enter", usage: "/enter [send|newline] [<chord>] — choose whether Enter sends or inserts a newline", run: runEnter},
+		{name: "split", usage: "/split [<from>-<to> [--keep] <label>] — move a range of turns into a new archived session", run: runSplit},
+		{name: "template", usage: "/template [<name> [<file>[:<from>-<to>]] [<text>...]] — render a prompt template and send it", run: runTemplate},
+		{name: "flow", usage: "/flow [<name> [<text>...]|stop] — list guided flows, or start one; later prompts answer its steps until it ends", run: runFlow},
+		{name: "undo", usage: "/undo — remove the latest exchange from the session and the model's context (also Ctrl+Z)", run: runUndo},
+		{name: "continue", usage: "/continue — have the model finish a reply that was cut off", run: runContinue},
+		{name: "candidates", usage: "/candidates [<n>] — compare the candidate replies generated for the latest message", run: runCandidates},
+		{name: "pick", usage: "/pick <n> — keep another candidate reply in the session history", run: runPick},
+		{name: "prompt", usage: "/prompt [show [<message>]] — show the instructions the model receives, layer by layer, and how a message would be sent", run: runPrompt},
+		{name: "facts", usage: "/facts [add <text>|edit <n> <text>|rm <n>] — durable project knowledge included in every session", run: runFacts},
+		{name: "memories", usage: "/memories [approve <n>|all|reject <n>|all] — facts and preferences from ended sessions, awaiting approval", run: runMemories},
+		{name: "findings", usage: "/findings [export <file.sarif|file.rdjson>] — review findings of the session, as a tree or for CI", run: runFindings},
+		{name: "workspace", usage: "/workspace [<name>|<dir>] — list known workspaces, or switch the chat to another project", run: runWorkspace},
+		{name: "scope", usage: "/scope [<dir>|clear] — confine sessions, sources, and indexes to a subdirectory of the project", run: runScope},
+		{name: "rename", usage: "/rename <label> — rename the active session", run: runRename},
+		{name: "pin", usage: "/pin [off|message|snippet <text>|list|rm <n>] — pin the active session, or pin a message or snippet to always keep in its context", run: runPin},
+		{name: "priority", usage: "/priority [low|medium|high] — show or set the priority of the active session", run: runPriority},
+		{name: "todos", usage: "/todos [done <n>|undo <n>|export <file>|issue <n>] — session action items", run: runTodos},
+	}
+}
+
+// runCommand parses and dispatches a line starting with "/".
+func (m *Model) runCommand(line string) tea.Cmd {
+	fields := strings.Fields(strings.TrimPrefix(line, "/"))
+	if len(fields) == 0 {
+		return runHelp(m, nil)
+	}
+	for _, c := range slashCommands() {
+		if c.name == fields[0] {
+			return c.run(m, fields[1:])
+		}
+	}
+	return commandResult("", fmt.Errorf("unknown command /%s, try /help", fields[0]))
+}
+
+// runHelp implements /help.
+func runHelp(m *Model, args []string) tea.Cmd {
+	var b strings.Builder
+	b.WriteString("# Commands\n\n")
+	for _, c := range slashCommands() {
+		b.WriteString(fmt.Sprintf("- `%s`\n", c.usage))
+	}
+	b.WriteString(fmt.Sprintf("\n# Input Keys\n\n%s: edit the prompt in $VISUAL or $EDITOR; the UI resumes with the edited prompt when the editor exits\n", editorKey.Help().Key))
+	b.WriteString("\n# History Keys\n\nPress Tab to focus the history pane, then: " + m.tr("help.history") + "\n")
+	b.WriteString(fmt.Sprintf("\n# Preview Keys\n\n%s/%s: page through earlier responses\n",
+		previewPrevKey.Help().Key, previewNextKey.Help().Key))
+	b.WriteString(fmt.Sprintf("%s: jump to a heading • %s/%s (or alt+n/alt+p): next/previous heading\n",
+		tocKey.Help().Key, nextHeadingKey.Help().Key, prevHeadingKey.Help().Key))
+	b.WriteString(fmt.Sprintf("\n# Layout Keys\n\n%s/%s: resize the columns • %s: next layout preset (see /layout) • %s: full-screen preview, Esc to return\n",
+		shrinkKey.Help().Key, growKey.Help().Key, layoutKey.Help().Key, zenKey.Help().Key))
+	return commandResult(b.String(), nil)
+}
+
+// runAttach implements /attach. Files are validated when queued and are sent
+// with the next prompt; without arguments it lists the queued files.
+func runAttach(m *Model, args []string) tea.Cmd {
+	if len(args) == 1 && args[0] == "clear" {
+		m.attachments = nil
+		return commandResult("Cleared queued attachments.", nil)
+	}
+	for _, path := range args {
+		attachment, err := ai.NewAttachment(path)
+		if err != nil {
+			return commandResult("", err)
+		}
+		m.attachments = append(m.attachments, attachment)
+	}
+
+	if len(m.attachments) == 0 {
+		return commandResult("No attachments queued. Use `/attach <file>` to add one.", nil)
+	}
+	var b strings.Builder
+	b.WriteString("# Attachments\n\nSent with your next message:\n\n")
+	for _, a := range m.attachments {
+		b.WriteString(fmt.Sprintf("- `%s` (%s)\n", a.Path, a.MIMEType))
+	}
+	return commandResult(b.String(), nil)
+}
+
+// commandOutputMsg carries the result of a command started with /run.
+type commandOutputMsg struct {
+	output ai.CommandOutput
+	err    error
+}
+
+// runTimeout bounds how long a command started with /run may take.
+const runTimeout = 5 * time.Minute
+
+// runRun implements /run. Commands whose program is on the workspace's run
+// allowlist start at once; others wait for `/run yes`, or `/run always`, which
+// also adds the program to the allowlist. The output is shown in the preview
+// pane and sent with the next prompt.
+func runRun(m *Model, args []string) tea.Cmd {
+	if len(args) == 0 {
+		if len(m.outputs) == 0 {
+			return commandResult("No command output queued. Use `/run <command>` to capture some.", nil)
+		}
+		var b strings.Builder
+		b.WriteString("# Command Output\n\nSent with your next message:\n\n")
+		for _, o := range m.outputs {
+			b.WriteString(fmt.Sprintf("- `%s` (exit status %d)\n", o.Command, o.ExitCode))
+		}
+		return commandResult(b.String(), nil)
+	}
+
+	command := strings.Join(args, " ")
+	switch command {
+	case "clear":
+		m.outputs = nil
+		m.pendingRun = ""
+		return commandResult("Cleared queued command output.", nil)
+	case "yes", "always":
+		if m.pendingRun == "" {
+			return commandResult("", errors.New("no command is waiting for confirmation"))
+		}
+		if command == "always" {
+			if err := m.workspace.AllowCommand(ai.CommandProgram(m.pendingRun)); err != nil {
+				return commandResult("", err)
+			}
+		}
+		command, m.pendingRun = m.pendingRun, ""
+		return m.startCommand(command)
+	}
+
+	if !m.workspace.CommandAllowed(command) {
+		m.pendingRun = command
+		return commandResult(fmt.Sprintf("Run `%s`?\n\nType `/run yes` to run it once, or `/run always` to run `%s` commands without asking from now on.",
+			command, ai.CommandProgram(command)), nil)
+	}
+	return m.startCommand(command)
+}
+
+// startCommand runs command in the background, showing the spinner until it
+// finishes.
+func (m *Model) startCommand(command string) tea.Cmd {
+	m.loading = true
+	run := func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+		defer cancel()
+		output, err := m.workspace.RunShellCommand(ctx, command)
+		return commandOutputMsg{output: output, err: err}
+	}
+	return tea.Batch(run, m.spinner.Tick)
+}
+
+// applyResultMsg carries the result of applying a code block with /apply.
+type applyResultMsg struct {
+	result ai.ApplyResult
+	err    error
+}
+
+// runApply implements /apply. The code block is written, formatted, and
+// checked in the background; if the formatter or checks fail, their output is
+// sent to the model as the next prompt so it can fix the code.
+func runApply(m *Model, args []string) tea.Cmd {
+	if len(args) == 0 || len(args) > 2 {
+		return commandResult("", errors.New("usage: /apply <file> [<n>]"))
+	}
+	if m.loading {
+		return commandResult("", errors.New("wait for the current response before applying code"))
+	}
+	current := m.displayedMessage()
+	if current < 0 || m.messages[current].Role != "ai-content" {
+		return commandResult("", errors.New("no reply to apply code from"))
+	}
+	blocks := ai.CodeBlocks(m.messages[current].Content)
+	n := 1
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+			return commandResult("", fmt.Errorf("invalid code block number '%s'", args[1]))
+		}
+	}
+	if n > len(blocks) {
+		return commandResult("", fmt.Errorf("the reply has %d code block(s), not %d", len(blocks), n))
+	}
+
+	path, code := args[0], blocks[n-1].Code
+	m.loading = true
+	apply := func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+		defer cancel()
+		result, err := m.workspace.ApplyCodeBlock(ctx, path, code)
+		return applyResultMsg{result: result, err: err}
+	}
+	return tea.Batch(apply, m.spinner.Tick)
+}
+
+// runPatch implements /patch. The diff is taken from the reply's patch field,
+// for roles whose schema has one (see `ai.PatchField`), or else from its first
+// code block fenced as diff or patch.
+func runPatch(m *Model, args []string) tea.Cmd {
+	current := m.displayedMessage()
+	if current < 0 || m.messages[current].Role != "ai-content" {
+		return commandResult("", errors.New("no reply to apply a patch from"))
+	}
+	chatID := m.messages[current].ChatID
+	var diff string
+	if session, err := m.workspace.GetActiveSession(); err != nil {
+		return commandResult("", err)
+	} else if session != nil {
+		for _, chat := range session.Chat {
+			if chat.ID == chatID && chatID != "" {
+				diff, _ = chat.Response.Fields[ai.PatchField].(string)
+			}
+		}
+	}
+	if strings.TrimSpace(diff) == "" {
+		for _, block := range ai.CodeBlocks(m.messages[current].Content) {
+			if block.Lang == "diff" || block.Lang == "patch" {
+				diff = block.Code
+				break
+			}
+		}
+	}
+	if strings.TrimSpace(diff) == "" {
+		return commandResult("", errors.New("the reply has no patch"))
+	}
+
+	applied, err := m.workspace.ApplyPatch(chatID, diff)
+	if err != nil {
+		return commandResult("", err)
+	}
+	var b strings.Builder
+	b.WriteString("# Patch Applied\n\n")
+	for _, file := range applied.Files {
+		b.WriteString(fmt.Sprintf("- `%s`\n", file))
+	}
+	return commandResult(b.String(), nil)
+}
+
+// runEnter implements /enter, switching between Enter-to-send and
+// Enter-for-newline and optionally setting the chord for the other action.
+func runEnter(m *Model, args []string) tea.Cmd {
+	if len(args) > 0 {
+		if args[0] != enterSends && args[0] != enterNewlines {
+			return commandResult("", fmt.Errorf("unknown enter mode '%s', expected send or newline", args[0]))
+		}
+		m.prefs.EnterMode = args[0]
+		m.prefs.EnterChord = ""
+		if len(args) > 1 {
+			m.prefs.EnterChord = args[1]
+		}
+		m.applyEnterMode()
+		m.savePreferences()
+	}
+	return commandResult(fmt.Sprintf("`%s` sends the prompt; `%s` inserts a newline.",
+		m.sendKey.Help().Key, m.textarea.KeyMap.InsertNewline.Help().Key), nil)
+}
+
+// runDetails implements /details, toggling whether response metadata (model,
+// latency, finish reason, and token usage) is expanded in the history pane.
+func runDetails(m *Model, args []string) tea.Cmd {
+	switch {
+	case len(args) == 0:
+		m.prefs.ShowDetails = !m.prefs.ShowDetails
+	case args[0] == "on" || args[0] == "off":
+		m.prefs.ShowDetails = args[0] == "on"
+	default:
+		return commandResult("", fmt.Errorf("unknown /details argument '%s', expected on or off", args[0]))
+	}
+	m.savePreferences()
+	m.updateHistoryContent()
+	if m.prefs.ShowDetails {
+		return commandResult("Response details are expanded in the history.", nil)
+	}
+	return commandResult("Response details are collapsed in the history.", nil)
+}
+
+// runThink implements /think, the setting that hides the model's thought
+// process from the history pane entirely. Individual thoughts are expanded
+// with `t` while the history pane is focused.
+func runThink(m *Model, args []string) tea.Cmd {
+	switch {
+	case len(args) == 0:
+		m.prefs.HideThink = !m.prefs.HideThink
+	case args[0] == "show" || args[0] == "hide":
+		m.prefs.HideThink = args[0] == "hide"
+	default:
+		return commandResult("", fmt.Errorf("unknown /think argument '%s', expected show or hide", args[0]))
+	}
+	m.savePreferences()
+	m.updateHistoryContent()
+	if m.prefs.HideThink {
+		return commandResult("The thought process is hidden from the history.", nil)
+	}
+	return commandResult("The thought process is shown in the history; focus it with Tab and press `t` to expand the latest one.", nil)
+}
+
+// runTheme implements /theme, choosing the glamour style used for Markdown and
+// the column at which it is wrapped. The style is validated by rendering with
+// it before it is saved.
+func runTheme(m *Model, args []string) tea.Cmd {
+	prefs := m.prefs
+	for i := 0; i < len(args); i++ {
+		if args[i] != "wrap" {
+			prefs.Theme = args[i]
+			continue
+		}
+		if i+1 >= len(args) {
+			return commandResult("", fmt.Errorf("usage: /theme wrap <columns>"))
+		}
+		width, err := strconv.Atoi(args[i+1])
+		if err != nil || width < 0 {
+			return commandResult("", fmt.Errorf("invalid wrap width '%s'", args[i+1]))
+		}
+		prefs.WrapWidth = width
+		i++
+	}
+
+	previous := m.prefs
+	m.prefs = prefs
+	if _, err := m.renderMarkdown("", defaultWrapWidth); err != nil {
+		m.prefs = previous
+		return commandResult("", fmt.Errorf("invalid theme '%s': %w", prefs.Theme, err))
+	}
+	m.savePreferences()
+	m.updatePreviewContent()
+
+	wrap := "the pane width"
+	if m.prefs.WrapWidth > 0 {
+		wrap = fmt.Sprintf("column %d", m.prefs.WrapWidth)
+	}
+	return commandResult(fmt.Sprintf("Rendering Markdown with the `%s` style (%s), wrapped at %s.",
+		m.prefs.Theme, m.glamourStyle(), wrap), nil)
+}
+
+// runSplit implements /split. Without arguments it lists the turns of the active
+// session with their numbers; otherwise it splits the given one-based, inclusive
+// range of turns off into a new archived session. Moved turns leave the
+// history pane and, as the session is reopened, the model's context.
+func runSplit(m *Model, args []string) tea.Cmd {
+	session, err := m.workspace.GetActiveSession()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if session == nil {
+		return commandResult("", fmt.Errorf("no active session to split"))
+	}
+	if len(args) == 0 {
+		var b strings.Builder
+		b.WriteString("# Turns\n\n")
+		for i, c := range session.Chat {
+			b.WriteString(fmt.Sprintf("%d. %s\n", i+1, excerpt(c.Message.Content, 80)))
+		}
+		b.WriteString("\nUse `/split <from>-<to> [--keep] <label>` to move turns into a new session.\n")
+		return commandResult(b.String(), nil)
+	}
+
+	from, to, ok := strings.Cut(args[0], "-")
+	start, errFrom := strconv.Atoi(from)
+	end, errTo := strconv.Atoi(to)
+	if !ok || errFrom != nil || errTo != nil {
+		return commandResult("", fmt.Errorf("invalid turn range '%s', expected <from>-<to>", args[0]))
+	}
+	keep := false
+	var label []string
+	for _, a := range args[1:] {
+		if a == "--keep" {
+			keep = true
+		} else {
+			label = append(label, a)
+		}
+	}
+	if len(label) == 0 {
+		label = []string{fmt.Sprintf("%s (turns %d-%d)", session.Label, start, end)}
+	}
+
+	var opener sessionOpener
+	if !keep {
+		// Moving turns out changes the model's context, so the chat is reopened.
+		if m.loading {
+			return commandResult("", errors.New("wait for the current request to finish before splitting"))
+		}
+		if opener, ok = m.aiClient.(sessionOpener); !ok {
+			return commandResult("", errors.New("cannot move turns while attached to a daemon; use --keep to copy them"))
+		}
+	}
+
+	summary, err := m.workspace.SplitSession(start-1, end, strings.Join(label, " "), keep)
+	if err != nil {
+		return commandResult("", err)
+	}
+	if keep {
+		return commandResult(fmt.Sprintf("Copied turns %d-%d into archived session **%s** (`%s`).", start, end, summary.Label, summary.ID), nil)
+	}
+
+	moved := make(map[string]bool)
+	for _, c := range session.Chat[start-1 : end] {
+		moved[c.ID] = true
+	}
+	kept := m.messages[:0]
+	for _, msg := range m.messages {
+		if msg.ChatID == "" || !moved[msg.ChatID] {
+			kept = append(kept, msg)
+		}
+	}
+	m.messages = kept
+	m.selected, m.previewed, m.cutOff = -1, -1, -1
+	m.candidates = nil
+	m.loading = true
+	m.updateHistoryContent()
+	output := fmt.Sprintf("Moved turns %d-%d into archived session **%s** (`%s`).", start, end, summary.Label, summary.ID)
+	reopen := func() tea.Msg {
+		_, err := opener.OpenSession(context.Background())
+		return sessionReopenedMsg{output: output, err: err}
+	}
+	return tea.Batch(reopen, m.spinner.Tick)
+}
+
+// excerpt returns the first line of text, shortened to at most n cells wide.
+func excerpt(text string, n int) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return truncateText(line, n)
+}
+
+// runTemplate implements /template. Without arguments it lists the available
+// templates; otherwise it renders the named template and sends the result as
+// a prompt. An optional file argument fills {{.File}} and, with a line range,
+// {{.Selection}}; the message selected in the history pane is the selection
+// otherwise. Remaining arguments fill {{.Input}}, and {{.Diff}} is the
+// output of `git diff HEAD` when the template uses it.
+func runTemplate(m *Model, args []string) tea.Cmd {
+	if len(args) == 0 {
+		templates, err := m.workspace.ListTemplates()
+		if err != nil {
+			return commandResult("", err)
+		}
+		if len(templates) == 0 {
+			return commandResult("No templates found. Add one as `templates/<name>.json` in the workspace.", nil)
+		}
+		var b strings.Builder
+		b.WriteString("# Templates\n\n")
+		for _, t := range templates {
+			b.WriteString(fmt.Sprintf("- `%s`", t.Name))
+			if t.Description != "" {
+				b.WriteString(" — " + t.Description)
+			}
+			b.WriteString("\n")
+		}
+		return commandResult(b.String(), nil)
+	}
+	if m.loading {
+		return commandResult("", fmt.Errorf("wait for the current response before sending a template"))
+	}
+
+	t, err := m.workspace.LoadTemplate(args[0])
+	if err != nil {
+		return commandResult("", err)
+	}
+	var data ai.TemplateData
+	rest := args[1:]
+	if len(rest) > 0 {
+		if file, selection, ok, err := templateFile(rest[0]); err != nil {
+			return commandResult("", err)
+		} else if ok {
+			data.File, data.Selection = file, selection
+			rest = rest[1:]
+		}
+	}
+	if data.Selection == "" && m.selected >= 0 && m.selected < len(m.messages) {
+		data.Selection = m.messageText(m.selected)
+	}
+	data.Input = strings.Join(rest, " ")
+	if t.UsesPlaceholder("Diff") {
+		out, err := exec.Command("git", "diff", "HEAD").Output()
+		if err != nil {
+			return commandResult("", fmt.Errorf("failed to get git diff: %w", err))
+		}
+		data.Diff = string(out)
+	}
+
+	prompt, err := m.workspace.RenderTemplate(t.Name, data)
+	if err != nil {
+		return commandResult("", err)
+	}
+	return m.submitPrompt(prompt)
+}
+
+// runFlow implements /flow. Without arguments it lists the flows, and the
+// step of the one in progress; with a name, it starts that flow and sends its
+// first step with the rest of the arguments as input.
+func runFlow(m *Model, args []string) tea.Cmd {
+	if len(args) == 0 {
+		flows, err := m.workspace.ListFlows()
+		if err != nil {
+			return commandResult("", err)
+		}
+		var b strings.Builder
+		if m.flow != nil {
+			b.WriteString(fmt.Sprintf("In flow `%s`, at step `%s`. Type `/flow stop` to leave it.\n\n", m.flow.Flow.Name, m.flow.Current().ID))
+		}
+		b.WriteString("# Flows\n\n")
+		for _, f := range flows {
+			b.WriteString(fmt.Sprintf("- `%s`", f.Name))
+			if f.Description != "" {
+				b.WriteString(" — " + f.Description)
+			}
+			b.WriteString("\n")
+		}
+		return commandResult(b.String(), nil)
+	}
+	if args[0] == "stop" {
+		if m.flow == nil {
+			return commandResult("", fmt.Errorf("no flow in progress"))
+		}
+		name := m.flow.Flow.Name
+		m.flow, m.flowSent = nil, false
+		return commandResult(fmt.Sprintf("Left flow `%s`.", name), nil)
+	}
+	if m.loading {
+		return commandResult("", fmt.Errorf("wait for the current response before starting a flow"))
+	}
+
+	run, err := m.workspace.StartFlow(args[0])
+	if err != nil {
+		return commandResult("", err)
+	}
+	m.flow = run
+	return m.submitFlowStep(strings.Join(args[1:], " "))
+}
+
+// templateFile interprets arg as `<file>` or `<file>:<from>-<to>`. It reports
+// ok as false if arg does not name an existing file, and returns the given
+// one-based, inclusive range of lines as the selection.
+func templateFile(arg string) (file, selection string, ok bool, err error) {
+	file, lines, hasRange := strings.Cut(arg, ":")
+	if info, statErr := os.Stat(file); statErr != nil || info.IsDir() {
+		return "", "", false, nil
+	}
+	if !hasRange {
+		return file, "", true, nil
+	}
+
+	fromText, toText, _ := strings.Cut(lines, "-")
+	from, fromErr := strconv.Atoi(fromText)
+	to, toErr := strconv.Atoi(toText)
+	if toText == "" {
+		to, toErr = from, nil
+	}
+	if fromErr != nil || toErr != nil || from < 1 || to < from {
+		return "", "", false, fmt.Errorf("invalid line range '%s', expected <from>-<to>", lines)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	all := strings.Split(string(content), "\n")
+	if from > len(all) {
+		return "", "", false, fmt.Errorf("%s has only %d lines", file, len(all))
+	}
+	return file, strings.Join(all[from-1:min(to, len(all))], "\n"), true, nil
+}
+
+// runTodos implements /todos, the checklist of action items aggregated across the session.
+func runTodos(m *Model, args []string) tea.Cmd {
+	actions, err := m.workspace.SessionActions()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if len(args) == 0 {
+		return commandResult("# Action Items\n\n"+ai.ActionsMarkdown(actions), nil)
+	}
+
+	switch args[0] {
+	case "done", "undo":
+		index, err := actionIndex(args, len(actions))
+		if err != nil {
+			return commandResult("", err)
+		}
+		if err := m.workspace.SetActionDone(index, args[0] == "done"); err != nil {
+			return commandResult("", err)
+		}
+		actions[index].Done = args[0] == "done"
+		return commandResult("# Action Items\n\n"+ai.ActionsMarkdown(actions), nil)
+	case "export":
+		if len(args) < 2 {
+			return commandResult("", fmt.Errorf("usage: /todos export <file>"))
+		}
+		content := "# Action Items\n\n" + ai.ActionsMarkdown(actions)
+		if err := os.WriteFile(args[1], []byte(content), 0644); err != nil {
+			return commandResult("", fmt.Errorf("failed to export actions to %s: %w", args[1], err))
+		}
+		return commandResult(fmt.Sprintf("Exported %d action items to `%s`.", len(actions), args[1]), nil)
+	case "issue":
+		index, err := actionIndex(args, len(actions))
+		if err != nil {
+			return commandResult("", err)
+		}
+		return createIssue(actions[index])
+	default:
+		return commandResult("", fmt.Errorf("unknown /todos subcommand '%s'", args[0]))
+	}
+}
+
+// runFacts implements /facts, listing the facts recorded about the project,
+// or adding, editing, or removing one by its number in the list. Edited facts
+// count as the user's.
+func runFacts(m *Model, args []string) tea.Cmd {
+	facts, err := m.workspace.ListFacts()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if len(args) == 0 {
+		return commandResult("# Facts\n\n"+ai.FactsMarkdown(facts), nil)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			return commandResult("", errors.New("usage: /facts add <text>"))
+		}
+		fact, err := m.workspace.AddFact(strings.Join(args[1:], " "), ai.FactSourceUser)
+		if err != nil {
+			return commandResult("", err)
+		}
+		return commandResult(fmt.Sprintf("Remembered: %s", fact.Content), nil)
+	case "edit":
+		if len(args) < 3 {
+			return commandResult("", errors.New("usage: /facts edit <n> <text>"))
+		}
+		index, err := factIndex(args[1], len(facts))
+		if err != nil {
+			return commandResult("", err)
+		}
+		fact := facts[index]
+		fact.Content = strings.Join(args[2:], " ")
+		fact.Source = ai.FactSourceUser
+		fact.Timestamp = time.Now()
+		if err := m.workspace.SaveFact(fact); err != nil {
+			return commandResult("", err)
+		}
+		return commandResult(fmt.Sprintf("Updated fact %d: %s", index+1, fact.Content), nil)
+	case "rm":
+		if len(args) != 2 {
+			return commandResult("", errors.New("usage: /facts rm <n>"))
+		}
+		index, err := factIndex(args[1], len(facts))
+		if err != nil {
+			return commandResult("", err)
+		}
+		if err := m.workspace.DeleteFact(facts[index].ID); err != nil {
+			return commandResult("", err)
+		}
+		return commandResult(fmt.Sprintf("Forgot: %s", facts[index].Content), nil)
+	default:
+		return commandResult("", fmt.Errorf("unknown /facts subcommand '%s'", args[0]))
+	}
+}
+
+// factIndex parses a one-based fact number into a zero-based index.
+func factIndex(arg string, count int) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > count {
+		return 0, fmt.Errorf("invalid fact number '%s'", arg)
+	}
+	return n - 1, nil
+}
+
+// runMemories implements /memories, listing the facts and preferences
+// extracted from ended sessions that await approval, or approving or
+// rejecting one by its number in the list, or all of them.
+func runMemories(m *Model, args []string) tea.Cmd {
+	pending, err := m.workspace.PendingMemories()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if len(args) == 0 {
+		return commandResult("# Memories\n\n"+ai.MemoriesMarkdown(pending), nil)
+	}
+	if (args[0] != "approve" && args[0] != "reject") || len(args) != 2 {
+		return commandResult("", errors.New("usage: /memories [approve <n>|all|reject <n>|all]"))
+	}
+	chosen := pending
+	if args[1] != "all" {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(pending) {
+			return commandResult("", fmt.Errorf("invalid memory number '%s'", args[1]))
+		}
+		chosen = pending[n-1 : n]
+	}
+	for _, c := range chosen {
+		if args[0] == "approve" {
+			err = m.workspace.ApproveMemory(c.ID)
+		} else {
+			err = m.workspace.RejectMemory(c.ID)
+		}
+		if err != nil {
+			return commandResult("", err)
+		}
+	}
+	remaining, err := m.workspace.PendingMemories()
+	if err != nil {
+		return commandResult("", err)
+	}
+	verb := "Approved"
+	if args[0] == "reject" {
+		verb = "Rejected"
+	}
+	return commandResult(fmt.Sprintf("%s %d memories.\n\n# Memories\n\n%s", verb, len(chosen), ai.MemoriesMarkdown(remaining)), nil)
+}
+
+// extractMemories asks the model for facts and preferences stated in the
+// session sessionID, just ended, when `Settings.ExtractMemories` is enabled
+// and the client can answer prompts outside a session. It returns nil
+// otherwise.
+func (m *Model) extractMemories(sessionID string) tea.Cmd {
+	runner, ok := m.aiClient.(ai.PromptRunner)
+	if !ok || sessionID == "" || !m.workspace.Settings().ExtractMemories {
+		return nil
+	}
+	workspace := m.workspace
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		queued, err := workspace.ExtractMemories(ctx, runner, sessionID)
+		if err != nil {
+			return CommandResultMsg{Err: err}
+		}
+		if len(queued) == 0 {
+			return nil
+		}
+		return CommandResultMsg{Output: fmt.Sprintf("# Memories\n\nFound in the session that ended, awaiting approval with `/memories approve <n>|all`:\n\n%s", ai.MemoriesMarkdown(queued))}
+	}
+}
+
+// runFindings implements /findings, showing the review findings of the
+// session as a tree of the files they annotate, or exporting them as SARIF or
+// reviewdog diagnostics, chosen by the file's extension.
+func runFindings(m *Model, args []string) tea.Cmd {
+	findings, err := m.workspace.SessionFindings()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if len(args) == 0 {
+		if len(findings) == 0 {
+			return commandResult("# Findings\n\n_No findings in this session._\n", nil)
+		}
+		return commandResult("# Findings\n\n```\n"+ai.FindingsTree(findings)+"```\n", nil)
+	}
+	if args[0] != "export" || len(args) != 2 {
+		return commandResult("", fmt.Errorf("usage: /findings [export <file>]"))
+	}
+	data, err := ai.ExportFindings(findings, ai.FindingsFormat(args[1]))
+	if err != nil {
+		return commandResult("", err)
+	}
+	if err := os.WriteFile(args[1], data, 0644); err != nil {
+		return commandResult("", fmt.Errorf("failed to export findings: %w", err))
+	}
+	return commandResult(fmt.Sprintf("Exported %d findings to `%s`.", len(findings), args[1]), nil)
+}
+
+// actionIndex parses the one-based action number in args[1] into a zero-based index.
+func actionIndex(args []string, count int) (int, error) {
+	if len(args) < 2 {
+		return 0, fmt.Errorf("usage: /todos %s <n>", args[0])
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 || n > count {
+		return 0, fmt.Errorf("invalid action number '%s'", args[1])
+	}
+	return n - 1, nil
+}
+
+// createIssue opens a GitHub issue for the action using the `gh` CLI.
+// It runs asynchronously so the UI stays responsive while the request is made.
+func createIssue(action ai.Action) tea.Cmd {
+	return func() tea.Msg {
+		body := action.Description
+		if action.File != "" {
+			body += fmt.Sprintf("\n\nFile: `%s`", action.File)
+		}
+		if action.Type != "" {
+			body += fmt.Sprintf("\nType: %s", action.Type)
+		}
+		out, err := exec.Command("gh", "issue", "create", "--title", action.Description, "--body", body).CombinedOutput()
+		if err != nil {
+			return CommandResultMsg{Err: fmt.Errorf("gh issue create failed: %v: %s", err, strings.TrimSpace(string(out)))}
+		}
+		return CommandResultMsg{Output: fmt.Sprintf("Created issue: %s", strings.TrimSpace(string(out)))}
+	}
+}
+
+// workspaceSwitcher is implemented by AI clients that can be pointed at
+// another workspace, such as `ai.GeminiAIClient`.
+type workspaceSwitcher interface {
+	SetWorkspace(w *ai.Workspace)
+}
+
+// runWorkspace implements /workspace. Without arguments it lists the known
+// workspaces; otherwise it switches the chat to the named workspace or project
+// directory, saving the current session state first.
+func runWorkspace(m *Model, args []string) tea.Cmd {
+	if len(args) == 0 {
+		known, err := ai.KnownWorkspaces()
+		if err != nil {
+			return commandResult("", err)
+		}
+		current, _ := filepath.Abs(filepath.Dir(m.workspace.RootDir))
+		var b strings.Builder
+		b.WriteString("# Workspaces\n\n")
+		for _, k := range known {
+			marker := ""
+			if k.Path == current {
+				marker = " (current)"
+			}
+			b.WriteString(fmt.Sprintf("- **%s**%s — `%s`\n", k.Name, marker, k.Path))
+		}
+		b.WriteString("\nUse `/workspace <name|dir>` to switch.\n")
+		return commandResult(b.String(), nil)
+	}
+
+	if m.loading {
+		return commandResult("", errors.New("wait for the current request to finish before switching workspaces"))
+	}
+	switcher, ok := m.aiClient.(workspaceSwitcher)
+	if !ok {
+		return commandResult("", errors.New("cannot switch workspaces while attached to a daemon"))
+	}
+	dir, err := ai.ResolveWorkspaceDir(strings.Join(args, " "))
+	if err != nil {
+		return commandResult("", err)
+	}
+	m.autosave()
+	if err := m.workspace.Flush(); err != nil {
+		return commandResult("", err)
+	}
+	ws, err := ai.OpenWorkspace(context.Background(), dir)
+	if err != nil {
+		return commandResult("", err)
+	}
+	if embedder, ok := m.aiClient.(ai.Embedder); ok {
+		ws.SetEmbedder(embedder)
+	}
+	prefs, err := ws.LoadUIPreferences()
+	if err != nil {
+		return commandResult("", err)
+	}
+
+	switcher.SetWorkspace(ws)
+	m.workspace = ws
+	m.prefs, m.savedPrefs = prefs, prefs
+	m.lastSaved = ai.SessionState{}
+	m.textarea.Reset() // Saved as the draft of the session left by autosave; the new session's is restored.
+	m.draftSession, m.savedDraft = "", ""
+	m.messages = nil
+	m.attachments = nil
+	m.outputs = nil
+	m.pendingRun = ""
+	m.replyTo = ""
+	m.selected, m.previewed = -1, -1
+	m.candidates = nil
+	m.refreshStatus()
+	m.applyEnterMode()
+	m.applyFocus()
+	watch := m.watchWorkspace()
+
+	m.banner = newStartupBanner(ws)
+	if m.banner == nil {
+		m.loading = true
+		m.updateHistoryContent()
+		return tea.Batch(m.startSession(), m.spinner.Tick, watch)
+	}
+	return tea.Batch(commandResult(m.banner.markdown(ws.Project()), nil), watch)
+}
+
+// sessionOpener is implemented by AI clients that can reopen the chat of the
+// active session without sending anything, such as `ai.GeminiAIClient`.
+type sessionOpener interface {
+	OpenSession(ctx context.Context) (*ai.Session, error)
+}
+
+// runScope implements /scope. Without arguments it shows the subdirectory the
+// workspace is confined to; otherwise it confines the workspace to dir,
+// relative to the project root, or removes the scope with "clear". The active
+// session continues with the new scope.
+func runScope(m *Model, args []string) tea.Cmd {
+	if len(args) == 0 {
+		if m.workspace.Scope() == "" {
+			return commandResult("The workspace is not scoped. Use `/scope <dir>` to confine it to a subdirectory.", nil)
+		}
+		return commandResult(fmt.Sprintf("The workspace is scoped to `%s`.", m.workspace.Scope()), nil)
+	}
+
+	if m.loading {
+		return commandResult("", errors.New("wait for the current request to finish before changing the scope"))
+	}
+	switcher, ok := m.aiClient.(workspaceSwitcher)
+	opener, canOpen := m.aiClient.(sessionOpener)
+	if !ok || !canOpen {
+		return commandResult("", errors.New("cannot change the scope while attached to a daemon"))
+	}
+	dir := strings.Join(args, " ")
+	if dir == "clear" {
+		dir = ""
+	}
+	ws, err := m.workspace.WithScope(dir)
+	if err != nil {
+		return commandResult("", err)
+	}
+	switcher.SetWorkspace(ws)
+	m.workspace = ws
+
+	output := "Removed the scope; the workspace covers the whole project."
+	if ws.Scope() != "" {
+		output = fmt.Sprintf("Scoped the workspace to `%s`.", ws.Scope())
+	}
+	m.loading = true
+	reopen := func() tea.Msg {
+		_, err := opener.OpenSession(context.Background())
+		return sessionReopenedMsg{output: output, err: err}
+	}
+	return tea.Batch(reopen, m.spinner.Tick)
+}
+
+// sessionReopenedMsg reports that the chat was reopened after a change of
+// scope, with the output to show.
+type sessionReopenedMsg struct {
+	output string
+	err    error
+}
+
+// runPin implements /pin, pinning the active session, or unpinning it with
+// "off". Its other subcommands manage the session's context pins: the
+// selected message, or a snippet of text, always replayed when the context is
+// rebuilt, however much of the history is compacted.
+func runPin(m *Model, args []string) tea.Cmd {
+	session, err := m.workspace.GetActiveSession()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if session == nil {
+		return commandResult("", ai.ErrNoActiveSession)
+	}
+	if len(args) == 0 {
+		if err := m.workspace.PinSession(session.ID); err != nil {
+			return commandResult("", err)
+		}
+		return commandResult(fmt.Sprintf("Pinned **%s**. `/pin off` unpins it.", session.Label), nil)
+	}
+
+	switch args[0] {
+	case "off":
+		if err := m.workspace.UnpinSession(session.ID); err != nil {
+			return commandResult("", err)
+		}
+		return commandResult(fmt.Sprintf("Unpinned **%s**.", session.Label), nil)
+	case "list":
+		return commandResult("# Pinned Context\n\n"+ai.PinsMarkdown(session.Pins), nil)
+	case "message":
+		i := m.selected
+		if i < 0 || i >= len(m.messages) {
+			// Without a selection, pin the latest saved message rather than
+			// the echo of this command.
+			for i = len(m.messages) - 1; i >= 0 && m.messages[i].ChatID == ""; i-- {
+			}
+		}
+		if i < 0 {
+			return commandResult("", errors.New("there is no message to pin"))
+		}
+		if _, err := m.workspace.AddContextPin(session.ID, m.messages[i].ChatID, m.messageText(i)); err != nil {
+			return commandResult("", err)
+		}
+		return m.reopenPinned("Pinned the selected message to the session's context.")
+	case "snippet":
+		if len(args) < 2 {
+			return commandResult("", errors.New("usage: /pin snippet <text>"))
+		}
+		if _, err := m.workspace.AddContextPin(session.ID, "", strings.Join(args[1:], " ")); err != nil {
+			return commandResult("", err)
+		}
+		return m.reopenPinned("Pinned the snippet to the session's context.")
+	case "rm":
+		if len(args) != 2 {
+			return commandResult("", errors.New("usage: /pin rm <n>"))
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(session.Pins) {
+			return commandResult("", fmt.Errorf("invalid pin number '%s'", args[1]))
+		}
+		if err := m.workspace.RemoveContextPin(session.ID, session.Pins[n-1].ID); err != nil {
+			return commandResult("", err)
+		}
+		return m.reopenPinned(fmt.Sprintf("Unpinned context %d.", n))
+	default:
+		return commandResult("", fmt.Errorf("unknown /pin subcommand '%s'", args[0]))
+	}
+}
+
+// reopenPinned reopens the live chat after its context pins changed, so the
+// next message is sent with them, and reports output.
+func (m *Model) reopenPinned(output string) tea.Cmd {
+	opener, ok := m.aiClient.(sessionOpener)
+	if !ok || m.loading {
+		return commandResult(output+" It takes effect when the session is next opened.", nil)
+	}
+	m.loading = true
+	m.updateHistoryContent()
+	reopen := func() tea.Msg {
+		_, err := opener.OpenSession(context.Background())
+		return sessionReopenedMsg{output: output, err: err}
+	}
+	return tea.Batch(reopen, m.spinner.Tick)
+}
+
+// runPriority implements /priority, showing or setting the priority of the
+// active session.
+func runPriority(m *Model, args []string) tea.Cmd {
+	session, err := m.workspace.GetActiveSession()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if session == nil {
+		return commandResult("", ai.ErrNoActiveSession)
+	}
+	if len(args) == 0 {
+		return commandResult(fmt.Sprintf("**%s** has %s priority.", session.Label, session.Metadata.Priority), nil)
+	}
+	priority, err := ai.ParsePriority(args[0])
+	if err != nil {
+		return commandResult("", err)
+	}
+	if err := m.workspace.SetSessionPriority(session.ID, priority); err != nil {
+		return commandResult("", err)
+	}
+	return commandResult(fmt.Sprintf("Set the priority of **%s** to %s.", session.Label, priority), nil)
+}
+
+// runRename implements /rename, setting the label of the active session.
+func runRename(m *Model, args []string) tea.Cmd {
+	if len(args) == 0 {
+		return commandResult("", errors.New("usage: /rename <label>"))
+	}
+	session, err := m.workspace.GetActiveSession()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if session == nil {
+		return commandResult("", ai.ErrNoActiveSession)
+	}
+	label := strings.Join(args, " ")
+	if err := m.workspace.RenameSession(session.ID, label); err != nil {
+		return commandResult("", err)
+	}
+	return commandResult(fmt.Sprintf("Renamed **%s** to **%s**.", session.Label, label), nil)
+}
+
+// runUndo implements /undo, also bound to Ctrl+Z, which removes the latest
+// exchange from the active session and reopens the chat so the model forgets
+// it too. Its prompt is put back in the input box, unless a draft is already
+// there, so it can be reworded.
+func runUndo(m *Model, args []string) tea.Cmd {
+	if m.loading {
+		return commandResult("", errors.New("wait for the current request to finish before undoing"))
+	}
+	opener, ok := m.aiClient.(sessionOpener)
+	if !ok {
+		return commandResult("", errors.New("cannot undo while attached to a daemon"))
+	}
+	session, err := m.workspace.GetActiveSession()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if session == nil || len(session.Chat) == 0 {
+		return commandResult("", errors.New("there is no exchange to undo"))
+	}
+	last := session.Chat[len(session.Chat)-1]
+	if err := m.workspace.RemoveInteraction(session.ID, last.ID); err != nil {
+		return commandResult("", err)
+	}
+
+	kept := m.messages[:0]
+	for _, msg := range m.messages {
+		if msg.ChatID != last.ID {
+			kept = append(kept, msg)
+		}
+	}
+	m.messages = kept
+	m.selected, m.previewed, m.cutOff = -1, -1, -1
+	m.candidates = nil
+	if m.textarea.Value() == "" {
+		m.textarea.SetValue(last.Message.Content)
+	}
+	m.loading = true
+	m.updateHistoryContent()
+	reopen := func() tea.Msg {
+		_, err := opener.OpenSession(context.Background())
+		return sessionReopenedMsg{output: "Undid the latest exchange; its prompt is back in the input box.", err: err}
+	}
+	return tea.Batch(reopen, m.spinner.Tick)
+}
+
+// runContinue implements /continue, which asks the model to finish the latest
+// reply after it was cut off by the output token limit or a failure
+// mid-stream. The completed reply replaces the partial one in the history.
+func runContinue(m *Model, args []string) tea.Cmd {
+	if m.loading {
+		return commandResult("", errors.New("wait for the current request to finish before continuing"))
+	}
+	continuer, ok := m.aiClient.(ai.ResponseContinuer)
+	if !ok {
+		return commandResult("", errors.New("cut-off replies cannot be continued while attached to a daemon"))
+	}
+	if m.cutOff < 0 {
+		return commandResult("", errors.New("the latest reply was not cut off; there is nothing to continue"))
+	}
+	m.loading = true
+	m.updateHistoryContent()
+	resume := func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		response, err := continuer.ContinueResponse(ctx, nil)
+		msg := newAIResponseMsg(response, err)
+		msg.Continued = true
+		return msg
+	}
+	return tea.Batch(resume, m.spinner.Tick)
+}
+
+// runCandidates implements /candidates, which shows the candidate replies
+// generated for the latest message one after another, or only candidate n.
+func runCandidates(m *Model, args []string) tea.Cmd {
+	if len(m.candidates) < 2 {
+		return commandResult("", errors.New("the latest reply has no other candidates; set candidateCount in the role's parameters to generate several"))
+	}
+	first, last := 0, len(m.candidates)
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(m.candidates) {
+			return commandResult("", fmt.Errorf("invalid candidate number '%s'", args[0]))
+		}
+		first, last = n-1, n
+	}
+
+	var b strings.Builder
+	b.WriteString("# Candidates\n\n")
+	for i := first; i < last; i++ {
+		c := m.candidates[i]
+		kept := ""
+		if i == m.kept {
+			kept = " (kept)"
+		}
+		fmt.Fprintf(&b, "## Candidate %d of %d%s\n\n_%s_\n\n%s\n\n", i+1, len(m.candidates), kept, c.Summary, replyContent(c.Content, c.Findings, c.Fields))
+	}
+	b.WriteString("Type `/pick <n>` to keep another candidate in the session history.\n")
+	return commandResult(b.String(), nil)
+}
+
+// runPick implements /pick, which keeps candidate n of the latest reply in
+// the session history in place of the one kept before. The chat continues
+// from the kept candidate.
+func runPick(m *Model, args []string) tea.Cmd {
+	if m.loading {
+		return commandResult("", errors.New("wait for the current request to finish before picking a candidate"))
+	}
+	selector, ok := m.aiClient.(ai.CandidateSelector)
+	if !ok {
+		return commandResult("", errors.New("candidates cannot be picked while attached to a daemon"))
+	}
+	if len(m.candidates) < 2 {
+		return commandResult("", errors.New("the latest reply has no other candidates"))
+	}
+	if len(args) != 1 {
+		return commandResult("", errors.New("usage: /pick <n>"))
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(m.candidates) {
+		return commandResult("", fmt.Errorf("invalid candidate number '%s'", args[0]))
+	}
+	response, err := selector.SelectCandidate(context.Background(), n-1)
+	if err != nil {
+		return commandResult("", err)
+	}
+	m.kept = n - 1
+
+	meta := response.Meta()
+	for i := range m.messages {
+		msg := &m.messages[i]
+		if msg.ChatID != response.ChatID || response.ChatID == "" {
+			continue
+		}
+		switch msg.Role {
+		case "assistant":
+			// Notes follow the summary, separated by a blank line.
+			summary := "Summary: " + response.Summary
+			if _, notes, ok := strings.Cut(msg.Content, "\n\n"); ok {
+				summary += "\n\n" + notes
+			}
+			msg.Content, msg.Think, msg.Meta = summary, response.Think, &meta
+		case "ai-content":
+			msg.Content = replyContent(response.Content, response.Findings, response.Fields)
+		}
+	}
+	m.updateHistoryContent()
+	return commandResult(fmt.Sprintf("Kept candidate %d of %d in the session history.", n, len(m.candidates)), nil)
+}
+
+// runPrompt implements /prompt show, which shows the system instruction of
+// the active session layer by layer and, given a message, the text it would
+// be sent as, including retrieved context.
+func runPrompt(m *Model, args []string) tea.Cmd {
+	if len(args) > 0 && args[0] != "show" {
+		return commandResult("", errors.New("usage: /prompt [show [<message>]]"))
+	}
+	session, err := m.workspace.GetActiveSession()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if session == nil {
+		return commandResult("", ai.ErrNoActiveSession)
+	}
+	system, err := m.workspace.SystemPrompt(session.Role)
+	if err != nil {
+		return commandResult("", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Prompt\n\n## System instruction\n\n")
+	writePromptLayers(&b, system)
+	total := len(system.String())
+	if len(args) > 1 {
+		message, _, err := m.workspace.MessagePrompt(context.Background(), strings.Join(args[1:], " "))
+		if err != nil {
+			return commandResult("", err)
+		}
+		b.WriteString("## Message\n\n")
+		writePromptLayers(&b, message)
+		total += len(message.String())
+	}
+	fmt.Fprintf(&b, "_%d characters in total, before the conversation history._\n", total)
+	return commandResult(b.String(), nil)
+}
+
+// writePromptLayers renders the layers of prompt as sections of Markdown.
+func writePromptLayers(b *strings.Builder, prompt *ai.PromptBuilder) {
+	if len(prompt.Layers()) == 0 {
+		b.WriteString("_Empty._\n\n")
+	}
+	for _, l := range prompt.Layers() {
+		fmt.Fprintf(b, "### %s\n\n_From %s, %d characters._\n\n````\n%s\n````\n\n", l.Name, l.Source, len(l.Text), strings.TrimRight(l.Text, "\n"))
+	}
+}
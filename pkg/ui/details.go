@@ -0,0 +1,39 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// metaDetails renders response metadata for the history pane. Collapsed, it is
+// a single line with the model and latency; expanded, it also lists the
+// provider, finish reason, and token usage.
+func metaDetails(meta ai.ResponseMeta, expanded bool) string {
+	model := meta.Model
+	if model == "" {
+		model = "unknown model"
+	}
+	latency := meta.Latency.Round(10 * time.Millisecond)
+	if !expanded {
+		return fmt.Sprintf("▸ %s · %s", model, latency)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("▾ model: %s\n  latency: %s", model, latency))
+	if meta.Provider != "" {
+		b.WriteString("\n  provider: " + meta.Provider)
+	}
+	if meta.FinishReason != "" {
+		b.WriteString("\n  finish: " + meta.FinishReason)
+	}
+	if u := meta.Usage; u != nil {
+		b.WriteString(fmt.Sprintf("\n  tokens: %d prompt + %d completion = %d", u.PromptTokens, u.CompletionTokens, u.TotalTokens))
+		if u.CachedTokens > 0 {
+			b.WriteString(fmt.Sprintf(" (%d cached)", u.CachedTokens))
+		}
+	}
+	return b.String()
+}
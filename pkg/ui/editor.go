@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorKey opens the prompt in the user's editor. It is checked before the
+// input box sees the key, so it works whichever pane is focused.
+var editorKey = key.NewBinding(key.WithKeys("ctrl+e"), key.WithHelp("ctrl+e", "Edit in $EDITOR"))
+
+// editorFinishedMsg carries the prompt back from the editor.
+type editorFinishedMsg struct {
+	text string
+	err  error
+}
+
+// editorCommand returns the command line of the user's editor: $VISUAL or
+// $EDITOR, which may include arguments (e.g., "code --wait"), or vi.
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(name)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// openEditor writes the prompt to a temporary Markdown file and suspends the
+// UI while the user's editor has it open. The edited prompt replaces the
+// input box's content once the editor exits.
+func (m *Model) openEditor() tea.Cmd {
+	file, err := os.CreateTemp("", "nani-prompt-*.md")
+	if err != nil {
+		return commandResult("", fmt.Errorf("failed to create the prompt file: %w", err))
+	}
+	path := file.Name()
+	_, err = file.WriteString(m.textarea.Value())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return commandResult("", fmt.Errorf("failed to write the prompt file: %w", err))
+	}
+
+	args := append(editorCommand(), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("editor %s failed: %w", args[0], err)}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("failed to read the edited prompt: %w", err)}
+		}
+		return editorFinishedMsg{text: strings.TrimRight(string(data), "\n")}
+	})
+}
+
+// handleEditorFinished puts the prompt edited in the user's editor in the
+// input box and focuses it. If the editor failed, the prompt is left as it was.
+func (m *Model) handleEditorFinished(msg editorFinishedMsg) tea.Cmd {
+	if msg.err != nil {
+		return commandResult("", msg.err)
+	}
+	m.textarea.SetValue(msg.text)
+	m.prefs.Focused = inputPane
+	m.applyFocus()
+	return nil
+}
@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// failure is a failed attempt to get a reply from the model, shown in the
+// error panel until the user picks one of its actions or dismisses it.
+type failure struct {
+	what   string           // What failed, as in "Sending the message".
+	err    error            // The failure.
+	class  ai.ErrorClass    // Kind of the failure; see ai.ClassifyError.
+	prompt *ai.SavedMessage // Message whose sending failed, which e puts back in the input; nil for other failures.
+	retry  func() tea.Cmd   // Repeats the attempt; nil if it cannot be repeated.
+}
+
+// errorAdvice holds the IDs of the UI strings with what the error panel
+// suggests for each class of failure. Failures of other classes get the hint
+// of errorHint, if any.
+var errorAdvice = map[ai.ErrorClass]string{
+	ai.ErrorNetwork: "failure.network",
+	ai.ErrorAuth:    "failure.auth",
+	ai.ErrorQuota:   "failure.quota",
+	ai.ErrorParse:   "failure.parse",
+}
+
+// showFailure records err, which happened while doing what, in the workspace
+// log and opens the error panel for it. prompt and retry are as in failure.
+func (m *Model) showFailure(what string, err error, prompt *ai.SavedMessage, retry func() tea.Cmd) {
+	m.workspace.LogError(what, err)
+	m.failure = &failure{what: what, err: err, class: ai.ClassifyError(err), prompt: prompt, retry: retry}
+}
+
+// nextProvider returns the client's provider switcher and the provider the
+// error panel offers to switch to, or nil and "" if there is no other.
+func (m *Model) nextProvider() (ai.ProviderSwitcher, string) {
+	switcher, ok := m.aiClient.(ai.ProviderSwitcher)
+	if !ok {
+		return nil, ""
+	}
+	providers := switcher.Providers()
+	if len(providers) < 2 {
+		return nil, ""
+	}
+	return switcher, providers[1]
+}
+
+// resend sends message, whose earlier sending failed, to the model again. It
+// is already in the history.
+func (m *Model) resend(message ai.SavedMessage) tea.Cmd {
+	m.loading = true
+	m.updateHistoryContent()
+	return tea.Batch(m.sendToAI(message), m.spinner.Tick)
+}
+
+// editPrompt removes message, whose sending failed, from the history and puts
+// it back in the input box with its attachments and quoted reply.
+func (m *Model) editPrompt(message ai.SavedMessage) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "user" && m.messages[i].Content == message.Content {
+			m.messages = append(m.messages[:i], m.messages[i+1:]...)
+			break
+		}
+	}
+	m.textarea.SetValue(message.Content)
+	m.attachments = message.Attachments
+	m.replyTo = message.ReplyTo
+	m.prefs.Focused = inputPane
+	m.applyFocus()
+	m.updateHistoryContent()
+}
+
+// handleFailureKey handles a key pressed while the error panel is open.
+func (m *Model) handleFailureKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := m.failure
+	switch msg.String() {
+	case "r":
+		if f.retry != nil {
+			m.failure = nil
+			return m, f.retry()
+		}
+	case "s":
+		if switcher, next := m.nextProvider(); f.retry != nil && switcher != nil {
+			m.failure = nil
+			if err := switcher.SwitchProvider(next); err != nil {
+				m.showFailure("Switching providers", err, nil, nil)
+				return m, nil
+			}
+			return m, f.retry()
+		}
+	case "e":
+		if f.prompt != nil {
+			m.failure = nil
+			m.editPrompt(*f.prompt)
+		}
+	case "esc":
+		m.failure = nil
+	case "ctrl+c":
+		return m, m.quit()
+	}
+	return m, nil
+}
+
+// failureView renders the error panel in place of the preview content.
+func (m *Model) failureView() string {
+	f := m.failure
+	advice := errorHint(f.err)
+	if id, ok := errorAdvice[f.class]; ok {
+		advice = m.tr(id)
+	}
+
+	var actions []string
+	if f.retry != nil {
+		actions = append(actions, m.tr("failure.retry"))
+		if _, next := m.nextProvider(); next != "" {
+			actions = append(actions, m.tr("failure.switch", next))
+		}
+	}
+	if f.prompt != nil {
+		actions = append(actions, m.tr("failure.edit"))
+	}
+	actions = append(actions, m.tr("failure.dismiss"))
+
+	var b strings.Builder
+	b.WriteString(ErrorStyle.Render(m.tr("failure.title", f.what, f.class)) + "\n\n")
+	b.WriteString(f.err.Error() + "\n\n")
+	if advice != "" {
+		b.WriteString(advice + "\n\n")
+	}
+	b.WriteString(HelpStyle.Render(strings.Join(append(actions, m.tr("failure.logged")), " • ")))
+	width := max(m.content.Width-ErrorPanelStyle.GetHorizontalFrameSize(), 10)
+	return ErrorPanelStyle.Width(width).Render(wrapText(b.String(), width-ErrorPanelStyle.GetHorizontalPadding()))
+}
@@ -0,0 +1,268 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// applyFocus gives keyboard focus to the input box when the input pane is
+// focused, and takes it away while the history or preview pane is, so that
+// keys typed there act on messages or scroll instead of editing the prompt.
+func (m *Model) applyFocus() {
+	if m.prefs.Focused == inputPane {
+		m.textarea.Focus()
+	} else {
+		m.textarea.Blur()
+	}
+}
+
+// selectable reports whether message i is listed in the history pane.
+func (m *Model) selectable(i int) bool {
+	return m.messages[i].Role == "user" || m.messages[i].Role == "assistant"
+}
+
+// selectedMessage returns the index of the message that history key actions
+// apply to: the selected message or, if none is selected, the most recent
+// message listed in the history pane. It returns -1 if there is none.
+func (m *Model) selectedMessage() int {
+	if m.selected >= 0 && m.selected < len(m.messages) {
+		return m.selected
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.selectable(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveSelection selects the next (delta > 0) or previous (delta < 0) message
+// listed in the history pane, staying put at either end.
+func (m *Model) moveSelection(delta int) {
+	current := m.selectedMessage()
+	if current < 0 {
+		return
+	}
+	if m.selected < 0 && delta < 0 {
+		// The first move up selects the latest message itself.
+		m.selected = current
+		return
+	}
+	for i := current + delta; i >= 0 && i < len(m.messages); i += delta {
+		if m.selectable(i) {
+			m.selected = i
+			return
+		}
+	}
+}
+
+// responseContent returns the index of the preview content answering the
+// message at index i, which is either the user prompt or the assistant
+// summary of a turn, or -1 if the turn has no content.
+func (m *Model) responseContent(i int) int {
+	for j := i + 1; j < len(m.messages); j++ {
+		switch m.messages[j].Role {
+		case "ai-content":
+			return j
+		case "user":
+			return -1
+		}
+	}
+	return -1
+}
+
+// handleHistoryKey handles a key pressed while the history pane is focused.
+// It reports whether the key was handled.
+func (m *Model) handleHistoryKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch msg.String() {
+	case "up", "k":
+		m.moveSelection(-1)
+	case "down", "j":
+		m.moveSelection(1)
+	case "esc":
+		m.selected = -1
+		m.previewed = -1
+		m.updatePreviewContent()
+	case "end", "G":
+		m.selected = -1
+		m.history.GotoBottom()
+	case "t":
+		i := m.selectedMessage()
+		if i >= 0 && m.messages[i].Role != "assistant" {
+			i = m.selectedAssistant(i)
+		}
+		if i >= 0 && m.messages[i].Think != "" {
+			m.messages[i].ThinkExpanded = !m.messages[i].ThinkExpanded
+		}
+	case "c", "y":
+		return m.copyMessage(), true
+	case "p", "enter":
+		if i := m.selectedMessage(); i >= 0 {
+			if j := m.responseContent(i); j >= 0 {
+				m.previewed = j
+				m.updatePreviewContent()
+			}
+		}
+	case "q":
+		m.quoteMessage()
+	case "d":
+		return m.deleteMessage(), true
+	case "m":
+		return m.messageMetadata(), true
+	default:
+		return nil, false
+	}
+	m.updateHistoryContent()
+	return nil, true
+}
+
+// selectedAssistant returns the index of the assistant message answering the
+// user prompt at index i, or -1 if there is none.
+func (m *Model) selectedAssistant(i int) int {
+	for j := i + 1; j < len(m.messages) && m.messages[j].Role != "user"; j++ {
+		if m.messages[j].Role == "assistant" {
+			return j
+		}
+	}
+	return -1
+}
+
+// messageText returns the text acted on for message i: the full answer for an
+// assistant message, and the message itself otherwise.
+func (m *Model) messageText(i int) string {
+	if m.messages[i].Role == "assistant" {
+		if j := m.responseContent(i); j >= 0 {
+			return m.messages[j].Content
+		}
+	}
+	return m.messages[i].Content
+}
+
+// copyMessage copies the selected message to the system clipboard.
+func (m *Model) copyMessage() tea.Cmd {
+	i := m.selectedMessage()
+	if i < 0 {
+		return nil
+	}
+	if err := clipboard.WriteAll(m.messageText(i)); err != nil {
+		return commandResult("", fmt.Errorf("failed to copy message to the clipboard: %w", err))
+	}
+	return commandResult("Copied the selected message to the clipboard.", nil)
+}
+
+// quoteMessage quotes the selected message into the input box as a Markdown
+// block quote and moves focus to the input. The quoted chat ID is recorded with
+// the next prompt so the reply can be threaded.
+func (m *Model) quoteMessage() {
+	i := m.selectedMessage()
+	if i < 0 {
+		return
+	}
+	var quote strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(m.messageText(i)), "\n") {
+		quote.WriteString("> " + line + "\n")
+	}
+	quote.WriteString("\n")
+	m.textarea.SetValue(quote.String() + m.textarea.Value())
+	m.replyTo = m.messages[i].ChatID
+	m.prefs.Focused = inputPane
+	m.applyFocus()
+}
+
+// deleteMessage removes the turn of the selected message from the session and
+// from the history pane, then reopens the session so the model forgets it too.
+func (m *Model) deleteMessage() tea.Cmd {
+	i := m.selectedMessage()
+	if i < 0 {
+		return nil
+	}
+	if m.loading {
+		return commandResult("", errors.New("wait for the current request to finish before deleting a message"))
+	}
+	opener, ok := m.aiClient.(sessionOpener)
+	if !ok {
+		return commandResult("", errors.New("cannot delete messages while attached to a daemon"))
+	}
+	chatID := m.messages[i].ChatID
+	if chatID == "" {
+		return commandResult("", fmt.Errorf("the selected message is not saved in the session"))
+	}
+	session, err := m.workspace.GetActiveSession()
+	if err != nil {
+		return commandResult("", err)
+	}
+	if session == nil {
+		return commandResult("", ai.ErrNoActiveSession)
+	}
+	if err := m.workspace.RemoveInteraction(session.ID, chatID); err != nil {
+		return commandResult("", err)
+	}
+	kept := m.messages[:0]
+	for _, msg := range m.messages {
+		if msg.ChatID != chatID {
+			kept = append(kept, msg)
+		}
+	}
+	m.messages = kept
+	m.selected, m.previewed = -1, -1
+	m.loading = true
+	m.updateHistoryContent()
+	reopen := func() tea.Msg {
+		_, err := opener.OpenSession(context.Background())
+		return sessionReopenedMsg{output: fmt.Sprintf("Deleted turn `%s` from the session.", chatID), err: err}
+	}
+	return tea.Batch(reopen, m.spinner.Tick)
+}
+
+// messageMetadata shows the metadata of the selected message in the preview pane.
+func (m *Model) messageMetadata() tea.Cmd {
+	i := m.selectedMessage()
+	if i < 0 {
+		return nil
+	}
+	msg := m.messages[i]
+	if msg.Role == "user" {
+		if j := m.selectedAssistant(i); j >= 0 {
+			msg = m.messages[j]
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Message Metadata\n\n")
+	b.WriteString(fmt.Sprintf("- **Time:** %s\n", msg.Time.Format("2006-01-02 15:04:05")))
+	if msg.ChatID != "" {
+		b.WriteString(fmt.Sprintf("- **Chat ID:** `%s`\n", msg.ChatID))
+	}
+	if m.messages[i].ReplyTo != "" {
+		b.WriteString(fmt.Sprintf("- **In reply to:** `%s`\n", m.messages[i].ReplyTo))
+	}
+	if meta := msg.Meta; meta != nil {
+		if meta.Provider != "" {
+			b.WriteString(fmt.Sprintf("- **Provider:** %s\n", meta.Provider))
+		}
+		b.WriteString(fmt.Sprintf("- **Model:** %s\n- **Latency:** %s\n", meta.Model, meta.Latency))
+		if meta.FinishReason != "" {
+			b.WriteString(fmt.Sprintf("- **Finish reason:** %s\n", meta.FinishReason))
+		}
+		if u := meta.Usage; u != nil {
+			b.WriteString(fmt.Sprintf("- **Tokens:** %d prompt, %d completion, %d total, %d cached\n",
+				u.PromptTokens, u.CompletionTokens, u.TotalTokens, u.CachedTokens))
+		}
+	}
+	return commandResult(b.String(), nil)
+}
+
+// thinkBlock renders the thought process of an assistant message for the
+// history pane: a one-line hint when collapsed, the full text when expanded.
+func thinkBlock(think string, expanded bool) string {
+	if !expanded {
+		return "▸ thought process (select, then t to expand)"
+	}
+	return "▾ Thought process:\n" + think
+}
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// localeFiles holds the translations of the UI strings, one `<language>.json`
+// file per language mapping string IDs to text. `en.json` has every string;
+// `template.json` lists the IDs with empty text, to be copied to a new
+// language's file and filled in. Strings a locale leaves empty or out are
+// shown in English.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// defaultLocale is the language whose strings fill in for those missing from
+// the others.
+const defaultLocale = "en"
+
+// locale maps the IDs of UI strings to their text in one language.
+type locale map[string]string
+
+// english holds the strings of defaultLocale.
+var english = mustLoadLocale(defaultLocale)
+
+// loadLocale returns the strings for language, a tag such as "es" or "pt-BR"
+// (see `ai.Settings.DefaultLanguage`), from its own file or else from that of
+// its base language ("pt"). It returns nil if neither exists.
+func loadLocale(language string) locale {
+	tag := strings.ToLower(strings.ReplaceAll(language, "_", "-"))
+	base, _, _ := strings.Cut(tag, "-")
+	for _, name := range []string{tag, base} {
+		if name == "" || name == "template" {
+			continue
+		}
+		data, err := localeFiles.ReadFile("locales/" + name + ".json")
+		if err != nil {
+			continue
+		}
+		var l locale
+		if json.Unmarshal(data, &l) == nil {
+			return l
+		}
+	}
+	return nil
+}
+
+// mustLoadLocale returns the strings for language, panicking if its file is
+// missing or malformed. It is only used for defaultLocale, which is embedded.
+func mustLoadLocale(language string) locale {
+	l := loadLocale(language)
+	if l == nil {
+		panic(fmt.Sprintf("ui: missing or malformed locale %q", language))
+	}
+	return l
+}
+
+// tr returns the UI string with the given ID in the workspace's language,
+// formatted with args as by fmt.Sprintf if there are any. Strings missing
+// from the language's locale are shown in English, and unknown IDs as is.
+func (m *Model) tr(id string, args ...any) string {
+	text := m.locale[id]
+	if text == "" {
+		text = english[id]
+	}
+	if text == "" {
+		text = id
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
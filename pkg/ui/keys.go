@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// Enter modes selectable with `UIPreferences.EnterMode`.
+const (
+	enterSends    = "send"    // Enter sends the prompt; the chord inserts a newline.
+	enterNewlines = "newline" // Enter inserts a newline; the chord sends the prompt.
+)
+
+// defaultChords are the keys that perform the action Enter does not, per mode,
+// when `UIPreferences.EnterChord` is not set. Several are accepted because
+// terminals differ in which modified Enter keys they can report.
+var defaultChords = map[string][]string{
+	enterSends:    {"alt+enter", "shift+enter", "ctrl+j"},
+	enterNewlines: {"ctrl+enter", "ctrl+s", "alt+enter"},
+}
+
+// applyEnterMode binds the send key and the textarea's newline key according to
+// the configured enter mode and chord.
+func (m *Model) applyEnterMode() {
+	mode := m.prefs.EnterMode
+	if mode != enterNewlines {
+		mode = enterSends
+	}
+	chord := defaultChords[mode]
+	if m.prefs.EnterChord != "" {
+		chord = []string{m.prefs.EnterChord}
+	}
+
+	if mode == enterSends {
+		m.sendKey = key.NewBinding(key.WithKeys("enter"), key.WithHelp("Enter", "Send"))
+		m.textarea.KeyMap.InsertNewline = key.NewBinding(key.WithKeys(chord...), key.WithHelp(chord[0], "Newline"))
+	} else {
+		m.sendKey = key.NewBinding(key.WithKeys(chord...), key.WithHelp(chord[0], "Send"))
+		m.textarea.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("enter"), key.WithHelp("Enter", "Newline"))
+	}
+	m.textarea.Placeholder = "Type your message here... (" + m.sendKey.Help().Key + " to send, " +
+		m.textarea.KeyMap.InsertNewline.Help().Key + " for a newline, Tab to switch panes)"
+}
@@ -0,0 +1,210 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Layout presets selectable with `/layout` and layoutKey, persisted in
+// `UIPreferences.Layout`.
+const (
+	layoutSplit   = "split"   // History and input on the left, preview on the right.
+	layoutPreview = "preview" // The preview pane maximized.
+	layoutHistory = "history" // The history and input panes maximized.
+	layoutStacked = "stacked" // History and input above the preview, for narrow terminals.
+
+	// layoutZen shows only the preview, borderless, across the whole terminal.
+	// It is entered with zenKey and left with Esc, and is never persisted.
+	layoutZen = "zen"
+)
+
+// layoutPresets lists the presets in the order layoutKey cycles through them.
+var layoutPresets = []string{layoutSplit, layoutPreview, layoutHistory, layoutStacked}
+
+// stackBelow is the terminal width, in columns, below which the split layout
+// is shown stacked.
+const stackBelow = 100
+
+// Keys changing the layout. They are checked before the input box sees the
+// key, so they work whichever pane is focused.
+var (
+	shrinkKey = key.NewBinding(key.WithKeys("ctrl+left"), key.WithHelp("ctrl+←", "Shrink the history column"))
+	growKey   = key.NewBinding(key.WithKeys("ctrl+right"), key.WithHelp("ctrl+→", "Grow the history column"))
+	layoutKey = key.NewBinding(key.WithKeys("alt+l"), key.WithHelp("alt+l", "Next layout preset"))
+	zenKey    = key.NewBinding(key.WithKeys("alt+z"), key.WithHelp("alt+z", "Full-screen preview"))
+)
+
+// resizeStep is how much shrinkKey and growKey change `UIPreferences.LeftRatio`,
+// which stays between minRatio and maxRatio.
+const (
+	resizeStep = 0.05
+	minRatio   = 0.15
+	maxRatio   = 0.85
+)
+
+// layoutPreset returns the preset the panes are arranged by in a terminal
+// width columns wide: the configured one, with the split layout stacked when
+// the terminal is too narrow for it or the UI is in accessible mode, so the
+// panes read top to bottom.
+func (m *Model) layoutPreset(width int) string {
+	if m.zen {
+		return layoutZen
+	}
+	if m.prefs.Layout == layoutSplit && (width < stackBelow || m.accessible) {
+		return layoutStacked
+	}
+	return m.prefs.Layout
+}
+
+// visible reports whether pane is shown by the current layout.
+func (m *Model) visible(pane int) bool {
+	switch m.layout.Preset {
+	case layoutPreview, layoutZen:
+		return pane == previewPane
+	case layoutHistory:
+		return pane != previewPane
+	}
+	return true
+}
+
+// applyLayout arranges the panes for the terminal size and layout preset,
+// sizes the viewports and the input box to match, and moves the focus to a
+// visible pane if the focused one was hidden. The preview keeps its scroll
+// position.
+func (m *Model) applyLayout() {
+	offset := m.content.YOffset
+	m.layout = m.calculateLayout(m.width-4, m.height-2)
+
+	m.history.Width = max(m.layout.LeftWidth-HistoryStyle.GetHorizontalFrameSize(), 0)
+	m.history.Height = max(m.layout.HistoryHeight-HistoryStyle.GetVerticalFrameSize(), 0)
+
+	availableTextareaHeight := m.layout.InputHeight - PromptStyle.GetVerticalFrameSize() - 6
+	if availableTextareaHeight < 1 {
+		availableTextareaHeight = 1
+	}
+	if availableTextareaHeight > 10 {
+		availableTextareaHeight = 10
+	}
+
+	m.textarea.SetWidth(max(m.layout.LeftWidth-PromptStyle.GetHorizontalFrameSize(), 1))
+	m.textarea.SetHeight(availableTextareaHeight)
+
+	m.content.Width = max(m.layout.RightWidth-PreviewStyle.GetHorizontalFrameSize(), 0)
+	m.content.Height = max(m.layout.PreviewHeight-PreviewStyle.GetVerticalFrameSize(), 0)
+	if m.zen {
+		m.content.Height = max(m.content.Height-1, 0) // Room for zenHelp.
+	}
+
+	// Full-screen preview is temporary, so the focus it hides is kept.
+	if !m.zen && !m.visible(m.prefs.Focused) {
+		m.prefs.Focused = inputPane
+		if m.layout.Preset == layoutPreview {
+			m.prefs.Focused = previewPane
+		}
+		m.applyFocus()
+	}
+
+	m.updateHistoryContent()
+	m.updatePreviewContent()
+	m.content.SetYOffset(offset)
+}
+
+// handleLayoutKey resizes the columns with shrinkKey and growKey, cycles the
+// layout presets with layoutKey, and shows the preview full screen with
+// zenKey. It reports whether the key was handled.
+func (m *Model) handleLayoutKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch {
+	case key.Matches(msg, shrinkKey):
+		m.prefs.LeftRatio = max(m.prefs.LeftRatio-resizeStep, minRatio)
+	case key.Matches(msg, growKey):
+		m.prefs.LeftRatio = min(m.prefs.LeftRatio+resizeStep, maxRatio)
+	case key.Matches(msg, layoutKey):
+		next := 0
+		for i, preset := range layoutPresets {
+			if preset == m.prefs.Layout {
+				next = (i + 1) % len(layoutPresets)
+			}
+		}
+		m.prefs.Layout = layoutPresets[next]
+	case key.Matches(msg, zenKey):
+		m.zen = true
+	default:
+		return nil, false
+	}
+	m.savePreferences()
+	if m.ready {
+		m.applyLayout()
+	}
+	return nil, true
+}
+
+// zenHelp returns the help shown above the preview in full-screen mode.
+func (m *Model) zenHelp() string {
+	return m.tr("help.zen", m.tr("help.preview"))
+}
+
+// handleZenKey handles a key pressed in full-screen preview mode: Esc or
+// zenKey return to the layout preset, the preview and heading keys work as
+// usual, and the other keys scroll the preview.
+func (m *Model) handleZenKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if cmd, handled := m.handlePreviewKey(msg); handled {
+		return m, cmd
+	}
+	if cmd, handled := m.handleHeadingKey(msg); handled {
+		return m, cmd
+	}
+	switch {
+	case msg.String() == "esc" || key.Matches(msg, zenKey):
+		m.zen = false
+		m.applyLayout()
+		return m, nil
+	case msg.String() == "ctrl+c":
+		return m, m.quit()
+	}
+	var cmd tea.Cmd
+	m.content, cmd = m.content.Update(msg)
+	return m, cmd
+}
+
+// nextVisiblePane returns the pane step panes after the focused one in Tab
+// order, skipping panes the layout hides.
+func (m *Model) nextVisiblePane(step int) int {
+	pane := m.prefs.Focused
+	for range paneCount {
+		pane = (pane + step) % paneCount
+		if m.visible(pane) {
+			return pane
+		}
+	}
+	return m.prefs.Focused
+}
+
+// runLayout implements /layout, choosing how the panes are arranged. Without
+// arguments it shows the current preset.
+func runLayout(m *Model, args []string) tea.Cmd {
+	if len(args) > 0 {
+		valid := false
+		for _, preset := range layoutPresets {
+			valid = valid || preset == args[0]
+		}
+		if !valid {
+			return commandResult("", fmt.Errorf("unknown layout '%s', expected one of %s", args[0], strings.Join(layoutPresets, ", ")))
+		}
+		m.prefs.Layout = args[0]
+		m.savePreferences()
+		if m.ready {
+			m.applyLayout()
+		}
+	}
+	output := fmt.Sprintf("The layout is `%s`", m.prefs.Layout)
+	if m.layout.Preset != m.prefs.Layout && m.accessible {
+		output += fmt.Sprintf(", shown `%s` in accessible mode", m.layout.Preset)
+	} else if m.layout.Preset != m.prefs.Layout {
+		output += fmt.Sprintf(", shown `%s` as the terminal is narrower than %d columns", m.layout.Preset, stackBelow)
+	}
+	return commandResult(output+fmt.Sprintf(". %s/%s resize the columns; %s cycles the presets.",
+		shrinkKey.Help().Key, growKey.Help().Key, layoutKey.Help().Key), nil)
+}
@@ -3,10 +3,11 @@ package ui
 import (
 	"context"
 	"fmt"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -15,45 +16,98 @@ import (
 )
 
 type Layout struct {
-	LeftWidth     int
-	RightWidth    int
+	Preset        string // Arrangement of the panes; see layoutPreset.
+	LeftWidth     int    // Width of the history and input panes; 0 when hidden.
+	RightWidth    int    // Width of the preview pane; 0 when hidden.
 	HistoryHeight int
 	InputHeight   int
+	PreviewHeight int
 	TotalHeight   int
 }
 
 type Model struct {
-	messages    []ai.Message
-	textarea    textarea.Model
+	messages   []ai.Message
+	textarea   textarea.Model
 	history    viewport.Model
-	content   viewport.Model
-	spinner     spinner.Model
-	loading     bool
-	ready       bool
-	aiClient    ai.AIClient
-	layout      Layout
-	previewMode bool
-	focused     int
+	content    viewport.Model
+	spinner    spinner.Model
+	loading    bool
+	ready      bool
+	aiClient   ai.AIClient
+	workspace  *ai.Workspace
+	layout     Layout
+	width      int              // Terminal width from the latest tea.WindowSizeMsg.
+	height     int              // Terminal height from the latest tea.WindowSizeMsg.
+	zen        bool             // Whether the preview is shown full screen; see layoutZen.
+	prefs      ai.UIPreferences // Persisted UI state, including focus and layout ratios.
+	savedPrefs ai.UIPreferences // Preferences as last written to the workspace.
+
+	lastSaved      ai.SessionState  // State written by the most recent autosave.
+	draftSession   string           // Session whose draft savedDraft is.
+	savedDraft     string           // Draft as last saved to or restored from `drafts/`.
+	pendingRestore *ai.SessionState // Scroll positions to apply once the layout is known.
+
+	attachments []ai.Attachment    // Files queued with /attach, sent with the next prompt.
+	outputs     []ai.CommandOutput // Output of commands run with /run, sent with the next prompt.
+	pendingRun  string             // Command line waiting for /run yes or /run always.
+	sendKey     key.Binding        // Key that sends the prompt; see applyEnterMode.
+	banner      *startupBanner     // Startup session chooser; nil once a session has started.
+	selected    int                // Index of the message selected in the history pane, or -1 to follow the latest.
+	previewed   int                // Index of the message shown in the preview pane, or -1 for the latest.
+	replyTo     string             // Chat ID of the message quoted into the input, sent with the next prompt.
+	cutOff      int                // Index of the first message of a reply cut off and awaiting /continue, or -1.
+	flow        *ai.FlowRun        // Guided flow started with /flow, whose current step wraps each prompt; nil when none.
+	flowSent    bool               // Whether the pending prompt answers a step of flow, so its reply advances it.
+	headings    []heading          // Headings of the document in the preview pane.
+	toc         *tocMenu           // Heading jump menu; nil when closed.
+	status      string             // Role, model, and generation parameters of the active session; see refreshStatus.
+	candidates  []ai.Response      // Candidates of the latest reply, if several were generated; see /candidates.
+	kept        int                // Index in candidates of the reply kept in the session.
+	warnings    []string           // Problems met while starting, shown above the banner or the first greeting.
+	failure     *failure           // Failure shown in the error panel; nil when closed.
+	newBelow    bool               // Whether messages were added below the part of the history pane in view.
+
+	stopWatch    context.CancelFunc        // Stops the workspace watcher; nil when not watching.
+	watchChanges <-chan ai.WorkspaceChange // Changes from the current workspace watcher.
+
+	darkBackground bool   // Whether the terminal background is dark, detected at startup for the "auto" theme.
+	accessible     bool   // Whether the UI is in accessible mode; see WithAccessible.
+	locale         locale // UI strings in the workspace's language; see tr.
 }
 
 type AIResponseMsg struct {
-	Content string
-	Think string
-	Summary string
-	Err     error
+	Content    string
+	Think      string
+	Summary    string
+	Actions    []ai.Action
+	Findings   []ai.Finding
+	Fields     map[string]any // Role-specific fields of the reply; see ai.Role.Schema.
+	Remember   []string       // Facts about the project the model recorded; see /facts.
+	Meta       ai.ResponseMeta
+	ChatID     string
+	Secrets    []ai.SecretFinding // Secrets found in the prompt; see ai.Settings.Redaction.
+	Continued  bool               // Whether the response continues a reply that was cut off (see /continue).
+	Candidates []ai.Response      // Every candidate reply, when the role asks for several (see /candidates).
+	Prompt     *ai.SavedMessage   // Message the reply answers, when this terminal sent it; the error panel retries or edits it.
+	Err        error              // Failure; with ai.ErrResponseIncomplete the other fields hold the partial reply.
+}
+
+// newAIResponseMsg reports response, or err, to the model.
+func newAIResponseMsg(response ai.Response, err error) AIResponseMsg {
+	return AIResponseMsg{Content: response.Content, Think: response.Think, Summary: response.Summary, Actions: response.Actions, Findings: response.Findings, Fields: response.Fields, Remember: response.Remember, Meta: response.Meta(), ChatID: response.ChatID, Secrets: response.Secrets, Candidates: response.Candidates, Err: err}
 }
 
 type ErrMsg error
 
-func New(aiClient ai.AIClient) *Model {
+func New(aiClient ai.AIClient, workspace *ai.Workspace, opts ...Option) *Model {
 	ta := textarea.New()
-	ta.Placeholder = "Type your message here... (Press Enter to send, Tab to toggle preview)"
 	ta.Focus()
 	ta.Prompt = "┃ "
 	ta.CharLimit = 2000000
 	ta.SetHeight(3)
 	ta.ShowLineNumbers = false
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	ta.KeyMap.LineEnd.SetKeys("end") // Ctrl+E opens the prompt in $EDITOR instead; see editorKey.
 
 	vp := viewport.New(50, 20)
 	vp.KeyMap.Down.SetKeys("down", "pgdown")
@@ -67,49 +121,130 @@ func New(aiClient ai.AIClient) *Model {
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
-	ctx := context.Background();
-	response, err := aiClient.StartSession(ctx)
-
+	// The alternate screen would hide anything printed now, so problems are
+	// shown in the preview pane once the UI is running.
+	var warnings []string
+	prefs, err := workspace.LoadUIPreferences()
 	if err != nil {
-		fmt.Printf("Error initializing Gemini client: %v\n", err)
-		os.Exit(1)
+		warnings = append(warnings, fmt.Sprintf("**Warning:** %v, using defaults.", err))
+	}
+
+	// Reopen the session that was open when the UI last exited if nothing is active.
+	if active, err := workspace.GetActiveSession(); err == nil && active == nil && prefs.LastSession != "" {
+		if workspace.IsArchived(prefs.LastSession) {
+			if _, err := workspace.ResumeArchivedSession(context.Background(), prefs.LastSession); err != nil {
+				warnings = append(warnings, fmt.Sprintf("**Warning:** failed to reopen last session: %v.", err))
+			}
+		}
 	}
 
 	result := &Model{
-		messages:    []ai.Message{},
-		textarea:    ta,
+		messages:   []ai.Message{},
+		textarea:   ta,
 		history:    vp,
-		content:   previewVp,
-		spinner:     s,
-		aiClient:    aiClient,
-		ready:       false,
-		previewMode: false,
-	}
-	result.messages = append(result.messages, ai.Message{
-		Role: "ai-content",
-		Content: response.Content,
-		Time: time.Now(),
-	})
+		content:    previewVp,
+		spinner:    s,
+		aiClient:   aiClient,
+		workspace:  workspace,
+		ready:      false,
+		prefs:      prefs,
+		savedPrefs: prefs,
+		selected:   -1,
+		previewed:  -1,
+		cutOff:     -1,
+		warnings:   warnings,
+
+		darkBackground: lipgloss.HasDarkBackground(),
+		locale:         loadLocale(workspace.Settings().DefaultLanguage),
+	}
+	for _, opt := range opts {
+		opt(result)
+	}
+	if result.accessible {
+		useAccessibleStyles()
+		result.textarea.Prompt = "> "
+	}
+	result.applyEnterMode()
+	result.applyFocus()
+
+	// Let the user pick a session before the first model call is made.
+	result.banner = newStartupBanner(workspace)
+	if result.banner != nil {
+		result.messages = append(result.messages, ai.Message{
+			Role:    "command-output",
+			Content: result.takeWarnings() + result.banner.markdown(workspace.Project()),
+			Time:    time.Now(),
+		})
+	} else {
+		result.loading = true
+	}
 	return result
 }
 
+// takeWarnings returns the startup warnings not yet shown, as markdown to
+// put before the content they accompany, and forgets them.
+func (m *Model) takeWarnings() string {
+	if len(m.warnings) == 0 {
+		return ""
+	}
+	text := strings.Join(m.warnings, "\n\n") + "\n\n"
+	m.warnings = nil
+	return text
+}
+
+// Workspace returns the workspace the chat targets, which `/workspace` and
+// `/scope` can change while the UI runs.
+func (m *Model) Workspace() *ai.Workspace {
+	return m.workspace
+}
+
 func (m *Model) Init() tea.Cmd {
-	return tea.Batch(textarea.Blink, m.spinner.Tick)
+	cmds := []tea.Cmd{textarea.Blink, m.spinner.Tick, autosaveTick(), m.subscribeRemote(), m.watchWorkspace()}
+	if m.banner == nil {
+		cmds = append(cmds, m.startSession())
+	}
+	return tea.Batch(cmds...)
 }
 
+// calculateLayout arranges the panes in a width by height area according to
+// the layout preset.
 func (m *Model) calculateLayout(width, height int) Layout {
 	minOverallWidth := 80
 	minOverallHeight := 15
+	minColumnContentWidth := 20
 
-	if width < minOverallWidth {
-		width = minOverallWidth
-	}
 	if height < minOverallHeight {
 		height = minOverallHeight
 	}
 
-	leftWidth := int(float64(width) * 0.4)
-	minColumnContentWidth := 20
+	// A single column spans the borders the other column would have had.
+	switch preset := m.layoutPreset(width); preset {
+	case layoutPreview, layoutZen:
+		return Layout{Preset: preset, RightWidth: width + 2, PreviewHeight: height, TotalHeight: height}
+	case layoutHistory:
+		historyHeight, inputHeight := m.splitLeftColumn(height)
+		return Layout{Preset: preset, LeftWidth: width + 2, HistoryHeight: historyHeight - 2, InputHeight: inputHeight, TotalHeight: height}
+	case layoutStacked:
+		minPreviewHeight := 6
+		width = max(width+2, minColumnContentWidth+PreviewStyle.GetHorizontalFrameSize())
+		top := max(int(float64(height)*m.prefs.LeftRatio), 14)
+		historyHeight, inputHeight := m.splitLeftColumn(top)
+		return Layout{
+			Preset:        preset,
+			LeftWidth:     width,
+			RightWidth:    width,
+			HistoryHeight: historyHeight - 2,
+			InputHeight:   inputHeight,
+			PreviewHeight: max(height-2-top, minPreviewHeight),
+			TotalHeight:   height,
+		}
+	}
+
+	if width < minOverallWidth {
+		width = minOverallWidth
+	}
+
+	leftWidth := int(float64(width) * m.prefs.LeftRatio)
 	if leftWidth < minColumnContentWidth+HistoryStyle.GetHorizontalFrameSize() {
 		leftWidth = minColumnContentWidth + HistoryStyle.GetHorizontalFrameSize()
 	}
@@ -123,13 +258,27 @@ func (m *Model) calculateLayout(width, height int) Layout {
 		}
 	}
 
+	historyHeight, inputHeight := m.splitLeftColumn(height)
+	return Layout{
+		Preset:        layoutSplit,
+		LeftWidth:     leftWidth,
+		RightWidth:    rightWidth,
+		HistoryHeight: historyHeight - 2,
+		InputHeight:   inputHeight,
+		PreviewHeight: height,
+		TotalHeight:   height,
+	}
+}
+
+// splitLeftColumn divides height rows between the history and input panes.
+func (m *Model) splitLeftColumn(height int) (historyHeight, inputHeight int) {
 	minInputHeight := 8
 	maxInputHeight := 15
 	minHistoryHeight := 6
 
-	proposedInputHeight := int(float64(height) * 0.25)
+	proposedInputHeight := int(float64(height) * m.prefs.InputRatio)
 
-	inputHeight := proposedInputHeight
+	inputHeight = proposedInputHeight
 	if inputHeight < minInputHeight {
 		inputHeight = minInputHeight
 	}
@@ -137,7 +286,7 @@ func (m *Model) calculateLayout(width, height int) Layout {
 		inputHeight = maxInputHeight
 	}
 
-	historyHeight := height - inputHeight
+	historyHeight = height - inputHeight
 
 	if historyHeight < minHistoryHeight {
 		historyHeight = minHistoryHeight
@@ -146,12 +295,5 @@ func (m *Model) calculateLayout(width, height int) Layout {
 			inputHeight = minInputHeight
 		}
 	}
-
-	return Layout{
-		LeftWidth:     leftWidth,
-		RightWidth:    rightWidth,
-		HistoryHeight: historyHeight - 2,
-		InputHeight:   inputHeight,
-		TotalHeight:   height,
-	}
+	return historyHeight, inputHeight
 }
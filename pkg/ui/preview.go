@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Keys paging the preview pane through earlier responses. They are checked
+// before the input box sees the key, so they work whichever pane is focused.
+var (
+	previewPrevKey = key.NewBinding(key.WithKeys("alt+,"), key.WithHelp("alt+,", "Previous response"))
+	previewNextKey = key.NewBinding(key.WithKeys("alt+."), key.WithHelp("alt+.", "Next response"))
+)
+
+// responses returns the indexes of all model responses that can be shown in
+// the preview pane, oldest first.
+func (m *Model) responses() []int {
+	var indexes []int
+	for i, msg := range m.messages {
+		if msg.Role == "ai-content" {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// displayedMessage returns the index of the message shown in the preview pane,
+// or -1 if it shows the welcome text.
+func (m *Model) displayedMessage() int {
+	if m.previewed >= 0 && m.previewed < len(m.messages) {
+		return m.previewed
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "ai-content" || m.messages[i].Role == "command-output" {
+			return i
+		}
+	}
+	return -1
+}
+
+// handlePreviewKey pages the preview pane to the previous or next response.
+// Paging past the newest response returns the pane to following the latest
+// output. It reports whether the key was handled.
+func (m *Model) handlePreviewKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	prev := key.Matches(msg, previewPrevKey)
+	if !prev && !key.Matches(msg, previewNextKey) {
+		return nil, false
+	}
+
+	current := m.displayedMessage()
+	if current < 0 {
+		return nil, true
+	}
+	responses := m.responses()
+	if prev {
+		for i := len(responses) - 1; i >= 0; i-- {
+			if responses[i] < current {
+				m.previewed = responses[i]
+				break
+			}
+		}
+	} else if m.previewed >= 0 {
+		m.previewed = -1
+		for _, r := range responses {
+			if r > current {
+				m.previewed = r
+				break
+			}
+		}
+	}
+	m.updatePreviewContent()
+	return nil, true
+}
+
+// previewHeader describes which response the preview pane shows, as
+// "Response n of m", or returns "" when it shows something else.
+func (m *Model) previewHeader() string {
+	current := m.displayedMessage()
+	responses := m.responses()
+	for n, r := range responses {
+		if r == current {
+			return fmt.Sprintf("Response %d of %d • %s/%s: Browse",
+				n+1, len(responses), previewPrevKey.Help().Key, previewNextKey.Help().Key)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/asaidimu/nani/pkg/server"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// eventSource is implemented by AI clients attached to a daemon shared with
+// other terminals, such as `server.Client`.
+type eventSource interface {
+	ID() string
+	Events(ctx context.Context) (<-chan server.Event, error)
+}
+
+// remoteEventMsg carries a chat event from the daemon.
+type remoteEventMsg struct {
+	event  server.Event
+	events <-chan server.Event
+}
+
+// remoteClosedMsg reports that the event stream from the daemon ended.
+type remoteClosedMsg struct{}
+
+// subscribeRemote starts listening for the daemon's chat events when the
+// client is attached to one, and returns nil otherwise.
+func (m *Model) subscribeRemote() tea.Cmd {
+	source, ok := m.aiClient.(eventSource)
+	if !ok {
+		return nil
+	}
+	return func() tea.Msg {
+		events, err := source.Events(context.Background())
+		if err != nil {
+			return CommandResultMsg{Err: err}
+		}
+		return waitForEvent(events)()
+	}
+}
+
+// waitForEvent waits for the next event on events.
+func waitForEvent(events <-chan server.Event) tea.Cmd {
+	return func() tea.Msg {
+		e, ok := <-events
+		if !ok {
+			return remoteClosedMsg{}
+		}
+		return remoteEventMsg{event: e, events: events}
+	}
+}
+
+// handleRemoteEvent shows the turns other terminals attached to the daemon
+// send in this terminal's history. Events caused by this terminal are
+// ignored, as its own requests already return them.
+func (m *Model) handleRemoteEvent(msg remoteEventMsg) tea.Cmd {
+	next := waitForEvent(msg.events)
+	e := msg.event
+	if source, ok := m.aiClient.(eventSource); ok && e.Client == source.ID() {
+		return next
+	}
+
+	switch e.Type {
+	case server.EventMessage:
+		m.messages = append(m.messages, ai.Message{
+			Role:        "user",
+			Content:     e.Message.Content,
+			Time:        time.Now(),
+			Attachments: e.Message.Attachments,
+			ReplyTo:     e.Message.ReplyTo,
+		})
+		m.loading = true
+		m.updateHistoryContent()
+		return tea.Batch(next, m.spinner.Tick)
+	case server.EventResponse:
+		r := e.Response
+		response := AIResponseMsg{Content: r.Content, Think: r.Think, Summary: r.Summary, Actions: r.Actions, Findings: r.Findings, Fields: r.Fields, Remember: r.Remember, ChatID: r.ChatID,
+			Meta: ai.ResponseMeta{Provider: r.Provider, Model: r.Model, Latency: time.Duration(r.LatencyMs) * time.Millisecond, FinishReason: r.FinishReason, Usage: r.Usage}}
+		return tea.Batch(next, func() tea.Msg { return response })
+	case server.EventError:
+		err := errors.New(e.Error)
+		return tea.Batch(next, func() tea.Msg { return AIResponseMsg{Err: err} })
+	}
+	return next
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asaidimu/nani/pkg/ai"
+)
+
+// refreshStatus records the role, model, and generation parameters of the
+// active session, shown beside the history title. It is called whenever the
+// active session may have changed.
+func (m *Model) refreshStatus() {
+	session, err := m.workspace.GetActiveSession()
+	if err != nil || session == nil {
+		m.status = ""
+		return
+	}
+	m.status = roleStatus(session.Role, m.workspace.ModelsFor(ai.TaskChat, session.Role.Parameters.Model)[0])
+}
+
+// roleStatus summarizes the parameters role generates replies with on model.
+// Parameters the role leaves unset are shown as the provider's defaults. The
+// model comes last, as it is the first to be cut off in a narrow pane.
+func roleStatus(role ai.Role, model string) string {
+	parts := []string{role.Name}
+	params := role.Parameters
+	if params.Temperature != nil {
+		parts = append(parts, fmt.Sprintf("temp %.2g", *params.Temperature))
+	} else {
+		parts = append(parts, "temp default")
+	}
+	if params.TopP != nil {
+		parts = append(parts, fmt.Sprintf("top-p %.2g", *params.TopP))
+	}
+	if params.CandidateCount > 1 {
+		parts = append(parts, fmt.Sprintf("%d candidates", params.CandidateCount))
+	}
+	return strings.Join(append(parts, model), " · ")
+}
@@ -18,12 +18,24 @@ var (
 		BorderForeground(lipgloss.Color("#FF6B6B")).
 		Padding(0, 1) // Top/Bottom padding 1, Left/Right padding 1
 
+	// ZenStyle frames the full-screen preview. Its border is hidden but takes
+	// the same room as PreviewStyle's, so the preview content fits both.
+	ZenStyle = PreviewStyle.
+			Border(lipgloss.HiddenBorder())
+
 	TitleStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#FAFAFA")).
 		Background(lipgloss.Color("#7D56F4")).
 		Padding(0, 1). // Top/Bottom padding 0, Left/Right padding 1
 		Bold(true)
 
+	// FocusedBorderColor outlines the pane that has keyboard focus, whose
+	// title is drawn with FocusedTitleStyle instead of TitleStyle.
+	FocusedBorderColor = lipgloss.Color("#FAFAFA")
+
+	FocusedTitleStyle = TitleStyle.
+				Background(lipgloss.Color("#F25D94"))
+
 	UserMsgStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#04B575")).
 		Bold(true)
@@ -31,10 +43,27 @@ var (
 	AIMsgStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#626262"))
 
+	SelectedMsgStyle = lipgloss.NewStyle().
+				Border(lipgloss.ThickBorder(), false, false, false, true).
+				BorderForeground(lipgloss.Color("#7D56F4")).
+				PaddingLeft(1)
+
+	// NewBelowStyle marks the history pane when messages arrived below the
+	// part in view.
+	NewBelowStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FAFAFA")).
+			Background(lipgloss.Color("#04B575")).
+			Padding(0, 1)
+
 	HelpStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#626262")).
 		Italic(true)
 
+	ErrorPanelStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#FF6B6B")).
+			Padding(0, 1)
+
 	ErrorStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#FF6B6B")).
 		Bold(true)
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"github.com/charmbracelet/glamour"
+)
+
+const (
+	// autoTheme selects the "dark" or "light" glamour style to match the
+	// terminal background detected at startup.
+	autoTheme = "auto"
+
+	// defaultWrapWidth is the column at which Markdown is wrapped when wrapping
+	// to the pane width is disabled and no width is configured.
+	defaultWrapWidth = 80
+)
+
+// glamourStyle resolves the configured theme to a glamour style name or path.
+// Accessible mode always uses the plain "ascii" style.
+func (m *Model) glamourStyle() string {
+	if m.accessible {
+		return "ascii"
+	}
+	if m.prefs.Theme != autoTheme {
+		return m.prefs.Theme
+	}
+	if m.darkBackground {
+		return "dark"
+	}
+	return "light"
+}
+
+// wrapWidth returns the column at which Markdown in a pane paneWidth columns
+// wide is word-wrapped: the configured width, capped at the pane width when
+// wrapping is enabled.
+func (m *Model) wrapWidth(paneWidth int) int {
+	width := m.prefs.WrapWidth
+	switch {
+	case width <= 0 && m.prefs.Wrap:
+		return paneWidth
+	case width <= 0:
+		return defaultWrapWidth
+	case m.prefs.Wrap && width > paneWidth:
+		return paneWidth
+	}
+	return width
+}
+
+// renderMarkdown renders markdown with the configured style for a pane
+// paneWidth columns wide. The style may be a standard glamour style name or
+// the path of a custom JSON style file.
+func (m *Model) renderMarkdown(markdown string, paneWidth int) (string, error) {
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithStylePath(m.glamourStyle()),
+		glamour.WithWordWrap(m.wrapWidth(paneWidth)),
+	)
+	if err != nil {
+		return "", err
+	}
+	return renderer.Render(markdown)
+}
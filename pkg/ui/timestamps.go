@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Timestamp modes selectable with `/timestamps`, persisted in
+// `UIPreferences.Timestamps`.
+const (
+	timestampsAbsolute = "absolute" // Clock time, as in "14:03".
+	timestampsRelative = "relative" // Age, as in "5m ago".
+	timestampsOff      = "off"      // No timestamps or day separators.
+)
+
+// timeLabel formats t, the time of a message, for the history pane as of now,
+// or returns "" if timestamps are off or t is unknown.
+func (m *Model) timeLabel(t, now time.Time) string {
+	if t.IsZero() || m.prefs.Timestamps == timestampsOff {
+		return ""
+	}
+	if m.prefs.Timestamps != timestampsRelative {
+		return t.Local().Format("15:04")
+	}
+	switch age := now.Sub(t); {
+	case age < time.Minute:
+		return m.tr("time.justNow")
+	case age < time.Hour:
+		return m.tr("time.minutesAgo", int(age/time.Minute))
+	case age < 24*time.Hour:
+		return m.tr("time.hoursAgo", int(age/time.Hour))
+	}
+	return m.tr("time.daysAgo", int(now.Sub(t)/(24*time.Hour)))
+}
+
+// dayLabel names the day of t for the separator the history pane shows
+// before the first message of each day: "Today", "Yesterday", or the date.
+func (m *Model) dayLabel(t, now time.Time) string {
+	t, now = t.Local(), now.Local()
+	day := func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local) }
+	switch day(now).Sub(day(t)) {
+	case 0:
+		return m.tr("time.today")
+	case 24 * time.Hour:
+		return m.tr("time.yesterday")
+	}
+	if t.Year() == now.Year() {
+		return t.Format("Monday, January 2")
+	}
+	return t.Format("Monday, January 2, 2006")
+}
+
+// sameDay reports whether a and b fall on the same local calendar day.
+func sameDay(a, b time.Time) bool {
+	a, b = a.Local(), b.Local()
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// refreshTimestamps re-renders the history pane so relative timestamps stay
+// current, keeping its scroll position.
+func (m *Model) refreshTimestamps() {
+	if m.prefs.Timestamps != timestampsRelative {
+		return
+	}
+	offset := m.history.YOffset
+	m.updateHistoryContent()
+	m.history.SetYOffset(offset)
+}
+
+// runTimestamps implements /timestamps, choosing whether the history shows
+// when each message was sent as a clock time or an age, or not at all.
+// Without arguments it shows the current mode.
+func runTimestamps(m *Model, args []string) tea.Cmd {
+	if len(args) > 0 {
+		switch args[0] {
+		case timestampsAbsolute, timestampsRelative, timestampsOff:
+			m.prefs.Timestamps = args[0]
+		default:
+			return commandResult("", fmt.Errorf("unknown /timestamps argument '%s', expected absolute, relative, or off", args[0]))
+		}
+		m.savePreferences()
+		m.updateHistoryContent()
+	}
+	if m.prefs.Timestamps == timestampsOff {
+		return commandResult("The history shows no timestamps.", nil)
+	}
+	return commandResult(fmt.Sprintf("The history shows %s timestamps, with a separator before each day.", m.prefs.Timestamps), nil)
+}
@@ -0,0 +1,145 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Keys navigating the headings of the document shown in the preview pane.
+var (
+	tocKey         = key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "Jump to heading"))
+	nextHeadingKey = key.NewBinding(key.WithKeys("ctrl+down", "alt+n"), key.WithHelp("ctrl+↓", "Next heading"))
+	prevHeadingKey = key.NewBinding(key.WithKeys("ctrl+up", "alt+p"), key.WithHelp("ctrl+↑", "Previous heading"))
+)
+
+// heading is a Markdown heading of the previewed document.
+type heading struct {
+	level int    // 1 for "#", 2 for "##", and so on.
+	title string // Heading text without the leading hashes.
+	line  int    // Line of the rendered preview on which the heading appears.
+}
+
+// tocMenu is the heading jump menu opened with tocKey.
+type tocMenu struct {
+	cursor int // Index of the highlighted heading.
+}
+
+// markdownHeadings returns the ATX headings of a Markdown document in order,
+// ignoring lines inside fenced code blocks.
+func markdownHeadings(markdown string) []heading {
+	var headings []heading
+	fenced := false
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced || !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		title := strings.TrimSpace(trimmed[level:])
+		if closed := strings.TrimRight(title, "#"); strings.HasSuffix(closed, " ") {
+			title = strings.TrimSpace(closed) // Optional closing sequence, as in "## Title ##".
+		}
+		if level > 6 || title == "" || trimmed[level] != ' ' {
+			continue
+		}
+		headings = append(headings, heading{level: level, title: title})
+	}
+	return headings
+}
+
+// locateHeadings finds the line of each heading in the rendered preview,
+// searching forward from the previous heading. Headings that cannot be found,
+// for example because they were wrapped, are left out.
+func locateHeadings(headings []heading, rendered string) []heading {
+	lines := strings.Split(ansi.Strip(rendered), "\n")
+	located := make([]heading, 0, len(headings))
+	next := 0
+	for _, h := range headings {
+		for i := next; i < len(lines); i++ {
+			if strings.Contains(lines[i], h.title) {
+				h.line = i
+				located = append(located, h)
+				next = i + 1
+				break
+			}
+		}
+	}
+	return located
+}
+
+// handleHeadingKey handles the heading navigation keys. It reports whether
+// the key was handled.
+func (m *Model) handleHeadingKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch {
+	case key.Matches(msg, tocKey):
+		if len(m.headings) > 0 {
+			m.toc = &tocMenu{}
+			for i, h := range m.headings {
+				if h.line <= m.content.YOffset {
+					m.toc.cursor = i
+				}
+			}
+		}
+	case key.Matches(msg, nextHeadingKey):
+		for _, h := range m.headings {
+			if h.line > m.content.YOffset {
+				m.content.SetYOffset(h.line)
+				break
+			}
+		}
+	case key.Matches(msg, prevHeadingKey):
+		for i := len(m.headings) - 1; i >= 0; i-- {
+			if m.headings[i].line < m.content.YOffset {
+				m.content.SetYOffset(m.headings[i].line)
+				break
+			}
+		}
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+// handleTOCKey handles a key pressed while the heading jump menu is open.
+func (m *Model) handleTOCKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k", "ctrl+p":
+		if m.toc.cursor > 0 {
+			m.toc.cursor--
+		}
+	case "down", "j", "ctrl+n":
+		if m.toc.cursor < len(m.headings)-1 {
+			m.toc.cursor++
+		}
+	case "enter":
+		m.content.SetYOffset(m.headings[m.toc.cursor].line)
+		m.toc = nil
+	case "esc", "ctrl+g", "q":
+		m.toc = nil
+	case "ctrl+c":
+		return m, m.quit()
+	}
+	return m, nil
+}
+
+// tocView renders the heading jump menu in place of the preview content.
+func (m *Model) tocView() string {
+	var b strings.Builder
+	b.WriteString(HelpStyle.Render("Jump to heading • ↑/↓: Select • Enter: Jump • Esc: Close") + "\n\n")
+	for i, h := range m.headings {
+		line := strings.Repeat("  ", h.level-1) + h.title
+		if i == m.toc.cursor {
+			b.WriteString(SelectedMsgStyle.Render(line) + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return b.String()
+}
@@ -2,17 +2,27 @@ package ui
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/asaidimu/nani/pkg/ai"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
+// Panes that can hold keyboard focus, in the order Tab cycles through them.
+// The values are persisted in `UIPreferences.Focused`.
 const (
-	content = iota
-	history
+	inputPane = iota
+	historyPane
+	previewPane
+	paneCount
 )
 
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -24,100 +34,261 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds []tea.Cmd
 	)
 
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.banner != nil {
+		return m.handleBannerKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.failure != nil {
+		return m.handleFailureKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.toc != nil {
+		return m.handleTOCKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.zen {
+		return m.handleZenKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		if cmd, handled := m.handlePreviewKey(keyMsg); handled {
+			return m, cmd
+		}
+		if cmd, handled := m.handleLayoutKey(keyMsg); handled {
+			return m, cmd
+		}
+		if key.Matches(keyMsg, editorKey) {
+			return m, m.openEditor()
+		}
+		if cmd, handled := m.handleHeadingKey(keyMsg); handled {
+			return m, cmd
+		}
+	}
+
 	m.textarea, taCmd = m.textarea.Update(msg)
-	m.history, vpCmd = m.history.Update(msg)
-	m.spinner, spCmd = m.spinner.Update(msg)
-	m.content, previewVpCmd = m.content.Update(msg)
+	if m.receives(historyPane, msg) {
+		m.history, vpCmd = m.history.Update(msg)
+		if m.history.AtBottom() {
+			m.newBelow = false
+		}
+	}
+	if !m.accessible {
+		// Not renewing the spinner's tick stops its animation.
+		m.spinner, spCmd = m.spinner.Update(msg)
+	}
+	if m.receives(previewPane, msg) {
+		m.content, previewVpCmd = m.content.Update(msg)
+	}
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		m.layout = m.calculateLayout(msg.Width-4, msg.Height-2)
+		m.width, m.height = msg.Width, msg.Height
 		m.ready = true
+		m.applyLayout()
+		m.applyPendingRestore()
 
-		m.history.Width = m.layout.LeftWidth - HistoryStyle.GetHorizontalFrameSize()
-		m.history.Height = m.layout.HistoryHeight - HistoryStyle.GetVerticalFrameSize()
+	case editorFinishedMsg:
+		return m, m.handleEditorFinished(msg)
 
-		availableTextareaHeight := m.layout.InputHeight - PromptStyle.GetVerticalFrameSize() - 6
-		if availableTextareaHeight < 1 {
-			availableTextareaHeight = 1
+	case autosaveMsg:
+		m.autosave()
+		m.refreshTimestamps()
+		return m, autosaveTick()
+
+	case sessionReopenedMsg:
+		m.loading = false
+		m.refreshStatus()
+		return m, commandResult(msg.output, msg.err)
+
+	case commandOutputMsg:
+		m.loading = false
+		if msg.err != nil {
+			return m, commandResult("", msg.err)
 		}
-		if availableTextareaHeight > 10 {
-			availableTextareaHeight = 10
+		m.outputs = append(m.outputs, msg.output)
+		return m, commandResult(msg.output.Markdown()+"\n\nSent with your next message; `/run clear` discards it.", nil)
+
+	case applyResultMsg:
+		m.loading = false
+		switch {
+		case msg.err != nil:
+			return m, commandResult("", msg.err)
+		case msg.result.OK():
+			return m, commandResult(msg.result.Markdown(), nil)
 		}
+		return m, m.submitPrompt(msg.result.Markdown() + "\n\nFix these errors and reply with the corrected code.")
 
-		m.textarea.SetWidth(m.layout.LeftWidth - PromptStyle.GetHorizontalFrameSize())
-		m.textarea.SetHeight(availableTextareaHeight)
+	case remoteEventMsg:
+		return m, m.handleRemoteEvent(msg)
 
-		m.content.Width = m.layout.RightWidth - PreviewStyle.GetHorizontalFrameSize()
-		m.content.Height = m.layout.TotalHeight - PreviewStyle.GetVerticalFrameSize()
+	case workspaceChangedMsg:
+		return m, m.handleWorkspaceChange(msg)
 
-		m.updateHistoryContent()
-		m.updatePreviewContent()
+	case remoteClosedMsg:
+		return m, commandResult("", errors.New("lost the connection to the nani daemon; restart nani to continue"))
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "j", "k":
-			return m, nil
-		case "ctrl+c":
-			return m, tea.Quit
-		case "tab":
-			m.focused = (m.focused + 1) % 2
-			return m, nil
-		case "enter":
+		if m.prefs.Focused == historyPane {
+			if cmd, handled := m.handleHistoryKey(msg); handled {
+				return m, cmd
+			}
+		}
+		if key.Matches(msg, m.sendKey) && m.textarea.Focused() {
 			if !m.loading && m.textarea.Value() != "" {
 				userMsg := strings.TrimSpace(m.textarea.Value())
-				m.messages = append(m.messages, ai.Message{
-					Role:    "user",
-					Content: userMsg,
-					Time:    time.Now(),
-				})
+				m.history.GotoBottom() // Sending shows the latest messages.
+				if strings.HasPrefix(userMsg, "/") {
+					m.messages = append(m.messages, ai.Message{
+						Role:    "user",
+						Content: userMsg,
+						Time:    time.Now(),
+					})
+					m.textarea.Reset()
+					m.updateHistoryContent()
+					return m, m.runCommand(userMsg)
+				}
 
 				m.textarea.Reset()
-				m.loading = true
-				m.updateHistoryContent()
-				m.updatePreviewContent()
-
-				return m, tea.Batch(
-					m.sendToAI(userMsg),
-					m.spinner.Tick,
-				)
+				m.saveDraft() // A sent prompt is no longer a draft.
+				if m.flow != nil {
+					return m, m.submitFlowStep(userMsg)
+				}
+				return m, m.submitPrompt(userMsg)
 			}
 		}
-	case tea.MouseMsg:
-		var cmd tea.Cmd
-		if m.focused == content {
-			m.content, cmd = m.content.Update(msg)
-		} else {
-			m.history, cmd = m.history.Update(msg)
+
+		switch msg.String() {
+		case "j", "k":
+			return m, nil
+		case "ctrl+c":
+			return m, m.quit()
+		case "ctrl+z":
+			return m, runUndo(m, nil)
+		case "tab", "shift+tab":
+			step := 1
+			if msg.String() == "shift+tab" {
+				step = paneCount - 1
+			}
+			m.prefs.Focused = m.nextVisiblePane(step)
+			m.applyFocus()
+			m.savePreferences()
+			return m, nil
 		}
-		cmds = append(cmds, cmd)
 
 	case AIResponseMsg:
 		m.loading = false
-		if msg.Err != nil {
+		m.previewed = -1
+		m.candidates, m.kept = msg.Candidates, 0
+		incomplete := errors.Is(msg.Err, ai.ErrResponseIncomplete)
+		if msg.Continued && m.cutOff >= 0 && (msg.Err == nil || incomplete) {
+			// The continued reply replaces the part shown before.
+			m.messages = m.messages[:min(m.cutOff, len(m.messages))]
+			m.cutOff = -1
+		}
+		if incomplete {
+			m.cutOff = len(m.messages)
+		}
+		if msg.Err != nil && !incomplete {
+			var retry func() tea.Cmd
+			switch {
+			case msg.Prompt != nil:
+				prompt := *msg.Prompt
+				retry = func() tea.Cmd { return m.resend(prompt) }
+			case msg.Continued:
+				retry = func() tea.Cmd { return runContinue(m, nil) }
+			}
+			m.showFailure("Getting a reply", msg.Err, msg.Prompt, retry)
+		} else {
+			for i := len(m.messages) - 1; i >= 0; i-- {
+				if m.messages[i].Role == "user" {
+					m.messages[i].ChatID = msg.ChatID
+					break
+				}
+			}
 			m.messages = append(m.messages, ai.Message{
-				Role:    "ai-content",
-				Content: msg.Content,
+				Role:    "assistant",
+				Content: fmt.Sprintf("Summary: %s", msg.Summary),
+				Think:   msg.Think,
 				Time:    time.Now(),
+				Meta:    &msg.Meta,
+				ChatID:  msg.ChatID,
 			})
-		} else {
+			if len(msg.Actions) > 0 {
+				m.messages[len(m.messages)-1].Content += fmt.Sprintf("\n\n%d action item(s) added — see /todos", len(msg.Actions))
+			}
+			if len(msg.Remember) > 0 {
+				m.messages[len(m.messages)-1].Content += fmt.Sprintf("\n\n%d fact(s) remembered — see /facts", len(msg.Remember))
+			}
+			if len(msg.Candidates) > 1 {
+				m.messages[len(m.messages)-1].Content += fmt.Sprintf("\n\n%d candidates generated — /candidates to compare, /pick <n> to keep another", len(msg.Candidates))
+			}
+			if len(msg.Secrets) > 0 {
+				m.messages[len(m.messages)-1].Content += "\n\n" + secretsNote(msg.Secrets, m.workspace.Settings().Redaction)
+			}
+			if incomplete {
+				m.messages[len(m.messages)-1].Content += fmt.Sprintf("\n\n**Incomplete:** %v. Type `/continue` to have the model finish it.", msg.Err)
+			}
+			if len(msg.Findings) > 0 {
+				m.messages[len(m.messages)-1].Content += fmt.Sprintf("\n\n%d finding(s) — see /findings", len(msg.Findings))
+			}
+			if m.flowSent && !incomplete {
+				m.messages[len(m.messages)-1].Content += "\n\n" + m.advanceFlow(msg.Content)
+			}
+
 			m.messages = append(m.messages, ai.Message{
-				Role:    "assistant",
-				Content: fmt.Sprintf("Summary: %s\n\nThought Process: %s", msg.Summary, msg.Think), // Combine for history
+				Role:    "ai-content",
+				Content: replyContent(msg.Content, msg.Findings, msg.Fields),
 				Time:    time.Now(),
+				ChatID:  msg.ChatID,
 			})
+		}
+		m.updateHistoryContent()
+		m.updatePreviewContent()
 
+	case SessionStartedMsg:
+		m.loading = false
+		if msg.Err != nil {
+			if warnings := m.takeWarnings(); warnings != "" {
+				m.messages = append(m.messages, ai.Message{
+					Role:    "command-output",
+					Content: warnings,
+					Time:    time.Now(),
+				})
+			}
+			m.showFailure("Starting the session", msg.Err, nil, func() tea.Cmd {
+				m.loading = true
+				m.updateHistoryContent()
+				return tea.Batch(m.startSession(), m.spinner.Tick)
+			})
+		} else {
+			m.restoreDraft()
 			m.messages = append(m.messages, ai.Message{
 				Role:    "ai-content",
-				Content: msg.Content,
+				Content: m.takeWarnings() + msg.Response.Content,
 				Time:    time.Now(),
 			})
+			if state, err := m.workspace.LoadSessionState(); err == nil && state != nil {
+				m.restoreSessionState(state)
+			}
+			m.refreshStatus()
+		}
+		m.updateHistoryContent()
+		m.updatePreviewContent()
+		m.applyPendingRestore()
+
+	case CommandResultMsg:
+		m.previewed = -1
+		output := msg.Output
+		if msg.Err != nil {
+			output = errorMarkdown(msg.Err)
 		}
+		m.messages = append(m.messages, ai.Message{
+			Role:    "command-output",
+			Content: output,
+			Time:    time.Now(),
+		})
 		m.updateHistoryContent()
 		m.updatePreviewContent()
 
 	case ErrMsg:
 		m.loading = false
+		m.showFailure("A background task", msg, nil, nil)
 		return m, nil
 	}
 
@@ -125,6 +296,29 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(taCmd, vpCmd, spCmd, previewVpCmd)
 }
 
+// receives reports whether the viewport of pane should see msg. Keys and
+// mouse events go to the focused pane only, except that the preview also
+// scrolls with PgUp/PgDn and the mouse while the input box is focused; every
+// other message goes to both viewports.
+func (m *Model) receives(pane int, msg tea.Msg) bool {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.zen {
+			return pane == previewPane
+		}
+		if pane == previewPane && m.prefs.Focused == inputPane {
+			return msg.String() == "pgup" || msg.String() == "pgdown"
+		}
+		return m.prefs.Focused == pane
+	case tea.MouseMsg:
+		if m.zen || m.prefs.Focused == inputPane {
+			return pane == previewPane
+		}
+		return m.prefs.Focused == pane
+	}
+	return true
+}
+
 func (m *Model) updateHistoryContent() {
 	if !m.ready {
 		return
@@ -135,19 +329,63 @@ func (m *Model) updateHistoryContent() {
 	// Get the available width for text content inside the history box
 	contentWidth := m.layout.LeftWidth - HistoryStyle.GetHorizontalFrameSize()
 
+	selectedLine := -1
+	now := time.Now()
+	var lastDay time.Time // Time of the latest message listed, whose day has had a separator.
 	for i, msg := range m.messages {
 		if i > 0 {
 			content.WriteString("\n") // Add a newline between messages
 		}
 
+		if m.selectable(i) && m.prefs.Timestamps != timestampsOff && !msg.Time.IsZero() {
+			if lastDay.IsZero() || !sameDay(lastDay, msg.Time) {
+				separator := "── " + m.dayLabel(msg.Time, now) + " ──"
+				if m.accessible {
+					separator = m.dayLabel(msg.Time, now) + ":"
+				}
+				content.WriteString(HelpStyle.Width(contentWidth).Align(lipgloss.Center).Render(truncateText(separator, contentWidth)) + "\n")
+			}
+			lastDay = msg.Time
+		}
+		stamp := ""
+		if label := m.timeLabel(msg.Time, now); label != "" {
+			stamp = " (" + label + ")"
+		}
+
+		width := contentWidth
+		if i == m.selected {
+			width -= SelectedMsgStyle.GetHorizontalFrameSize()
+			selectedLine = strings.Count(content.String(), "\n")
+		}
+
 		var styledLine string
 		if msg.Role == "user" {
-			styledLine = UserMsgStyle.Width(contentWidth).Render("You: " + msg.Content)
+			you, attached, reply := m.tr("history.you"), "📎 %s", "↪ "+m.tr("history.replyTo")
+			if m.accessible {
+				you, attached, reply = strings.ToUpper(you), m.tr("history.attached"), m.tr("history.replyTo")
+			}
+			text := you + stamp + ": " + msg.Content
+			for _, a := range msg.Attachments {
+				text += "\n" + fmt.Sprintf(attached, filepath.Base(a.Path))
+			}
+			if msg.ReplyTo != "" {
+				text += "\n" + reply
+			}
+			styledLine = UserMsgStyle.Width(width).Render(wrapText(text, width))
 		} else if msg.Role == "assistant" { // This will now show summary and think
-			styledLine = AIMsgStyle.Width(contentWidth).Render("AI: " + msg.Content)
-		} else if msg.Role == "ai-content" { // This message is for preview only, skip for history
+			styledLine = AIMsgStyle.Width(width).Render(wrapText(m.tr("history.ai")+stamp+": "+msg.Content, width))
+			if msg.Think != "" && !m.prefs.HideThink {
+				styledLine += "\n" + HelpStyle.Width(width).Render(wrapText(thinkBlock(msg.Think, msg.ThinkExpanded), width))
+			}
+			if msg.Meta != nil {
+				styledLine += "\n" + HelpStyle.Width(width).Render(wrapText(metaDetails(*msg.Meta, m.prefs.ShowDetails), width))
+			}
+		} else if msg.Role == "ai-content" || msg.Role == "command-output" { // These messages are for preview only, skip for history
 			continue
 		}
+		if i == m.selected {
+			styledLine = SelectedMsgStyle.Render(styledLine)
+		}
 		content.WriteString(styledLine)
 	}
 
@@ -156,24 +394,177 @@ func (m *Model) updateHistoryContent() {
 	}
 
 	var spinnerLine string
-	if m.loading {
-		spinnerLine = AIMsgStyle.Render("AI: " + m.spinner.View() + " Thinking...")
+	if m.loading && m.accessible {
+		spinnerLine = AIMsgStyle.Render(m.tr("history.ai") + ": " + m.tr("history.thinking"))
+	} else if m.loading {
+		spinnerLine = AIMsgStyle.Render(m.tr("history.ai") + ": " + m.spinner.View() + " " + m.tr("history.thinking"))
 	} else {
-		spinnerLine = AIMsgStyle.Render("AI: ")
+		spinnerLine = AIMsgStyle.Render(m.tr("history.ai") + ": ")
 	}
 
 	content.WriteString(spinnerLine)
 
+	// Follow new messages only if the pane already shows the latest, so that
+	// reading earlier ones is not interrupted; newBelow marks them instead.
+	atBottom, lines := m.history.AtBottom(), m.history.TotalLineCount()
 	m.history.SetContent(content.String())
-	m.history.GotoBottom()
+	switch {
+	case selectedLine >= 0:
+		if selectedLine < m.history.YOffset || selectedLine >= m.history.YOffset+m.history.Height {
+			m.history.SetYOffset(selectedLine)
+		}
+	case atBottom:
+		m.history.GotoBottom()
+	case m.history.TotalLineCount() > lines:
+		m.newBelow = true
+	}
+	if m.history.AtBottom() {
+		m.newBelow = false
+	}
+}
+
+// submitPrompt adds prompt to the history as a user message and sends it to
+// the model together with any queued attachments and quoted reply.
+func (m *Model) submitPrompt(prompt string) tea.Cmd {
+	attachments := m.attachments
+	m.attachments = nil
+	for _, o := range m.outputs {
+		prompt += "\n\n" + o.Markdown()
+	}
+	m.outputs = nil
+	replyTo := m.replyTo
+	m.replyTo = ""
+	m.cutOff = -1 // A new prompt abandons any cut-off reply.
+	if !strings.HasPrefix(prompt, ">") {
+		replyTo = "" // The quote was removed from the prompt.
+	}
+	m.messages = append(m.messages, ai.Message{
+		Role:        "user",
+		Content:     prompt,
+		Time:        time.Now(),
+		Attachments: attachments,
+		ReplyTo:     replyTo,
+	})
+
+	m.loading = true
+	m.updateHistoryContent()
+	m.updatePreviewContent()
+
+	return tea.Batch(
+		m.sendToAI(ai.SavedMessage{Content: prompt, Attachments: attachments, ReplyTo: replyTo}),
+		m.spinner.Tick,
+	)
 }
 
-func (m *Model) sendToAI(message string) tea.Cmd {
+// submitFlowStep sends the current step of the flow in progress, with input
+// as the user's text for it.
+func (m *Model) submitFlowStep(input string) tea.Cmd {
+	prompt, err := m.flow.Prompt(input)
+	if err != nil {
+		return commandResult("", err)
+	}
+	m.flowSent = true
+	return m.submitPrompt(prompt)
+}
+
+// advanceFlow moves the flow in progress past the step reply answers and
+// returns a note on where it went for the history. A reply without the
+// step's fields leaves the flow at the step, to be answered again.
+func (m *Model) advanceFlow(reply string) string {
+	m.flowSent = false
+	name := m.flow.Flow.Name
+	done, err := m.flow.Advance(reply)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("**Flow `%s`:** %v. Answer again to retry step `%s`, or type `/flow stop`.", name, err, m.flow.Current().ID)
+	case done:
+		m.flow = nil
+		return fmt.Sprintf("Flow `%s` finished.", name)
+	}
+	return fmt.Sprintf("Flow `%s`: next is step `%s` — type your answer, or `/flow stop` to leave the flow.", name, m.flow.Current().ID)
+}
+
+func (m *Model) sendToAI(message ai.SavedMessage) tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
 		response, err := m.aiClient.SendMessage(ctx, message, m.messages, true)
-		return AIResponseMsg{Content: response.Content, Think: response.Think, Summary: response.Summary, Err: err}
+		msg := newAIResponseMsg(response, err)
+		msg.Prompt = &message
+		return msg
+	}
+}
+
+// errorMarkdown formats err for the preview pane, adding a hint for the error
+// kinds the user can do something about.
+func errorMarkdown(err error) string {
+	output := fmt.Sprintf("**Error:** %v", err)
+	if hint := errorHint(err); hint != "" {
+		output += "\n\n" + hint
+	}
+	return output
+}
+
+// errorHint tells the user what to do about err, or returns "" if there is
+// nothing specific to suggest.
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, ai.ErrProviderUnavailable):
+		return "The model provider is unavailable or rate limiting requests. Wait a moment and send the message again."
+	case errors.Is(err, ai.ErrContextTooLarge):
+		return "The conversation no longer fits in the model's context. Move older turns out with `/split`, or lower `compactionThreshold` in the workspace settings."
+	case errors.Is(err, ai.ErrArchiveConflict):
+		return "Archiving the active session would overwrite turns of its earlier archive. Run `nani sessions end --copy` to archive it under a new ID and keep both."
+	case errors.Is(err, ai.ErrSecretsDetected):
+		return "The message was not sent. Remove the secrets, or set `redaction` to `mask` in the workspace settings to send it with the secrets replaced."
+	case errors.Is(err, ai.ErrNoActiveSession):
+		return "There is no active session. Restart nani to start one."
+	}
+	return ""
+}
+
+// secretsNote tells the user about secrets found in the prompt they sent:
+// masked before sending, or sent unchanged in the default warn mode.
+func secretsNote(secrets []ai.SecretFinding, mode ai.RedactionMode) string {
+	found := make([]string, len(secrets))
+	for i, f := range secrets {
+		found[i] = f.String()
+	}
+	if mode == ai.RedactMask {
+		return fmt.Sprintf("Masked %d secret(s) before sending: %s", len(secrets), strings.Join(found, "; "))
+	}
+	return fmt.Sprintf("**Warning:** sent %d possible secret(s) to the model provider: %s. Set `redaction` to `mask` or `block` in the workspace settings to stop this.", len(secrets), strings.Join(found, "; "))
+}
+
+// fieldsMarkdown renders the role-specific fields of a reply as a list,
+// replyContent renders the content of a reply for the preview, followed by
+// its findings and role-specific fields, if any.
+func replyContent(content string, findings []ai.Finding, fields map[string]any) string {
+	if len(findings) > 0 {
+		content += "\n\n## Findings\n\n```\n" + ai.FindingsTree(findings) + "```\n"
+	}
+	if len(fields) > 0 {
+		content += "\n\n## Fields\n\n" + fieldsMarkdown(fields)
+	}
+	return content
+}
+
+// strings as they are and other values as JSON.
+func fieldsMarkdown(fields map[string]any) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		value, ok := fields[name].(string)
+		if !ok {
+			data, _ := json.Marshal(fields[name])
+			value = "`" + string(data) + "`"
+		}
+		fmt.Fprintf(&b, "- **%s**: %s\n", name, value)
 	}
+	return b.String()
 }
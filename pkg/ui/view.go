@@ -1,48 +1,121 @@
 package ui
 
 import (
-	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
 )
 
 func (m *Model) View() string {
 	if !m.ready {
-		return "Initializing AI Chat Terminal..."
+		return m.tr("loading")
+	}
+
+	if m.layout.Preset == layoutZen {
+		return m.zenView()
 	}
 
 	// Get the history content (which now includes the spinner area)
 	historyText := m.history.View()
 
 	// History section:
-	historyContent := TitleStyle.Render("Chat History") + "\n\n" + historyText
-	historySection := HistoryStyle.
+	title := m.paneTitle(m.tr("pane.history"), historyPane)
+	if m.newBelow && m.accessible {
+		title += " " + NewBelowStyle.Render(m.tr("history.newBelowPlain"))
+	} else if m.newBelow {
+		title += " " + NewBelowStyle.Render(m.tr("history.newBelow"))
+	}
+	if m.status != "" {
+		// The status shares the title line, trimmed to the pane's inner width.
+		room := m.layout.LeftWidth - HistoryStyle.GetHorizontalFrameSize() - lipgloss.Width(title) - 1
+		title += " " + HelpStyle.Render(truncateText(m.status, max(room, 0)))
+	}
+	historyContent := title + "\n\n" + historyText
+	historySection := m.paneStyle(HistoryStyle, historyPane).
 		Width(m.layout.LeftWidth).
 		Height(m.layout.HistoryHeight).
 		Render(historyContent)
 
 	// Input section:
-	inputContent := TitleStyle.Render("Input") + "\n\n" +
+	var help string
+	switch m.prefs.Focused {
+	case historyPane:
+		help = m.tr("help.history") + " • " + m.tr("help.nextPane")
+	case previewPane:
+		help = m.tr("help.preview") + " • " + m.tr("help.nextPane")
+	default:
+		help = m.tr("help.input", m.sendKey.Help().Key, m.textarea.KeyMap.InsertNewline.Help().Key, "Ctrl+E")
+	}
+	inputContent := m.paneTitle(m.tr("pane.input"), inputPane) + "\n\n" +
 		m.textarea.View() + "\n\n" +
-		HelpStyle.Render("Enter: Send • Tab: Toggle Preview • Q/Ctrl+C: Quit")
-	inputSection := PromptStyle.
+		HelpStyle.Render(help)
+	inputSection := m.paneStyle(PromptStyle, inputPane).
 		Width(m.layout.LeftWidth).
 		Height(m.layout.InputHeight).
 		Render(inputContent)
 
 	// Preview section:
-	previewContent := TitleStyle.Render("Preview") + "\n\n" + m.content.View()
-	previewSection := PreviewStyle.
+	previewBody := m.previewBody()
+	previewContent := m.paneTitle(m.tr("pane.preview"), previewPane) + "\n\n" + previewBody
+	previewSection := m.paneStyle(PreviewStyle, previewPane).
 		Width(m.layout.RightWidth).
-		Height(m.layout.TotalHeight).
+		Height(m.layout.PreviewHeight).
 		Render(previewContent)
 
 	// Combine left column (history + input) vertically.
 	leftColumn := lipgloss.JoinVertical(lipgloss.Top, historySection, inputSection)
 
-	// Combine everything horizontally.
+	// Combine everything as the layout preset arranges it.
+	switch m.layout.Preset {
+	case layoutPreview:
+		return previewSection
+	case layoutHistory:
+		return leftColumn
+	case layoutStacked:
+		return lipgloss.JoinVertical(lipgloss.Left, leftColumn, previewSection)
+	}
 	return lipgloss.JoinHorizontal(lipgloss.Top, leftColumn, previewSection)
 }
 
+// previewBody renders what the preview pane shows: the error panel or the
+// heading jump menu when open, or else the previewed content.
+func (m *Model) previewBody() string {
+	switch {
+	case m.failure != nil:
+		return m.failureView()
+	case m.toc != nil:
+		return m.tocView()
+	}
+	return m.content.View()
+}
+
+// zenView renders the preview alone across the whole terminal.
+func (m *Model) zenView() string {
+	body := m.previewBody()
+	return ZenStyle.
+		Width(m.layout.RightWidth).
+		Height(m.layout.PreviewHeight).
+		Render(HelpStyle.Render(m.zenHelp()) + "\n" + body)
+}
+
+// paneStyle returns style, the style of pane, with a highlighted border if
+// the pane has keyboard focus and the UI is not in accessible mode.
+func (m *Model) paneStyle(style lipgloss.Style, pane int) lipgloss.Style {
+	if m.prefs.Focused != pane || m.accessible {
+		return style
+	}
+	return style.Border(lipgloss.ThickBorder()).BorderForeground(FocusedBorderColor)
+}
+
+// paneTitle renders the title of pane, marked if the pane has keyboard focus.
+func (m *Model) paneTitle(title string, pane int) string {
+	if m.prefs.Focused != pane {
+		return TitleStyle.Render(title)
+	}
+	if m.accessible {
+		return FocusedTitleStyle.Render(m.tr("pane.focused", title))
+	}
+	return FocusedTitleStyle.Render("▸ " + title)
+}
+
 // updatePreviewContent prepares the styled content for the preview viewport
 func (m *Model) updatePreviewContent() {
 	if !m.ready {
@@ -50,40 +123,41 @@ func (m *Model) updatePreviewContent() {
 	}
 
 	var rawPreviewContent string
+	var headings []heading
 
 	// Get the available width for content inside the preview box
 	contentWidth := m.layout.RightWidth - PreviewStyle.GetHorizontalFrameSize()
 
 	if len(m.messages) > 0 {
 		var lastAIContentMsg string
-		for i := len(m.messages) - 1; i >= 0; i-- {
-			if m.messages[i].Role == "ai-content" {
-				lastAIContentMsg = m.messages[i].Content
-				break
-			}
+		if i := m.displayedMessage(); i >= 0 {
+			lastAIContentMsg = m.messages[i].Content
+		}
+		if header := m.previewHeader(); header != "" {
+			rawPreviewContent = HelpStyle.Render(header) + "\n"
 		}
 
 		if lastAIContentMsg != "" {
-			rendered, err := glamour.Render(lastAIContentMsg, "dark")
+			wrap := func(s string) string { return s }
+			if m.prefs.Wrap {
+				wrap = func(s string) string { return wrapText(s, contentWidth) }
+			}
+			headings = markdownHeadings(lastAIContentMsg)
+			rendered, err := m.renderMarkdown(lastAIContentMsg, contentWidth)
 			if err != nil {
-				rawPreviewContent += ErrorStyle.Render("Render Error: "+err.Error()) + "\n\n" +
-					lipgloss.NewStyle().Width(contentWidth).Render(lastAIContentMsg)
+				rawPreviewContent += ErrorStyle.Render(m.tr("preview.renderError", err)) + "\n\n" +
+					wrap(lastAIContentMsg)
 			} else {
-				rawPreviewContent += lipgloss.NewStyle().Width(contentWidth).Render(rendered)
+				rawPreviewContent += wrap(rendered)
 			}
 		}
 	} else {
-		welcomeText := "Welcome to AI Chat Terminal!\n\n" +
-			"Features:\n" +
-			"• Real-time markdown preview\n" +
-			"• Responsive layout\n" +
-			"• Beautiful terminal UI\n" +
-			"• AI conversation history\n\n" +
-			HelpStyle.Render("Start typing to see your message preview here.")
-		rawPreviewContent = TitleStyle.Render("Preview Panel") + "\n\n" +
-			lipgloss.NewStyle().Width(contentWidth).Render(welcomeText)
+		welcomeText := m.tr("preview.welcome") + "\n\n" + HelpStyle.Render(m.tr("preview.hint"))
+		rawPreviewContent = TitleStyle.Render(m.tr("preview.title")) + "\n\n" +
+			wrapText(welcomeText, contentWidth)
 	}
 
+	m.headings = locateHeadings(headings, rawPreviewContent)
 	m.content.SetContent(rawPreviewContent)
 	m.content.GotoTop()
 }
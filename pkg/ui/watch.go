@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"context"
+
+	"github.com/asaidimu/nani/pkg/ai"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// workspaceChangedMsg carries artifacts changed outside nani, reported by the
+// workspace watcher.
+type workspaceChangedMsg struct {
+	change  ai.WorkspaceChange
+	changes <-chan ai.WorkspaceChange
+}
+
+// watchWorkspace stops watching the previous workspace, if any, and starts
+// watching the current one when `Settings.WatchFiles` is enabled.
+func (m *Model) watchWorkspace() tea.Cmd {
+	if m.stopWatch != nil {
+		m.stopWatch()
+		m.stopWatch, m.watchChanges = nil, nil
+	}
+	if !m.workspace.Settings().WatchFiles {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := m.workspace.Watch(ctx)
+	if err != nil {
+		cancel()
+		return commandResult("", err)
+	}
+	m.stopWatch, m.watchChanges = cancel, changes
+	return waitForChange(changes)
+}
+
+// waitForChange waits for the next change on changes.
+func waitForChange(changes <-chan ai.WorkspaceChange) tea.Cmd {
+	return func() tea.Msg {
+		c, ok := <-changes
+		if !ok {
+			return nil
+		}
+		return workspaceChangedMsg{change: c, changes: changes}
+	}
+}
+
+// handleWorkspaceChange updates the indexes and status for files changed
+// outside nani and, while the startup banner is shown, offers the sessions
+// now on disk.
+// Changes from the watcher of a workspace switched away from are dropped.
+func (m *Model) handleWorkspaceChange(msg workspaceChangedMsg) tea.Cmd {
+	if msg.changes != m.watchChanges {
+		return nil
+	}
+	next := waitForChange(msg.changes)
+	if err := m.workspace.ApplyChange(context.Background(), msg.change); err != nil {
+		return tea.Batch(next, commandResult("", err))
+	}
+	m.refreshStatus()
+	if m.banner != nil && msg.change.Affects("sessions") {
+		if banner := newStartupBanner(m.workspace); banner != nil {
+			m.banner = banner
+			return tea.Batch(next, commandResult(banner.markdown(m.workspace.Project()), nil))
+		}
+	}
+	return next
+}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// softHyphen marks where a word may be hyphenated. It is shown as a hyphen
+// where wrapText breaks the line there, and hidden elsewhere.
+const softHyphen = "\u00ad"
+
+// wrapText wraps s to lines at most width terminal cells wide. Widths are
+// measured as the terminal draws them, so CJK characters and emoji count as
+// two cells and ANSI sequences as none; the sequences are kept intact. Lines
+// break at spaces, after hyphens, and at soft hyphens; words wider than the
+// line, such as runs of CJK text, which has no spaces, are broken anywhere.
+func wrapText(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	wrapped := ansi.WrapWc(s, width, "-"+softHyphen)
+	if !strings.Contains(wrapped, softHyphen) {
+		return wrapped
+	}
+	lines := strings.Split(wrapped, "\n")
+	for i, line := range lines {
+		hyphenate := strings.HasSuffix(line, softHyphen)
+		line = strings.ReplaceAll(line, softHyphen, "")
+		if hyphenate {
+			line += "-"
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateText shortens s to at most width terminal cells, measured as in
+// wrapText, ending it with "…" if anything was cut.
+func truncateText(s string, width int) string {
+	return ansi.TruncateWc(s, width, "…")
+}